@@ -0,0 +1,215 @@
+package smartraiden
+
+import (
+	"math"
+	"math/big"
+	"sort"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/SmartMeshFoundation/SmartRaiden/params"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mediatedtransfer"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//names of events generated by this node
+const (
+	EventNameTransferSentSuccess     = "EventTransferSentSuccess"
+	EventNameTransferSentFailed      = "EventTransferSentFailed"
+	EventNameTransferReceivedSuccess = "EventTransferReceivedSuccess"
+)
+
+/*
+EventData is the exported format of both contract events and internal events,
+fields not related to an event are omitted.
+*/
+type EventData struct {
+	EventType           string         `json:"event_type"`
+	BlockNumber         int64          `json:"block_number"`
+	TokenAddress        string         `json:"token_address,omitempty"`
+	TokenNetworkAddress string         `json:"token_network_address,omitempty"`
+	ChannelIdentifier   string         `json:"channel_identifier,omitempty"`
+	OpenBlockNumber     int64          `json:"open_block_number,omitempty"`
+	Participant1        string         `json:"participant1,omitempty"`
+	Participant2        string         `json:"participant2,omitempty"`
+	Participant1Balance *big.Int       `json:"participant1_balance,omitempty"`
+	Participant2Balance *big.Int       `json:"participant2_balance,omitempty"`
+	SettleTimeout       int            `json:"settle_timeout,omitempty"`
+	Participant         string         `json:"participant,omitempty"`
+	Balance             *big.Int       `json:"balance,omitempty"`
+	ClosingAddress      string         `json:"closing_address,omitempty"`
+	Beneficiary         string         `json:"beneficiary,omitempty"`
+	LocksRoot           string         `json:"locksroot,omitempty"`
+	TransferAmount      *big.Int       `json:"transferred_amount,omitempty"`
+	LockSecretHash      string         `json:"lock_secret_hash,omitempty"`
+	Amount              *big.Int       `json:"amount,omitempty"`
+	Initiator           string         `json:"initiator,omitempty"`
+	Target              string         `json:"target,omitempty"`
+	Reason              string         `json:"reason,omitempty"`
+	channel             common.Hash    //for filter only
+	tokenNetwork        common.Address //for filter only
+}
+
+func hashString(h common.Hash) string {
+	if h == utils.EmptyHash {
+		return ""
+	}
+	return h.String()
+}
+
+/*
+contractStateChange2EventData convert events on blockchain to EventData,
+returns nil if it's not a known statechange.
+*/
+func contractStateChange2EventData(st mediatedtransfer.ContractStateChange) (e *EventData) {
+	e = &EventData{BlockNumber: st.GetBlockNumber()}
+	switch st2 := st.(type) {
+	case *mediatedtransfer.ContractTokenAddedStateChange:
+		e.EventType = params.NameTokenNetworkCreated
+		e.TokenAddress = st2.TokenAddress.String()
+		e.tokenNetwork = st2.TokenNetworkAddress
+	case *mediatedtransfer.ContractNewChannelStateChange:
+		e.EventType = params.NameChannelOpened
+		e.channel = st2.ChannelIdentifier.ChannelIdentifier
+		e.tokenNetwork = st2.TokenNetworkAddress
+		e.OpenBlockNumber = st2.ChannelIdentifier.OpenBlockNumber
+		e.Participant1 = st2.Participant1.String()
+		e.Participant2 = st2.Participant2.String()
+		e.SettleTimeout = st2.SettleTimeout
+	case *mediatedtransfer.ContractBalanceStateChange:
+		e.EventType = params.NameChannelNewDeposit
+		e.channel = st2.ChannelIdentifier
+		e.tokenNetwork = st2.TokenNetworkAddress
+		e.Participant = st2.ParticipantAddress.String()
+		e.Balance = st2.Balance
+	case *mediatedtransfer.ContractClosedStateChange:
+		e.EventType = params.NameChannelClosed
+		e.channel = st2.ChannelIdentifier
+		e.tokenNetwork = st2.TokenNetworkAddress
+		e.ClosingAddress = st2.ClosingAddress.String()
+		e.LocksRoot = hashString(st2.LocksRoot)
+		e.TransferAmount = st2.TransferredAmount
+	case *mediatedtransfer.ContractSettledStateChange:
+		e.EventType = params.NameChannelSettled
+		e.channel = st2.ChannelIdentifier
+		e.tokenNetwork = st2.TokenNetworkAddress
+	case *mediatedtransfer.ContractCooperativeSettledStateChange:
+		e.EventType = params.NameChannelCooperativeSettled
+		e.channel = st2.ChannelIdentifier
+		e.tokenNetwork = st2.TokenNetworkAddress
+	case *mediatedtransfer.ContractPunishedStateChange:
+		e.EventType = params.NameChannelPunished
+		e.channel = st2.ChannelIdentifier
+		e.tokenNetwork = st2.TokenNetworkAddress
+		e.Beneficiary = st2.Beneficiary.String()
+	case *mediatedtransfer.ContractChannelWithdrawStateChange:
+		e.EventType = params.NameChannelWithdraw
+		e.channel = st2.ChannelIdentifier.ChannelIdentifier
+		e.tokenNetwork = st2.TokenNetworkAddress
+		e.Participant1 = st2.Participant1.String()
+		e.Participant2 = st2.Participant2.String()
+		e.Participant1Balance = st2.Participant1Balance
+		e.Participant2Balance = st2.Participant2Balance
+	case *mediatedtransfer.ContractBalanceProofUpdatedStateChange:
+		e.EventType = params.NameBalanceProofUpdated
+		e.channel = st2.ChannelIdentifier
+		e.tokenNetwork = st2.TokenNetworkAddress
+		e.Participant = st2.Participant.String()
+		e.LocksRoot = hashString(st2.LocksRoot)
+		e.TransferAmount = st2.TransferAmount
+	case *mediatedtransfer.ContractUnlockStateChange:
+		e.EventType = params.NameChannelUnlocked
+		e.channel = st2.ChannelIdentifier
+		e.tokenNetwork = st2.TokenNetworkAddress
+		e.Participant = st2.Participant.String()
+		e.TransferAmount = st2.TransferAmount
+	case *mediatedtransfer.ContractSecretRevealOnChainStateChange:
+		e.EventType = params.NameSecretRevealed
+		e.LockSecretHash = st2.LockSecretHash.String()
+	default:
+		return nil
+	}
+	e.ChannelIdentifier = hashString(e.channel)
+	if e.tokenNetwork != utils.EmptyAddress {
+		e.TokenNetworkAddress = e.tokenNetwork.String()
+	}
+	return
+}
+
+func sentTransfer2EventData(st *models.SentTransfer) *EventData {
+	return &EventData{
+		EventType:         EventNameTransferSentSuccess,
+		BlockNumber:       st.BlockNumber,
+		TokenAddress:      st.TokenAddress.String(),
+		ChannelIdentifier: st.ChannelIdentifier.String(),
+		Target:            st.ToAddress.String(),
+		Amount:            st.Amount,
+		channel:           st.ChannelIdentifier,
+	}
+}
+
+/*
+failedTransfer2EventData returns nil if ie is not a failed transfer,
+failed transfers are recorded as internal events only.
+*/
+func failedTransfer2EventData(ie *models.InternalEvent) *EventData {
+	if ie.Name != EventNameTransferSentFailed {
+		return nil
+	}
+	e := &EventData{
+		EventType:         EventNameTransferSentFailed,
+		BlockNumber:       ie.BlockNumber,
+		ChannelIdentifier: hashString(ie.ChannelIdentifier),
+		LockSecretHash:    ie.LockSecretHash.String(),
+		Reason:            ie.Reason,
+		channel:           ie.ChannelIdentifier,
+	}
+	if ev, ok := ie.EventObject.(*transfer.EventTransferSentFailed); ok {
+		e.TokenAddress = ev.Token.String()
+		e.Target = ev.Target.String()
+		e.Amount = ev.Amount
+	}
+	return e
+}
+
+func receivedTransfer2EventData(rt *models.ReceivedTransfer) *EventData {
+	return &EventData{
+		EventType:         EventNameTransferReceivedSuccess,
+		BlockNumber:       rt.BlockNumber,
+		TokenAddress:      rt.TokenAddress.String(),
+		ChannelIdentifier: rt.ChannelIdentifier.String(),
+		Initiator:         rt.FromAddress.String(),
+		Amount:            rt.Amount,
+		channel:           rt.ChannelIdentifier,
+	}
+}
+
+//normalize block range like GetSentTransferInBlockRange does
+func normalizeBlockRange(fromBlock, toBlock int64) (int64, int64) {
+	if fromBlock < 0 {
+		fromBlock = 0
+	}
+	if toBlock < 0 {
+		toBlock = math.MaxInt64
+	}
+	return fromBlock, toBlock
+}
+
+//filterEvents returns events in [fromBlock,toBlock] which `match` returns true,sorted by block number
+func filterEvents(events []*EventData, fromBlock, toBlock int64, match func(e *EventData) bool) (data []*EventData) {
+	data = []*EventData{}
+	for _, e := range events {
+		if e == nil || e.BlockNumber < fromBlock || e.BlockNumber > toBlock {
+			continue
+		}
+		if match != nil && !match(e) {
+			continue
+		}
+		data = append(data, e)
+	}
+	sort.SliceStable(data, func(i, j int) bool {
+		return data[i].BlockNumber < data[j].BlockNumber
+	})
+	return
+}
@@ -0,0 +1,101 @@
+package smartraiden
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/SmartMeshFoundation/SmartRaiden/network/rpc/contracts"
+	"github.com/SmartMeshFoundation/SmartRaiden/params"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mediatedtransfer"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+)
+
+func TestContractStateChange2EventData(t *testing.T) {
+	ch := utils.NewRandomHash()
+	p1, p2 := utils.NewRandomAddress(), utils.NewRandomAddress()
+	sts := []mediatedtransfer.ContractStateChange{
+		&mediatedtransfer.ContractClosedStateChange{ChannelIdentifier: ch, ClosingAddress: p1, ClosedBlock: 30},
+		&mediatedtransfer.ContractNewChannelStateChange{
+			ChannelIdentifier: &contracts.ChannelUniqueID{ChannelIdentifier: ch, OpenBlockNumber: 10},
+			Participant1:      p1,
+			Participant2:      p2,
+			SettleTimeout:     100,
+			BlockNumber:       10,
+		},
+		&mediatedtransfer.ContractBalanceStateChange{ChannelIdentifier: utils.NewRandomHash(), ParticipantAddress: p1, Balance: big.NewInt(20), BlockNumber: 20},
+		&mediatedtransfer.FakeContractInfoCompleteStateChange{},
+	}
+	var events []*EventData
+	for _, st := range sts {
+		events = append(events, contractStateChange2EventData(st))
+	}
+	if events[3] != nil {
+		t.Error("unknown statechange should be ignored")
+	}
+	data := filterEvents(events, 0, 25, nil)
+	if len(data) != 2 || data[0].EventType != params.NameChannelOpened || data[1].EventType != params.NameChannelNewDeposit {
+		t.Errorf("filter by block number error %s", utils.StringInterface(data, 2))
+	}
+	data = filterEvents(events, 0, 100, func(e *EventData) bool {
+		return e.channel == ch
+	})
+	if len(data) != 2 || data[1].EventType != params.NameChannelClosed || data[1].ClosingAddress != p1.String() {
+		t.Errorf("filter by channel error %s", utils.StringInterface(data, 2))
+	}
+	b, err := json.Marshal(data[0])
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	m := make(map[string]interface{})
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if m["event_type"] != params.NameChannelOpened || m["channel_identifier"] != ch.String() || m["participant2"] != p2.String() {
+		t.Errorf("json format error %s", string(b))
+	}
+	if _, ok := m["balance"]; ok {
+		t.Error("balance should be omitted")
+	}
+}
+
+func TestFailedTransfer2EventData(t *testing.T) {
+	ch := utils.NewRandomHash()
+	ev := &transfer.EventTransferSentFailed{
+		LockSecretHash:    utils.NewRandomHash(),
+		Reason:            "no route available",
+		Target:            utils.NewRandomAddress(),
+		Token:             utils.NewRandomAddress(),
+		Amount:            big.NewInt(10),
+		ChannelIdentifier: ch,
+	}
+	ies := []*models.InternalEvent{
+		{BlockNumber: 10, Name: EventNameTransferSentFailed, LockSecretHash: ev.LockSecretHash, ChannelIdentifier: ch, Reason: ev.Reason, EventObject: ev},
+		{BlockNumber: 11, Name: "EventSendMediatedTransfer", LockSecretHash: ev.LockSecretHash, ChannelIdentifier: ch},
+		{BlockNumber: 12, Name: EventNameTransferSentFailed, LockSecretHash: utils.NewRandomHash()},
+	}
+	var events []*EventData
+	for _, ie := range ies {
+		events = append(events, failedTransfer2EventData(ie))
+	}
+	if events[1] != nil {
+		t.Error("only failed transfers should be converted")
+	}
+	data := filterEvents(events, 0, 100, func(e *EventData) bool {
+		return e.channel == ch
+	})
+	if len(data) != 1 {
+		t.Errorf("filter by channel error %s", utils.StringInterface(data, 2))
+		return
+	}
+	e := data[0]
+	if e.EventType != EventNameTransferSentFailed || e.Reason != ev.Reason || e.Amount.Cmp(ev.Amount) != 0 ||
+		e.Target != ev.Target.String() || e.LockSecretHash != ev.LockSecretHash.String() {
+		t.Errorf("failed transfer event error %s", utils.StringInterface(e, 2))
+	}
+}
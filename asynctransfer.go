@@ -0,0 +1,196 @@
+package smartraiden
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mediatedtransfer"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mediatedtransfer/initiator"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//TransferStatusPending transfer is still running
+const TransferStatusPending = models.AsyncTransferPending
+
+//TransferStatusSuccess transfer finished successfully
+const TransferStatusSuccess = models.AsyncTransferSuccess
+
+//TransferStatusFailed transfer failed or canceled
+const TransferStatusFailed = models.AsyncTransferFailed
+
+/*
+asyncTransferKeepBlocks status of a finished async transfer can be queried within these blocks,
+it's forgotten after that.
+*/
+const asyncTransferKeepBlocks = 17280
+
+var errTransferNotFound = errors.New("transfer not found")
+var errTransferCannotCancel = errors.New("secret already revealed, transfer cannot be canceled")
+
+//TransferRoute is a route a transfer is using
+type TransferRoute struct {
+	HopNode           common.Address `json:"hop_node"`
+	ChannelIdentifier common.Hash    `json:"channel_identifier"`
+}
+
+//TransferStatus is the status of a transfer started asynchronously
+type TransferStatus struct {
+	LockSecretHash common.Hash      `json:"lock_secret_hash"`
+	Token          common.Address   `json:"token_address"`
+	Status         string           `json:"status"`
+	ManagerState   string           `json:"manager_state,omitempty"`
+	Routes         []*TransferRoute `json:"routes,omitempty"` //routes in use,more than one for a multi path transfer
+	Error          string           `json:"error,omitempty"`
+}
+
+//finishAsyncTransfer saves result of a transfer, nothing happens if it's not started asynchronously
+func (rs *RaidenService) finishAsyncTransfer(lockSecretHash common.Hash, token common.Address, err error) {
+	if lockSecretHash == utils.EmptyHash {
+		return
+	}
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+	}
+	err = rs.db.FinishAsyncTransfer(lockSecretHash, token, errMsg, rs.GetBlockNumber())
+	if err != nil {
+		log.Error(fmt.Sprintf("FinishAsyncTransfer %s err %s", utils.HPex(lockSecretHash), err))
+	}
+}
+
+//initiatorStateManager returns state manager of a transfer started by us
+func (rs *RaidenService) initiatorStateManager(lockSecretHash common.Hash) *transfer.StateManager {
+	for _, mgr := range rs.Transfer2StateManager {
+		if mgr.Identifier != lockSecretHash {
+			continue
+		}
+		if mgr.Name == initiator.NameInitiatorTransition || mgr.Name == initiator.NameMultiPathInitiatorTransition {
+			return mgr
+		}
+	}
+	return nil
+}
+
+//initiatorParts returns all the single path initiator states of a transfer
+func initiatorParts(state transfer.State) (parts []*mediatedtransfer.InitiatorState) {
+	switch s := state.(type) {
+	case *mediatedtransfer.InitiatorState:
+		parts = append(parts, s)
+	case *mediatedtransfer.MultiPathInitiatorState:
+		for _, p := range s.Parts {
+			if p != nil {
+				parts = append(parts, p)
+			}
+		}
+	}
+	return
+}
+
+/*
+transferStatus returns status of a transfer started asynchronously, result.Tag is *TransferStatus
+*/
+func (rs *RaidenService) transferStatus(lockSecretHash common.Hash) (result *utils.AsyncResult) {
+	result = utils.NewAsyncResult()
+	at, err := rs.db.GetAsyncTransfer(lockSecretHash)
+	if err != nil {
+		result.Result <- err
+		return
+	}
+	if at == nil {
+		result.Result <- errTransferNotFound
+		return
+	}
+	st := &TransferStatus{
+		LockSecretHash: lockSecretHash,
+		Token:          at.Token,
+		Status:         at.Status,
+		Error:          at.Error,
+	}
+	if at.Status == TransferStatusPending {
+		mgr := rs.initiatorStateManager(lockSecretHash)
+		if mgr != nil {
+			st.Token = mgr.TokenAddress
+			st.ManagerState = mgr.ManagerState
+			for _, p := range initiatorParts(mgr.CurrentState) {
+				if p.Route != nil {
+					st.Routes = append(st.Routes, &TransferRoute{p.Route.HopNode(), p.Route.ChannelIdentifier})
+				}
+			}
+		}
+	}
+	result.Tag = st
+	result.Result <- nil
+	return
+}
+
+/*
+cancelTransfer cancel a transfer started by us, it can be canceled only before secret is revealed.
+*/
+func (rs *RaidenService) cancelTransfer(lockSecretHash common.Hash) (result *utils.AsyncResult) {
+	result = utils.NewAsyncResult()
+	mgr := rs.initiatorStateManager(lockSecretHash)
+	if mgr == nil || mgr.CurrentState == nil {
+		result.Result <- errTransferNotFound
+		return
+	}
+	parts := initiatorParts(mgr.CurrentState)
+	var inFlight []*models.CanceledLock
+	for _, p := range parts {
+		//target of a keysend transfer may reveal the secret at any time
+		if p.RevealSecret != nil || len(p.Transfer.EncryptedSecret) > 0 {
+			result.Result <- errTransferCannotCancel
+			return
+		}
+		if p.Route != nil && p.Transfer.LockSecretHash != utils.EmptyHash {
+			inFlight = append(inFlight, &models.CanceledLock{
+				ChannelIdentifier: p.Route.ChannelIdentifier,
+				LockSecretHash:    lockSecretHash,
+				Expiration:        p.Transfer.Expiration,
+			})
+		}
+	}
+	rs.StateMachineEventHandler.dispatch(mgr, &transfer.ActionCancelTransferStateChange{LockSecretHash: lockSecretHash})
+	if mgr.CurrentState == nil {
+		//single path initiator doesn't remove itself when canceled
+		delete(rs.Transfer2StateManager, utils.Sha3(lockSecretHash[:], mgr.TokenAddress[:]))
+	}
+	for _, l := range inFlight {
+		err := rs.db.NewCanceledLock(l)
+		if err != nil {
+			log.Error(fmt.Sprintf("NewCanceledLock err %s", err))
+		}
+	}
+	result.Result <- nil
+	return
+}
+
+/*
+removeCanceledLocks removes locks of canceled transfers from channel once they expired,
+and forgets async transfers finished long ago.
+*/
+func (rs *RaidenService) removeCanceledLocks(blockNumber int64) {
+	ls, err := rs.db.GetExpiredCanceledLocks(blockNumber)
+	if err != nil {
+		log.Error(fmt.Sprintf("GetExpiredCanceledLocks err %s", err))
+		return
+	}
+	for _, l := range ls {
+		err = rs.StateMachineEventHandler.removeExpiredHashlock(l.ChannelIdentifier, l.LockSecretHash)
+		if err != nil {
+			log.Warn(fmt.Sprintf("remove canceled lock %s on channel %s err %s",
+				utils.HPex(l.LockSecretHash), utils.HPex(l.ChannelIdentifier), err))
+		}
+		err = rs.db.RemoveCanceledLock(l)
+		if err != nil {
+			log.Error(fmt.Sprintf("RemoveCanceledLock err %s", err))
+		}
+	}
+	err = rs.db.RemoveAsyncTransfersFinishedBefore(blockNumber - asyncTransferKeepBlocks)
+	if err != nil {
+		log.Error(fmt.Sprintf("RemoveAsyncTransfersFinishedBefore err %s", err))
+	}
+}
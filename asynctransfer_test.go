@@ -0,0 +1,230 @@
+package smartraiden
+
+import (
+	"math/big"
+	"os"
+	"path"
+	"sync/atomic"
+	"testing"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/channel"
+	"github.com/SmartMeshFoundation/SmartRaiden/channel/channeltype"
+	"github.com/SmartMeshFoundation/SmartRaiden/encoding"
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/SmartMeshFoundation/SmartRaiden/network/graph"
+	"github.com/SmartMeshFoundation/SmartRaiden/network/rpc/contracts"
+	"github.com/SmartMeshFoundation/SmartRaiden/params"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mediatedtransfer"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mediatedtransfer/initiator"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mtree"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/route"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+//newTestRaidenWithDb returns a RaidenService with only db and state machine, it needs no blockchain
+func newTestRaidenWithDb(t *testing.T, blockNumber int64) *RaidenService {
+	dbPath := path.Join(os.TempDir(), "testraiden.db")
+	os.Remove(dbPath)
+	os.Remove(dbPath + ".lock")
+	db, err := models.OpenDb(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := &RaidenService{
+		NodeAddress:           utils.NewRandomAddress(),
+		Config:                &params.Config{},
+		Token2ChannelGraph:    make(map[common.Address]*graph.ChannelGraph),
+		Transfer2StateManager: make(map[common.Hash]*transfer.StateManager),
+		Transfer2Result:       make(map[common.Hash]*utils.AsyncResult),
+		BlockNumber:           new(atomic.Value),
+		db:                    db,
+	}
+	rs.BlockNumber.Store(blockNumber)
+	rs.StateMachineEventHandler = newStateMachineEventHandler(rs)
+	return rs
+}
+
+//newTestRoute returns a route to partner by an open channel with balance tokens
+func newTestRoute(ourAddress, partner common.Address, balance int64) *route.State {
+	ch := &channel.Channel{
+		OurState:          channel.NewChannelEndState(ourAddress, big.NewInt(balance), nil, mtree.EmptyTree),
+		PartnerState:      channel.NewChannelEndState(partner, big.NewInt(0), nil, mtree.EmptyTree),
+		ExternState:       &channel.ExternalState{},
+		ChannelIdentifier: contracts.ChannelUniqueID{ChannelIdentifier: utils.NewRandomHash()},
+		State:             channeltype.StateOpened,
+	}
+	return route.NewState(ch)
+}
+
+//addTestInitiator adds a state manager of a transfer in flight by r
+func addTestInitiator(rs *RaidenService, token common.Address, r *route.State) *mediatedtransfer.InitiatorState {
+	secret := utils.NewRandomHash()
+	lockSecretHash := utils.Sha3(secret[:])
+	state := &mediatedtransfer.InitiatorState{
+		OurAddress: rs.NodeAddress,
+		Transfer: &mediatedtransfer.LockedTransferState{
+			TargetAmount:   big.NewInt(10),
+			Amount:         big.NewInt(10),
+			Token:          token,
+			Initiator:      rs.NodeAddress,
+			Target:         utils.NewRandomAddress(),
+			Expiration:     200,
+			LockSecretHash: lockSecretHash,
+			Secret:         secret,
+			Fee:            utils.BigInt0,
+		},
+		Routes:         route.NewRoutesState(nil),
+		BlockNumber:    rs.GetBlockNumber(),
+		LockSecretHash: lockSecretHash,
+		Secret:         secret,
+		Route:          r,
+	}
+	mgr := transfer.NewStateManager(initiator.StateTransition, state, initiator.NameInitiatorTransition, lockSecretHash, token)
+	rs.Transfer2StateManager[utils.Sha3(lockSecretHash[:], token[:])] = mgr
+	return state
+}
+
+func TestTransferStatus(t *testing.T) {
+	rs := newTestRaidenWithDb(t, 100)
+	defer rs.db.CloseDB()
+	token := utils.NewRandomAddress()
+	r := newTestRoute(rs.NodeAddress, utils.NewRandomAddress(), 100)
+	state := addTestInitiator(rs, token, r)
+	result := rs.transferStatus(state.LockSecretHash)
+	assert2.EqualValues(t, errTransferNotFound, <-result.Result)
+
+	err := rs.db.NewAsyncTransfer(state.LockSecretHash, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result = rs.transferStatus(state.LockSecretHash)
+	if assert2.Nil(t, <-result.Result) {
+		st := result.Tag.(*TransferStatus)
+		assert2.EqualValues(t, TransferStatusPending, st.Status)
+		assert2.EqualValues(t, token, st.Token)
+		if assert2.Len(t, st.Routes, 1) {
+			assert2.EqualValues(t, r.HopNode(), st.Routes[0].HopNode)
+			assert2.EqualValues(t, r.ChannelIdentifier, st.Routes[0].ChannelIdentifier)
+		}
+	}
+
+	rs.finishAsyncTransfer(state.LockSecretHash, token, errTransferCannotCancel)
+	result = rs.transferStatus(state.LockSecretHash)
+	if assert2.Nil(t, <-result.Result) {
+		st := result.Tag.(*TransferStatus)
+		assert2.EqualValues(t, TransferStatusFailed, st.Status)
+		assert2.EqualValues(t, errTransferCannotCancel.Error(), st.Error)
+		assert2.Len(t, st.Routes, 0)
+	}
+}
+
+func TestCancelTransfer(t *testing.T) {
+	rs := newTestRaidenWithDb(t, 100)
+	defer rs.db.CloseDB()
+	token := utils.NewRandomAddress()
+	result := rs.cancelTransfer(utils.NewRandomHash())
+	assert2.EqualValues(t, errTransferNotFound, <-result.Result)
+
+	//secret has been revealed
+	state := addTestInitiator(rs, token, newTestRoute(rs.NodeAddress, utils.NewRandomAddress(), 100))
+	state.RevealSecret = &mediatedtransfer.EventSendRevealSecret{LockSecretHash: state.LockSecretHash}
+	result = rs.cancelTransfer(state.LockSecretHash)
+	assert2.EqualValues(t, errTransferCannotCancel, <-result.Result)
+	//target of a keysend transfer may reveal the secret at any time
+	state = addTestInitiator(rs, token, newTestRoute(rs.NodeAddress, utils.NewRandomAddress(), 100))
+	state.Transfer.EncryptedSecret = []byte{1}
+	result = rs.cancelTransfer(state.LockSecretHash)
+	assert2.EqualValues(t, errTransferCannotCancel, <-result.Result)
+
+	r := newTestRoute(rs.NodeAddress, utils.NewRandomAddress(), 100)
+	state = addTestInitiator(rs, token, r)
+	lockSecretHash := state.LockSecretHash
+	err := rs.db.NewAsyncTransfer(lockSecretHash, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result = rs.cancelTransfer(lockSecretHash)
+	assert2.Nil(t, <-result.Result)
+	assert2.Nil(t, rs.initiatorStateManager(lockSecretHash))
+	at, err := rs.db.GetAsyncTransfer(lockSecretHash)
+	if assert2.Nil(t, err) {
+		assert2.EqualValues(t, TransferStatusFailed, at.Status)
+	}
+	//the lock sent has to be removed after it expired
+	ls, err := rs.db.GetExpiredCanceledLocks(state.Transfer.Expiration + 1)
+	if assert2.Nil(t, err) && assert2.Len(t, ls, 1) {
+		assert2.EqualValues(t, r.ChannelIdentifier, ls[0].ChannelIdentifier)
+		assert2.EqualValues(t, lockSecretHash, ls[0].LockSecretHash)
+	}
+	result = rs.cancelTransfer(lockSecretHash)
+	assert2.EqualValues(t, errTransferNotFound, <-result.Result)
+}
+
+func TestRemoveCanceledLocks(t *testing.T) {
+	blockNumber := int64(asyncTransferKeepBlocks + 50)
+	rs := newTestRaidenWithDb(t, blockNumber)
+	defer rs.db.CloseDB()
+	expired := &models.CanceledLock{ChannelIdentifier: utils.NewRandomHash(), LockSecretHash: utils.NewRandomHash(), Expiration: blockNumber - 1}
+	alive := &models.CanceledLock{ChannelIdentifier: utils.NewRandomHash(), LockSecretHash: utils.NewRandomHash(), Expiration: blockNumber + 1}
+	for _, l := range []*models.CanceledLock{expired, alive} {
+		err := rs.db.NewCanceledLock(l)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	token := utils.NewRandomAddress()
+	old, recent, pending := utils.NewRandomHash(), utils.NewRandomHash(), utils.NewRandomHash()
+	for _, h := range []common.Hash{old, recent, pending} {
+		err := rs.db.NewAsyncTransfer(h, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	rs.db.FinishAsyncTransfer(old, token, "", 10)
+	rs.db.FinishAsyncTransfer(recent, token, "", 100)
+
+	//channel of the expired lock is gone, lock is forgotten anyway
+	rs.removeCanceledLocks(blockNumber)
+	ls, err := rs.db.GetExpiredCanceledLocks(blockNumber + 2)
+	if assert2.Nil(t, err) && assert2.Len(t, ls, 1) {
+		assert2.EqualValues(t, alive.LockSecretHash, ls[0].LockSecretHash)
+	}
+	at, err := rs.db.GetAsyncTransfer(old)
+	assert2.Nil(t, err)
+	assert2.Nil(t, at)
+	for _, h := range []common.Hash{recent, pending} {
+		at, err = rs.db.GetAsyncTransfer(h)
+		assert2.Nil(t, err)
+		assert2.NotNil(t, at)
+	}
+}
+
+func TestTransferOptionsInvalid(t *testing.T) {
+	rs := newTestRaidenWithDb(t, 100)
+	defer rs.db.CloseDB()
+	api := NewRaidenAPI(rs)
+	token := utils.NewRandomAddress()
+	err := rs.db.AddToken(token, utils.NewRandomAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := crypto.GenerateKey()
+	target := crypto.PubkeyToAddress(key.PublicKey)
+	cases := []*TransferOptions{
+		{Memo: string(make([]byte, encoding.MaxMemoLength+1))},
+		{TargetPublicKey: &key.PublicKey, MultiPath: true},
+		{TargetPublicKey: &key.PublicKey, LockSecretHash: utils.NewRandomHash()},
+	}
+	for i, opts := range cases {
+		_, err = api.TransferAsync(token, big.NewInt(10), target, opts)
+		assert2.NotNil(t, err, "case %d", i)
+	}
+	_, err = api.TransferAsync(token, big.NewInt(10), utils.NewRandomAddress(), &TransferOptions{TargetPublicKey: &key.PublicKey})
+	assert2.NotNil(t, err, "public key of another node")
+	_, err = api.TransferAsync(utils.NewRandomAddress(), big.NewInt(10), target, nil)
+	assert2.NotNil(t, err, "unknown token")
+}
@@ -0,0 +1,202 @@
+package smartraiden
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/channel"
+	"github.com/SmartMeshFoundation/SmartRaiden/channel/channeltype"
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+autoDepositRetryBlocks a channel is not topped up again within these blocks after the last deposit,
+unless its balance has changed, it's the time for the deposit to be confirmed.
+*/
+const autoDepositRetryBlocks = 20
+
+/*
+autoDepositMaxBackoff after failed deposits, the wait is doubled for each failure in a row,
+up to autoDepositRetryBlocks<<autoDepositMaxBackoff blocks.
+*/
+const autoDepositMaxBackoff = 6
+
+const autoDepositPeriod = 24 * time.Hour
+
+var errAutoDepositInterrupted = errors.New("node stopped before the deposit finished")
+
+/*
+autoDeposit tops up channels whose balance is lower than the threshold in deposit policy,
+it's called on each block in the main loop.
+*/
+func (rs *RaidenService) autoDeposit(blockNumber int64) {
+	dp, err := rs.db.GetDepositPolicy()
+	if err != nil {
+		log.Error(fmt.Sprintf("GetDepositPolicy err %s", err))
+		return
+	}
+	if dp == nil || (len(dp.TokenDeposits) == 0 && len(dp.ChannelDeposits) == 0) {
+		return
+	}
+	for _, g := range rs.Token2ChannelGraph {
+		for _, c := range g.ChannelAddress2Channel {
+			if c.State != channeltype.StateOpened {
+				continue
+			}
+			ds, rule := dp.GetSetting(c.ChannelIdentifier.ChannelIdentifier, c.TokenAddress)
+			if ds == nil {
+				continue
+			}
+			balance := c.Balance()
+			if balance.Cmp(ds.MinBalance) >= 0 {
+				continue
+			}
+			err = rs.autoDepositChannel(c, dp.DryRun, ds, rule, balance, blockNumber)
+			if err != nil {
+				log.Error(fmt.Sprintf("auto deposit channel %s err %s", utils.HPex(c.ChannelIdentifier.ChannelIdentifier), err))
+			}
+		}
+	}
+}
+
+//autoDepositRetryBlocksAfter returns how many blocks to wait after failures in a row
+func autoDepositRetryBlocksAfter(failures int) int64 {
+	if failures > autoDepositMaxBackoff+1 {
+		failures = autoDepositMaxBackoff + 1
+	}
+	if failures < 1 {
+		failures = 1
+	}
+	return autoDepositRetryBlocks << uint(failures-1)
+}
+
+func (rs *RaidenService) autoDepositChannel(c *channel.Channel, dryRun bool, ds *models.DepositSetting, rule string, balance *big.Int, blockNumber int64) error {
+	channelIdentifier := c.ChannelIdentifier.ChannelIdentifier
+	last, err := rs.db.GetLastAutoDeposit(channelIdentifier)
+	if err != nil {
+		return err
+	}
+	if last != nil && last.Status == models.AutoDepositPending {
+		//wait for the result of the last one
+		return nil
+	}
+	if last != nil && last.Status == models.AutoDepositFailed {
+		//back off whether balance changed or not, the same error is likely to happen again
+		failures, err := rs.db.GetAutoDepositFailures(channelIdentifier)
+		if err != nil {
+			return err
+		}
+		if blockNumber < last.BlockNumber+autoDepositRetryBlocksAfter(failures) {
+			return nil
+		}
+	} else if last != nil && last.Balance.Cmp(balance) == 0 {
+		//nothing changed since the last one
+		if last.Status == models.AutoDepositDryRun || blockNumber < last.BlockNumber+autoDepositRetryBlocks {
+			return nil
+		}
+	}
+	if ds.MaxDaily != nil {
+		key := channelIdentifier
+		if rule == models.AutoDepositRuleToken {
+			key = common.BytesToHash(c.TokenAddress[:])
+		}
+		spent, err := rs.db.GetAutoDepositAmountSince(rule, key, time.Now().Add(-autoDepositPeriod), dryRun)
+		if err != nil {
+			return err
+		}
+		if new(big.Int).Add(spent, ds.Amount).Cmp(ds.MaxDaily) > 0 {
+			log.Debug(fmt.Sprintf("auto deposit channel %s skipped, %s deposited in 24 hours, max=%s", utils.HPex(channelIdentifier), spent, ds.MaxDaily))
+			return nil
+		}
+	}
+	d := &models.AutoDeposit{
+		ChannelIdentifier: channelIdentifier,
+		Token:             c.TokenAddress,
+		Partner:           c.PartnerState.Address,
+		Rule:              rule,
+		BlockNumber:       blockNumber,
+		Balance:           balance,
+		Amount:            ds.Amount,
+		Status:            models.AutoDepositPending,
+	}
+	if dryRun {
+		d.Status = models.AutoDepositDryRun
+	}
+	err = rs.db.NewAutoDeposit(d)
+	if err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("auto deposit %s to channel %s, balance=%s,dryrun=%v", d.Amount, utils.HPex(channelIdentifier), balance, dryRun))
+	if dryRun {
+		return nil
+	}
+	result := rs.depositChannel(channelIdentifier, d.Amount)
+	go func() {
+		var err error
+		select {
+		case err = <-result.Result:
+		case <-rs.quitChan:
+			return
+		}
+		if err == nil {
+			d.Status = models.AutoDepositSuccess
+		} else {
+			d.Status = models.AutoDepositFailed
+			d.Error = err.Error()
+			log.Error(fmt.Sprintf("auto deposit %s to channel %s failed %s", d.Amount, utils.HPex(channelIdentifier), err))
+			rs.db.NewInternalEvent(rs.GetBlockNumber(), "AutoDepositFailed", utils.EmptyHash, channelIdentifier, d.Error, d)
+		}
+		err = rs.db.UpdateAutoDeposit(d)
+		if err != nil {
+			log.Error(fmt.Sprintf("UpdateAutoDeposit err %s", err))
+		}
+	}()
+	return nil
+}
+
+/*
+failInterruptedAutoDeposits marks deposits still pending from the last run as failed,
+their results are lost when the node stopped, otherwise these channels are never topped up again.
+*/
+func (rs *RaidenService) failInterruptedAutoDeposits() {
+	ds, err := rs.db.GetPendingAutoDeposits()
+	if err != nil {
+		log.Error(fmt.Sprintf("GetPendingAutoDeposits err %s", err))
+		return
+	}
+	for _, d := range ds {
+		d.Status = models.AutoDepositFailed
+		d.Error = errAutoDepositInterrupted.Error()
+		err = rs.db.UpdateAutoDeposit(d)
+		if err != nil {
+			log.Error(fmt.Sprintf("UpdateAutoDeposit err %s", err))
+		}
+	}
+}
+
+//GetDepositPolicy returns the auto deposit policy of this node, nil if never set
+func (r *RaidenAPI) GetDepositPolicy() (*models.DepositPolicy, error) {
+	return r.Raiden.db.GetDepositPolicy()
+}
+
+//SetDepositPolicy replaces the auto deposit policy, it takes effect from the next block
+func (r *RaidenAPI) SetDepositPolicy(dp *models.DepositPolicy) error {
+	if dp == nil {
+		return errors.New("empty deposit policy")
+	}
+	err := dp.Validate()
+	if err != nil {
+		return err
+	}
+	return r.Raiden.db.SaveDepositPolicy(dp)
+}
+
+//GetAutoDeposits returns deposits triggered by deposit policy, the latest first
+func (r *RaidenAPI) GetAutoDeposits() ([]*models.AutoDeposit, error) {
+	return r.Raiden.db.GetAutoDepositList()
+}
@@ -0,0 +1,136 @@
+package smartraiden
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/channel"
+	"github.com/SmartMeshFoundation/SmartRaiden/channel/channeltype"
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/SmartMeshFoundation/SmartRaiden/network/rpc/contracts"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mtree"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+func newTestDepositChannel(rs *RaidenService) *channel.Channel {
+	return &channel.Channel{
+		OurState:          channel.NewChannelEndState(rs.NodeAddress, big.NewInt(1), nil, mtree.EmptyTree),
+		PartnerState:      channel.NewChannelEndState(utils.NewRandomAddress(), big.NewInt(0), nil, mtree.EmptyTree),
+		ExternState:       &channel.ExternalState{},
+		ChannelIdentifier: contracts.ChannelUniqueID{ChannelIdentifier: utils.NewRandomHash()},
+		TokenAddress:      utils.NewRandomAddress(),
+		State:             channeltype.StateOpened,
+	}
+}
+
+func getTestAutoDeposits(t *testing.T, rs *RaidenService) []*models.AutoDeposit {
+	ds, err := rs.db.GetAutoDepositList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ds
+}
+
+//waitTestAutoDeposit waits for the result of the latest deposit
+func waitTestAutoDeposit(t *testing.T, rs *RaidenService) *models.AutoDeposit {
+	for i := 0; i < 100; i++ {
+		d := getTestAutoDeposits(t, rs)[0]
+		if d.Status != models.AutoDepositPending {
+			return d
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("auto deposit is still pending")
+	return nil
+}
+
+func TestAutoDepositPending(t *testing.T) {
+	rs := newTestRaidenWithDb(t, 100)
+	defer rs.db.CloseDB()
+	c := newTestDepositChannel(rs)
+	ds := &models.DepositSetting{MinBalance: big.NewInt(10), Amount: big.NewInt(100)}
+	err := rs.db.NewAutoDeposit(&models.AutoDeposit{
+		ChannelIdentifier: c.ChannelIdentifier.ChannelIdentifier,
+		Token:             c.TokenAddress,
+		Rule:              models.AutoDepositRuleChannel,
+		BlockNumber:       10,
+		Balance:           big.NewInt(5),
+		Amount:            ds.Amount,
+		Status:            models.AutoDepositPending,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the last one is not confirmed, however long it takes
+	err = rs.autoDepositChannel(c, false, ds, models.AutoDepositRuleChannel, big.NewInt(1), 1000)
+	assert2.Nil(t, err)
+	assert2.Equal(t, 1, len(getTestAutoDeposits(t, rs)))
+}
+
+func TestAutoDepositDryRun(t *testing.T) {
+	rs := newTestRaidenWithDb(t, 100)
+	defer rs.db.CloseDB()
+	c := newTestDepositChannel(rs)
+	ds := &models.DepositSetting{MinBalance: big.NewInt(10), Amount: big.NewInt(100), MaxDaily: big.NewInt(250)}
+	err := rs.autoDepositChannel(c, true, ds, models.AutoDepositRuleToken, big.NewInt(1), 100)
+	assert2.Nil(t, err)
+	ads := getTestAutoDeposits(t, rs)
+	if assert2.Equal(t, 1, len(ads)) {
+		assert2.Equal(t, models.AutoDepositDryRun, ads[0].Status)
+		assert2.Equal(t, models.AutoDepositRuleToken, ads[0].Rule)
+		assert2.EqualValues(t, big.NewInt(100), ads[0].Amount)
+	}
+	//balance unchanged, a dry run is never repeated
+	err = rs.autoDepositChannel(c, true, ds, models.AutoDepositRuleToken, big.NewInt(1), 1000)
+	assert2.Nil(t, err)
+	assert2.Equal(t, 1, len(getTestAutoDeposits(t, rs)))
+	err = rs.autoDepositChannel(c, true, ds, models.AutoDepositRuleToken, big.NewInt(2), 1001)
+	assert2.Nil(t, err)
+	assert2.Equal(t, 2, len(getTestAutoDeposits(t, rs)))
+	//dry runs count against max daily in dry run mode
+	err = rs.autoDepositChannel(c, true, ds, models.AutoDepositRuleToken, big.NewInt(3), 1002)
+	assert2.Nil(t, err)
+	assert2.Equal(t, 2, len(getTestAutoDeposits(t, rs)))
+}
+
+func TestAutoDepositRetry(t *testing.T) {
+	rs := newTestRaidenWithDb(t, 100)
+	defer rs.db.CloseDB()
+	//channel is not in any graph, so every deposit fails
+	c := newTestDepositChannel(rs)
+	ds := &models.DepositSetting{MinBalance: big.NewInt(10), Amount: big.NewInt(10), MaxDaily: big.NewInt(25)}
+	var blockNumber int64 = 100
+	deposit := func(balance int64) int {
+		err := rs.autoDepositChannel(c, false, ds, models.AutoDepositRuleChannel, big.NewInt(balance), blockNumber)
+		assert2.Nil(t, err)
+		return len(getTestAutoDeposits(t, rs))
+	}
+	assert2.Equal(t, 1, deposit(1))
+	d := waitTestAutoDeposit(t, rs)
+	assert2.Equal(t, models.AutoDepositFailed, d.Status)
+	assert2.NotEmpty(t, d.Error)
+	//back off after a failure even if balance changed
+	blockNumber += autoDepositRetryBlocks - 1
+	assert2.Equal(t, 1, deposit(2))
+	blockNumber++
+	assert2.Equal(t, 2, deposit(2))
+	assert2.Equal(t, models.AutoDepositFailed, waitTestAutoDeposit(t, rs).Status)
+	//the wait doubles after two failures in a row
+	blockNumber += 2*autoDepositRetryBlocks - 1
+	assert2.Equal(t, 2, deposit(3))
+	//failed ones count against max daily, 20 spent, another 10 exceeds 25
+	blockNumber++
+	assert2.Equal(t, 2, deposit(3))
+	ds.MaxDaily = big.NewInt(30)
+	assert2.Equal(t, 3, deposit(3))
+	waitTestAutoDeposit(t, rs)
+}
+
+func TestAutoDepositRetryBlocksAfter(t *testing.T) {
+	assert2.EqualValues(t, autoDepositRetryBlocks, autoDepositRetryBlocksAfter(0))
+	assert2.EqualValues(t, autoDepositRetryBlocks, autoDepositRetryBlocksAfter(1))
+	assert2.EqualValues(t, 4*autoDepositRetryBlocks, autoDepositRetryBlocksAfter(3))
+	assert2.EqualValues(t, autoDepositRetryBlocksAfter(autoDepositMaxBackoff+1), autoDepositRetryBlocksAfter(100))
+}
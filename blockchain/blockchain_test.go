@@ -60,7 +60,7 @@ func TestGetTokenNetworkCreated(t *testing.T) {
 }
 
 func TestEvents_GetAllChannels(t *testing.T) {
-	channels, err := be.GetChannelNew(0, rpc.TestGetTokenNetworkAddress())
+	channels, err := be.GetChannelNew(0, rpc.TestGetTokenNetworkAddress(), utils.EmptyHash)
 	if err != nil {
 		t.Error(err)
 		return
@@ -69,7 +69,7 @@ func TestEvents_GetAllChannels(t *testing.T) {
 }
 
 func TestEvents_GetAllChannelClosed(t *testing.T) {
-	events, err := be.GetChannelClosed(0, rpc.TestGetTokenNetworkAddress())
+	events, err := be.GetChannelClosed(0, rpc.TestGetTokenNetworkAddress(), utils.EmptyHash)
 	if err != nil {
 		t.Error(err)
 		return
@@ -78,7 +78,7 @@ func TestEvents_GetAllChannelClosed(t *testing.T) {
 }
 
 func TestEvents_GetAllChannelSettled(t *testing.T) {
-	events, err := be.GetChannelSettled(0, rpc.TestGetTokenNetworkAddress())
+	events, err := be.GetChannelSettled(0, rpc.TestGetTokenNetworkAddress(), utils.EmptyHash)
 	if err != nil {
 		t.Error(err)
 		return
@@ -96,7 +96,7 @@ func TestEvents_GetAllSecretRevealed(t *testing.T) {
 }
 
 func TestEvents_GetChannelNewAndDeposit(t *testing.T) {
-	events, err := be.GetChannelNewAndDeposit(0, utils.EmptyAddress)
+	events, err := be.GetChannelNewAndDeposit(0, utils.EmptyAddress, utils.EmptyHash)
 	if err != nil {
 		t.Error(err)
 		return
@@ -253,6 +253,7 @@ func EventChannelUnlocked2StateChange(ev *contracts.TokenNetworkChannelUnlocked)
 		TokenNetworkAddress: ev.Raw.Address,
 		ChannelIdentifier:   ev.ChannelIdentifier,
 		BlockNumber:         int64(ev.Raw.BlockNumber),
+		LockHash:            ev.Lockhash,
 		TransferAmount:      ev.TransferredAmount,
 		Participant:         ev.PayerParticipant,
 	}
@@ -453,6 +454,18 @@ func (be *Events) sendStateChange(st mediatedtransfer.ContractStateChange) {
 
 //GetAllTokenNetworks returns all the token network,events 本身需要知道所有的 tokennetwork, 这样才能处理相关事件.
 func (be *Events) GetAllTokenNetworks(fromBlock int64) (events []*contracts.TokenNetworkRegistryTokenNetworkCreated, err error) {
+	events, err = be.GetTokenNetworkCreated(fromBlock)
+	if err != nil {
+		return
+	}
+	for _, e := range events {
+		be.TokenNetworks[e.TokenNetworkAddress] = true
+	}
+	return
+}
+
+//GetTokenNetworkCreated returns token network created events since `fromBlock`,it doesn't change TokenNetworks
+func (be *Events) GetTokenNetworkCreated(fromBlock int64) (events []*contracts.TokenNetworkRegistryTokenNetworkCreated, err error) {
 	logs, err := rpc.EventGetInternal(rpc.GetQueryConext(), be.RegistryAddress, ethrpc.BlockNumber(fromBlock), ethrpc.LatestBlockNumber,
 		params.NameTokenNetworkCreated, eventAbiMap[params.NameTokenNetworkCreated], be.client)
 	if err != nil {
@@ -466,9 +479,6 @@ func (be *Events) GetAllTokenNetworks(fromBlock int64) (events []*contracts.Toke
 		}
 		events = append(events, e)
 	}
-	for _, e := range events {
-		be.TokenNetworks[e.TokenNetworkAddress] = true
-	}
 	return
 }
 
@@ -478,8 +488,8 @@ if tokenNetworkAddress is empty, return all events have this sigature
 如果 channel 特别多,比如十万个,怎么办,
 为了防止出现这样的情况,应该一个一个 tokennetwork 获取事件,而不要是一起获取.
 */
-func (be *Events) GetChannelNew(fromBlock int64, tokenNetworkAddress common.Address) (events []*contracts.TokenNetworkChannelOpened, err error) {
-	logs, err := rpc.EventGetInternal(rpc.GetQueryConext(), tokenNetworkAddress, ethrpc.BlockNumber(fromBlock), ethrpc.LatestBlockNumber,
+func (be *Events) GetChannelNew(fromBlock int64, tokenNetworkAddress common.Address, channelIdentifier common.Hash) (events []*contracts.TokenNetworkChannelOpened, err error) {
+	logs, err := rpc.EventGetChannel(rpc.GetQueryConext(), tokenNetworkAddress, channelIdentifier, ethrpc.BlockNumber(fromBlock), ethrpc.LatestBlockNumber,
 		params.NameChannelOpened, eventAbiMap[params.NameChannelOpened], be.client)
 	if err != nil {
 		return
@@ -502,8 +512,8 @@ if tokenNetworkAddress is empty, return all events have this sigature
 如果 channel 特别多,比如十万个,怎么办,
 为了防止出现这样的情况,应该一个一个 tokennetwork 获取事件,而不要是一起获取.
 */
-func (be *Events) GetChannelNewAndDeposit(fromBlock int64, tokenNetworkAddress common.Address) (events []*contracts.TokenNetworkChannelOpenedAndDeposit, err error) {
-	logs, err := rpc.EventGetInternal(rpc.GetQueryConext(), tokenNetworkAddress, ethrpc.BlockNumber(fromBlock), ethrpc.LatestBlockNumber,
+func (be *Events) GetChannelNewAndDeposit(fromBlock int64, tokenNetworkAddress common.Address, channelIdentifier common.Hash) (events []*contracts.TokenNetworkChannelOpenedAndDeposit, err error) {
+	logs, err := rpc.EventGetChannel(rpc.GetQueryConext(), tokenNetworkAddress, channelIdentifier, ethrpc.BlockNumber(fromBlock), ethrpc.LatestBlockNumber,
 		params.NameChannelOpenedAndDeposit, eventAbiMap[params.NameChannelOpenedAndDeposit], be.client)
 	if err != nil {
 		return
@@ -521,8 +531,8 @@ func (be *Events) GetChannelNewAndDeposit(fromBlock int64, tokenNetworkAddress c
 }
 
 //GetChannelClosed return  channel closed events
-func (be *Events) GetChannelClosed(fromBlock int64, tokenNetworkAddress common.Address) (events []*contracts.TokenNetworkChannelClosed, err error) {
-	logs, err := rpc.EventGetInternal(rpc.GetQueryConext(), tokenNetworkAddress, ethrpc.BlockNumber(fromBlock), ethrpc.LatestBlockNumber,
+func (be *Events) GetChannelClosed(fromBlock int64, tokenNetworkAddress common.Address, channelIdentifier common.Hash) (events []*contracts.TokenNetworkChannelClosed, err error) {
+	logs, err := rpc.EventGetChannel(rpc.GetQueryConext(), tokenNetworkAddress, channelIdentifier, ethrpc.BlockNumber(fromBlock), ethrpc.LatestBlockNumber,
 		params.NameChannelClosed, eventAbiMap[params.NameChannelClosed], be.client)
 	if err != nil {
 		return
@@ -540,8 +550,8 @@ func (be *Events) GetChannelClosed(fromBlock int64, tokenNetworkAddress common.A
 
 //GetChannelSettled return all channel settled events since `fromBlock` on tokenNetworkAddress
 //if tokenNetworkAddress is empty, return's all events have this signature
-func (be *Events) GetChannelSettled(fromBlock int64, tokenNetworkAddress common.Address) (events []*contracts.TokenNetworkChannelSettled, err error) {
-	logs, err := rpc.EventGetInternal(rpc.GetQueryConext(), tokenNetworkAddress, ethrpc.BlockNumber(fromBlock), ethrpc.LatestBlockNumber,
+func (be *Events) GetChannelSettled(fromBlock int64, tokenNetworkAddress common.Address, channelIdentifier common.Hash) (events []*contracts.TokenNetworkChannelSettled, err error) {
+	logs, err := rpc.EventGetChannel(rpc.GetQueryConext(), tokenNetworkAddress, channelIdentifier, ethrpc.BlockNumber(fromBlock), ethrpc.LatestBlockNumber,
 		params.NameChannelSettled, eventAbiMap[params.NameChannelSettled], be.client)
 	if err != nil {
 		return
@@ -559,8 +569,8 @@ func (be *Events) GetChannelSettled(fromBlock int64, tokenNetworkAddress common.
 
 //GetChannelCooperativeSettled return all channel settled events since `fromBlock` on tokenNetworkAddress
 //if tokenNetworkAddress is empty, return's all events have this signature
-func (be *Events) GetChannelCooperativeSettled(fromBlock int64, tokenNetworkAddress common.Address) (events []*contracts.TokenNetworkChannelCooperativeSettled, err error) {
-	logs, err := rpc.EventGetInternal(rpc.GetQueryConext(), tokenNetworkAddress, ethrpc.BlockNumber(fromBlock), ethrpc.LatestBlockNumber,
+func (be *Events) GetChannelCooperativeSettled(fromBlock int64, tokenNetworkAddress common.Address, channelIdentifier common.Hash) (events []*contracts.TokenNetworkChannelCooperativeSettled, err error) {
+	logs, err := rpc.EventGetChannel(rpc.GetQueryConext(), tokenNetworkAddress, channelIdentifier, ethrpc.BlockNumber(fromBlock), ethrpc.LatestBlockNumber,
 		params.NameChannelCooperativeSettled, eventAbiMap[params.NameChannelCooperativeSettled], be.client)
 	if err != nil {
 		return
@@ -577,8 +587,8 @@ func (be *Events) GetChannelCooperativeSettled(fromBlock int64, tokenNetworkAddr
 }
 
 //GetChannelPunished punish events of contract
-func (be *Events) GetChannelPunished(fromBlock int64, tokenNetworkAddress common.Address) (events []*contracts.TokenNetworkChannelPunished, err error) {
-	logs, err := rpc.EventGetInternal(rpc.GetQueryConext(), tokenNetworkAddress, ethrpc.BlockNumber(fromBlock), ethrpc.LatestBlockNumber,
+func (be *Events) GetChannelPunished(fromBlock int64, tokenNetworkAddress common.Address, channelIdentifier common.Hash) (events []*contracts.TokenNetworkChannelPunished, err error) {
+	logs, err := rpc.EventGetChannel(rpc.GetQueryConext(), tokenNetworkAddress, channelIdentifier, ethrpc.BlockNumber(fromBlock), ethrpc.LatestBlockNumber,
 		params.NameChannelPunished, eventAbiMap[params.NameChannelPunished], be.client)
 	if err != nil {
 		return
@@ -596,8 +606,8 @@ func (be *Events) GetChannelPunished(fromBlock int64, tokenNetworkAddress common
 
 //GetChannelWithdraw return all channel settled events since `fromBlock` on tokenNetworkAddress
 //if tokenNetworkAddress is empty, return's all events have this signature
-func (be *Events) GetChannelWithdraw(fromBlock int64, tokenNetworkAddress common.Address) (events []*contracts.TokenNetworkChannelWithdraw, err error) {
-	logs, err := rpc.EventGetInternal(rpc.GetQueryConext(), tokenNetworkAddress, ethrpc.BlockNumber(fromBlock), ethrpc.LatestBlockNumber,
+func (be *Events) GetChannelWithdraw(fromBlock int64, tokenNetworkAddress common.Address, channelIdentifier common.Hash) (events []*contracts.TokenNetworkChannelWithdraw, err error) {
+	logs, err := rpc.EventGetChannel(rpc.GetQueryConext(), tokenNetworkAddress, channelIdentifier, ethrpc.BlockNumber(fromBlock), ethrpc.LatestBlockNumber,
 		params.NameChannelWithdraw, eventAbiMap[params.NameChannelWithdraw], be.client)
 	if err != nil {
 		return
@@ -615,8 +625,8 @@ func (be *Events) GetChannelWithdraw(fromBlock int64, tokenNetworkAddress common
 
 //GetChannelNewDeposit return all channel settled events since `fromBlock` on tokenNetworkAddress
 //if tokenNetworkAddress is empty, return's all events have this signature
-func (be *Events) GetChannelNewDeposit(fromBlock int64, tokenNetworkAddress common.Address) (events []*contracts.TokenNetworkChannelNewDeposit, err error) {
-	logs, err := rpc.EventGetInternal(rpc.GetQueryConext(), tokenNetworkAddress, ethrpc.BlockNumber(fromBlock), ethrpc.LatestBlockNumber,
+func (be *Events) GetChannelNewDeposit(fromBlock int64, tokenNetworkAddress common.Address, channelIdentifier common.Hash) (events []*contracts.TokenNetworkChannelNewDeposit, err error) {
+	logs, err := rpc.EventGetChannel(rpc.GetQueryConext(), tokenNetworkAddress, channelIdentifier, ethrpc.BlockNumber(fromBlock), ethrpc.LatestBlockNumber,
 		params.NameChannelNewDeposit, eventAbiMap[params.NameChannelNewDeposit], be.client)
 	if err != nil {
 		return
@@ -634,8 +644,8 @@ func (be *Events) GetChannelNewDeposit(fromBlock int64, tokenNetworkAddress comm
 
 //GetChannelUnlocked return all channel settled events since `fromBlock` on tokenNetworkAddress
 //if tokenNetworkAddress is empty, return's all events have this signature
-func (be *Events) GetChannelUnlocked(fromBlock int64, tokenNetworkAddress common.Address) (events []*contracts.TokenNetworkChannelUnlocked, err error) {
-	logs, err := rpc.EventGetInternal(rpc.GetQueryConext(), tokenNetworkAddress, ethrpc.BlockNumber(fromBlock), ethrpc.LatestBlockNumber,
+func (be *Events) GetChannelUnlocked(fromBlock int64, tokenNetworkAddress common.Address, channelIdentifier common.Hash) (events []*contracts.TokenNetworkChannelUnlocked, err error) {
+	logs, err := rpc.EventGetChannel(rpc.GetQueryConext(), tokenNetworkAddress, channelIdentifier, ethrpc.BlockNumber(fromBlock), ethrpc.LatestBlockNumber,
 		params.NameChannelUnlocked, eventAbiMap[params.NameChannelUnlocked], be.client)
 	if err != nil {
 		return
@@ -654,8 +664,8 @@ func (be *Events) GetChannelUnlocked(fromBlock int64, tokenNetworkAddress common
 /*
 GetChannelBalanceProofUpdated returns all NonClosing balance proof events since `fromBlock`
 */
-func (be *Events) GetChannelBalanceProofUpdated(fromBlock int64, tokenNetworkAddress common.Address) (events []*contracts.TokenNetworkBalanceProofUpdated, err error) {
-	logs, err := rpc.EventGetInternal(rpc.GetQueryConext(), tokenNetworkAddress, ethrpc.BlockNumber(fromBlock), ethrpc.LatestBlockNumber,
+func (be *Events) GetChannelBalanceProofUpdated(fromBlock int64, tokenNetworkAddress common.Address, channelIdentifier common.Hash) (events []*contracts.TokenNetworkBalanceProofUpdated, err error) {
+	logs, err := rpc.EventGetChannel(rpc.GetQueryConext(), tokenNetworkAddress, channelIdentifier, ethrpc.BlockNumber(fromBlock), ethrpc.LatestBlockNumber,
 		params.NameBalanceProofUpdated, eventAbiMap[params.NameBalanceProofUpdated], be.client)
 	if err != nil {
 		return
@@ -716,78 +726,100 @@ func (be *Events) GetAllStateChangeSince(lastBlockNumber int64) (stateChangs []m
 		如何处理在查询过程中新收到的事件呢?
 	*/
 	for tokenNetwork := range be.TokenNetworks {
-		var err error
-		events2, err := be.GetChannelNew(lastBlockNumber, tokenNetwork)
-		if err != nil {
-			return nil, err
-		}
-		for _, e := range events2 {
-			stateChangs = append(stateChangs, EventChannelOpen2StateChange(e))
-		}
-		events3, err := be.GetChannelClosed(lastBlockNumber, tokenNetwork)
-		if err != nil {
-			return nil, err
-		}
-		for _, e := range events3 {
-			stateChangs = append(stateChangs, EventChannelClosed2StateChange(e))
-		}
-		events4, err := be.GetChannelSettled(lastBlockNumber, tokenNetwork)
-		if err != nil {
-			return nil, err
-		}
-		for _, e := range events4 {
-			stateChangs = append(stateChangs, EventChannelSettled2StateChange(e))
-		}
-		events5, err := be.GetChannelCooperativeSettled(lastBlockNumber, tokenNetwork)
-		if err != nil {
-			return nil, err
-		}
-		for _, e := range events5 {
-			stateChangs = append(stateChangs, EventChannelCooperativeSettled2StateChange(e))
-		}
-		events6, err := be.GetChannelBalanceProofUpdated(lastBlockNumber, tokenNetwork)
-		if err != nil {
-			return nil, err
-		}
-		for _, e := range events6 {
-			stateChangs = append(stateChangs, EventBalanceProofUpdated2StateChange(e))
-		}
-		events7, err := be.GetChannelUnlocked(lastBlockNumber, tokenNetwork)
-		if err != nil {
-			return nil, err
-		}
-		for _, e := range events7 {
-			stateChangs = append(stateChangs, EventChannelUnlocked2StateChange(e))
-		}
-		events8, err := be.GetChannelWithdraw(lastBlockNumber, tokenNetwork)
+		var sts []mediatedtransfer.ContractStateChange
+		sts, err = be.GetTokenNetworkStateChanges(lastBlockNumber, tokenNetwork)
 		if err != nil {
-			return nil, err
-		}
-		for _, e := range events8 {
-			stateChangs = append(stateChangs, EventChannelWithdraw2StateChange(e))
-		}
-		events9, err := be.GetChannelNewDeposit(lastBlockNumber, tokenNetwork)
-		if err != nil {
-			return nil, err
-		}
-		for _, e := range events9 {
-			stateChangs = append(stateChangs, EventChannelNewDeposit2StateChange(e))
-		}
-		events10, err := be.GetChannelPunished(lastBlockNumber, tokenNetwork)
-		if err != nil {
-			return nil, err
-		}
-		for _, e := range events10 {
-			stateChangs = append(stateChangs, EventChannelPunished2StateChange(e))
-		}
-		events11, err := be.GetChannelNewAndDeposit(lastBlockNumber, tokenNetwork)
-		if err != nil {
-			return nil, err
-		}
-		for _, e := range events11 {
-			st1, st2 := EventChannelOpenAndDeposit2StateChange(e)
-			stateChangs = append(stateChangs, st1, st2)
+			return
 		}
+		stateChangs = append(stateChangs, sts...)
+	}
+	return
+}
+
+/*
+GetTokenNetworkStateChanges returns all the statechanges of `tokenNetwork` since `fromBlock`,
+they are not sorted.
+*/
+func (be *Events) GetTokenNetworkStateChanges(fromBlock int64, tokenNetwork common.Address) (stateChangs []mediatedtransfer.ContractStateChange, err error) {
+	return be.GetChannelStateChanges(fromBlock, tokenNetwork, utils.EmptyHash)
+}
+
+/*
+GetChannelStateChanges returns the statechanges of channel `channelIdentifier` on `tokenNetwork` since `fromBlock`,
+channel identifier is the first indexed argument of these events, so only logs of this channel are fetched.
+empty channelIdentifier means all channels, they are not sorted.
+*/
+func (be *Events) GetChannelStateChanges(fromBlock int64, tokenNetwork common.Address, channelIdentifier common.Hash) (stateChangs []mediatedtransfer.ContractStateChange, err error) {
+	events2, err := be.GetChannelNew(fromBlock, tokenNetwork, channelIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events2 {
+		stateChangs = append(stateChangs, EventChannelOpen2StateChange(e))
+	}
+	events3, err := be.GetChannelClosed(fromBlock, tokenNetwork, channelIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events3 {
+		stateChangs = append(stateChangs, EventChannelClosed2StateChange(e))
+	}
+	events4, err := be.GetChannelSettled(fromBlock, tokenNetwork, channelIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events4 {
+		stateChangs = append(stateChangs, EventChannelSettled2StateChange(e))
+	}
+	events5, err := be.GetChannelCooperativeSettled(fromBlock, tokenNetwork, channelIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events5 {
+		stateChangs = append(stateChangs, EventChannelCooperativeSettled2StateChange(e))
+	}
+	events6, err := be.GetChannelBalanceProofUpdated(fromBlock, tokenNetwork, channelIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events6 {
+		stateChangs = append(stateChangs, EventBalanceProofUpdated2StateChange(e))
+	}
+	events7, err := be.GetChannelUnlocked(fromBlock, tokenNetwork, channelIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events7 {
+		stateChangs = append(stateChangs, EventChannelUnlocked2StateChange(e))
+	}
+	events8, err := be.GetChannelWithdraw(fromBlock, tokenNetwork, channelIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events8 {
+		stateChangs = append(stateChangs, EventChannelWithdraw2StateChange(e))
+	}
+	events9, err := be.GetChannelNewDeposit(fromBlock, tokenNetwork, channelIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events9 {
+		stateChangs = append(stateChangs, EventChannelNewDeposit2StateChange(e))
+	}
+	events10, err := be.GetChannelPunished(fromBlock, tokenNetwork, channelIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events10 {
+		stateChangs = append(stateChangs, EventChannelPunished2StateChange(e))
+	}
+	events11, err := be.GetChannelNewAndDeposit(fromBlock, tokenNetwork, channelIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events11 {
+		st1, st2 := EventChannelOpenAndDeposit2StateChange(e)
+		stateChangs = append(stateChangs, st1, st2)
 	}
 	return
 }
@@ -1,6 +1,7 @@
 package mainimpl
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -14,13 +15,22 @@ import (
 	"os/signal"
 	"time"
 
+	"math/big"
+
 	"net"
 	"strconv"
 
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
 	"github.com/SmartMeshFoundation/SmartRaiden"
 	"github.com/SmartMeshFoundation/SmartRaiden/accounts"
+	"github.com/SmartMeshFoundation/SmartRaiden/console"
 	"github.com/SmartMeshFoundation/SmartRaiden/internal/debug"
 	"github.com/SmartMeshFoundation/SmartRaiden/internal/rpanic"
+	"github.com/SmartMeshFoundation/SmartRaiden/jsonrpc"
 	"github.com/SmartMeshFoundation/SmartRaiden/log"
 	"github.com/SmartMeshFoundation/SmartRaiden/network"
 	"github.com/SmartMeshFoundation/SmartRaiden/network/helper"
@@ -32,6 +42,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/node"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -77,6 +88,34 @@ func StartMain() (*smartraiden.RaidenAPI, error) {
 			Usage: `host:port" for the RPC server to listen on.`,
 			Value: "127.0.0.1:5001",
 		},
+		cli.StringFlag{
+			Name:  "api-token-file",
+			Usage: "Text file containing the bearer token of api operator, api has no authentication if neither this nor api-readonly-token-file is set",
+		},
+		cli.StringFlag{
+			Name:  "api-readonly-token-file",
+			Usage: "Text file containing the bearer token which can only query by api",
+		},
+		cli.StringFlag{
+			Name:  "api-tls-cert",
+			Usage: "certificate file, serve api by https, work with --api-tls-key",
+		},
+		cli.StringFlag{
+			Name:  "api-tls-key",
+			Usage: "private key file of api-tls-cert",
+		},
+		cli.BoolFlag{
+			Name:  "enable-debug-api",
+			Usage: "enable debug and test apis, such as /api/1/stop and /api/1/debug/*, never enable it on a public node",
+		},
+		cli.StringFlag{
+			Name:  "rpc-address",
+			Usage: `"host:port" for json-rpc over http, disabled if empty`,
+		},
+		cli.BoolFlag{
+			Name:  "ipcdisable",
+			Usage: "disable json-rpc over ipc, which listens on smartraiden.ipc in datadir",
+		},
 		ethutils.DirectoryFlag{
 			Name:  "datadir",
 			Usage: "Directory for storing raiden data.",
@@ -103,6 +142,10 @@ func StartMain() (*smartraiden.RaidenAPI, error) {
 			Name:  "fee",
 			Usage: "enable mediation fee",
 		},
+		cli.StringFlag{
+			Name:  "fee-policy",
+			Usage: "json file of mediation fee policy, work with --fee",
+		},
 		cli.StringFlag{
 			Name:  "xmpp-server",
 			Usage: "use another xmpp server ",
@@ -116,6 +159,40 @@ func StartMain() (*smartraiden.RaidenAPI, error) {
 			Name:  "enable-health-check",
 			Usage: "enable health check ",
 		},
+		cli.StringSliceFlag{
+			Name:  "watchtower",
+			Usage: "address@url of a watchtower, partner's balance proofs are pushed to it, can be repeated, for example 0x6B9E4D89EE3828e7a477eA9AA7B62810260e27E9@http://127.0.0.1:5010/packages",
+		},
+		cli.DurationFlag{
+			Name:  "rebalance",
+			Usage: "rebalance channels by transfers to ourselves at this interval, for example 10m, disabled if 0",
+		},
+		cli.Float64Flag{
+			Name:  "rebalance-ratio",
+			Usage: "share of ours in each channel the rebalancer moves toward, 0.5 means even",
+			Value: params.DefaultConfig.RebalanceRatio,
+		},
+		cli.StringFlag{
+			Name:  "rebalance-max-fee",
+			Usage: "most fee paid for one rebalance transfer",
+			Value: "0",
+		},
+		cli.StringFlag{
+			Name:  "rebalance-max-daily-fee",
+			Usage: "most fee the rebalancer pays for each token in 24 hours, no limit if empty",
+		},
+		cli.BoolFlag{
+			Name:  "console",
+			Usage: "start an interactive console, the node stops when it quits",
+		},
+	}
+	app.Commands = []cli.Command{
+		{
+			Name:      "attach",
+			Usage:     "Start an interactive console attached to a running node",
+			ArgsUsage: "[ipc path]",
+			Action:    attach,
+		},
 	}
 	app.Flags = append(app.Flags, debug.Flags...)
 	app.Action = mainCtx
@@ -171,7 +248,29 @@ func mainCtx(ctx *cli.Context) (err error) {
 		return
 	}
 	api = smartraiden.NewRaidenAPI(raidenService)
-	regQuitHandler(api)
+	var rpcServer *jsonrpc.Server
+	if !params.MobileMode {
+		rpcServer, err = jsonrpc.Start(api, cfg)
+		if err != nil {
+			api.Stop()
+			return
+		}
+	}
+	regQuitHandler(api, rpcServer)
+	if cfg.UseConsole && !params.MobileMode {
+		var client *ethrpc.Client
+		client, err = jsonrpc.DialInProc(api)
+		if err != nil {
+			api.Stop()
+			return
+		}
+		go restful.Start(api, cfg)
+		console.New(client, filepath.Join(cfg.DataDir, "console_history")).Run()
+		client.Close()
+		rpcServer.Stop()
+		api.Stop()
+		return nil
+	}
 	if params.MobileMode {
 		if cfg.APIHost == "0.0.0.0" {
 			log.Info("start http server for test only...")
@@ -184,6 +283,26 @@ func mainCtx(ctx *cli.Context) (err error) {
 
 	return nil
 }
+
+//attach start a console of the node listening on ipc path
+func attach(ctx *cli.Context) error {
+	ipcPath := ctx.Args().First()
+	if ipcPath == "" {
+		dataDir := ctx.GlobalString("datadir")
+		if dataDir == "" {
+			dataDir = params.DefaultDataDir()
+		}
+		ipcPath = filepath.Join(dataDir, "smartraiden.ipc")
+	}
+	client, err := ethrpc.DialIPC(context.Background(), ipcPath)
+	if err != nil {
+		return fmt.Errorf("cannot attach to %s err %s", ipcPath, err)
+	}
+	defer client.Close()
+	console.New(client, filepath.Join(filepath.Dir(ipcPath), "console_history")).Run()
+	return nil
+}
+
 func buildTransport(cfg *params.Config, bcs *rpc.BlockChainService) (transport network.Transporter, err error) {
 	/*
 		use ice and doesn't work as route node,means this node runs  on a mobile phone.
@@ -211,13 +330,16 @@ func buildTransport(cfg *params.Config, bcs *rpc.BlockChainService) (transport n
 	}
 	return
 }
-func regQuitHandler(api *smartraiden.RaidenAPI) {
+
+//regQuitHandler stop json-rpc server and the node on interrupt, rpcServer may be nil
+func regQuitHandler(api *smartraiden.RaidenAPI, rpcServer *jsonrpc.Server) {
 	go func() {
 		defer rpanic.PanicRecover("regQuitHandler")
 		quitSignal := make(chan os.Signal, 1)
 		signal.Notify(quitSignal, os.Interrupt, os.Kill)
 		<-quitSignal
 		signal.Stop(quitSignal)
+		rpcServer.Stop()
 		api.Stop()
 		utils.SystemExit(0)
 	}()
@@ -243,6 +365,10 @@ func config(ctx *cli.Context) (config *params.Config, err error) {
 	if err != nil {
 		return
 	}
+	err = apiConfig(ctx, config)
+	if err != nil {
+		return
+	}
 	address := common.HexToAddress(ctx.String("address"))
 	address, privkeyBin, err := accounts.PromptAccount(address, ctx.String("keystore-path"), ctx.String("password-file"))
 	if err != nil {
@@ -271,6 +397,10 @@ func config(ctx *cli.Context) (config *params.Config, err error) {
 			return
 		}
 	}
+	config.IPCPath = ""
+	if !ctx.Bool("ipcdisable") {
+		config.IPCPath = filepath.Join(config.DataDir, "smartraiden.ipc")
+	}
 	userDbPath := hex.EncodeToString(config.MyAddress[:])
 	userDbPath = userDbPath[:8]
 	userDbPath = filepath.Join(config.DataDir, userDbPath)
@@ -301,10 +431,103 @@ func config(ctx *cli.Context) (config *params.Config, err error) {
 	}
 	if ctx.Bool("fee") {
 		config.EnableMediationFee = true
+		config.FeePolicyFile = ctx.String("fee-policy")
 	}
 	if ctx.Bool("enable-health-check") {
 		config.EnableHealthCheck = true
 	}
 	config.XMPPServer = ctx.String("xmpp-server")
+	for _, s := range ctx.StringSlice("watchtower") {
+		var w *params.Watchtower
+		w, err = parseWatchtower(s)
+		if err != nil {
+			return
+		}
+		config.Watchtowers = append(config.Watchtowers, w)
+	}
+	config.RebalanceInterval = ctx.Duration("rebalance")
+	config.RebalanceRatio = ctx.Float64("rebalance-ratio")
+	if config.RebalanceRatio <= 0 || config.RebalanceRatio >= 1 {
+		err = fmt.Errorf("rebalance-ratio should be between 0 and 1")
+		return
+	}
+	var ok bool
+	config.RebalanceMaxFee, ok = new(big.Int).SetString(ctx.String("rebalance-max-fee"), 10)
+	if !ok || config.RebalanceMaxFee.Sign() < 0 {
+		err = fmt.Errorf("rebalance-max-fee %s is not a valid amount", ctx.String("rebalance-max-fee"))
+		return
+	}
+	if ctx.String("rebalance-max-daily-fee") != "" {
+		config.RebalanceMaxDailyFee, ok = new(big.Int).SetString(ctx.String("rebalance-max-daily-fee"), 10)
+		if !ok || config.RebalanceMaxDailyFee.Sign() < 0 {
+			err = fmt.Errorf("rebalance-max-daily-fee %s is not a valid amount", ctx.String("rebalance-max-daily-fee"))
+			return
+		}
+	}
+	return
+}
+
+//parseWatchtower parse address@url
+func parseWatchtower(s string) (w *params.Watchtower, err error) {
+	ss := strings.SplitN(s, "@", 2)
+	if len(ss) != 2 || !common.IsHexAddress(ss[0]) {
+		return nil, fmt.Errorf("watchtower %s should be address@url", s)
+	}
+	u, err := url.Parse(ss[1])
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("watchtower %s should be address@url, url must be http or https", s)
+	}
+	return &params.Watchtower{Address: common.HexToAddress(ss[0]), URL: ss[1]}, nil
+}
+
+func readTokenFile(name string) (string, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if len(token) == 0 {
+		return "", fmt.Errorf("token file %s is empty", name)
+	}
+	return token, nil
+}
+
+//apiConfig authentication and tls of api
+func apiConfig(ctx *cli.Context, config *params.Config) (err error) {
+	if f := ctx.String("api-token-file"); f != "" {
+		config.APIToken, err = readTokenFile(f)
+		if err != nil {
+			return
+		}
+	}
+	if f := ctx.String("api-readonly-token-file"); f != "" {
+		config.APIReadOnlyToken, err = readTokenFile(f)
+		if err != nil {
+			return
+		}
+		if config.APIToken == "" {
+			return errors.New("api-readonly-token-file must work with api-token-file")
+		}
+		if config.APIReadOnlyToken == config.APIToken {
+			return errors.New("readonly token must be different from operator token")
+		}
+	}
+	config.APITLSCertFile = ctx.String("api-tls-cert")
+	config.APITLSKeyFile = ctx.String("api-tls-key")
+	if (config.APITLSCertFile == "") != (config.APITLSKeyFile == "") {
+		return errors.New("api-tls-cert and api-tls-key must be set together")
+	}
+	config.EnableDebugAPI = ctx.Bool("enable-debug-api")
+	if addr := ctx.String("rpc-address"); addr != "" {
+		var port string
+		config.RPCHost, port, err = net.SplitHostPort(addr)
+		if err != nil {
+			return
+		}
+		config.RPCPort, err = strconv.Atoi(port)
+		if err != nil {
+			return
+		}
+	}
 	return
 }
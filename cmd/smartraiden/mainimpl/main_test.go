@@ -45,3 +45,20 @@ func TestStruct(t *testing.T) {
 func TestStartMain(t *testing.T) {
 	StartMain()
 }
+
+func TestParseWatchtower(t *testing.T) {
+	w, err := parseWatchtower("0x6B9E4D89EE3828e7a477eA9AA7B62810260e27E9@http://127.0.0.1:5010/packages")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if w.Address.String() != "0x6B9E4D89EE3828e7a477eA9AA7B62810260e27E9" || w.URL != "http://127.0.0.1:5010/packages" {
+		t.Errorf("parse wrong %v", w)
+	}
+	for _, s := range []string{"http://127.0.0.1:5010/packages", "0x6B9E4D89EE3828e7a477eA9AA7B62810260e27E9@127.0.0.1:5010", "0x6B9E@http://127.0.0.1:5010"} {
+		_, err = parseWatchtower(s)
+		if err == nil {
+			t.Errorf("%s should be invalid", s)
+		}
+	}
+}
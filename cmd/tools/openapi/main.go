@@ -0,0 +1,27 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/restful/client"
+)
+
+/*
+openapi writes the OpenAPI document of the rest api,
+it's generated from the types used by restful/v1 and restful/client.
+*/
+func main() {
+	out := flag.String("o", "openapi.json", "output file")
+	flag.Parse()
+	data, err := json.MarshalIndent(client.OpenAPI(), "", "  ")
+	if err != nil {
+		log.Fatalf("marshal err %s", err)
+	}
+	err = ioutil.WriteFile(*out, append(data, '\n'), 0644)
+	if err != nil {
+		log.Fatalf("write %s err %s", *out, err)
+	}
+}
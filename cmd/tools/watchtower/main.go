@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/accounts"
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/SmartMeshFoundation/SmartRaiden/network/helper"
+	"github.com/SmartMeshFoundation/SmartRaiden/network/rpc"
+	"github.com/SmartMeshFoundation/SmartRaiden/params"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	ethutils "github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/node"
+	"gopkg.in/urfave/cli.v1"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "address",
+			Usage: "The ethereum address the watchtower sends transactions from, the channel packages must be signed for it.",
+		},
+		ethutils.DirectoryFlag{
+			Name:  "keystore-path",
+			Usage: "If you have a non-standard path for the ethereum keystore directory provide it using this argument. ",
+			Value: ethutils.DirectoryString{Value: params.DefaultKeyStoreDir()},
+		},
+		cli.StringFlag{
+			Name:  "password-file",
+			Usage: "Text file containing password for provided account",
+		},
+		cli.StringFlag{
+			Name: "eth-rpc-endpoint",
+			Usage: `"host:port" address of ethereum JSON-RPC server.\n'
+	           'Also accepts a protocol prefix (ws:// or ipc channel) with optional port',`,
+			Value: node.DefaultIPCEndpoint("geth"),
+		},
+		cli.StringFlag{
+			Name:  "registry-contract-address",
+			Usage: `hex encoded address of the registry contract.`,
+			Value: params.RopstenRegistryAddress.String(),
+		},
+		cli.StringFlag{
+			Name:  "api-address",
+			Usage: `"host:port" to receive channel packages on.`,
+			Value: "127.0.0.1:5010",
+		},
+		ethutils.DirectoryFlag{
+			Name:  "datadir",
+			Usage: "Directory for storing channel packages.",
+			Value: ethutils.DirectoryString{Value: params.DefaultDataDir() + "/watchtower"},
+		},
+		cli.IntFlag{
+			Name:  "verbosity",
+			Usage: "log level,0=crit,5=trace",
+			Value: int(log.LvlInfo),
+		},
+	}
+	app.Action = mainctx
+	app.Name = "watchtower"
+	app.Usage = "update balance proof and unlock for offline smartraiden nodes before their channels settle"
+	app.Version = "0.1"
+	err := app.Run(os.Args)
+	if err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+func mainctx(ctx *cli.Context) error {
+	log.Root().SetHandler(log.LvlFilterHandler(log.Lvl(ctx.Int("verbosity")), utils.MyStreamHandler(os.Stderr)))
+	address := common.HexToAddress(ctx.String("address"))
+	address, keybin, err := accounts.PromptAccount(address, ctx.String("keystore-path"), ctx.String("password-file"))
+	if err != nil {
+		return fmt.Errorf("failed to unlock account %s", err)
+	}
+	key, err := crypto.ToECDSA(keybin)
+	if err != nil {
+		return fmt.Errorf("failed to parse priv key %s", err)
+	}
+	dataDir := ctx.String("datadir")
+	if !utils.Exists(dataDir) {
+		err = os.MkdirAll(dataDir, 0700) //packages are signed by the participants, only for the watchtower
+		if err != nil {
+			return fmt.Errorf("datadir:%s doesn't exist and cannot create %v", dataDir, err)
+		}
+	}
+	client, err := helper.NewSafeClient(ctx.String("eth-rpc-endpoint"))
+	if err != nil {
+		return fmt.Errorf("cannot connect to geth :%s err=%s", ctx.String("eth-rpc-endpoint"), err)
+	}
+	bcs := rpc.NewBlockChainService(key, common.HexToAddress(ctx.String("registry-contract-address")), client)
+	if bcs.Registry(bcs.RegistryAddress) == nil {
+		return fmt.Errorf("cannot find registry %s", bcs.RegistryAddress.String())
+	}
+	wt := newWatchTower(bcs, dataDir)
+	err = wt.load()
+	if err != nil {
+		return err
+	}
+	go wt.run()
+	log.Info(fmt.Sprintf("watchtower %s receives channel packages on http://%s/packages", utils.APex(address), ctx.String("api-address")))
+	http.HandleFunc("/packages", wt.handlePackage)
+	return http.ListenAndServe(ctx.String("api-address"), nil)
+}
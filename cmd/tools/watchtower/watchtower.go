@@ -0,0 +1,529 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/SmartMeshFoundation/SmartRaiden"
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/SmartMeshFoundation/SmartRaiden/network/rpc"
+	"github.com/SmartMeshFoundation/SmartRaiden/params"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mtree"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//channelStateClosed state of a closed channel on chain
+const channelStateClosed = 2
+
+//pollInterval how often to check new blocks
+const pollInterval = 5 * time.Second
+
+//maxTxAttempts a failed transaction still costs gas, so each one is tried at most this many times
+const maxTxAttempts = 3
+
+type unlockProof struct {
+	lock             *mtree.Lock
+	proof            []byte
+	secret           common.Hash
+	signature        []byte
+	secretRegistered bool
+	registerAttempts int
+	unlocked         bool
+	attempts         int
+}
+
+/*
+channelPackage is what a participant signed for the watchtower,
+it's parsed from smartraiden.ChannelFor3rd.
+*/
+type channelPackage struct {
+	channel              common.Hash
+	openBlockNumber      int64
+	tokenNetwork         common.Address
+	partner              common.Address
+	participant          common.Address
+	nonce                int64
+	transferAmount       *big.Int
+	locksroot            common.Hash
+	extraHash            common.Hash
+	partnerSignature     []byte
+	participantSignature []byte
+	unlocks              []*unlockProof
+	scanned              int64 //ChannelClosed events before this block have been checked
+	closed               bool
+	settleBlock          int64
+	settleTimeout        int64
+	updated              bool //partner's balance proof on chain is not older than ours
+	updateAttempts       int
+}
+
+/*
+packageKey both participants of a channel may use the same watchtower,
+so a package is identified by its channel and participant.
+*/
+type packageKey struct {
+	channel     common.Hash
+	participant common.Address
+}
+
+func (p *channelPackage) key() packageKey {
+	return packageKey{p.channel, p.participant}
+}
+
+//verifySignature returns true if sig is signed by signer over data
+func verifySignature(data, sig []byte, signer common.Address) bool {
+	addr, err := utils.Ecrecover(utils.Sha3(data), sig)
+	return err == nil && addr == signer
+}
+
+//balanceProofData is what partner signed, the same as recoverAddressFromBalanceProof of TokenNetwork
+func (p *channelPackage) balanceProofData() []byte {
+	buf := new(bytes.Buffer)
+	buf.Write(utils.BigIntTo32Bytes(p.transferAmount))
+	buf.Write(p.locksroot[:])
+	binary.Write(buf, binary.BigEndian, uint64(p.nonce))
+	buf.Write(p.extraHash[:])
+	buf.Write(p.channel[:])
+	binary.Write(buf, binary.BigEndian, uint64(p.openBlockNumber))
+	buf.Write(utils.BigIntTo32Bytes(params.ChainID))
+	return buf.Bytes()
+}
+
+//updateMessageData is what participant signed, the same as recoverAddressFromBalanceProofUpdateMessage of TokenNetwork
+func (p *channelPackage) updateMessageData() []byte {
+	return append(p.balanceProofData(), p.partnerSignature...)
+}
+
+//unlockData is what participant signed for unlockDelegate called by thirdParty
+func (p *channelPackage) unlockData(lock *mtree.Lock, thirdParty common.Address) []byte {
+	buf := new(bytes.Buffer)
+	buf.Write(thirdParty[:])
+	buf.Write(utils.BigIntTo32Bytes(big.NewInt(lock.Expiration)))
+	buf.Write(utils.BigIntTo32Bytes(lock.Amount))
+	buf.Write(lock.LockSecretHash[:])
+	buf.Write(p.channel[:])
+	binary.Write(buf, binary.BigEndian, uint64(p.openBlockNumber))
+	buf.Write(utils.BigIntTo32Bytes(params.ChainID))
+	return buf.Bytes()
+}
+
+func parsePackage(c *smartraiden.ChannelFor3rd, thirdParty common.Address) (p *channelPackage, err error) {
+	if common.HexToAddress(c.ThirdPartyAddress) != thirdParty {
+		return nil, fmt.Errorf("package is signed for %s, not for me", c.ThirdPartyAddress)
+	}
+	p = &channelPackage{
+		channel:              common.HexToHash(c.ChannelAddress),
+		openBlockNumber:      c.OpenBlockNumber,
+		tokenNetwork:         common.HexToAddress(c.TokenNetworkAddress),
+		partner:              common.HexToAddress(c.PartnerAddress),
+		participant:          common.HexToAddress(c.ParticipantAddress),
+		nonce:                c.UpdateTransfer.Nonce,
+		transferAmount:       c.UpdateTransfer.TransferAmount,
+		locksroot:            common.HexToHash(c.UpdateTransfer.Locksroot),
+		extraHash:            common.HexToHash(c.UpdateTransfer.ExtraHash),
+		partnerSignature:     common.FromHex(c.UpdateTransfer.ClosingSignature),
+		participantSignature: common.FromHex(c.UpdateTransfer.NonClosingSignature),
+		scanned:              c.OpenBlockNumber,
+	}
+	if p.channel == utils.EmptyHash || p.tokenNetwork == utils.EmptyAddress ||
+		p.partner == utils.EmptyAddress || p.participant == utils.EmptyAddress {
+		return nil, errors.New("channel, token network, partner and participant are required")
+	}
+	if p.nonce <= 0 {
+		return nil, errors.New("there is no balance proof of partner to update")
+	}
+	if p.transferAmount == nil || p.transferAmount.Sign() < 0 || len(p.partnerSignature) != 65 || len(p.participantSignature) != 65 {
+		return nil, errors.New("invalid update transfer")
+	}
+	if !verifySignature(p.balanceProofData(), p.partnerSignature, p.partner) {
+		return nil, errors.New("balance proof is not signed by partner")
+	}
+	if !verifySignature(p.updateMessageData(), p.participantSignature, p.participant) {
+		return nil, errors.New("update transfer is not signed by participant")
+	}
+	for _, w := range c.Withdraws {
+		u := &unlockProof{
+			lock:      new(mtree.Lock),
+			proof:     common.FromHex(w.MerkleProof),
+			secret:    common.HexToHash(w.Secret),
+			signature: common.FromHex(w.Signature),
+		}
+		err = u.lock.FromBytes(common.FromHex(w.LockedEncoded))
+		if err != nil || len(u.proof)%32 != 0 || len(u.signature) != 65 || utils.Sha3(u.secret[:]) != u.lock.LockSecretHash {
+			return nil, fmt.Errorf("invalid withdraw of lock %s", w.LockedEncoded)
+		}
+		if !verifySignature(p.unlockData(u.lock, thirdParty), u.signature, p.participant) {
+			return nil, fmt.Errorf("withdraw of lock %s is not signed by participant", w.LockedEncoded)
+		}
+		p.unlocks = append(p.unlocks, u)
+	}
+	return
+}
+
+/*
+WatchTower keeps channel packages of offline participants,
+when a channel is closed, it updates partner's balance proof and unlocks partner's locks for the participant
+in the second half of settle window, contract doesn't accept delegate update before that.
+*/
+type WatchTower struct {
+	bcs      *rpc.BlockChainService
+	dataDir  string
+	lock     sync.Mutex
+	packages map[packageKey]*channelPackage
+}
+
+func newWatchTower(bcs *rpc.BlockChainService, dataDir string) *WatchTower {
+	return &WatchTower{
+		bcs:      bcs,
+		dataDir:  dataDir,
+		packages: make(map[packageKey]*channelPackage),
+	}
+}
+
+func (wt *WatchTower) packageFile(key packageKey) string {
+	return filepath.Join(wt.dataDir, fmt.Sprintf("%s-%s.json", key.channel.String(), key.participant.String()))
+}
+
+//load packages saved in datadir
+func (wt *WatchTower) load() error {
+	files, err := filepath.Glob(filepath.Join(wt.dataDir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		c := new(smartraiden.ChannelFor3rd)
+		err = json.Unmarshal(data, c)
+		if err != nil {
+			return fmt.Errorf("%s err %s", f, err)
+		}
+		p, err := parsePackage(c, wt.bcs.NodeAddress)
+		if err != nil {
+			log.Warn(fmt.Sprintf("ignore package %s, %s", f, err))
+			continue
+		}
+		if wt.packageFile(p.key()) != f {
+			log.Warn(fmt.Sprintf("ignore package %s, it's for channel %s of participant %s", f, utils.HPex(p.channel), utils.APex(p.participant)))
+			continue
+		}
+		wt.packages[p.key()] = p
+	}
+	log.Info(fmt.Sprintf("load %d channel packages", len(wt.packages)))
+	return nil
+}
+
+/*
+Ingest saves a new package, a package with lower nonce than the saved one of the same participant is refused.
+the two participants of a channel are watched separately, a package whose participant or partner
+differs from the saved ones of the same channel is refused.
+*/
+func (wt *WatchTower) Ingest(c *smartraiden.ChannelFor3rd) error {
+	p, err := parsePackage(c, wt.bcs.NodeAddress)
+	if err != nil {
+		return err
+	}
+	wt.lock.Lock()
+	defer wt.lock.Unlock()
+	for k, q := range wt.packages {
+		if k.channel != p.channel {
+			continue
+		}
+		if q.tokenNetwork != p.tokenNetwork || q.openBlockNumber != p.openBlockNumber ||
+			(k.participant == p.participant && q.partner != p.partner) ||
+			(k.participant != p.participant && (k.participant != p.partner || q.partner != p.participant)) {
+			return fmt.Errorf("package of channel %s doesn't match the saved one of participant %s", utils.HPex(p.channel), utils.APex(q.participant))
+		}
+	}
+	old := wt.packages[p.key()]
+	if old != nil {
+		if old.closed {
+			return fmt.Errorf("channel %s has been closed", utils.HPex(p.channel))
+		}
+		if old.nonce > p.nonce || (old.nonce == p.nonce && len(old.unlocks) > len(p.unlocks)) {
+			return fmt.Errorf("package is older than the saved one, nonce=%d", old.nonce)
+		}
+		p.scanned = old.scanned
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(wt.packageFile(p.key()), data, 0600)
+	if err != nil {
+		return err
+	}
+	wt.packages[p.key()] = p
+	log.Info(fmt.Sprintf("ingest package of channel %s,participant=%s,nonce=%d,unlocks=%d",
+		utils.HPex(p.channel), utils.APex(p.participant), p.nonce, len(p.unlocks)))
+	return nil
+}
+
+//remove package p, unless it has been replaced by a newer one
+func (wt *WatchTower) remove(p *channelPackage) {
+	wt.lock.Lock()
+	defer wt.lock.Unlock()
+	if wt.packages[p.key()] != p {
+		return
+	}
+	delete(wt.packages, p.key())
+	err := os.Remove(wt.packageFile(p.key()))
+	if err != nil {
+		log.Error(fmt.Sprintf("remove package %s err %s", utils.HPex(p.channel), err))
+	}
+}
+
+//updatePackage runs f with lock held, unless p has been replaced by a newer one
+func (wt *WatchTower) updatePackage(p *channelPackage, f func()) {
+	wt.lock.Lock()
+	defer wt.lock.Unlock()
+	if wt.packages[p.key()] == p {
+		f()
+	}
+}
+
+//handlePackage POST /packages with a json of smartraiden.ChannelFor3rd
+func (wt *WatchTower) handlePackage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c := new(smartraiden.ChannelFor3rd)
+	err := json.NewDecoder(r.Body).Decode(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = wt.Ingest(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (wt *WatchTower) run() {
+	var lastBlock int64
+	for {
+		h, err := wt.bcs.Client.HeaderByNumber(context.Background(), nil)
+		if err != nil {
+			log.Error(fmt.Sprintf("get block number err %s", err))
+		} else if h.Number.Int64() > lastBlock {
+			lastBlock = h.Number.Int64()
+			wt.onNewBlock(lastBlock)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+/*
+onNewBlock checks packages without holding the lock, so new packages can be ingested while waiting transactions mined.
+only this goroutine changes packages in the map, Ingest replaces them.
+*/
+func (wt *WatchTower) onNewBlock(blockNumber int64) {
+	var closed []*channelPackage
+	tokenNetworks := make(map[common.Address][]*channelPackage)
+	wt.lock.Lock()
+	for _, p := range wt.packages {
+		if p.closed {
+			closed = append(closed, p)
+		} else {
+			tokenNetworks[p.tokenNetwork] = append(tokenNetworks[p.tokenNetwork], p)
+		}
+	}
+	wt.lock.Unlock()
+	for addr, ps := range tokenNetworks {
+		wt.watchChannelClosed(addr, ps, blockNumber)
+	}
+	for _, p := range closed {
+		wt.handleClosedChannel(p, blockNumber)
+	}
+}
+
+//watchChannelClosed find ChannelClosed events of channels in ps
+func (wt *WatchTower) watchChannelClosed(tokenNetwork common.Address, ps []*channelPackage, blockNumber int64) {
+	tn, err := wt.bcs.TokenNetwork(tokenNetwork)
+	if err != nil {
+		log.Error(fmt.Sprintf("token network %s err %s", utils.APex(tokenNetwork), err))
+		return
+	}
+	start := blockNumber
+	var ids [][32]byte
+	for _, p := range ps {
+		ids = append(ids, p.channel)
+		if p.scanned < start {
+			start = p.scanned
+		}
+	}
+	end := uint64(blockNumber)
+	it, err := tn.GetContract().FilterChannelClosed(&bind.FilterOpts{Start: uint64(start), End: &end}, ids)
+	if err != nil {
+		log.Error(fmt.Sprintf("FilterChannelClosed err %s", err))
+		return
+	}
+	closed := make(map[common.Hash]int64) //channel -> block closed
+	for it.Next() {
+		closed[it.Event.ChannelIdentifier] = int64(it.Event.Raw.BlockNumber)
+	}
+	if it.Error() != nil {
+		log.Error(fmt.Sprintf("FilterChannelClosed err %s", it.Error()))
+		return
+	}
+	for _, p := range ps {
+		closedBlock, ok := closed[p.channel]
+		if !ok {
+			wt.updatePackage(p, func() {
+				p.scanned = blockNumber + 1
+			})
+			continue
+		}
+		channelID, settleBlock, _, state, settleTimeout, err := tn.GetChannelInfo(p.participant, p.partner)
+		if err != nil {
+			log.Error(fmt.Sprintf("GetChannelInfo %s err %s", utils.HPex(p.channel), err))
+			wt.updatePackage(p, func() {
+				p.scanned = closedBlock //try again
+			})
+			continue
+		}
+		if channelID != p.channel || state != channelStateClosed {
+			log.Info(fmt.Sprintf("channel %s has been settled, remove it", utils.HPex(p.channel)))
+			wt.remove(p)
+			continue
+		}
+		wt.updatePackage(p, func() {
+			p.scanned = blockNumber + 1
+			p.closed = true
+			p.settleBlock = int64(settleBlock)
+			p.settleTimeout = int64(settleTimeout)
+		})
+		log.Info(fmt.Sprintf("channel %s closed, participant=%s,settle block=%d",
+			utils.HPex(p.channel), utils.APex(p.participant), p.settleBlock))
+	}
+}
+
+/*
+handleClosedChannel register secrets as soon as possible, locks expire,
+then update and unlock in the second half of settle window.
+a failed transaction is tried again in later blocks, no more than maxTxAttempts times, it still costs gas.
+the package is removed when everything is done or the channel can be settled.
+*/
+func (wt *WatchTower) handleClosedChannel(p *channelPackage, blockNumber int64) {
+	if blockNumber > p.settleBlock {
+		log.Info(fmt.Sprintf("channel %s can be settled, remove it", utils.HPex(p.channel)))
+		wt.remove(p)
+		return
+	}
+	for _, u := range p.unlocks {
+		wt.registerSecret(u, blockNumber)
+	}
+	if blockNumber < p.settleBlock-p.settleTimeout/2 {
+		return
+	}
+	tn, err := wt.bcs.TokenNetwork(p.tokenNetwork)
+	if err != nil {
+		log.Error(fmt.Sprintf("token network %s err %s", utils.APex(p.tokenNetwork), err))
+		return
+	}
+	if !p.updated {
+		if p.updateAttempts >= maxTxAttempts {
+			log.Error(fmt.Sprintf("channel %s update failed %d times, give up", utils.HPex(p.channel), p.updateAttempts))
+			wt.remove(p)
+			return
+		}
+		_, _, nonce, err := tn.GetChannelParticipantInfo(p.partner, p.participant)
+		if err != nil {
+			log.Error(fmt.Sprintf("GetChannelParticipantInfo %s err %s", utils.HPex(p.channel), err))
+			return
+		}
+		if int64(nonce) > p.nonce {
+			log.Info(fmt.Sprintf("channel %s partner's nonce on chain is %d, newer than %d, remove it", utils.HPex(p.channel), nonce, p.nonce))
+			wt.remove(p)
+			return
+		}
+		if int64(nonce) < p.nonce {
+			p.updateAttempts++
+			err = tn.UpdateBalanceProofDelegate(p.partner, p.participant, p.transferAmount, p.locksroot, p.nonce, p.extraHash, p.partnerSignature, p.participantSignature)
+			if err != nil {
+				log.Error(fmt.Sprintf("UpdateBalanceProofDelegate %s err %s, try again later", utils.HPex(p.channel), err))
+				return
+			}
+		}
+		p.updated = true
+	}
+	//balance hash on chain is updated with transferred amount of every unlock
+	transferAmount := new(big.Int).Set(p.transferAmount)
+	for _, u := range p.unlocks {
+		if u.unlocked {
+			transferAmount.Add(transferAmount, u.lock.Amount)
+		}
+	}
+	done := true
+	for _, u := range p.unlocks {
+		if u.unlocked || u.attempts >= maxTxAttempts {
+			continue
+		}
+		if !u.secretRegistered {
+			//it's too late to register
+			if blockNumber < u.lock.Expiration && u.registerAttempts < maxTxAttempts {
+				done = false
+			}
+			continue
+		}
+		u.attempts++
+		err = tn.UnlockDelegate(p.partner, p.participant, transferAmount, u.lock, u.proof, u.signature)
+		if err != nil {
+			log.Error(fmt.Sprintf("UnlockDelegate %s lock=%s err %s", utils.HPex(p.channel), u.lock, err))
+			if u.attempts < maxTxAttempts {
+				done = false
+			}
+			continue
+		}
+		u.unlocked = true
+		transferAmount.Add(transferAmount, u.lock.Amount)
+	}
+	if done {
+		log.Info(fmt.Sprintf("channel %s updated and unlocked, remove it", utils.HPex(p.channel)))
+		wt.remove(p)
+	}
+}
+
+func (wt *WatchTower) isSecretRegistered(u *unlockProof) bool {
+	registered, err := wt.bcs.SecretRegistryProxy.IsSecretRegistered(u.secret)
+	if err != nil {
+		log.Error(fmt.Sprintf("IsSecretRegistered err %s", err))
+	}
+	return registered
+}
+
+//registerSecret before the lock expires, otherwise it cannot be unlocked, failed one is tried again in the next block
+func (wt *WatchTower) registerSecret(u *unlockProof, blockNumber int64) {
+	if u.secretRegistered || blockNumber >= u.lock.Expiration || u.registerAttempts >= maxTxAttempts {
+		return
+	}
+	if wt.isSecretRegistered(u) {
+		u.secretRegistered = true
+		return
+	}
+	u.registerAttempts++
+	err := wt.bcs.SecretRegistryProxy.RegisterSecret(u.secret)
+	if err != nil {
+		log.Error(fmt.Sprintf("RegisterSecret %s err %s, try again later", utils.HPex(u.lock.LockSecretHash), err))
+		return
+	}
+	u.secretRegistered = true
+}
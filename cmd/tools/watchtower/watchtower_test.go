@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/SmartMeshFoundation/SmartRaiden"
+	"github.com/SmartMeshFoundation/SmartRaiden/network/rpc"
+	"github.com/SmartMeshFoundation/SmartRaiden/params"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mtree"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type testKeys struct {
+	partner     *ecdsa.PrivateKey
+	participant *ecdsa.PrivateKey
+}
+
+func newTestKeys() *testKeys {
+	k1, _ := crypto.GenerateKey()
+	k2, _ := crypto.GenerateKey()
+	return &testKeys{partner: k1, participant: k2}
+}
+
+//newTestPackage signs the package of a new channel
+func newTestPackage(keys *testKeys, thirdParty common.Address, secret common.Hash) *smartraiden.ChannelFor3rd {
+	return newTestChannelPackage(keys, thirdParty, secret, utils.NewRandomHash(), utils.NewRandomAddress())
+}
+
+//newTestChannelPackage signs the package the same way as the node, see signFor3rd and signUnlockFor3rd of smartraiden
+func newTestChannelPackage(keys *testKeys, thirdParty common.Address, secret common.Hash, channel common.Hash, tokenNetwork common.Address) *smartraiden.ChannelFor3rd {
+	var openBlockNumber int64 = 30
+	c := &smartraiden.ChannelFor3rd{
+		ChannelAddress:      channel.String(),
+		OpenBlockNumber:     openBlockNumber,
+		TokenNetworkAddress: tokenNetwork.String(),
+		PartnerAddress:      crypto.PubkeyToAddress(keys.partner.PublicKey).String(),
+		ParticipantAddress:  crypto.PubkeyToAddress(keys.participant.PublicKey).String(),
+		ThirdPartyAddress:   thirdParty.String(),
+	}
+	locksroot := utils.NewRandomHash()
+	extraHash := utils.NewRandomHash()
+	c.UpdateTransfer.Nonce = 3
+	c.UpdateTransfer.TransferAmount = big.NewInt(10)
+	c.UpdateTransfer.Locksroot = locksroot.String()
+	c.UpdateTransfer.ExtraHash = extraHash.String()
+	buf := new(bytes.Buffer)
+	buf.Write(utils.BigIntTo32Bytes(big.NewInt(10)))
+	buf.Write(locksroot[:])
+	binary.Write(buf, binary.BigEndian, uint64(3))
+	buf.Write(extraHash[:])
+	buf.Write(channel[:])
+	binary.Write(buf, binary.BigEndian, uint64(openBlockNumber))
+	buf.Write(utils.BigIntTo32Bytes(params.ChainID))
+	partnerSig, err := utils.SignData(keys.partner, buf.Bytes())
+	if err != nil {
+		panic(err)
+	}
+	buf.Write(partnerSig)
+	participantSig, err := utils.SignData(keys.participant, buf.Bytes())
+	if err != nil {
+		panic(err)
+	}
+	c.UpdateTransfer.ClosingSignature = common.Bytes2Hex(partnerSig)
+	c.UpdateTransfer.NonClosingSignature = common.Bytes2Hex(participantSig)
+
+	lock := &mtree.Lock{Expiration: 100, Amount: big.NewInt(5), LockSecretHash: utils.Sha3(secret[:])}
+	buf = new(bytes.Buffer)
+	buf.Write(thirdParty[:])
+	buf.Write(utils.BigIntTo32Bytes(big.NewInt(lock.Expiration)))
+	buf.Write(utils.BigIntTo32Bytes(lock.Amount))
+	buf.Write(lock.LockSecretHash[:])
+	buf.Write(channel[:])
+	binary.Write(buf, binary.BigEndian, uint64(openBlockNumber))
+	buf.Write(utils.BigIntTo32Bytes(params.ChainID))
+	unlockSig, err := utils.SignData(keys.participant, buf.Bytes())
+	if err != nil {
+		panic(err)
+	}
+	withdraws := fmt.Sprintf(`[{"locked_encoded":"%s","merkle_proof":"","secret":"%s","signature":"%s"}]`,
+		common.Bytes2Hex(lock.AsBytes()), secret.String(), common.Bytes2Hex(unlockSig))
+	err = json.Unmarshal([]byte(withdraws), &c.Withdraws)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func TestParsePackage(t *testing.T) {
+	keys := newTestKeys()
+	thirdParty := utils.NewRandomAddress()
+	secret := utils.NewRandomHash()
+	c := newTestPackage(keys, thirdParty, secret)
+	p, err := parsePackage(c, thirdParty)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.EqualValues(t, 3, p.nonce)
+	assert.EqualValues(t, 30, p.scanned)
+	assert.EqualValues(t, 30, p.openBlockNumber)
+	assert.Equal(t, 1, len(p.unlocks))
+	assert.EqualValues(t, 100, p.unlocks[0].lock.Expiration)
+	assert.Equal(t, secret, p.unlocks[0].secret)
+
+	_, err = parsePackage(c, utils.NewRandomAddress())
+	assert.NotNil(t, err, "signed for another third party")
+
+	c = newTestPackage(keys, thirdParty, secret)
+	c.Withdraws[0].Secret = utils.NewRandomHash().String()
+	_, err = parsePackage(c, thirdParty)
+	assert.NotNil(t, err, "secret doesn't match lock")
+
+	c = newTestPackage(keys, thirdParty, secret)
+	c.UpdateTransfer.Nonce = 0
+	_, err = parsePackage(c, thirdParty)
+	assert.NotNil(t, err, "nothing to update")
+
+	c = newTestPackage(keys, thirdParty, secret)
+	c.UpdateTransfer.TransferAmount = big.NewInt(11)
+	_, err = parsePackage(c, thirdParty)
+	assert.NotNil(t, err, "balance proof changed after signed")
+
+	c = newTestPackage(keys, thirdParty, secret)
+	c.OpenBlockNumber = 31
+	_, err = parsePackage(c, thirdParty)
+	assert.NotNil(t, err, "signed for another channel")
+
+	c = newTestPackage(keys, thirdParty, secret)
+	other := newTestPackage(keys, thirdParty, secret)
+	c.UpdateTransfer.NonClosingSignature = other.UpdateTransfer.NonClosingSignature
+	_, err = parsePackage(c, thirdParty)
+	assert.NotNil(t, err, "update message signed for another balance proof")
+
+	//balance proof is signed by someone else
+	c = newTestPackage(&testKeys{partner: keys.participant, participant: keys.participant}, thirdParty, secret)
+	c.PartnerAddress = utils.NewRandomAddress().String()
+	_, err = parsePackage(c, thirdParty)
+	assert.NotNil(t, err, "balance proof not signed by partner")
+
+	c = newTestPackage(keys, thirdParty, secret)
+	c.Withdraws[0].Signature = other.Withdraws[0].Signature
+	_, err = parsePackage(c, thirdParty)
+	assert.NotNil(t, err, "unlock signed for another channel")
+
+	//unlock signed for another third party
+	c = newTestPackage(keys, utils.NewRandomAddress(), secret)
+	c.ThirdPartyAddress = thirdParty.String()
+	_, err = parsePackage(c, thirdParty)
+	assert.NotNil(t, err, "unlock signed for another third party")
+}
+
+func TestIngestReplace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watchtower")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keys := newTestKeys()
+	thirdParty := utils.NewRandomAddress()
+	wt := newWatchTower(&rpc.BlockChainService{NodeAddress: thirdParty}, dir)
+	c := newTestPackage(keys, thirdParty, utils.NewRandomHash())
+	err = wt.Ingest(c)
+	if !assert.Nil(t, err) {
+		return
+	}
+	key := packageKey{common.HexToHash(c.ChannelAddress), common.HexToAddress(c.ParticipantAddress)}
+	old := wt.packages[key]
+	//a newer package replaces the one being handled
+	c.Withdraws = append(c.Withdraws, c.Withdraws[0])
+	err = wt.Ingest(c)
+	if !assert.Nil(t, err) {
+		return
+	}
+	wt.updatePackage(old, func() {
+		t.Error("replaced package should not be updated")
+	})
+	wt.remove(old)
+	assert.NotNil(t, wt.packages[key], "replaced package should not remove the new one")
+	wt.remove(wt.packages[key])
+	assert.Nil(t, wt.packages[key])
+	_, err = os.Stat(wt.packageFile(key))
+	assert.True(t, os.IsNotExist(err))
+}
+
+//both participants of a channel can use one watchtower, nobody else can
+func TestIngestParticipants(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watchtower")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keys := newTestKeys()
+	thirdParty := utils.NewRandomAddress()
+	channel := utils.NewRandomHash()
+	tokenNetwork := utils.NewRandomAddress()
+	wt := newWatchTower(&rpc.BlockChainService{NodeAddress: thirdParty}, dir)
+	err = wt.Ingest(newTestChannelPackage(keys, thirdParty, utils.NewRandomHash(), channel, tokenNetwork))
+	if !assert.Nil(t, err) {
+		return
+	}
+	//partner's package of the same channel
+	err = wt.Ingest(newTestChannelPackage(&testKeys{partner: keys.participant, participant: keys.partner}, thirdParty, utils.NewRandomHash(), channel, tokenNetwork))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, 2, len(wt.packages))
+	for key := range wt.packages {
+		info, err := os.Stat(wt.packageFile(key))
+		if assert.Nil(t, err) {
+			assert.EqualValues(t, 0600, info.Mode().Perm())
+		}
+	}
+	stranger, _ := crypto.GenerateKey()
+	err = wt.Ingest(newTestChannelPackage(&testKeys{partner: keys.partner, participant: stranger}, thirdParty, utils.NewRandomHash(), channel, tokenNetwork))
+	assert.NotNil(t, err, "participant is not in the channel")
+	err = wt.Ingest(newTestChannelPackage(&testKeys{partner: stranger, participant: keys.participant}, thirdParty, utils.NewRandomHash(), channel, tokenNetwork))
+	assert.NotNil(t, err, "partner is not in the channel")
+	err = wt.Ingest(newTestChannelPackage(keys, thirdParty, utils.NewRandomHash(), channel, utils.NewRandomAddress()))
+	assert.NotNil(t, err, "channel of another token network")
+	assert.Equal(t, 2, len(wt.packages))
+
+	//packages are loaded after restart
+	wt2 := newWatchTower(&rpc.BlockChainService{NodeAddress: thirdParty}, dir)
+	err = wt2.load()
+	if assert.Nil(t, err) {
+		assert.Equal(t, 2, len(wt2.packages))
+	}
+}
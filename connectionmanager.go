@@ -0,0 +1,383 @@
+package smartraiden
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/channel/channeltype"
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/SmartMeshFoundation/SmartRaiden/params"
+	"github.com/SmartMeshFoundation/SmartRaiden/rerr"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+ConnectionManager handles the channel creation/joining for a single token network.
+joinableFundsTarget of funds is reserved for channels opened by other nodes with us,
+the rest is split equally between initialChannelTarget partners.
+lock only protects fields, it's never held while waiting for the chain.
+*/
+type ConnectionManager struct {
+	api                  *RaidenAPI
+	tokenAddress         common.Address
+	lock                 sync.Mutex
+	funds                *big.Int
+	reserved             *big.Int //funds being deposited but not confirmed yet
+	initialChannelTarget int
+	joinableFundsTarget  float64
+	leaving              bool
+}
+
+//ConnectionDetail summary of a joined token network
+type ConnectionDetail struct {
+	Funds       *big.Int `json:"funds"`
+	SumDeposits *big.Int `json:"sum_deposits"`
+	Channels    int      `json:"channels"`
+}
+
+//NewConnectionManager create a connection manager for `tokenAddress`
+func NewConnectionManager(api *RaidenAPI, tokenAddress common.Address) *ConnectionManager {
+	return &ConnectionManager{
+		api:                  api,
+		tokenAddress:         tokenAddress,
+		funds:                big.NewInt(0),
+		reserved:             big.NewInt(0),
+		initialChannelTarget: params.DefaultInitialChannelTarget,
+		joinableFundsTarget:  params.DefaultJoinableFundsTarget,
+	}
+}
+
+/*
+Connect to the token network.
+open `initialChannelTarget` channels with the best connected nodes,
+funds which are not used here will be used to join channels opened by other nodes.
+*/
+func (cm *ConnectionManager) Connect(funds *big.Int, initialChannelTarget int, joinableFundsTarget float64) (err error) {
+	if funds == nil || funds.Cmp(utils.BigInt0) <= 0 {
+		return rerr.ErrInvalidAmount
+	}
+	if initialChannelTarget <= 0 {
+		initialChannelTarget = params.DefaultInitialChannelTarget
+	}
+	if joinableFundsTarget < 0 || joinableFundsTarget > 1 {
+		joinableFundsTarget = params.DefaultJoinableFundsTarget
+	}
+	cm.lock.Lock()
+	cm.funds = new(big.Int).Set(funds)
+	cm.initialChannelTarget = initialChannelTarget
+	cm.joinableFundsTarget = joinableFundsTarget
+	cm.leaving = false
+	cm.lock.Unlock()
+	err = cm.api.Raiden.db.SaveConnectionSetting(&models.ConnectionSetting{
+		Token:                cm.tokenAddress,
+		Funds:                funds,
+		InitialChannelTarget: initialChannelTarget,
+		JoinableFundsTarget:  joinableFundsTarget,
+	})
+	if err != nil {
+		return
+	}
+	return cm.addNewPartners()
+}
+
+//restore the setting saved by Connect, channels are not opened here
+func (cm *ConnectionManager) restore(s *models.ConnectionSetting) {
+	cm.lock.Lock()
+	cm.funds = new(big.Int).Set(s.Funds)
+	cm.initialChannelTarget = s.InitialChannelTarget
+	cm.joinableFundsTarget = s.JoinableFundsTarget
+	cm.lock.Unlock()
+}
+
+//LeaveError lists channels failed to be left, others have been left
+type LeaveError struct {
+	Failed map[common.Hash]error
+}
+
+func (e *LeaveError) Error() string {
+	var ss []string
+	for c, err := range e.Failed {
+		ss = append(ss, fmt.Sprintf("%s:%s", c.String(), err))
+	}
+	sort.Strings(ss)
+	return fmt.Sprintf("failed to leave channels %s", strings.Join(ss, ","))
+}
+
+/*
+Leave the token network.
+channels are cooperative settled if partner agrees, otherwise closed,
+settle of closed channels is left to user after settle timeout.
+if onlyReceiving is true, only channels which have received tokens are left.
+a channel failed to be closed doesn't stop leaving others, err is *LeaveError then.
+*/
+func (cm *ConnectionManager) Leave(onlyReceiving bool) (channels []common.Hash, err error) {
+	cm.lock.Lock()
+	cm.leaving = true
+	cm.funds = big.NewInt(0)
+	cm.lock.Unlock()
+	chs, err := cm.openChannels()
+	if err != nil {
+		return
+	}
+	failed := make(map[common.Hash]error)
+	for _, c := range chs {
+		if onlyReceiving && (c.PartnerBalanceProof == nil || c.PartnerBalanceProof.TransferAmount == nil ||
+			c.PartnerBalanceProof.TransferAmount.Cmp(utils.BigInt0) <= 0) {
+			continue
+		}
+		_, err = cm.api.CooperativeSettle(cm.tokenAddress, c.PartnerAddress())
+		if err != nil {
+			log.Info(fmt.Sprintf("cooperative settle %s err %s, try to close it", c.ChannelIdentifier, err))
+			_, err = cm.api.Close(cm.tokenAddress, c.PartnerAddress())
+			if err != nil {
+				log.Error(fmt.Sprintf("close channel %s err %s", c.ChannelIdentifier, err))
+				failed[c.ChannelIdentifier.ChannelIdentifier] = err
+				continue
+			}
+		}
+		channels = append(channels, c.ChannelIdentifier.ChannelIdentifier)
+	}
+	err = nil
+	if len(failed) > 0 {
+		err = &LeaveError{Failed: failed}
+	}
+	return
+}
+
+/*
+JoinChannel deposit to a channel opened by partner with us.
+we will deposit at most the same amount as partner's deposit.
+*/
+func (cm *ConnectionManager) JoinChannel(partnerAddress common.Address, partnerDeposit *big.Int) (err error) {
+	c, err := cm.api.GetChannelList(cm.tokenAddress, partnerAddress)
+	if err != nil || len(c) == 0 {
+		return
+	}
+	if c[0].State != channeltype.StateOpened || c[0].OurContractBalance.Cmp(utils.BigInt0) > 0 {
+		return
+	}
+	cm.lock.Lock()
+	if cm.leaving || cm.funds.Cmp(utils.BigInt0) <= 0 {
+		cm.lock.Unlock()
+		return
+	}
+	joiningFunds := cm.joinableFundsPerPartner()
+	if partnerDeposit.Cmp(joiningFunds) < 0 {
+		joiningFunds = new(big.Int).Set(partnerDeposit)
+	}
+	remaining, err := cm.fundsRemaining()
+	if err != nil {
+		cm.lock.Unlock()
+		return
+	}
+	if remaining.Cmp(joiningFunds) < 0 {
+		joiningFunds = remaining
+	}
+	if joiningFunds.Cmp(utils.BigInt0) <= 0 {
+		cm.lock.Unlock()
+		return
+	}
+	cm.reserve(joiningFunds)
+	cm.lock.Unlock()
+	_, err = cm.api.Deposit(cm.tokenAddress, partnerAddress, joiningFunds, params.DefaultPollTimeout)
+	cm.release(joiningFunds)
+	log.Info(fmt.Sprintf("joined a channel token=%s,partner=%s,funds=%s,err=%v",
+		utils.APex2(cm.tokenAddress), utils.APex2(partnerAddress), joiningFunds, err))
+	return
+}
+
+//Detail returns funds and deposits of this token network
+func (cm *ConnectionManager) Detail() (d *ConnectionDetail, err error) {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+	chs, err := cm.openChannels()
+	if err != nil {
+		return
+	}
+	d = &ConnectionDetail{
+		Funds:       new(big.Int).Set(cm.funds),
+		SumDeposits: sumDeposits(chs),
+		Channels:    len(chs),
+	}
+	return
+}
+
+/*
+addNewPartners opens channels with new partners until there are initialChannelTarget channels,
+a partner failed to be opened with doesn't stop others, err is returned only when all failed.
+*/
+func (cm *ConnectionManager) addNewPartners() error {
+	chs, err := cm.openChannels()
+	if err != nil {
+		return err
+	}
+	cm.lock.Lock()
+	newPartnerCount := cm.initialChannelTarget - len(chs)
+	cm.lock.Unlock()
+	if newPartnerCount <= 0 {
+		return nil
+	}
+	edges, err := cm.api.Raiden.db.GetAllNonParticipantChannel(cm.tokenAddress)
+	if err != nil {
+		return err
+	}
+	exclude := map[common.Address]bool{cm.api.Address(): true}
+	//channels may be closed but not settled.
+	allChannels, err := cm.api.GetChannelList(cm.tokenAddress, utils.EmptyAddress)
+	if err != nil {
+		return err
+	}
+	for _, c := range allChannels {
+		exclude[c.PartnerAddress()] = true
+	}
+	partners := findNewPartners(edges, exclude, newPartnerCount)
+	if len(partners) == 0 {
+		log.Info(fmt.Sprintf("no partner available for token %s, wait for others to join", utils.APex2(cm.tokenAddress)))
+		return nil
+	}
+	cm.lock.Lock()
+	deposit := cm.initialFundingPerPartner()
+	total := new(big.Int).Mul(deposit, big.NewInt(int64(len(partners))))
+	cm.reserve(total)
+	cm.lock.Unlock()
+	defer cm.release(total)
+	opened := 0
+	for _, p := range partners {
+		_, err = cm.api.Open(cm.tokenAddress, p, 0, 0, deposit)
+		if err != nil {
+			log.Error(fmt.Sprintf("connection manager open channel with %s err %s", utils.APex2(p), err))
+			continue
+		}
+		opened++
+	}
+	if opened == 0 {
+		return err
+	}
+	return nil
+}
+
+//funds * (1-joinableFundsTarget) / initialChannelTarget
+func (cm *ConnectionManager) initialFundingPerPartner() *big.Int {
+	return cm.fundsPart(1-cm.joinableFundsTarget, cm.initialChannelTarget)
+}
+
+//funds * joinableFundsTarget / initialChannelTarget
+func (cm *ConnectionManager) joinableFundsPerPartner() *big.Int {
+	return cm.fundsPart(cm.joinableFundsTarget, cm.initialChannelTarget)
+}
+
+func (cm *ConnectionManager) fundsPart(ratio float64, parts int) *big.Int {
+	f := new(big.Float).SetInt(cm.funds)
+	f.Mul(f, big.NewFloat(ratio))
+	f.Quo(f, big.NewFloat(float64(parts)))
+	i, _ := f.Int(nil)
+	return i
+}
+
+//fundsRemaining must be called with lock held
+func (cm *ConnectionManager) fundsRemaining() (*big.Int, error) {
+	chs, err := cm.openChannels()
+	if err != nil {
+		return nil, err
+	}
+	remaining := new(big.Int).Sub(cm.funds, sumDeposits(chs))
+	return remaining.Sub(remaining, cm.reserved), nil
+}
+
+//reserve must be called with lock held
+func (cm *ConnectionManager) reserve(amount *big.Int) {
+	cm.reserved = new(big.Int).Add(cm.reserved, amount)
+}
+
+func (cm *ConnectionManager) release(amount *big.Int) {
+	cm.lock.Lock()
+	cm.reserved = new(big.Int).Sub(cm.reserved, amount)
+	cm.lock.Unlock()
+}
+
+func (cm *ConnectionManager) openChannels() (chs []*channeltype.Serialization, err error) {
+	all, err := cm.api.GetChannelList(cm.tokenAddress, utils.EmptyAddress)
+	if err != nil {
+		return
+	}
+	for _, c := range all {
+		if c.State == channeltype.StateOpened {
+			chs = append(chs, c)
+		}
+	}
+	return
+}
+
+func sumDeposits(chs []*channeltype.Serialization) *big.Int {
+	sum := big.NewInt(0)
+	for _, c := range chs {
+		if c.OurContractBalance != nil {
+			sum.Add(sum, c.OurContractBalance)
+		}
+	}
+	return sum
+}
+
+/*
+findNewPartners returns at most `number` nodes,ordered by how many channels they have.
+edges is a list of participant pairs like GetAllNonParticipantChannel returns.
+*/
+func findNewPartners(edges []common.Address, exclude map[common.Address]bool, number int) (partners []common.Address) {
+	degree := make(map[common.Address]int)
+	for _, n := range edges {
+		if exclude[n] {
+			continue
+		}
+		degree[n]++
+	}
+	for n := range degree {
+		partners = append(partners, n)
+	}
+	sort.Slice(partners, func(i, j int) bool {
+		if degree[partners[i]] != degree[partners[j]] {
+			return degree[partners[i]] > degree[partners[j]]
+		}
+		return bytes.Compare(partners[i][:], partners[j][:]) < 0
+	})
+	if len(partners) > number {
+		partners = partners[:number]
+	}
+	return
+}
+
+//restoreConnectionManagers creates connection managers of token networks we joined before restart
+func (rs *RaidenService) restoreConnectionManagers() {
+	ss, err := rs.db.GetConnectionSettings()
+	if err != nil {
+		log.Error(fmt.Sprintf("GetConnectionSettings err %s", err))
+		return
+	}
+	api := NewRaidenAPI(rs)
+	rs.connectionManagerLock.Lock()
+	defer rs.connectionManagerLock.Unlock()
+	for _, s := range ss {
+		cm := NewConnectionManager(api, s.Token)
+		cm.restore(s)
+		rs.Token2ConnectionManager[s.Token] = cm
+	}
+}
+
+//startConnectionManagers opens channels which were not opened before restart
+func (rs *RaidenService) startConnectionManagers() {
+	rs.connectionManagerLock.Lock()
+	defer rs.connectionManagerLock.Unlock()
+	for _, cm := range rs.Token2ConnectionManager {
+		go func(cm *ConnectionManager) {
+			err := cm.addNewPartners()
+			if err != nil {
+				log.Error(fmt.Sprintf("connection manager of token %s err %s", utils.APex2(cm.tokenAddress), err))
+			}
+		}(cm)
+	}
+}
@@ -0,0 +1,37 @@
+package smartraiden
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestFindNewPartners(t *testing.T) {
+	a, b, c, d := utils.NewRandomAddress(), utils.NewRandomAddress(), utils.NewRandomAddress(), utils.NewRandomAddress()
+	//b has three channels, c has two, a and d have one
+	edges := []common.Address{a, b, b, c, c, b, d, b}
+	partners := findNewPartners(edges, map[common.Address]bool{}, 2)
+	if len(partners) != 2 || partners[0] != b || partners[1] != c {
+		t.Errorf("partners should be b,c, but got %v", partners)
+	}
+	partners = findNewPartners(edges, map[common.Address]bool{b: true}, 5)
+	if len(partners) != 3 || partners[0] != c {
+		t.Errorf("partners should start with c and have 3 nodes, but got %v", partners)
+	}
+	partners = findNewPartners(nil, map[common.Address]bool{}, 3)
+	if len(partners) != 0 {
+		t.Errorf("no partner expected")
+	}
+}
+
+func TestLeaveError(t *testing.T) {
+	c1, c2 := common.HexToHash("0x02"), common.HexToHash("0x01")
+	err := &LeaveError{Failed: map[common.Hash]error{c1: errors.New("b"), c2: errors.New("a")}}
+	expected := fmt.Sprintf("failed to leave channels %s:a,%s:b", c2.String(), c1.String())
+	if err.Error() != expected {
+		t.Errorf("expect %s, got %s", expected, err.Error())
+	}
+}
@@ -0,0 +1,445 @@
+/*
+Package console is an interactive console of a node,
+it talks to the node by json-rpc, either in process or attached by ipc.
+*/
+package console
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var errArgs = errors.New("wrong arguments")
+
+type command struct {
+	name string
+	args string //usage of arguments
+	help string
+	run  func(c *Console, args []string) error
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{"help", "", "show this help", (*Console).help},
+		{"address", "", "address of this node", callNoArgs("raiden_address")},
+		{"tokens", "", "registered tokens and their token networks", callNoArgs("raiden_tokens")},
+		{"channels", "[token] [partner]", "list channels", (*Console).channels},
+		{"channel", "<channel>", "show a channel", (*Console).channel},
+		{"open", "<token> <partner> <deposit> [settle_timeout]", "open a channel and deposit to it", (*Console).open},
+		{"deposit", "<token> <partner> <amount>", "deposit to a channel", channelAmountCall("raiden_deposit")},
+		{"close", "<token> <partner> [force]", "cooperative settle a channel, close it if force", (*Console).close},
+		{"settle", "<token> <partner>", "settle a closed channel", channelCall("raiden_settle")},
+		{"withdraw", "<token> <partner> <amount>", "withdraw from a channel", channelAmountCall("raiden_withdraw")},
+		{"transfer", "<token> <target> <amount> [fee]", "send a transfer and wait for it", (*Console).transfer},
+		{"transferasync", "<token> <target> <amount> [fee]", "send a transfer without waiting", (*Console).transferAsync},
+		{"status", "<lock_secret_hash>", "status of an async transfer", hashCall("raiden_getTransferStatus")},
+		{"cancel", "<lock_secret_hash>", "cancel an async transfer", hashCall("raiden_cancelTransfer")},
+		{"path", "<token> <target> <amount>", "paths a transfer would use", (*Console).path},
+		{"pending", "", "transfers in progress (state managers)", callNoArgs("raiden_getStateManagers")},
+		{"sent", "[from_block] [to_block]", "sent transfers", blockRangeCall("raiden_getSentTransfers")},
+		{"received", "[from_block] [to_block]", "received transfers", blockRangeCall("raiden_getReceivedTransfers")},
+		{"events", "[from_block] [to_block]", "events recorded by this node", blockRangeCall("raiden_getInternalEvents")},
+		{"call", "<method> [json args...]", "call any json-rpc method, such as call raiden_getFeePolicy", (*Console).call},
+		{"exit", "", "quit the console", nil},
+	}
+}
+
+//Console reads commands and prints results
+type Console struct {
+	client *rpc.Client
+	reader lineReader
+	out    io.Writer
+	prompt string
+}
+
+/*
+New create a console of client reading from stdin,
+line editing and history are enabled when stdin is a terminal, histFile is empty means no history file.
+*/
+func New(client *rpc.Client, histFile string) *Console {
+	c := &Console{
+		client: client,
+		out:    os.Stdout,
+		prompt: "> ",
+	}
+	fd := int(os.Stdin.Fd())
+	if isTerminal(fd) {
+		c.reader = newLineEditor(fd, os.Stdin, os.Stdout, histFile, c.complete)
+	} else {
+		c.reader = &plainReader{in: bufio.NewReader(os.Stdin), out: os.Stdout}
+	}
+	return c
+}
+
+//Run read and execute commands until exit or EOF
+func (c *Console) Run() {
+	var addr common.Address
+	err := c.client.Call(&addr, "raiden_address")
+	if err != nil {
+		fmt.Fprintf(c.out, "cannot talk to the node: %s\n", err)
+		return
+	}
+	fmt.Fprintf(c.out, "Welcome to the SmartRaiden console, node %s\ntype help for commands, tab to complete\n", addr.String())
+	for {
+		line, err := c.reader.Prompt(c.prompt)
+		if err == errInterrupt {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		c.reader.AppendHistory(line)
+		if line == "exit" || line == "quit" {
+			return
+		}
+		err = c.Execute(line)
+		if err != nil {
+			fmt.Fprintf(c.out, "error: %s\n", err)
+		}
+	}
+}
+
+//Execute a command line
+func (c *Console) Execute(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	for _, cmd := range commands {
+		if cmd.name == fields[0] && cmd.run != nil {
+			err := cmd.run(c, fields[1:])
+			if err == errArgs {
+				return fmt.Errorf("usage: %s %s", cmd.name, cmd.args)
+			}
+			return err
+		}
+	}
+	return fmt.Errorf("unknown command %s, type help for commands", fields[0])
+}
+
+//callAndPrint call method and print the result as indented json
+func (c *Console) callAndPrint(method string, args ...interface{}) error {
+	var result json.RawMessage
+	err := c.client.Call(&result, method, args...)
+	if err != nil {
+		return err
+	}
+	if len(result) == 0 || string(result) == "null" {
+		fmt.Fprintln(c.out, "ok")
+		return nil
+	}
+	var buf bytes.Buffer
+	err = json.Indent(&buf, result, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, buf.String())
+	return nil
+}
+
+func (c *Console) help(args []string) error {
+	for _, cmd := range commands {
+		fmt.Fprintf(c.out, "  %-14s%-48s%s\n", cmd.name, cmd.args, cmd.help)
+	}
+	return nil
+}
+
+func parseAddress(s string) (addr common.Address, err error) {
+	if !common.IsHexAddress(s) {
+		err = fmt.Errorf("invalid address %s", s)
+		return
+	}
+	return common.HexToAddress(s), nil
+}
+
+func parseHash(s string) (h common.Hash, err error) {
+	if len(s) != len(utils.EmptyHash.String()) {
+		err = fmt.Errorf("invalid hash %s", s)
+		return
+	}
+	return common.HexToHash(s), nil
+}
+
+func parseAmount(s string) (*big.Int, error) {
+	n, ok := new(big.Int).SetString(s, 0)
+	if !ok || n.Sign() < 0 {
+		return nil, fmt.Errorf("invalid amount %s", s)
+	}
+	return n, nil
+}
+
+//parseArgs parse args by kinds, a is address, h is hash, n is amount, i is integer, s is string
+func parseArgs(args []string, kinds string) (vs []interface{}, err error) {
+	for i, a := range args {
+		if i >= len(kinds) {
+			return nil, errArgs
+		}
+		var v interface{}
+		switch kinds[i] {
+		case 'a':
+			v, err = parseAddress(a)
+		case 'h':
+			v, err = parseHash(a)
+		case 'n':
+			v, err = parseAmount(a)
+		case 'i':
+			v, err = strconv.ParseInt(a, 10, 64)
+		default:
+			v = a
+		}
+		if err != nil {
+			return
+		}
+		vs = append(vs, v)
+	}
+	return
+}
+
+func callNoArgs(method string) func(c *Console, args []string) error {
+	return func(c *Console, args []string) error {
+		if len(args) != 0 {
+			return errArgs
+		}
+		return c.callAndPrint(method)
+	}
+}
+
+func hashCall(method string) func(c *Console, args []string) error {
+	return func(c *Console, args []string) error {
+		if len(args) != 1 {
+			return errArgs
+		}
+		vs, err := parseArgs(args, "h")
+		if err != nil {
+			return err
+		}
+		return c.callAndPrint(method, vs...)
+	}
+}
+
+//channelCall methods of token and partner
+func channelCall(method string) func(c *Console, args []string) error {
+	return func(c *Console, args []string) error {
+		if len(args) != 2 {
+			return errArgs
+		}
+		vs, err := parseArgs(args, "aa")
+		if err != nil {
+			return err
+		}
+		return c.callAndPrint(method, vs...)
+	}
+}
+
+//channelAmountCall methods of token, partner and amount
+func channelAmountCall(method string) func(c *Console, args []string) error {
+	return func(c *Console, args []string) error {
+		if len(args) != 3 {
+			return errArgs
+		}
+		vs, err := parseArgs(args, "aan")
+		if err != nil {
+			return err
+		}
+		return c.callAndPrint(method, vs...)
+	}
+}
+
+func blockRangeCall(method string) func(c *Console, args []string) error {
+	return func(c *Console, args []string) error {
+		vs, err := parseArgs(args, "ii")
+		if err != nil {
+			return err
+		}
+		for len(vs) < 2 {
+			vs = append(vs, int64(-1))
+		}
+		return c.callAndPrint(method, vs...)
+	}
+}
+
+func (c *Console) channels(args []string) error {
+	vs, err := parseArgs(args, "aa")
+	if err != nil {
+		return err
+	}
+	for len(vs) < 2 {
+		vs = append(vs, utils.EmptyAddress)
+	}
+	return c.callAndPrint("raiden_getChannelList", vs...)
+}
+
+func (c *Console) channel(args []string) error {
+	return hashCall("raiden_getChannel")(c, args)
+}
+
+func (c *Console) open(args []string) error {
+	if len(args) < 3 {
+		return errArgs
+	}
+	vs, err := parseArgs(args, "aani")
+	if err != nil {
+		return err
+	}
+	settleTimeout := 0 //default of the node
+	if len(vs) > 3 {
+		settleTimeout = int(vs[3].(int64))
+	}
+	return c.callAndPrint("raiden_open", vs[0], vs[1], settleTimeout, vs[2])
+}
+
+func (c *Console) close(args []string) error {
+	if len(args) != 2 && len(args) != 3 {
+		return errArgs
+	}
+	if len(args) == 3 && args[2] != "force" {
+		return errArgs
+	}
+	vs, err := parseArgs(args[:2], "aa")
+	if err != nil {
+		return err
+	}
+	return c.callAndPrint("raiden_close", vs[0], vs[1], len(args) == 3)
+}
+
+func (c *Console) transferArgs(args []string) (vs []interface{}, err error) {
+	if len(args) != 3 && len(args) != 4 {
+		return nil, errArgs
+	}
+	vs, err = parseArgs(args, "aann")
+	if err != nil {
+		return
+	}
+	if len(vs) < 4 {
+		vs = append(vs, big.NewInt(0))
+	}
+	return
+}
+
+func (c *Console) transfer(args []string) error {
+	vs, err := c.transferArgs(args)
+	if err != nil {
+		return err
+	}
+	return c.callAndPrint("raiden_transfer", append(vs, utils.EmptyHash, false)...)
+}
+
+func (c *Console) transferAsync(args []string) error {
+	vs, err := c.transferArgs(args)
+	if err != nil {
+		return err
+	}
+	return c.callAndPrint("raiden_transferAsync", append(vs, utils.EmptyHash, false)...)
+}
+
+func (c *Console) path(args []string) error {
+	if len(args) != 3 {
+		return errArgs
+	}
+	vs, err := parseArgs(args, "aan")
+	if err != nil {
+		return err
+	}
+	return c.callAndPrint("raiden_findPath", vs...)
+}
+
+func (c *Console) call(args []string) error {
+	if len(args) < 1 {
+		return errArgs
+	}
+	var vs []interface{}
+	for _, a := range args[1:] {
+		var v interface{}
+		if json.Unmarshal([]byte(a), &v) != nil {
+			v = a //not json, such as an address
+		}
+		vs = append(vs, v)
+	}
+	return c.callAndPrint(args[0], vs...)
+}
+
+/*
+complete the last word of head,
+the first word is a command, the others may be tokens, partners or channels of this node.
+*/
+func (c *Console) complete(head string) []string {
+	words := strings.Split(head, " ")
+	word := words[len(words)-1]
+	if len(words) == 1 {
+		var names []string
+		for _, cmd := range commands {
+			names = append(names, cmd.name)
+		}
+		return completeWord(word, names)
+	}
+	if words[0] == "call" && len(words) == 2 {
+		return completeWord(word, c.methods())
+	}
+	return completeWord(word, c.knownAddresses())
+}
+
+//knownAddresses returns tokens, partners and channels of this node
+func (c *Console) knownAddresses() []string {
+	m := make(map[string]bool)
+	var tokens map[common.Address]common.Address
+	if c.client.Call(&tokens, "raiden_tokens") == nil {
+		for t := range tokens {
+			m[t.String()] = true
+		}
+	}
+	var chs []struct {
+		ChannelAddress string `json:"channel_address"`
+		PartnerAddress string `json:"partner_address"`
+	}
+	if c.client.Call(&chs, "raiden_getChannelList", utils.EmptyAddress, utils.EmptyAddress) == nil {
+		for _, ch := range chs {
+			m[ch.ChannelAddress] = true
+			m[ch.PartnerAddress] = true
+		}
+	}
+	var ret []string
+	for a := range m {
+		ret = append(ret, a)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+//methods returns json-rpc methods of the raiden namespace
+func (c *Console) methods() []string {
+	return []string{
+		"raiden_address", "raiden_tokens", "raiden_registerToken", "raiden_getChannelList", "raiden_getChannel",
+		"raiden_open", "raiden_deposit", "raiden_close", "raiden_settle", "raiden_withdraw",
+		"raiden_prepareForWithdraw", "raiden_cancelPrepareForWithdraw",
+		"raiden_prepareForCooperativeSettle", "raiden_cancelPrepareForCooperativeSettle",
+		"raiden_connectTokenNetwork", "raiden_leaveTokenNetwork", "raiden_getConnectionsInfo",
+		"raiden_transfer", "raiden_transferAsync", "raiden_getTransferStatus", "raiden_cancelTransfer",
+		"raiden_publicKey", "raiden_keysend", "raiden_keysendAsync",
+		"raiden_getStateManagers", "raiden_findPath", "raiden_rebalance", "raiden_tokenSwap",
+		"raiden_getSentTransfers", "raiden_getReceivedTransfers", "raiden_querySentTransfers", "raiden_queryReceivedTransfers",
+		"raiden_getNetworkEvents", "raiden_getTokenNetworkEvents", "raiden_getChannelEvents", "raiden_getInternalEvents", "raiden_getPunishments",
+		"raiden_getFeePolicy", "raiden_setFeePolicy",
+		"raiden_getDepositPolicy", "raiden_setDepositPolicy", "raiden_getAutoDeposits",
+		"raiden_createInvoice", "raiden_getInvoices", "raiden_getInvoice", "raiden_decodeInvoice", "raiden_payInvoice",
+		"raiden_createHoldInvoice", "raiden_acceptInvoice", "raiden_rejectInvoice",
+	}
+}
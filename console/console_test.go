@@ -0,0 +1,100 @@
+package console
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+type ChannelInfo struct {
+	ChannelAddress string `json:"channel_address"`
+	PartnerAddress string `json:"partner_address"`
+}
+
+//FakeRaiden is the raiden namespace with a few methods
+type FakeRaiden struct {
+	token, partner common.Address
+	channel        common.Hash
+	force          bool
+	amount, fee    *big.Int
+}
+
+func (f *FakeRaiden) Address() common.Address {
+	return f.partner
+}
+
+func (f *FakeRaiden) Tokens() map[common.Address]common.Address {
+	return map[common.Address]common.Address{f.token: utils.NewRandomAddress()}
+}
+
+func (f *FakeRaiden) GetChannelList(token, partner common.Address) []*ChannelInfo {
+	return []*ChannelInfo{{f.channel.String(), f.partner.String()}}
+}
+
+func (f *FakeRaiden) Close(token, partner common.Address, force bool) (*ChannelInfo, error) {
+	f.force = force
+	return &ChannelInfo{f.channel.String(), partner.String()}, nil
+}
+
+func (f *FakeRaiden) Transfer(token, target common.Address, amount, fee *big.Int, lockSecretHash common.Hash, isDirect bool) error {
+	f.amount, f.fee = amount, fee
+	return nil
+}
+
+func newTestConsole(t *testing.T) (*Console, *FakeRaiden, *bytes.Buffer) {
+	f := &FakeRaiden{
+		token:   utils.NewRandomAddress(),
+		partner: utils.NewRandomAddress(),
+		channel: utils.NewRandomHash(),
+	}
+	srv := rpc.NewServer()
+	err := srv.RegisterName("raiden", f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := new(bytes.Buffer)
+	return &Console{client: rpc.DialInProc(srv), out: out}, f, out
+}
+
+func TestConsoleExecute(t *testing.T) {
+	c, f, out := newTestConsole(t)
+	err := c.Execute("close " + f.token.String() + " " + f.partner.String() + " force")
+	assert.Nil(t, err)
+	assert.EqualValues(t, true, f.force)
+	assert.Contains(t, out.String(), f.channel.String())
+
+	out.Reset()
+	err = c.Execute("transfer " + f.token.String() + " " + f.partner.String() + " 10")
+	assert.Nil(t, err)
+	assert.EqualValues(t, big.NewInt(10), f.amount)
+	assert.EqualValues(t, big.NewInt(0), f.fee)
+	assert.EqualValues(t, "ok\n", out.String())
+
+	err = c.Execute("transfer " + f.token.String())
+	assert.True(t, strings.HasPrefix(err.Error(), "usage: transfer"))
+	err = c.Execute("close 0x12 " + f.partner.String())
+	assert.NotNil(t, err)
+	err = c.Execute("nosuchcommand")
+	assert.NotNil(t, err)
+}
+
+func TestConsoleComplete(t *testing.T) {
+	c, f, _ := newTestConsole(t)
+	assert.EqualValues(t, []string{"close"}, c.complete("cl"))
+	assert.EqualValues(t, []string{f.channel.String()}, c.complete("channel "+f.channel.String()[:10]))
+	assert.EqualValues(t, []string{f.token.String()}, c.complete("deposit "+strings.ToLower(f.token.String()[:12])))
+
+	e := &lineEditor{out: new(bytes.Buffer), complete: c.complete}
+	buf, pos := e.tab([]rune("ch"), 2)
+	assert.EqualValues(t, "channel", string(buf))
+	assert.EqualValues(t, 7, pos)
+	buf, pos = e.tab([]rune("clo x"), 3)
+	assert.EqualValues(t, "close x", string(buf))
+	assert.EqualValues(t, 5, pos)
+}
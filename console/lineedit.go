@@ -0,0 +1,256 @@
+package console
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+//maxHistory lines of history kept
+const maxHistory = 1000
+
+//errInterrupt is returned by Prompt when ctrl-c is pressed
+var errInterrupt = errors.New("interrupted")
+
+type lineReader interface {
+	Prompt(prompt string) (string, error)
+	AppendHistory(line string)
+}
+
+//plainReader reads lines without editing, when input is not a terminal
+type plainReader struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (p *plainReader) Prompt(prompt string) (string, error) {
+	fmt.Fprint(p.out, prompt)
+	line, err := p.in.ReadString('\n')
+	if err == io.EOF && len(line) > 0 {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+func (p *plainReader) AppendHistory(line string) {}
+
+/*
+lineEditor reads a line from terminal in raw mode,
+supports moving cursor, history by up and down, and completion by tab.
+*/
+type lineEditor struct {
+	fd       int
+	in       *bufio.Reader
+	out      io.Writer
+	history  []string
+	histFile string
+	complete func(head string) []string //candidates for the last word of head
+}
+
+func newLineEditor(fd int, in io.Reader, out io.Writer, histFile string, complete func(head string) []string) *lineEditor {
+	e := &lineEditor{
+		fd:       fd,
+		in:       bufio.NewReader(in),
+		out:      out,
+		histFile: histFile,
+		complete: complete,
+	}
+	if histFile != "" {
+		data, err := ioutil.ReadFile(histFile)
+		if err == nil {
+			for _, l := range strings.Split(string(data), "\n") {
+				if l != "" {
+					e.history = append(e.history, l)
+				}
+			}
+		}
+		if len(e.history) > maxHistory {
+			e.history = e.history[len(e.history)-maxHistory:]
+		}
+	}
+	return e
+}
+
+//AppendHistory add line to history and history file, repeated line is ignored
+func (e *lineEditor) AppendHistory(line string) {
+	if len(e.history) > 0 && e.history[len(e.history)-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[1:]
+	}
+	if e.histFile == "" {
+		return
+	}
+	f, err := os.OpenFile(e.histFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(f, line)
+	f.Close()
+}
+
+//Prompt read a line, the terminal is in raw mode only while reading
+func (e *lineEditor) Prompt(prompt string) (string, error) {
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+	var buf []rune
+	pos := 0
+	hpos := len(e.history)
+	editing := "" //line being edited before browsing history
+	refresh := func() {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(buf))
+		if n := len(buf) - pos; n > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", n)
+		}
+	}
+	setLine := func(s string) {
+		buf = []rune(s)
+		pos = len(buf)
+	}
+	fmt.Fprint(e.out, prompt)
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\n")
+			return string(buf), nil
+		case 3: //ctrl-c
+			fmt.Fprint(e.out, "^C\n")
+			return "", errInterrupt
+		case 4: //ctrl-d
+			if len(buf) == 0 {
+				fmt.Fprint(e.out, "\n")
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+			}
+		case 127, 8: //backspace
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+			}
+		case 1: //ctrl-a
+			pos = 0
+		case 5: //ctrl-e
+			pos = len(buf)
+		case 11: //ctrl-k
+			buf = buf[:pos]
+		case 21: //ctrl-u
+			buf = buf[pos:]
+			pos = 0
+		case '\t':
+			buf, pos = e.tab(buf, pos)
+		case 27: //escape sequence of arrows
+			r, _, _ = e.in.ReadRune()
+			if r != '[' && r != 'O' {
+				continue
+			}
+			r, _, _ = e.in.ReadRune()
+			switch r {
+			case 'A':
+				if hpos > 0 {
+					if hpos == len(e.history) {
+						editing = string(buf)
+					}
+					hpos--
+					setLine(e.history[hpos])
+				}
+			case 'B':
+				if hpos < len(e.history) {
+					hpos++
+					if hpos == len(e.history) {
+						setLine(editing)
+					} else {
+						setLine(e.history[hpos])
+					}
+				}
+			case 'C':
+				if pos < len(buf) {
+					pos++
+				}
+			case 'D':
+				if pos > 0 {
+					pos--
+				}
+			case 'H':
+				pos = 0
+			case 'F':
+				pos = len(buf)
+			case '3': //delete is ESC [ 3 ~
+				e.in.ReadRune()
+				if pos < len(buf) {
+					buf = append(buf[:pos], buf[pos+1:]...)
+				}
+			}
+		default:
+			if r < 32 {
+				continue
+			}
+			buf = append(buf[:pos], append([]rune{r}, buf[pos:]...)...)
+			pos++
+		}
+		refresh()
+	}
+}
+
+//tab complete the word before cursor, show candidates if there are more than one
+func (e *lineEditor) tab(buf []rune, pos int) ([]rune, int) {
+	if e.complete == nil {
+		return buf, pos
+	}
+	head := string(buf[:pos])
+	start := strings.LastIndex(head, " ") + 1
+	word := head[start:]
+	cands := e.complete(head)
+	if len(cands) == 0 {
+		return buf, pos
+	}
+	prefix := commonPrefix(cands)
+	if len(prefix) > len(word) || (len(cands) == 1 && prefix != word) {
+		nb := []rune(head[:start] + prefix)
+		if len(cands) == 1 && (pos == len(buf) || buf[pos] != ' ') {
+			nb = append(nb, ' ')
+		}
+		return append(nb, buf[pos:]...), len(nb)
+	}
+	if len(cands) > 1 {
+		fmt.Fprintf(e.out, "\n%s\n", strings.Join(cands, "  "))
+	}
+	return buf, pos
+}
+
+//commonPrefix of ss ignoring case, in the case of ss[0]
+func commonPrefix(ss []string) string {
+	p := ss[0]
+	for _, s := range ss[1:] {
+		n := 0
+		for n < len(p) && n < len(s) && strings.EqualFold(p[n:n+1], s[n:n+1]) {
+			n++
+		}
+		p = p[:n]
+	}
+	return p
+}
+
+//completeWord returns candidates starting with word ignoring case
+func completeWord(word string, candidates []string) (ret []string) {
+	for _, c := range candidates {
+		if len(c) >= len(word) && strings.EqualFold(c[:len(word)], word) {
+			ret = append(ret, c)
+		}
+	}
+	return
+}
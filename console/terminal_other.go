@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package console
+
+import "errors"
+
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (restore func(), err error) {
+	return nil, errors.New("line editing is not supported on this platform")
+}
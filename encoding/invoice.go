@@ -0,0 +1,117 @@
+package encoding
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+//InvoiceURIPrefix is the scheme of an encoded invoice
+const InvoiceURIPrefix = "smartraiden:"
+
+//invoiceVersion is the first byte of a packed invoice
+const invoiceVersion = 1
+
+//invoiceMinLength is the length of a packed invoice with empty memo and without signature
+const invoiceMinLength = 1 + 20 + 32 + 8 + 32 + 20 + 1
+
+//ErrInvalidInvoice is returned when an invoice cannot be decoded or its signature doesn't match the payee
+var ErrInvalidInvoice = errors.New("invalid invoice")
+
+/*
+Invoice is a payment request created and signed by the payee.
+the payer pays it with a mediated transfer to Payee locked by LockSecretHash,
+only the payee knows the secret, so the payer learns it when the payee has been paid.
+*/
+type Invoice struct {
+	Token          common.Address
+	Amount         *big.Int //amount the payee should receive
+	Expiration     int64    //unix time in seconds, the payee won't accept payment after it
+	LockSecretHash common.Hash
+	Payee          common.Address
+	Memo           string //at most MaxMemoLength bytes
+	Signature      []byte
+}
+
+func (inv *Invoice) String() string {
+	return fmt.Sprintf("Invoice{Token=%s,Amount=%s,Expiration=%d,LockSecretHash=%s,Payee=%s,Memo=%s}",
+		utils.APex2(inv.Token), inv.Amount, inv.Expiration, utils.HPex(inv.LockSecretHash), utils.APex2(inv.Payee), inv.Memo)
+}
+
+//pack is the data to sign
+func (inv *Invoice) pack() []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteByte(invoiceVersion)
+	buf.Write(inv.Token[:])
+	buf.Write(utils.BigIntTo32Bytes(inv.Amount))
+	binary.Write(buf, binary.BigEndian, inv.Expiration)
+	buf.Write(inv.LockSecretHash[:])
+	buf.Write(inv.Payee[:])
+	buf.WriteByte(byte(len(inv.Memo)))
+	buf.WriteString(inv.Memo)
+	return buf.Bytes()
+}
+
+//Sign the invoice, Payee is the address of privKey
+func (inv *Invoice) Sign(privKey *ecdsa.PrivateKey) (err error) {
+	if len(inv.Memo) > MaxMemoLength {
+		return fmt.Errorf("memo is longer than %d bytes", MaxMemoLength)
+	}
+	if inv.Amount == nil || inv.Amount.Sign() <= 0 {
+		return errors.New("amount should be positive")
+	}
+	inv.Payee = crypto.PubkeyToAddress(privKey.PublicKey)
+	inv.Signature, err = utils.SignData(privKey, inv.pack())
+	return
+}
+
+//URI encodes a signed invoice as a compact string
+func (inv *Invoice) URI() string {
+	data := append(inv.pack(), inv.Signature...)
+	return InvoiceURIPrefix + base64.RawURLEncoding.EncodeToString(data)
+}
+
+//DecodeInvoice decodes uri and verifies that it's signed by the payee
+func DecodeInvoice(uri string) (inv *Invoice, err error) {
+	if !strings.HasPrefix(uri, InvoiceURIPrefix) {
+		return nil, ErrInvalidInvoice
+	}
+	data, err := base64.RawURLEncoding.DecodeString(uri[len(InvoiceURIPrefix):])
+	if err != nil || len(data) < invoiceMinLength+signatureLength {
+		return nil, ErrInvalidInvoice
+	}
+	buf := bytes.NewReader(data[:len(data)-signatureLength])
+	version, err := buf.ReadByte()
+	if err != nil || version != invoiceVersion {
+		return nil, ErrInvalidInvoice
+	}
+	inv = new(Invoice)
+	io.ReadFull(buf, inv.Token[:])
+	inv.Amount = utils.ReadBigInt(buf)
+	binary.Read(buf, binary.BigEndian, &inv.Expiration)
+	io.ReadFull(buf, inv.LockSecretHash[:])
+	io.ReadFull(buf, inv.Payee[:])
+	memoLen, err := buf.ReadByte()
+	if err != nil || int(memoLen) > MaxMemoLength || int(memoLen) != buf.Len() {
+		return nil, ErrInvalidInvoice
+	}
+	memo := make([]byte, memoLen)
+	io.ReadFull(buf, memo)
+	inv.Memo = string(memo)
+	inv.Signature = data[len(data)-signatureLength:]
+	signer, err := VerifyMessage(data)
+	if err != nil || signer != inv.Payee {
+		return nil, ErrInvalidInvoice
+	}
+	return
+}
@@ -0,0 +1,67 @@
+package encoding
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"math/big"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+)
+
+//EncryptedSecretLength is the length of a secret encrypted by EncryptSecret: ephemeral public key, iv, secret, amount and mac
+const EncryptedSecretLength = 65 + 16 + 32 + 32 + 32
+
+//ErrInvalidPublicKey is returned when a public key is neither compressed nor uncompressed secp256k1 key
+var ErrInvalidPublicKey = errors.New("invalid public key")
+
+/*
+EncryptSecret encrypts secret and the amount target should receive to the target of a keysend transfer,
+only the target can decrypt it, so it can reveal the secret without asking the initiator.
+the amount can't be changed by mediators, so target can find out a transfer skimmed by them.
+*/
+func EncryptSecret(secret common.Hash, amount *big.Int, pub *ecdsa.PublicKey) ([]byte, error) {
+	if amount.Sign() < 0 || amount.BitLen() > 256 {
+		return nil, errors.New("invalid amount")
+	}
+	plain := append(secret[:], utils.BigIntTo32Bytes(amount)...)
+	return ecies.Encrypt(rand.Reader, ecies.ImportECDSAPublic(pub), plain, nil, nil)
+}
+
+//DecryptSecret decrypts the secret and amount of a keysend transfer locked by lockSecretHash
+func DecryptSecret(data []byte, lockSecretHash common.Hash, privKey *ecdsa.PrivateKey) (secret common.Hash, amount *big.Int, err error) {
+	b, err := ecies.ImportECDSA(privKey).Decrypt(rand.Reader, data, nil, nil)
+	if err != nil {
+		return
+	}
+	if len(b) != len(secret)+32 || utils.Sha3(b[:len(secret)]) != lockSecretHash {
+		err = errors.New("encrypted secret doesn't match lock secret hash")
+		return
+	}
+	secret = common.BytesToHash(b[:len(secret)])
+	amount = new(big.Int).SetBytes(b[len(secret):])
+	return
+}
+
+//ParsePublicKey parses a public key in hex, compressed(33 bytes) or uncompressed(65 bytes)
+func ParsePublicKey(s string) (*ecdsa.PublicKey, error) {
+	b := common.FromHex(s)
+	switch len(b) {
+	case 33:
+		pub, err := crypto.DecompressPubkey(b)
+		if err != nil {
+			return nil, ErrInvalidPublicKey
+		}
+		return pub, nil
+	case 65:
+		pub := crypto.ToECDSAPub(b)
+		if pub == nil || pub.X == nil {
+			return nil, ErrInvalidPublicKey
+		}
+		return pub, nil
+	}
+	return nil, ErrInvalidPublicKey
+}
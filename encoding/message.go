@@ -15,6 +15,8 @@ import (
 
 	"encoding/hex"
 
+	"io"
+
 	"github.com/SmartMeshFoundation/SmartRaiden/log"
 	"github.com/SmartMeshFoundation/SmartRaiden/network/rpc/contracts"
 	"github.com/SmartMeshFoundation/SmartRaiden/params"
@@ -67,6 +69,11 @@ const (
 		refund 响应,
 	*/
 	AnnounceDisposedTransferResponseCmdID
+	/*
+		MediatedTransfer with total amount,payment identifier,memo or encrypted secret,
+		a normal MediatedTransfer still uses MediatedTransferCmdID so that old nodes can decode it.
+	*/
+	ExtendedMediatedTransferCmdID
 )
 
 const signatureLength = 65
@@ -166,6 +173,8 @@ func (t MessageType) String() string {
 		return "DirectTransfer"
 	case MediatedTransferCmdID:
 		return "MediatedTransfer"
+	case ExtendedMediatedTransferCmdID:
+		return "ExtendedMediatedTransfer"
 	case AnnounceDisposedTransferCmdID:
 		return "AnnounceDisposed"
 	case AnnounceDisposedTransferResponseCmdID:
@@ -861,22 +870,32 @@ Initial `amount` should be expected received amount + fees.
 Fees are always payable by the initiator.
 
 `initiator` is the party that knows the secret to the `hashlock`
+
+`PaymentIdentifier` and `Memo` are set by the initiator for the target to match this payment,
+mediators forward them unchanged, they are visible to every hop.
 */
 type MediatedTransfer struct {
 	EnvelopMessage
-	Expiration     int64
-	LockSecretHash common.Hash
-	PaymentAmount  *big.Int //The number transferred to party
-	Target         common.Address
-	Initiator      common.Address
-	Fee            *big.Int
+	Expiration        int64
+	LockSecretHash    common.Hash
+	PaymentAmount     *big.Int //The number transferred to party
+	Target            common.Address
+	Initiator         common.Address
+	Fee               *big.Int
+	TotalAmount       *big.Int //amount target should receive for a multi path transfer,0 for a normal one
+	PaymentIdentifier uint64   //application's identifier of this payment, 0 if not set
+	Memo              string   //at most MaxMemoLength bytes
+	EncryptedSecret   []byte   //secret encrypted to target for a keysend transfer, empty for a normal one
 }
 
+//MaxMemoLength is the max length in bytes of memo of a MediatedTransfer
+const MaxMemoLength = 128
+
 //String is fmt.Stringer
 func (m *MediatedTransfer) String() string {
-	return fmt.Sprintf("Message{type=MediatedTransfer expiration=%d,target=%s,initiator=%s,hashlock=%s,amount=%s,fee=%s,%s}",
+	return fmt.Sprintf("Message{type=MediatedTransfer expiration=%d,target=%s,initiator=%s,hashlock=%s,amount=%s,fee=%s,totalamount=%s,paymentid=%d,memo=%q,keysend=%v,%s}",
 		m.Expiration, utils.APex2(m.Target), utils.APex2(m.Initiator),
-		utils.HPex(m.LockSecretHash), m.PaymentAmount, m.Fee, m.EnvelopMessage.String())
+		utils.HPex(m.LockSecretHash), m.PaymentAmount, m.Fee, m.TotalAmount, m.PaymentIdentifier, m.Memo, len(m.EncryptedSecret) > 0, m.EnvelopMessage.String())
 }
 
 //NewMediatedTransfer create MediatedTransfer
@@ -886,6 +905,7 @@ func NewMediatedTransfer(bp *BalanceProof, lock *mtree.Lock,
 		Target:         target,
 		Initiator:      initiator,
 		Fee:            new(big.Int).Set(fee),
+		TotalAmount:    new(big.Int),
 		PaymentAmount:  lock.Amount,
 		LockSecretHash: lock.LockSecretHash,
 		Expiration:     lock.Expiration,
@@ -904,11 +924,22 @@ func (m *MediatedTransfer) GetLock() *mtree.Lock {
 	}
 }
 
+//isExtended returns true if this transfer has fields which can only be packed with ExtendedMediatedTransferCmdID
+func (m *MediatedTransfer) isExtended() bool {
+	return (m.TotalAmount != nil && m.TotalAmount.Sign() > 0) || m.PaymentIdentifier != 0 ||
+		len(m.Memo) > 0 || len(m.EncryptedSecret) > 0
+}
+
 //Pack is MessagePacker
 func (m *MediatedTransfer) Pack() []byte {
 	var err error
 	buf := new(bytes.Buffer)
-	err = binary.Write(buf, binary.LittleEndian, m.CmdID) //one byte
+	cmdID := m.CmdID
+	extended := cmdID == MediatedTransferCmdID && m.isExtended()
+	if extended {
+		cmdID = ExtendedMediatedTransferCmdID
+	}
+	err = binary.Write(buf, binary.LittleEndian, cmdID) //one byte
 	//HTLC
 	err = binary.Write(buf, binary.BigEndian, m.Expiration)
 	_, err = buf.Write(m.LockSecretHash[:])
@@ -917,6 +948,20 @@ func (m *MediatedTransfer) Pack() []byte {
 	_, err = buf.Write(m.Target[:])
 	_, err = buf.Write(m.Initiator[:])
 	_, err = buf.Write(utils.BigIntTo32Bytes(m.Fee))
+	if extended {
+		_, err = buf.Write(utils.BigIntTo32Bytes(m.TotalAmount))
+		if len(m.Memo) > MaxMemoLength {
+			log.Crit(fmt.Sprintf("MediatedTransfer memo too long %d", len(m.Memo)))
+		}
+		err = binary.Write(buf, binary.BigEndian, m.PaymentIdentifier)
+		err = buf.WriteByte(byte(len(m.Memo)))
+		_, err = buf.WriteString(m.Memo)
+		if len(m.EncryptedSecret) != 0 && len(m.EncryptedSecret) != EncryptedSecretLength {
+			log.Crit(fmt.Sprintf("MediatedTransfer encrypted secret length error %d", len(m.EncryptedSecret)))
+		}
+		err = buf.WriteByte(byte(len(m.EncryptedSecret)))
+		_, err = buf.Write(m.EncryptedSecret)
+	}
 	m.EnvelopMessage.pack(buf)
 	if err != nil {
 		log.Crit(fmt.Sprintf("MediatedTransfer Pack err %s", err))
@@ -931,6 +976,10 @@ func (m *MediatedTransfer) UnPack(data []byte) error {
 	buf := bytes.NewBuffer(data)
 	err = binary.Read(buf, binary.LittleEndian, &t)
 	m.CmdID = t
+	extended := t == ExtendedMediatedTransferCmdID
+	if extended {
+		m.CmdID = MediatedTransferCmdID
+	}
 	if m.CmdID != MediatedTransferCmdID && m.CmdID != AnnounceDisposedTransferCmdID {
 		return errors.New("MediatedTransfer unpack cmd error")
 	}
@@ -942,6 +991,13 @@ func (m *MediatedTransfer) UnPack(data []byte) error {
 	_, err = buf.Read(m.Target[:])
 	_, err = buf.Read(m.Initiator[:])
 	m.Fee = utils.ReadBigInt(buf)
+	m.TotalAmount = new(big.Int) //keep same as NewMediatedTransfer
+	if extended {
+		err = m.unpackExtended(buf)
+		if err != nil {
+			return err
+		}
+	}
 	err = m.EnvelopMessage.unpack(buf)
 	if err != nil {
 		return err
@@ -949,6 +1005,42 @@ func (m *MediatedTransfer) UnPack(data []byte) error {
 	return m.verifySignature(data)
 }
 
+func (m *MediatedTransfer) unpackExtended(buf *bytes.Buffer) (err error) {
+	totalAmount := utils.ReadBigInt(buf)
+	if totalAmount.Sign() > 0 {
+		m.TotalAmount = totalAmount
+	}
+	err = binary.Read(buf, binary.BigEndian, &m.PaymentIdentifier)
+	if err != nil {
+		return err
+	}
+	memoLen, err := buf.ReadByte()
+	if err != nil {
+		return err
+	}
+	if int(memoLen) > MaxMemoLength {
+		return fmt.Errorf("MediatedTransfer memo too long %d", memoLen)
+	}
+	memo := make([]byte, memoLen)
+	_, err = io.ReadFull(buf, memo)
+	if err != nil {
+		return err
+	}
+	m.Memo = string(memo)
+	secretLen, err := buf.ReadByte()
+	if err != nil {
+		return err
+	}
+	if secretLen != 0 && int(secretLen) != EncryptedSecretLength {
+		return fmt.Errorf("MediatedTransfer encrypted secret length error %d", secretLen)
+	}
+	if secretLen > 0 {
+		m.EncryptedSecret = make([]byte, secretLen)
+		_, err = io.ReadFull(buf, m.EncryptedSecret)
+	}
+	return
+}
+
 //GetMtrFromLockedTransfer returns the MediatedTransfer ,the caller must maker sure this message is a  locked transfer
 func GetMtrFromLockedTransfer(tr Messager) (mtr *MediatedTransfer) {
 	if !(tr.Cmd() == MediatedTransferCmdID) {
@@ -1716,6 +1808,7 @@ var MessageMap = map[int]Messager{
 	DirectTransferCmdID:                   new(DirectTransfer),
 	RevealSecretCmdID:                     new(RevealSecret),
 	MediatedTransferCmdID:                 new(MediatedTransfer),
+	ExtendedMediatedTransferCmdID:         new(MediatedTransfer),
 	AnnounceDisposedTransferCmdID:         new(AnnounceDisposed),
 	RemoveExpiredLockCmdID:                new(RemoveExpiredHashlockTransfer),
 	AnnounceDisposedTransferResponseCmdID: new(AnnounceDisposedResponse),
@@ -21,6 +21,7 @@ import (
 	"github.com/SmartMeshFoundation/SmartRaiden/utils"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/stretchr/testify/assert"
 )
@@ -187,6 +188,88 @@ func TestMediatedTransfer(t *testing.T) {
 	}
 }
 
+func TestMediatedTransferPaymentIdentifier(t *testing.T) {
+	bp := &BalanceProof{
+		Nonce:             11,
+		ChannelIdentifier: utils.Sha3([]byte("123")),
+		TransferAmount:    big.NewInt(12),
+		OpenBlockNumber:   3,
+		Locksroot:         utils.EmptyHash,
+	}
+	lock := &mtree.Lock{
+		Amount:         big.NewInt(34),
+		Expiration:     4589895,
+		LockSecretHash: utils.Sha3([]byte("hashlock")),
+	}
+	m1 := NewMediatedTransfer(bp, lock, utils.NewRandomAddress(), utils.NewRandomAddress(), big.NewInt(33))
+	m1.PaymentIdentifier = 1234567
+	m1.Memo = "order #42, 两杯咖啡"
+	m1.Sign(GetTestPrivKey(), m1)
+	data := m1.Pack()
+	assert.EqualValues(t, ExtendedMediatedTransferCmdID, data[0])
+	m2 := new(MediatedTransfer)
+	err := m2.UnPack(data)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, m1, m2)
+	//memo is signed, tampered message is from someone else
+	i := bytes.Index(data, []byte("order"))
+	data[i] = 'O'
+	m3 := new(MediatedTransfer)
+	err = m3.UnPack(data)
+	assert.True(t, err != nil || m3.Sender != m1.Sender)
+}
+
+func TestMediatedTransferKeysend(t *testing.T) {
+	targetKey, _ := crypto.GenerateKey()
+	secret := utils.NewRandomHash()
+	bp := &BalanceProof{
+		Nonce:             11,
+		ChannelIdentifier: utils.Sha3([]byte("123")),
+		TransferAmount:    big.NewInt(12),
+		OpenBlockNumber:   3,
+		Locksroot:         utils.EmptyHash,
+	}
+	lock := &mtree.Lock{
+		Amount:         big.NewInt(34),
+		Expiration:     4589895,
+		LockSecretHash: utils.Sha3(secret[:]),
+	}
+	m1 := NewMediatedTransfer(bp, lock, crypto.PubkeyToAddress(targetKey.PublicKey), utils.NewRandomAddress(), big.NewInt(33))
+	pub, err := ParsePublicKey(hexutil.Encode(crypto.CompressPubkey(&targetKey.PublicKey)))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	m1.EncryptedSecret, err = EncryptSecret(secret, big.NewInt(33), pub)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, EncryptedSecretLength, len(m1.EncryptedSecret))
+	m1.Sign(GetTestPrivKey(), m1)
+	m2 := new(MediatedTransfer)
+	err = m2.UnPack(m1.Pack())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, m1, m2)
+	secret2, amount, err := DecryptSecret(m2.EncryptedSecret, m2.LockSecretHash, targetKey)
+	assert.Nil(t, err)
+	assert.EqualValues(t, secret, secret2)
+	assert.EqualValues(t, big.NewInt(33), amount)
+	//only target can decrypt it
+	_, _, err = DecryptSecret(m2.EncryptedSecret, m2.LockSecretHash, GetTestPrivKey())
+	assert.NotNil(t, err)
+	_, _, err = DecryptSecret(m2.EncryptedSecret, utils.NewRandomHash(), targetKey)
+	assert.NotNil(t, err)
+	_, err = ParsePublicKey("0x1234")
+	assert.Equal(t, ErrInvalidPublicKey, err)
+}
+
 func TestNewAnnounceDisposedTransfer(t *testing.T) {
 	bp := &AnnounceDisposedProof{
 		ChannelIDInMessage: ChannelIDInMessage{
@@ -466,3 +549,65 @@ func TestBigInt(t *testing.T) {
 		t.Error("not equal")
 	}
 }
+
+func TestInvoice(t *testing.T) {
+	inv := &Invoice{
+		Token:          utils.NewRandomAddress(),
+		Amount:         big.NewInt(300),
+		Expiration:     1600000000,
+		LockSecretHash: utils.NewRandomHash(),
+		Memo:           "coffee",
+	}
+	err := inv.Sign(GetTestPrivKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualValues(t, crypto.PubkeyToAddress(GetTestPubKey()), inv.Payee)
+	uri := inv.URI()
+	inv2, err := DecodeInvoice(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualValues(t, inv, inv2)
+	//changed amount doesn't match the signature
+	inv.Amount = big.NewInt(3000)
+	_, err = DecodeInvoice(inv.URI())
+	assert.EqualValues(t, ErrInvalidInvoice, err)
+	_, err = DecodeInvoice(uri[:len(uri)-10])
+	assert.EqualValues(t, ErrInvalidInvoice, err)
+	_, err = DecodeInvoice("raiden:" + uri[len(InvoiceURIPrefix):])
+	assert.EqualValues(t, ErrInvalidInvoice, err)
+}
+
+//a MediatedTransfer packed and signed by a node without payment identifier,memo and keysend support
+const oldMediatedTransferHex = "0b0000000000000000460947d308ba732707b60e614774890bfc30891a1c15eb994e2db91d8fe1f16ccf8e970000000000000000000000000000000000000000000000000000000000000022111111111111111111111111111111111111111122222222222222222222222222222222222222220000000000000000000000000000000000000000000000000000000000000005000000000000000b64e604787cbf194841e7b68d7cd28786f6c9a0a3ab9f8b0a0e87cb4387ab01070000000000000003000000000000000000000000000000000000000000000000000000000000000cc9780ff7bc9f061ff1361da2c7b86bdbcaba9127217d526c051fa0337ce2dcfcdca14e1701f6f15a5af87958afdb2ea5ffc621a00187c110f7bc1d9aa170499808743cb403a792e46d40cd941030ecc431c7022f3ef939e11fef1e8c9ffca97d1b"
+
+func TestMediatedTransferOldFormat(t *testing.T) {
+	data, err := hex.DecodeString(oldMediatedTransferHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := new(MediatedTransfer)
+	err = m.UnPack(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualValues(t, MediatedTransferCmdID, m.CmdID)
+	assert.EqualValues(t, crypto.PubkeyToAddress(GetTestPubKey()), m.Sender)
+	assert.EqualValues(t, 11, m.Nonce)
+	assert.EqualValues(t, utils.Sha3([]byte("123")), m.ChannelIdentifier)
+	assert.EqualValues(t, big.NewInt(12), m.TransferAmount)
+	assert.EqualValues(t, utils.Sha3([]byte("locksroot")), m.Locksroot)
+	assert.EqualValues(t, 4589895, m.Expiration)
+	assert.EqualValues(t, utils.Sha3([]byte("hashlock")), m.LockSecretHash)
+	assert.EqualValues(t, big.NewInt(34), m.PaymentAmount)
+	assert.EqualValues(t, common.HexToAddress("0x1111111111111111111111111111111111111111"), m.Target)
+	assert.EqualValues(t, common.HexToAddress("0x2222222222222222222222222222222222222222"), m.Initiator)
+	assert.EqualValues(t, big.NewInt(5), m.Fee)
+	assert.EqualValues(t, new(big.Int), m.TotalAmount)
+	assert.EqualValues(t, 0, m.PaymentIdentifier)
+	assert.EqualValues(t, "", m.Memo)
+	assert.EqualValues(t, 0, len(m.EncryptedSecret))
+	//a normal transfer is still packed in the old format
+	assert.EqualValues(t, data, m.Pack())
+}
@@ -7,6 +7,8 @@ import (
 
 	"math/big"
 
+	"strings"
+
 	"github.com/SmartMeshFoundation/SmartRaiden/channel"
 	"github.com/SmartMeshFoundation/SmartRaiden/channel/channeltype"
 	"github.com/SmartMeshFoundation/SmartRaiden/encoding"
@@ -88,6 +90,12 @@ func (eh *stateMachineEventHandler) eventSendMediatedTransfer(event *mediatedtra
 	if err != nil {
 		return
 	}
+	if event.TotalAmount != nil {
+		mtr.TotalAmount = new(big.Int).Set(event.TotalAmount)
+	}
+	mtr.PaymentIdentifier = event.PaymentIdentifier
+	mtr.Memo = event.Memo
+	mtr.EncryptedSecret = event.EncryptedSecret
 	err = mtr.Sign(eh.raiden.PrivateKey, mtr)
 	err = ch.RegisterTransfer(eh.raiden.GetBlockNumber(), mtr)
 	if err != nil {
@@ -204,16 +212,21 @@ func (eh *stateMachineEventHandler) eventContractSendWithdraw(e2 *mediatedtransf
 the transfer I payed for a payee has expired. give a new balanceproof which doesn't contain this hashlock
 */
 func (eh *stateMachineEventHandler) eventUnlockFailed(e2 *mediatedtransfer.EventUnlockFailed, manager *transfer.StateManager) (err error) {
-	if manager.Name != mediator.NameMediatorTransition && manager.Name != initiator.NameInitiatorTransition {
+	if manager.Name != mediator.NameMediatorTransition && manager.Name != initiator.NameInitiatorTransition && manager.Name != initiator.NameMultiPathInitiatorTransition {
 		panic("event unlock failed only happen for a mediated node")
 	}
-	ch, err := eh.raiden.findChannelByAddress(e2.ChannelIdentifier)
+	return eh.removeExpiredHashlock(e2.ChannelIdentifier, e2.LockSecretHash)
+}
+
+//removeExpiredHashlock send RemoveExpiredHashlockTransfer to partner
+func (eh *stateMachineEventHandler) removeExpiredHashlock(channelIdentifier, lockSecretHash common.Hash) (err error) {
+	ch, err := eh.raiden.findChannelByAddress(channelIdentifier)
 	if err != nil {
-		log.Error(fmt.Sprintf("payee's lock expired ,but cannot find channel %s, eh may happen long later restart after a stop", e2.ChannelIdentifier))
+		log.Error(fmt.Sprintf("payee's lock expired ,but cannot find channel %s, eh may happen long later restart after a stop", channelIdentifier))
 		return
 	}
-	log.Info(fmt.Sprintf("remove expired hashlock channel=%s,hashlock=%s ", utils.HPex(e2.ChannelIdentifier), utils.HPex(e2.LockSecretHash)))
-	tr, err := ch.CreateRemoveExpiredHashLockTransfer(e2.LockSecretHash, eh.raiden.GetBlockNumber())
+	log.Info(fmt.Sprintf("remove expired hashlock channel=%s,hashlock=%s ", utils.HPex(channelIdentifier), utils.HPex(lockSecretHash)))
+	tr, err := ch.CreateRemoveExpiredHashLockTransfer(lockSecretHash, eh.raiden.GetBlockNumber())
 	if err != nil {
 		log.Warn(fmt.Sprintf("Get Event UnlockFailed ,but hashlock cannot be removed err:%s", err))
 		return
@@ -231,6 +244,9 @@ func (eh *stateMachineEventHandler) eventUnlockFailed(e2 *mediatedtransfer.Event
 }
 func (eh *stateMachineEventHandler) OnEvent(event transfer.Event, stateManager *transfer.StateManager) (err error) {
 	var ch *channel.Channel
+	if _, ok := event.(*mediatedtransfer.EventRemoveStateManager); !ok {
+		eh.recordInternalEvent(event, utils.EmptyHash)
+	}
 	switch e2 := event.(type) {
 	case *mediatedtransfer.EventSendMediatedTransfer:
 		err = eh.eventSendMediatedTransfer(e2, stateManager)
@@ -264,7 +280,7 @@ func (eh *stateMachineEventHandler) OnEvent(event transfer.Event, stateManager *
 		if err != nil {
 			log.Error(fmt.Sprintf("UpdateChannelNoTx err %s", err))
 		}
-		eh.raiden.db.NewSentTransfer(eh.raiden.GetBlockNumber(), e2.ChannelIdentifier, ch.TokenAddress, e2.Target, ch.GetNextNonce(), e2.Amount)
+		eh.raiden.db.NewSentTransfer(eh.raiden.GetBlockNumber(), e2.ChannelIdentifier, ch.TokenAddress, e2.Target, ch.GetNextNonce(), e2.Amount, e2.PaymentIdentifier, e2.Memo)
 		eh.finishOneTransfer(event)
 	case *transfer.EventTransferSentFailed:
 		eh.finishOneTransfer(event)
@@ -278,7 +294,7 @@ func (eh *stateMachineEventHandler) OnEvent(event transfer.Event, stateManager *
 		if err != nil {
 			log.Error(fmt.Sprintf("UpdateChannelNoTx err %s", err))
 		}
-		eh.raiden.db.NewReceivedTransfer(eh.raiden.GetBlockNumber(), e2.ChannelIdentifier, ch.TokenAddress, e2.Initiator, ch.PartnerState.BalanceProofState.Nonce, e2.Amount)
+		eh.raiden.db.NewReceivedTransfer(eh.raiden.GetBlockNumber(), e2.ChannelIdentifier, ch.TokenAddress, e2.Initiator, ch.PartnerState.BalanceProofState.Nonce, e2.Amount, e2.PaymentIdentifier, e2.Memo)
 	case *mediatedtransfer.EventUnlockSuccess:
 	case *mediatedtransfer.EventWithdrawFailed:
 		log.Error(fmt.Sprintf("EventWithdrawFailed hashlock=%s,reason=%s", utils.HPex(e2.LockSecretHash), e2.Reason))
@@ -297,6 +313,12 @@ func (eh *stateMachineEventHandler) OnEvent(event transfer.Event, stateManager *
 		eh.raiden.conditionQuit("EventSendRemoveExpiredHashlockTransferAfter")
 	case *mediatedtransfer.EventContractSendRegisterSecret:
 		err = eh.eventContractSendRegisterSecret(e2)
+	case *mediatedtransfer.EventInvoicePaid:
+		err = eh.raiden.db.InvoicePaid(e2.LockSecretHash, e2.Payer, e2.Amount)
+	case *mediatedtransfer.EventInvoiceHeld:
+		err = eh.raiden.db.InvoiceHeld(e2.LockSecretHash, e2.Payer, e2.Amount)
+	case *mediatedtransfer.EventInvoiceRejected:
+		err = eh.raiden.db.CancelInvoice(e2.LockSecretHash)
 	case *mediatedtransfer.EventRemoveStateManager:
 		delete(eh.raiden.Transfer2StateManager, e2.Key)
 	default:
@@ -325,17 +347,97 @@ func (eh *stateMachineEventHandler) finishOneTransfer(ev transfer.Event) {
 	default:
 		panic("unknow event")
 	}
+	eh.raiden.finishAsyncTransfer(lockSecretHash, tokenAddress, err)
 	if lockSecretHash != utils.EmptyHash {
 		smkey := utils.Sha3(lockSecretHash[:], tokenAddress[:])
 		r := eh.raiden.Transfer2Result[smkey]
-		if r == nil { //restart after crash?
-			log.Error(fmt.Sprintf("transfer finished ,but have no relate results :%s", utils.StringInterface(ev, 2)))
+		if r == nil { //restart after crash? or another part of a multi path transfer
+			log.Info(fmt.Sprintf("transfer finished ,but have no relate results :%s", utils.StringInterface(ev, 2)))
 			return
 		}
 		r.Result <- err
 		delete(eh.raiden.Transfer2Result, smkey)
 	}
 }
+
+/*
+internalEventKeepBlocks internal events are kept for about 30 days, older ones are removed
+every internalEventPruneBlocks blocks, otherwise db of a busy mediator grows forever.
+*/
+const internalEventKeepBlocks = 172800
+
+const internalEventPruneBlocks = 100
+
+//removeOldInternalEvents removes internal events older than internalEventKeepBlocks
+func (rs *RaidenService) removeOldInternalEvents(blockNumber int64) {
+	if blockNumber%internalEventPruneBlocks != 0 {
+		return
+	}
+	err := rs.db.RemoveInternalEventsBefore(blockNumber - internalEventKeepBlocks)
+	if err != nil {
+		log.Error(fmt.Sprintf("RemoveInternalEventsBefore err %s", err))
+	}
+}
+
+/*
+recordInternalEvent save event or channel statechange to db for auditing,
+channelIdentifier is used when the event itself doesn't know which channel it belongs to.
+*/
+func (eh *stateMachineEventHandler) recordInternalEvent(obj interface{}, channelIdentifier common.Hash) {
+	var lockSecretHash common.Hash
+	var reason string
+	blockNumber := eh.raiden.GetBlockNumber()
+	switch e2 := obj.(type) {
+	case *transfer.EventTransferSentSuccess:
+		lockSecretHash, channelIdentifier = e2.LockSecretHash, e2.ChannelIdentifier
+	case *transfer.EventTransferSentFailed:
+		lockSecretHash, channelIdentifier, reason = e2.LockSecretHash, e2.ChannelIdentifier, e2.Reason
+	case *transfer.EventTransferReceivedSuccess:
+		lockSecretHash, channelIdentifier = e2.LockSecretHash, e2.ChannelIdentifier
+	case *mediatedtransfer.EventSendMediatedTransfer:
+		lockSecretHash = e2.LockSecretHash
+	case *mediatedtransfer.EventSendRevealSecret:
+		lockSecretHash = e2.LockSecretHash
+	case *mediatedtransfer.EventSendBalanceProof:
+		lockSecretHash, channelIdentifier = e2.LockSecretHash, e2.ChannelIdentifier
+	case *mediatedtransfer.EventSendSecretRequest:
+		lockSecretHash = e2.LockSecretHash
+	case *mediatedtransfer.EventSendAnnounceDisposed:
+		lockSecretHash = e2.LockSecretHash
+	case *mediatedtransfer.EventSendAnnounceDisposedResponse:
+		lockSecretHash = e2.LockSecretHash
+	case *mediatedtransfer.EventContractSendRegisterSecret:
+		lockSecretHash = utils.Sha3(e2.Secret[:])
+	case *mediatedtransfer.EventContractSendWithdraw:
+		channelIdentifier = e2.ChannelIdentifier
+		if e2.Transfer != nil {
+			lockSecretHash = e2.Transfer.LockSecretHash
+		}
+	case *mediatedtransfer.EventUnlockSuccess:
+		lockSecretHash = e2.LockSecretHash
+	case *mediatedtransfer.EventUnlockFailed:
+		lockSecretHash, channelIdentifier, reason = e2.LockSecretHash, e2.ChannelIdentifier, e2.Reason
+	case *mediatedtransfer.EventWithdrawSuccess:
+		lockSecretHash = e2.LockSecretHash
+	case *mediatedtransfer.EventWithdrawFailed:
+		lockSecretHash, channelIdentifier, reason = e2.LockSecretHash, e2.ChannelIdentifier, e2.Reason
+	case *mediatedtransfer.EventInvoicePaid:
+		lockSecretHash = e2.LockSecretHash
+	case *mediatedtransfer.EventInvoiceHeld:
+		lockSecretHash = e2.LockSecretHash
+	case *mediatedtransfer.EventInvoiceRejected:
+		lockSecretHash, reason = e2.LockSecretHash, e2.Reason
+	case *mediatedtransfer.ContractSecretRevealOnChainStateChange:
+		lockSecretHash = e2.LockSecretHash
+	}
+	if st, ok := obj.(mediatedtransfer.ContractStateChange); ok {
+		blockNumber = st.GetBlockNumber()
+	}
+	name := fmt.Sprintf("%T", obj)
+	name = name[strings.LastIndex(name, ".")+1:]
+	eh.raiden.db.NewInternalEvent(blockNumber, name, lockSecretHash, channelIdentifier, reason, obj)
+}
+
 func (eh *stateMachineEventHandler) HandleTokenAdded(st *mediatedtransfer.ContractTokenAddedStateChange) error {
 	if st.RegistryAddress != eh.raiden.RegistryAddress {
 		panic("unkown registry")
@@ -401,6 +503,21 @@ func (eh *stateMachineEventHandler) handleBalance(st *mediatedtransfer.ContractB
 		log.Error(fmt.Sprintf("handleBalance ChannelStateTransition err=%s", err))
 	}
 	err = eh.raiden.db.UpdateChannelContractBalance(channel.NewChannelSerialization(ch))
+	/*
+		partner opened a channel with us and deposit,join it if we have connected to this token network.
+		JoinChannel will call api,so it cannot run in the main loop.
+	*/
+	if st.ParticipantAddress == ch.PartnerState.Address && ch.OurState.ContractBalance.Cmp(utils.BigInt0) == 0 {
+		cm := eh.raiden.getConnectionManager(ch.TokenAddress)
+		if cm != nil {
+			go func() {
+				err := cm.JoinChannel(st.ParticipantAddress, st.Balance)
+				if err != nil {
+					log.Error(fmt.Sprintf("JoinChannel %s err %s", utils.APex2(st.ParticipantAddress), err))
+				}
+			}()
+		}
+	}
 	return nil
 }
 
@@ -498,21 +615,12 @@ func (eh *stateMachineEventHandler) handleUnlockOnChain(st *mediatedtransfer.Con
 		log.Error(fmt.Sprintf("handle unlock ChannelStateTransition err=%s", err))
 		return err
 	}
+	cs := channel.NewChannelSerialization(ch)
 	//对方解锁我发出去的交易,考虑可否惩罚
 	if eh.raiden.NodeAddress == st.Participant {
-		ad := eh.raiden.db.GetReceiviedAnnounceDisposed(st.LockHash, ch.ChannelIdentifier.ChannelIdentifier)
-		if ad != nil {
-			result := ch.ExternState.PunishObsoleteUnlock(common.BytesToHash(ad.LockHash), ad.AdditionalHash, ad.Signature)
-			go func() {
-				var err2 error
-				err2 = <-result.Result
-				if err2 != nil {
-					log.Error(fmt.Sprintf("PunishObsoleteUnlock %s ,err2 %s", utils.BPex(ad.LockHash), err2))
-				}
-			}()
-		}
+		eh.raiden.punisher.onUnlock(st, cs)
 	}
-	err = eh.raiden.db.UpdateChannelState(channel.NewChannelSerialization(ch))
+	err = eh.raiden.db.UpdateChannelState(cs)
 	return err
 }
 func (eh *stateMachineEventHandler) handlePunishedOnChain(st *mediatedtransfer.ContractPunishedStateChange) error {
@@ -539,6 +647,9 @@ func (eh *stateMachineEventHandler) handleSecretRegisteredOnChain(st *mediatedtr
 //avoid dead lock
 func (eh *stateMachineEventHandler) ChannelStateTransition(c *channel.Channel, st transfer.StateChange) (err error) {
 	blockNumber := eh.raiden.GetBlockNumber()
+	if _, ok := st.(*transfer.BlockStateChange); !ok {
+		eh.recordInternalEvent(st, c.ChannelIdentifier.ChannelIdentifier)
+	}
 	switch st2 := st.(type) {
 	case *transfer.BlockStateChange:
 		if c.State == channeltype.StateClosed {
@@ -1,8 +1,12 @@
 package smartraiden
 
 import (
+	"encoding/json"
+	"io/ioutil"
 	"math/big"
+	"sync"
 
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
 	"github.com/SmartMeshFoundation/SmartRaiden/utils"
 	"github.com/ethereum/go-ethereum/common"
 )
@@ -36,3 +40,116 @@ func (c *CombinationFeePolicy) GetNodeChargeFee(nodeAddress, tokenAddress common
 	f := new(big.Int).Div(amount, big.NewInt(1000)) //fee rate: one in thousand.
 	return f.Add(f, fixedFee)
 }
+
+/*
+ConfigurableFeePolicy charge fee according to models.FeePolicy,
+the policy can be replaced at any time and is persisted in db.
+*/
+type ConfigurableFeePolicy struct {
+	lock   sync.RWMutex
+	db     *models.ModelDB
+	policy *models.FeePolicy
+}
+
+//DefaultFeePolicy same as ConstantFeePolicy
+func DefaultFeePolicy() *models.FeePolicy {
+	return &models.FeePolicy{
+		DefaultFee: &models.FeeSetting{
+			BaseFee: new(big.Int).Set(fixedFee),
+		},
+	}
+}
+
+/*
+NewConfigurableFeePolicy load fee policy from db,
+DefaultFeePolicy is used if there is none.
+*/
+func NewConfigurableFeePolicy(db *models.ModelDB) (c *ConfigurableFeePolicy, err error) {
+	c = &ConfigurableFeePolicy{db: db}
+	fp, err := db.GetFeePolicy()
+	if err != nil {
+		return
+	}
+	if fp == nil {
+		fp = DefaultFeePolicy()
+	}
+	c.policy = fp
+	return
+}
+
+/*
+newConfigurableFeePolicyFromConfig load fee policy from db,
+if file is not empty, the policy in file will replace the one in db.
+*/
+func newConfigurableFeePolicyFromConfig(db *models.ModelDB, file string) (c *ConfigurableFeePolicy, err error) {
+	c, err = NewConfigurableFeePolicy(db)
+	if err != nil || len(file) == 0 {
+		return
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+	fp := new(models.FeePolicy)
+	err = json.Unmarshal(data, fp)
+	if err != nil {
+		return
+	}
+	err = c.SetPolicy(fp)
+	return
+}
+
+//SetPolicy validate and save the new policy, it takes effect immediately
+func (c *ConfigurableFeePolicy) SetPolicy(fp *models.FeePolicy) error {
+	err := fp.Validate()
+	if err != nil {
+		return err
+	}
+	err = c.db.SaveFeePolicy(fp)
+	if err != nil {
+		return err
+	}
+	c.lock.Lock()
+	c.policy = fp
+	c.lock.Unlock()
+	return nil
+}
+
+//GetPolicy returns the fee policy in use
+func (c *ConfigurableFeePolicy) GetPolicy() *models.FeePolicy {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.policy
+}
+
+//GetNodeChargeFee returns fee of the partner setting, token setting or default setting.
+func (c *ConfigurableFeePolicy) GetNodeChargeFee(nodeAddress, tokenAddress common.Address, amount *big.Int) *big.Int {
+	c.lock.RLock()
+	fs := c.policy.PartnerFees[nodeAddress]
+	if fs == nil {
+		fs = c.policy.TokenFees[tokenAddress]
+	}
+	if fs == nil {
+		fs = c.policy.DefaultFee
+	}
+	c.lock.RUnlock()
+	return calcFee(fs, amount)
+}
+
+func calcFee(fs *models.FeeSetting, amount *big.Int) *big.Int {
+	f := new(big.Int)
+	if fs.FeeRate > 0 && amount != nil {
+		f.Mul(amount, big.NewInt(fs.FeeRate))
+		f.Div(f, big.NewInt(1000000))
+	}
+	if fs.BaseFee != nil {
+		f.Add(f, fs.BaseFee)
+	}
+	if fs.MinFee != nil && f.Cmp(fs.MinFee) < 0 {
+		f.Set(fs.MinFee)
+	}
+	if fs.MaxFee != nil && f.Cmp(fs.MaxFee) > 0 {
+		f.Set(fs.MaxFee)
+	}
+	return f
+}
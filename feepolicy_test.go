@@ -0,0 +1,76 @@
+package smartraiden
+
+import (
+	"math/big"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestConfigurableFeePolicy(t *testing.T) {
+	dbPath := path.Join(os.TempDir(), "testfeepolicy.db")
+	os.Remove(dbPath)
+	db, err := models.OpenDb(dbPath)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer db.CloseDB()
+	c, err := NewConfigurableFeePolicy(db)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	token, partner := utils.NewRandomAddress(), utils.NewRandomAddress()
+	if c.GetNodeChargeFee(partner, token, big.NewInt(1000)).Cmp(fixedFee) != 0 {
+		t.Error("default policy should charge fixed fee")
+	}
+	fp := &models.FeePolicy{
+		DefaultFee: &models.FeeSetting{BaseFee: big.NewInt(1), FeeRate: 1000},
+		TokenFees: map[common.Address]*models.FeeSetting{
+			token: {BaseFee: big.NewInt(2), FeeRate: 10000, MinFee: big.NewInt(5), MaxFee: big.NewInt(50)},
+		},
+		PartnerFees: map[common.Address]*models.FeeSetting{
+			partner: {},
+		},
+	}
+	err = c.SetPolicy(fp)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	cases := []struct {
+		node, token common.Address
+		amount      int64
+		fee         int64
+	}{
+		{utils.NewRandomAddress(), utils.NewRandomAddress(), 10000, 11},
+		{utils.NewRandomAddress(), token, 100, 5},
+		{utils.NewRandomAddress(), token, 1000, 12},
+		{utils.NewRandomAddress(), token, 100000, 50},
+		{partner, token, 100000, 0},
+	}
+	for i, cs := range cases {
+		f := c.GetNodeChargeFee(cs.node, cs.token, big.NewInt(cs.amount))
+		if f.Int64() != cs.fee {
+			t.Errorf("case %d expect fee %d,got %s", i, cs.fee, f)
+		}
+	}
+	//reload from db
+	c, err = NewConfigurableFeePolicy(db)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if c.GetNodeChargeFee(utils.NewRandomAddress(), token, big.NewInt(1000)).Int64() != 12 {
+		t.Error("policy should be saved to db")
+	}
+	err = c.SetPolicy(&models.FeePolicy{DefaultFee: &models.FeeSetting{FeeRate: -1}})
+	if err == nil {
+		t.Error("invalid policy should be rejected")
+	}
+}
@@ -0,0 +1,214 @@
+package smartraiden
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/encoding"
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/SmartMeshFoundation/SmartRaiden/rerr"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mediatedtransfer"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mediatedtransfer/target"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var errNoHeldTransfer = errors.New("no transfer is held by this invoice")
+
+/*
+CreateInvoice creates an invoice asking for amount of token, which can be paid within expiry.
+the secret is kept by this node, the returned invoice has the uri to give to the payer.
+*/
+func (r *RaidenAPI) CreateInvoice(token common.Address, amount *big.Int, expiry time.Duration, memo string) (inv *models.Invoice, err error) {
+	return r.createInvoice(token, amount, expiry, memo, false, utils.EmptyHash)
+}
+
+/*
+CreateHoldInvoice creates an invoice whose payment is held until AcceptHeldInvoice or RejectHeldInvoice is called,
+if lockSecretHash is empty, a secret is generated and kept by this node,
+otherwise the application keeps the secret and gives it when accepting.
+a held payment is rejected automatically when there is no time left to reveal the secret safely.
+*/
+func (r *RaidenAPI) CreateHoldInvoice(token common.Address, amount *big.Int, expiry time.Duration, memo string, lockSecretHash common.Hash) (inv *models.Invoice, err error) {
+	return r.createInvoice(token, amount, expiry, memo, true, lockSecretHash)
+}
+
+func (r *RaidenAPI) createInvoice(token common.Address, amount *big.Int, expiry time.Duration, memo string, hold bool, lockSecretHash common.Hash) (inv *models.Invoice, err error) {
+	if amount == nil || amount.Cmp(utils.BigInt0) <= 0 {
+		err = rerr.ErrInvalidAmount
+		return
+	}
+	if len(memo) > encoding.MaxMemoLength {
+		err = rerr.ErrMemoTooLong
+		return
+	}
+	if expiry <= 0 {
+		err = errors.New("expiry should be positive")
+		return
+	}
+	tokens, err := r.Raiden.db.GetAllTokens()
+	if err != nil {
+		return
+	}
+	if _, ok := tokens[token]; !ok {
+		err = rerr.ErrNoTokenManager
+		return
+	}
+	var secret common.Hash
+	if lockSecretHash == utils.EmptyHash {
+		secret = utils.NewRandomHash()
+		lockSecretHash = utils.Sha3(secret[:])
+	}
+	signed := &encoding.Invoice{
+		Token:          token,
+		Amount:         new(big.Int).Set(amount),
+		Expiration:     time.Now().Add(expiry).Unix(),
+		LockSecretHash: lockSecretHash,
+		Memo:           memo,
+	}
+	err = signed.Sign(r.Raiden.PrivateKey)
+	if err != nil {
+		return
+	}
+	inv = &models.Invoice{
+		LockSecretHash: signed.LockSecretHash,
+		Secret:         secret,
+		Token:          token,
+		Amount:         signed.Amount,
+		Expiration:     signed.Expiration,
+		Memo:           memo,
+		URI:            signed.URI(),
+		Hold:           hold,
+	}
+	err = r.Raiden.db.NewInvoice(inv)
+	if err != nil {
+		return
+	}
+	log.Info(fmt.Sprintf("create invoice %s", signed))
+	return
+}
+
+/*
+AcceptHeldInvoice reveals the secret of a hold invoice to receive the held payment,
+secret can be empty if it's kept by this node.
+*/
+func (r *RaidenAPI) AcceptHeldInvoice(lockSecretHash, secret common.Hash) (err error) {
+	inv, err := r.holdInvoice(lockSecretHash)
+	if err != nil {
+		return
+	}
+	if secret == utils.EmptyHash {
+		secret = inv.Secret
+	}
+	if secret == utils.EmptyHash || utils.Sha3(secret[:]) != lockSecretHash {
+		return errors.New("secret doesn't match lock secret hash")
+	}
+	if inv.Status != models.InvoiceHeld {
+		return errNoHeldTransfer
+	}
+	result := r.Raiden.settleHeldTransferClient(lockSecretHash, secret, true)
+	return <-result.Result
+}
+
+/*
+RejectHeldInvoice cancels a hold invoice, the held payment is given back to the payer at once,
+payments arriving later are rejected too.
+*/
+func (r *RaidenAPI) RejectHeldInvoice(lockSecretHash common.Hash) (err error) {
+	inv, err := r.holdInvoice(lockSecretHash)
+	if err != nil {
+		return
+	}
+	switch inv.Status {
+	case models.InvoiceOpen:
+		return r.Raiden.db.CancelInvoice(lockSecretHash)
+	case models.InvoiceHeld:
+		result := r.Raiden.settleHeldTransferClient(lockSecretHash, utils.EmptyHash, false)
+		return <-result.Result
+	}
+	return fmt.Errorf("invoice is %s, cannot be rejected", inv.Status)
+}
+
+func (r *RaidenAPI) holdInvoice(lockSecretHash common.Hash) (inv *models.Invoice, err error) {
+	inv, err = r.Raiden.db.GetInvoice(lockSecretHash)
+	if err != nil {
+		return
+	}
+	if !inv.Hold {
+		err = errors.New("not a hold invoice")
+	}
+	return
+}
+
+/*
+settleHeldTransfer accepts or rejects all the transfers held by the hold invoice locked by lockSecretHash.
+*/
+func (rs *RaidenService) settleHeldTransfer(lockSecretHash, secret common.Hash, accept bool) (result *utils.AsyncResult) {
+	result = utils.NewAsyncResult()
+	var mgrs []*transfer.StateManager
+	for _, mgr := range rs.Transfer2StateManager {
+		if mgr.Identifier != lockSecretHash || mgr.Name != target.NameTargetTransition {
+			continue
+		}
+		if state, ok := mgr.CurrentState.(*mediatedtransfer.TargetState); ok && state.State == mediatedtransfer.StateHeld {
+			mgrs = append(mgrs, mgr)
+		}
+	}
+	if len(mgrs) == 0 {
+		result.Result <- errNoHeldTransfer
+		return
+	}
+	for _, mgr := range mgrs {
+		if accept {
+			rs.StateMachineEventHandler.dispatch(mgr, &mediatedtransfer.ActionAcceptHeldStateChange{LockSecretHash: lockSecretHash, Secret: secret})
+		} else {
+			rs.StateMachineEventHandler.dispatch(mgr, &mediatedtransfer.ActionRejectHeldStateChange{LockSecretHash: lockSecretHash})
+		}
+	}
+	result.Result <- nil
+	return
+}
+
+//GetInvoice returns the invoice created by this node
+func (r *RaidenAPI) GetInvoice(lockSecretHash common.Hash) (*models.Invoice, error) {
+	return r.Raiden.db.GetInvoice(lockSecretHash)
+}
+
+//GetInvoices returns all invoices created by this node
+func (r *RaidenAPI) GetInvoices() ([]*models.Invoice, error) {
+	return r.Raiden.db.GetInvoiceList()
+}
+
+//DecodeInvoice decodes an invoice uri and verifies its signature
+func (r *RaidenAPI) DecodeInvoice(uri string) (*encoding.Invoice, error) {
+	return encoding.DecodeInvoice(uri)
+}
+
+/*
+PayInvoice pays an invoice created by another node and wait,
+fee is the most fee to pay, the payee will receive the amount of the invoice.
+*/
+func (r *RaidenAPI) PayInvoice(uri string, fee *big.Int, timeout time.Duration) (inv *encoding.Invoice, err error) {
+	inv, err = encoding.DecodeInvoice(uri)
+	if err != nil {
+		return
+	}
+	if inv.Payee == r.Raiden.NodeAddress {
+		err = rerr.ErrSamePeerAddress
+		return
+	}
+	if time.Now().Unix() >= inv.Expiration {
+		err = rerr.ErrInvoiceExpired
+		return
+	}
+	err = r.TransferAndWait(inv.Token, inv.Amount, inv.Payee, timeout, &TransferOptions{
+		Fee:            fee,
+		LockSecretHash: inv.LockSecretHash,
+		Memo:           inv.Memo,
+	})
+	return
+}
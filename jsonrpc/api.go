@@ -0,0 +1,479 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/SmartMeshFoundation/SmartRaiden"
+	"github.com/SmartMeshFoundation/SmartRaiden/channel/channeltype"
+	"github.com/SmartMeshFoundation/SmartRaiden/encoding"
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/SmartMeshFoundation/SmartRaiden/params"
+	"github.com/SmartMeshFoundation/SmartRaiden/restful/v1"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+/*
+RaidenAPI is the "raiden" namespace of json-rpc,
+results are the same as the rest api, for example raiden_getChannelList returns what GET /api/1/channels returns.
+*/
+type RaidenAPI struct {
+	api *smartraiden.RaidenAPI
+}
+
+//NewRaidenAPI create the json-rpc api of api
+func NewRaidenAPI(api *smartraiden.RaidenAPI) *RaidenAPI {
+	return &RaidenAPI{api: api}
+}
+
+func channelData(c *channeltype.Serialization) *v1.ChannelData {
+	return &v1.ChannelData{
+		ChannelAddress:      c.ChannelIdentifier.ChannelIdentifier.String(),
+		OpenBlockNumber:     c.ChannelIdentifier.OpenBlockNumber,
+		PartnerAddrses:      c.PartnerAddress().String(),
+		Balance:             c.OurBalance(),
+		PartnerBalance:      c.PartnerBalance(),
+		State:               c.State,
+		StateString:         c.State.String(),
+		PrepareState:        v1.ChannelPrepareState(c.State),
+		SettleTimeout:       c.SettleTimeout,
+		TokenAddress:        c.TokenAddress().String(),
+		LockedAmount:        c.OurAmountLocked(),
+		PartnerLockedAmount: c.PartnerAmountLocked(),
+		RevealTimeout:       c.RevealTimeout,
+	}
+}
+
+func channelResult(c *channeltype.Serialization, err error) (*v1.ChannelData, error) {
+	if err != nil {
+		return nil, err
+	}
+	return channelData(c), nil
+}
+
+//Address returns address of this node
+func (r *RaidenAPI) Address() common.Address {
+	return r.api.Address()
+}
+
+//PublicKey returns the compressed public key of this node, which is needed to keysend to it
+func (r *RaidenAPI) PublicKey() hexutil.Bytes {
+	return r.api.PublicKey()
+}
+
+//Tokens returns registered tokens and their token networks
+func (r *RaidenAPI) Tokens() models.AddressMap {
+	return r.api.GetTokenTokenNetorks()
+}
+
+//RegisterToken register a new token to the raiden network
+func (r *RaidenAPI) RegisterToken(token common.Address) (common.Address, error) {
+	return r.api.RegisterToken(token)
+}
+
+//GetChannelList returns channels of token with partner, empty address means any
+func (r *RaidenAPI) GetChannelList(token, partner common.Address) (ds []*v1.ChannelData, err error) {
+	chs, err := r.api.GetChannelList(token, partner)
+	if err != nil {
+		return
+	}
+	ds = []*v1.ChannelData{}
+	for _, c := range chs {
+		ds = append(ds, channelData(c))
+	}
+	return
+}
+
+//GetChannel returns a channel
+func (r *RaidenAPI) GetChannel(channel common.Hash) (*v1.ChannelData, error) {
+	return channelResult(r.api.GetChannel(channel))
+}
+
+//Open a channel with partner and deposit to it
+func (r *RaidenAPI) Open(token, partner common.Address, settleTimeout int, deposit *big.Int) (*v1.ChannelData, error) {
+	return channelResult(r.api.Open(token, partner, settleTimeout, r.api.Raiden.Config.RevealTimeout, deposit))
+}
+
+//Deposit to the channel of token with partner
+func (r *RaidenAPI) Deposit(token, partner common.Address, amount *big.Int) (*v1.ChannelData, error) {
+	return channelResult(r.api.Deposit(token, partner, amount, params.DefaultPollTimeout))
+}
+
+//Close the channel, cooperative settle it instead unless force
+func (r *RaidenAPI) Close(token, partner common.Address, force bool) (*v1.ChannelData, error) {
+	if force {
+		return channelResult(r.api.Close(token, partner))
+	}
+	return channelResult(r.api.CooperativeSettle(token, partner))
+}
+
+//Settle a closed channel
+func (r *RaidenAPI) Settle(token, partner common.Address) (*v1.ChannelData, error) {
+	return channelResult(r.api.Settle(token, partner))
+}
+
+//Withdraw amount from the channel
+func (r *RaidenAPI) Withdraw(token, partner common.Address, amount *big.Int) (*v1.ChannelData, error) {
+	return channelResult(r.api.Withdraw(token, partner, amount))
+}
+
+//PrepareForWithdraw stop accepting new transfers before withdraw
+func (r *RaidenAPI) PrepareForWithdraw(token, partner common.Address) (*v1.ChannelData, error) {
+	return channelResult(r.api.PrepareForWithdraw(token, partner))
+}
+
+//CancelPrepareForWithdraw accept new transfers again
+func (r *RaidenAPI) CancelPrepareForWithdraw(token, partner common.Address) (*v1.ChannelData, error) {
+	return channelResult(r.api.CancelPrepareForWithdraw(token, partner))
+}
+
+//PrepareForCooperativeSettle stop accepting new transfers before cooperative settle
+func (r *RaidenAPI) PrepareForCooperativeSettle(token, partner common.Address) (*v1.ChannelData, error) {
+	return channelResult(r.api.PrepareForCooperativeSettle(token, partner))
+}
+
+//CancelPrepareForCooperativeSettle accept new transfers again
+func (r *RaidenAPI) CancelPrepareForCooperativeSettle(token, partner common.Address) (*v1.ChannelData, error) {
+	return channelResult(r.api.CancelPrepareForCooperativeSettle(token, partner))
+}
+
+//ConnectTokenNetwork join the token network of token
+func (r *RaidenAPI) ConnectTokenNetwork(token common.Address, funds *big.Int) error {
+	return r.api.ConnectTokenNetwork(token, funds, params.DefaultInitialChannelTarget, params.DefaultJoinableFundsTarget)
+}
+
+//LeaveTokenNetwork settle or close channels of token
+func (r *RaidenAPI) LeaveTokenNetwork(token common.Address, onlyReceiving bool) ([]common.Hash, error) {
+	return r.api.LeaveTokenNetwork(token, onlyReceiving)
+}
+
+//GetConnectionsInfo returns joined token networks
+func (r *RaidenAPI) GetConnectionsInfo() (map[common.Address]*smartraiden.ConnectionDetail, error) {
+	return r.api.GetConnectionsInfo()
+}
+
+/*
+Transfer send amount of token to target and wait until it finished.
+fee nil means no fee, lockSecretHash empty means the node chooses a secret.
+paymentIdentifier and memo are optional, only for mediated transfer.
+*/
+func (r *RaidenAPI) Transfer(token, target common.Address, amount, fee *big.Int, lockSecretHash common.Hash, isDirect bool, paymentIdentifier *uint64, memo *string) error {
+	id, m := payment(paymentIdentifier, memo)
+	return r.api.TransferAndWait(token, amount, target, params.MaxRequestTimeout, &smartraiden.TransferOptions{
+		Fee:               fee,
+		LockSecretHash:    lockSecretHash,
+		IsDirect:          isDirect,
+		PaymentIdentifier: id,
+		Memo:              m,
+	})
+}
+
+//TransferAsync start a transfer and return its lock secret hash, query it by getTransferStatus
+func (r *RaidenAPI) TransferAsync(token, target common.Address, amount, fee *big.Int, lockSecretHash common.Hash, isMultiPath bool, paymentIdentifier *uint64, memo *string) (common.Hash, error) {
+	id, m := payment(paymentIdentifier, memo)
+	return r.api.TransferAsync(token, amount, target, &smartraiden.TransferOptions{
+		Fee:               fee,
+		LockSecretHash:    lockSecretHash,
+		MultiPath:         isMultiPath,
+		PaymentIdentifier: id,
+		Memo:              m,
+	})
+}
+
+//Keysend start a mediated transfer whose secret is encrypted to targetPublicKey, and wait
+func (r *RaidenAPI) Keysend(token, target common.Address, amount, fee *big.Int, targetPublicKey hexutil.Bytes, paymentIdentifier *uint64, memo *string) error {
+	pub, err := encoding.ParsePublicKey(hexutil.Encode(targetPublicKey))
+	if err != nil {
+		return err
+	}
+	id, m := payment(paymentIdentifier, memo)
+	return r.api.TransferAndWait(token, amount, target, params.MaxRequestTimeout, &smartraiden.TransferOptions{
+		Fee:               fee,
+		PaymentIdentifier: id,
+		Memo:              m,
+		TargetPublicKey:   pub,
+	})
+}
+
+//KeysendAsync is Keysend without waiting, it returns the lock secret hash
+func (r *RaidenAPI) KeysendAsync(token, target common.Address, amount, fee *big.Int, targetPublicKey hexutil.Bytes, paymentIdentifier *uint64, memo *string) (common.Hash, error) {
+	pub, err := encoding.ParsePublicKey(hexutil.Encode(targetPublicKey))
+	if err != nil {
+		return utils.EmptyHash, err
+	}
+	id, m := payment(paymentIdentifier, memo)
+	return r.api.TransferAsync(token, amount, target, &smartraiden.TransferOptions{
+		Fee:               fee,
+		PaymentIdentifier: id,
+		Memo:              m,
+		TargetPublicKey:   pub,
+	})
+}
+
+//payment returns values of optional paymentIdentifier and memo
+func payment(paymentIdentifier *uint64, memo *string) (id uint64, m string) {
+	if paymentIdentifier != nil {
+		id = *paymentIdentifier
+	}
+	if memo != nil {
+		m = *memo
+	}
+	return
+}
+
+//GetTransferStatus returns status of an async transfer
+func (r *RaidenAPI) GetTransferStatus(lockSecretHash common.Hash) (*smartraiden.TransferStatus, error) {
+	return r.api.GetTransferStatus(lockSecretHash)
+}
+
+//CancelTransfer cancel an async transfer whose secret is not revealed
+func (r *RaidenAPI) CancelTransfer(lockSecretHash common.Hash) error {
+	return r.api.CancelTransfer(lockSecretHash)
+}
+
+//GetStateManagers returns transfers in progress on this node
+func (r *RaidenAPI) GetStateManagers() ([]*smartraiden.StateManagerInfo, error) {
+	return r.api.GetStateManagers()
+}
+
+//FindPath returns paths a transfer would use
+func (r *RaidenAPI) FindPath(token, target common.Address, amount *big.Int) ([]*smartraiden.FoundPath, error) {
+	return r.api.FindPath(token, target, amount)
+}
+
+/*
+Rebalance moves our balance between channels of token by a transfer to ourselves and waits,
+ratio and maxFee can be 0 and nil to use the configured ones.
+*/
+func (r *RaidenAPI) Rebalance(token common.Address, ratio float64, maxFee *big.Int) (*smartraiden.Rebalance, error) {
+	return r.api.Rebalance(token, ratio, maxFee, params.MaxRequestTimeout)
+}
+
+//TokenSwap start a token swap as maker or wait for it as taker
+func (r *RaidenAPI) TokenSwap(target common.Address, id string, req *v1.TokenSwapData) error {
+	self := r.api.Address()
+	switch req.Role {
+	case "maker":
+		return r.api.TokenSwapAndWait(id, common.HexToAddress(req.SendingToken), common.HexToAddress(req.ReceivingToken),
+			self, target, req.SendingAmount, req.ReceivingAmount)
+	case "taker":
+		return r.api.ExpectTokenSwap(id, common.HexToAddress(req.ReceivingToken), common.HexToAddress(req.SendingToken),
+			target, self, req.ReceivingAmount, req.SendingAmount)
+	}
+	return fmt.Errorf("Provided invalid token swap role %s", req.Role)
+}
+
+//GetSentTransfers returns transfers sent between fromBlock and toBlock, -1 means no limit
+func (r *RaidenAPI) GetSentTransfers(fromBlock, toBlock int64) ([]*models.SentTransfer, error) {
+	return r.api.GetSentTransfers(fromBlock, toBlock)
+}
+
+//GetReceivedTransfers returns transfers received between fromBlock and toBlock, -1 means no limit
+func (r *RaidenAPI) GetReceivedTransfers(fromBlock, toBlock int64) ([]*models.ReceivedTransfer, error) {
+	return r.api.GetReceivedTransfers(fromBlock, toBlock)
+}
+
+//SentTransferPage is a page of sent transfers, Next is the cursor of the next page, empty if no more
+type SentTransferPage struct {
+	Transfers []*models.SentTransfer `json:"transfers"`
+	Next      string                 `json:"next"`
+}
+
+//ReceivedTransferPage is a page of received transfers, Next is the cursor of the next page, empty if no more
+type ReceivedTransferPage struct {
+	Transfers []*models.ReceivedTransfer `json:"transfers"`
+	Next      string                     `json:"next"`
+}
+
+//QuerySentTransfers returns sent transfers selected by filter
+func (r *RaidenAPI) QuerySentTransfers(f *models.TransferFilter) (*SentTransferPage, error) {
+	trs, next, err := r.api.QuerySentTransfers(f)
+	if err != nil {
+		return nil, err
+	}
+	return &SentTransferPage{trs, next}, nil
+}
+
+//QueryReceivedTransfers returns received transfers selected by filter
+func (r *RaidenAPI) QueryReceivedTransfers(f *models.TransferFilter) (*ReceivedTransferPage, error) {
+	trs, next, err := r.api.QueryReceivedTransfers(f)
+	if err != nil {
+		return nil, err
+	}
+	return &ReceivedTransferPage{trs, next}, nil
+}
+
+//CreateInvoice create an invoice which can be paid within expiry seconds
+func (r *RaidenAPI) CreateInvoice(token common.Address, amount *big.Int, expiry int64, memo *string) (*models.Invoice, error) {
+	_, m := payment(nil, memo)
+	return r.api.CreateInvoice(token, amount, time.Duration(expiry)*time.Second, m)
+}
+
+//CreateHoldInvoice create an invoice whose payment is held until accepted or rejected, lockSecretHash nil means the node keeps the secret
+func (r *RaidenAPI) CreateHoldInvoice(token common.Address, amount *big.Int, expiry int64, memo *string, lockSecretHash *common.Hash) (*models.Invoice, error) {
+	_, m := payment(nil, memo)
+	var h common.Hash
+	if lockSecretHash != nil {
+		h = *lockSecretHash
+	}
+	return r.api.CreateHoldInvoice(token, amount, time.Duration(expiry)*time.Second, m, h)
+}
+
+//AcceptInvoice reveals the secret of a hold invoice to receive the held payment, secret nil means it's kept by the node
+func (r *RaidenAPI) AcceptInvoice(lockSecretHash common.Hash, secret *common.Hash) (*models.Invoice, error) {
+	var s common.Hash
+	if secret != nil {
+		s = *secret
+	}
+	err := r.api.AcceptHeldInvoice(lockSecretHash, s)
+	if err != nil {
+		return nil, err
+	}
+	return r.api.GetInvoice(lockSecretHash)
+}
+
+//RejectInvoice gives back the held payment and cancels a hold invoice
+func (r *RaidenAPI) RejectInvoice(lockSecretHash common.Hash) (*models.Invoice, error) {
+	err := r.api.RejectHeldInvoice(lockSecretHash)
+	if err != nil {
+		return nil, err
+	}
+	return r.api.GetInvoice(lockSecretHash)
+}
+
+//GetInvoices returns invoices created by this node
+func (r *RaidenAPI) GetInvoices() ([]*models.Invoice, error) {
+	return r.api.GetInvoices()
+}
+
+//GetInvoice returns an invoice created by this node
+func (r *RaidenAPI) GetInvoice(lockSecretHash common.Hash) (*models.Invoice, error) {
+	return r.api.GetInvoice(lockSecretHash)
+}
+
+//DecodeInvoice decodes and verifies an invoice uri
+func (r *RaidenAPI) DecodeInvoice(uri string) (*v1.DecodedInvoice, error) {
+	inv, err := r.api.DecodeInvoice(uri)
+	if err != nil {
+		return nil, err
+	}
+	return v1.NewDecodedInvoice(inv), nil
+}
+
+//PayInvoice pays an invoice and wait until it finished, fee nil means no fee
+func (r *RaidenAPI) PayInvoice(uri string, fee *big.Int) (*v1.DecodedInvoice, error) {
+	inv, err := r.api.PayInvoice(uri, fee, params.MaxRequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return v1.NewDecodedInvoice(inv), nil
+}
+
+//GetNetworkEvents returns contract events of the raiden network
+func (r *RaidenAPI) GetNetworkEvents(fromBlock, toBlock int64) ([]*smartraiden.EventData, error) {
+	return r.api.GetNetworkEvents(fromBlock, toBlock)
+}
+
+//GetTokenNetworkEvents returns contract events of the token network of token
+func (r *RaidenAPI) GetTokenNetworkEvents(token common.Address, fromBlock, toBlock int64) ([]*smartraiden.EventData, error) {
+	return r.api.GetTokenNetworkEvents(token, fromBlock, toBlock)
+}
+
+//GetChannelEvents returns contract events of the channel
+func (r *RaidenAPI) GetChannelEvents(channel common.Hash, fromBlock, toBlock int64) ([]*smartraiden.EventData, error) {
+	return r.api.GetChannelEvents(channel, fromBlock, toBlock)
+}
+
+//GetInternalEvents returns events recorded by this node
+func (r *RaidenAPI) GetInternalEvents(fromBlock, toBlock int64) ([]*models.InternalEvent, error) {
+	return r.api.GetInternalEvents(fromBlock, toBlock)
+}
+
+//GetPunishments returns punishments of obsolete unlocks submitted by this node
+func (r *RaidenAPI) GetPunishments() ([]*models.Punishment, error) {
+	return r.api.GetPunishments()
+}
+
+//GetFeePolicy returns the mediation fee policy
+func (r *RaidenAPI) GetFeePolicy() (*models.FeePolicy, error) {
+	return r.api.GetFeePolicy()
+}
+
+//SetFeePolicy replace the mediation fee policy
+func (r *RaidenAPI) SetFeePolicy(fp *models.FeePolicy) error {
+	return r.api.SetFeePolicy(fp)
+}
+
+//GetDepositPolicy returns the auto deposit policy
+func (r *RaidenAPI) GetDepositPolicy() (*models.DepositPolicy, error) {
+	return r.api.GetDepositPolicy()
+}
+
+//SetDepositPolicy replace the auto deposit policy
+func (r *RaidenAPI) SetDepositPolicy(dp *models.DepositPolicy) error {
+	return r.api.SetDepositPolicy(dp)
+}
+
+//GetAutoDeposits returns deposits triggered by the deposit policy
+func (r *RaidenAPI) GetAutoDeposits() ([]*models.AutoDeposit, error) {
+	return r.api.GetAutoDeposits()
+}
+
+/*
+subscribe pushes notifications accepted by filter until the client unsubscribes,
+if the subscriber is too slow, the subscription ends and the client should subscribe again.
+*/
+func (r *RaidenAPI) subscribe(ctx context.Context, filter func(e *smartraiden.NotifyEvent) bool) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+	sub, _, err := r.api.Subscribe(-1)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case e := <-sub.C:
+				if !filter(e) {
+					continue
+				}
+				err := notifier.Notify(rpcSub.ID, e)
+				if err != nil {
+					log.Info(fmt.Sprintf("notify subscription %s err %s", rpcSub.ID, err))
+					return
+				}
+			case <-sub.Done():
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+//NewTransfers subscribe transfers sent and received, by raiden_subscribe("newTransfers")
+func (r *RaidenAPI) NewTransfers(ctx context.Context) (*rpc.Subscription, error) {
+	return r.subscribe(ctx, func(e *smartraiden.NotifyEvent) bool {
+		return e.Type == smartraiden.NotifySentTransfer || e.Type == smartraiden.NotifyReceivedTransfer
+	})
+}
+
+//ChannelEvents subscribe new, deposited, state changed and settled channels, by raiden_subscribe("channelEvents")
+func (r *RaidenAPI) ChannelEvents(ctx context.Context) (*rpc.Subscription, error) {
+	return r.subscribe(ctx, func(e *smartraiden.NotifyEvent) bool {
+		return e.Channel != nil
+	})
+}
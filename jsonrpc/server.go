@@ -0,0 +1,138 @@
+/*
+Package jsonrpc serves RaidenAPI by json-rpc over http and ipc, the same protocol as geth.
+namespace "raiden" is the api of this node, namespace "debug" is internal/debug.
+subscriptions are only available over ipc.
+*/
+package jsonrpc
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/SmartMeshFoundation/SmartRaiden"
+	"github.com/SmartMeshFoundation/SmartRaiden/internal/debug"
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/SmartMeshFoundation/SmartRaiden/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+//Server is the json-rpc server of a node
+type Server struct {
+	ipcListener  net.Listener
+	httpListener net.Listener
+	ipcServer    *rpc.Server
+	httpServer   *rpc.Server
+}
+
+func newServer(api *smartraiden.RaidenAPI, withDebug bool) (*rpc.Server, error) {
+	srv := rpc.NewServer()
+	err := srv.RegisterName("raiden", NewRaidenAPI(api))
+	if err != nil {
+		return nil, err
+	}
+	if withDebug {
+		err = srv.RegisterName("debug", debug.Handler)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return srv, nil
+}
+
+/*
+Start json-rpc on config.IPCPath and config.RPCHost:config.RPCPort, empty path or zero port means disabled.
+ipc socket can only be accessed by the same user, so debug is always available there,
+over http debug is available only with EnableDebugAPI and the operator token is required if it's set.
+*/
+func Start(api *smartraiden.RaidenAPI, config *params.Config) (s *Server, err error) {
+	s = new(Server)
+	defer func() {
+		if err != nil {
+			s.Stop()
+			s = nil
+		}
+	}()
+	if config.IPCPath != "" {
+		s.ipcServer, err = newServer(api, true)
+		if err != nil {
+			return
+		}
+		s.ipcListener, err = rpc.CreateIPCListener(config.IPCPath)
+		if err != nil {
+			return
+		}
+		go s.ipcServer.ServeListener(s.ipcListener)
+		log.Info(fmt.Sprintf("json-rpc ipc listens on %s", config.IPCPath))
+	}
+	if config.RPCPort > 0 {
+		s.httpServer, err = newServer(api, config.EnableDebugAPI)
+		if err != nil {
+			return
+		}
+		listen := fmt.Sprintf("%s:%d", config.RPCHost, config.RPCPort)
+		s.httpListener, err = net.Listen("tcp", listen)
+		if err != nil {
+			return
+		}
+		var handler http.Handler = s.httpServer
+		if config.APIToken != "" {
+			handler = &authHandler{token: config.APIToken, next: handler}
+		} else if config.RPCHost != "127.0.0.1" && config.RPCHost != "localhost" {
+			log.Warn(fmt.Sprintf("json-rpc listens on %s without authentication", config.RPCHost))
+		}
+		go http.Serve(s.httpListener, handler)
+		log.Info(fmt.Sprintf("json-rpc http listens on %s", listen))
+	}
+	return
+}
+
+/*
+DialInProc returns a client calling api in process, the same as ipc.
+it is used by the console started with the node.
+*/
+func DialInProc(api *smartraiden.RaidenAPI) (*rpc.Client, error) {
+	srv, err := newServer(api, true)
+	if err != nil {
+		return nil, err
+	}
+	return rpc.DialInProc(srv), nil
+}
+
+//Stop close listeners and stop serving, ipc socket file is removed. it's safe to stop a nil server
+func (s *Server) Stop() {
+	if s == nil {
+		return
+	}
+	if s.ipcListener != nil {
+		s.ipcListener.Close()
+	}
+	if s.httpListener != nil {
+		s.httpListener.Close()
+	}
+	if s.ipcServer != nil {
+		s.ipcServer.Stop()
+	}
+	if s.httpServer != nil {
+		s.httpServer.Stop()
+	}
+}
+
+//authHandler requires the operator token, json-rpc cannot tell queries from operations by url
+type authHandler struct {
+	token string
+	next  http.Handler
+}
+
+func (h *authHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	token := strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	if !strings.HasPrefix(auth, "Bearer ") || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.next.ServeHTTP(w, r)
+}
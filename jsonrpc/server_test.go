@@ -0,0 +1,103 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/params"
+	"github.com/stretchr/testify/assert"
+)
+
+func postRPC(t *testing.T, url, token, body string) *http.Response {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestServerAuth(t *testing.T) {
+	srv, err := newServer(nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+	ts := httptest.NewServer(&authHandler{token: "secret", next: srv})
+	defer ts.Close()
+	body := `{"jsonrpc":"2.0","id":1,"method":"rpc_modules","params":[]}`
+
+	resp := postRPC(t, ts.URL, "", body)
+	resp.Body.Close()
+	assert.EqualValues(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = postRPC(t, ts.URL, "wrong", body)
+	resp.Body.Close()
+	assert.EqualValues(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = postRPC(t, ts.URL, "secret", body)
+	defer resp.Body.Close()
+	assert.EqualValues(t, http.StatusOK, resp.StatusCode)
+	var ret struct {
+		Result map[string]string
+	}
+	err = json.NewDecoder(resp.Body).Decode(&ret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ok := ret.Result["raiden"]
+	assert.EqualValues(t, true, ok)
+	_, ok = ret.Result["debug"]
+	assert.EqualValues(t, false, ok)
+}
+
+func TestServerStop(t *testing.T) {
+	var s *Server
+	s.Stop() //mobile mode has no json-rpc server
+	dir, err := ioutil.TempDir("", "jsonrpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	config := &params.Config{
+		IPCPath: filepath.Join(dir, "smartraiden.ipc"),
+		RPCHost: "127.0.0.1",
+		RPCPort: port,
+	}
+	s, err = Start(nil, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = os.Stat(config.IPCPath)
+	assert.EqualValues(t, nil, err)
+	s.Stop()
+	_, err = os.Stat(config.IPCPath)
+	assert.EqualValues(t, true, os.IsNotExist(err), "ipc socket should be removed")
+	_, err = net.Dial("tcp", l.Addr().String())
+	assert.NotEqual(t, nil, err, "http should stop listening")
+	//the same address can be used again
+	s, err = Start(nil, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Stop()
+}
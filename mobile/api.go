@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 
 	"fmt"
+	"strconv"
 	"time"
 
 	"math/big"
@@ -11,14 +12,17 @@ import (
 	"errors"
 
 	"github.com/SmartMeshFoundation/SmartRaiden"
+	"github.com/SmartMeshFoundation/SmartRaiden/encoding"
 	"github.com/SmartMeshFoundation/SmartRaiden/internal/rpanic"
 	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
 	"github.com/SmartMeshFoundation/SmartRaiden/network"
 	"github.com/SmartMeshFoundation/SmartRaiden/network/netshare"
 	"github.com/SmartMeshFoundation/SmartRaiden/params"
 	"github.com/SmartMeshFoundation/SmartRaiden/restful/v1"
 	"github.com/SmartMeshFoundation/SmartRaiden/utils"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 )
 
 //API for export interface
@@ -26,6 +30,21 @@ type API struct {
 	api *smartraiden.RaidenAPI
 }
 
+/*
+parsePaymentIdentifier parses payment identifier in decimal, empty means none.
+gomobile has no uint64, so it's passed as string to use the whole range.
+*/
+func parsePaymentIdentifier(s string) (uint64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid payment identifier %s", s)
+	}
+	return id, nil
+}
+
 func marshal(v interface{}) (s string, err error) {
 	d, err := json.Marshal(v)
 	if err != nil {
@@ -55,6 +74,7 @@ func (a *API) GetChannelList() (channels string, err error) {
 			LockedAmount:        c.OurAmountLocked(),
 			PartnerLockedAmount: c.PartnerAmountLocked(),
 			State:               c.State,
+			PrepareState:        v1.ChannelPrepareState(c.State),
 			TokenAddress:        c.TokenAddress().String(),
 			SettleTimeout:       c.SettleTimeout,
 			RevealTimeout:       c.RevealTimeout,
@@ -82,6 +102,7 @@ func (a *API) GetOneChannel(channelAddress string) (channel string, err error) {
 		Balance:                  c.OurBalance(),
 		PartnerBalance:           c.PartnerBalance(),
 		State:                    c.State,
+		PrepareState:             v1.ChannelPrepareState(c.State),
 		SettleTimeout:            c.SettleTimeout,
 		TokenAddress:             c.TokenAddress().String(),
 		LockedAmount:             c.OurAmountLocked(),
@@ -122,6 +143,7 @@ func (a *API) OpenChannel(partnerAddress, tokenAddress string, settleTimeout int
 		Balance:             c.OurBalance(),
 		PartnerBalance:      c.PartnerBalance(),
 		State:               c.State,
+		PrepareState:        v1.ChannelPrepareState(c.State),
 		SettleTimeout:       c.SettleTimeout,
 		TokenAddress:        c.TokenAddress().String(),
 		LockedAmount:        c.OurAmountLocked(),
@@ -164,6 +186,7 @@ func (a *API) CloseChannel(channelAddress string, force bool) (channel string, e
 		Balance:             c.OurBalance(),
 		PartnerBalance:      c.PartnerBalance(),
 		State:               c.State,
+		PrepareState:        v1.ChannelPrepareState(c.State),
 		SettleTimeout:       c.SettleTimeout,
 		TokenAddress:        c.TokenAddress().String(),
 		LockedAmount:        c.OurAmountLocked(),
@@ -198,6 +221,7 @@ func (a *API) SettleChannel(channelAddres string) (channel string, err error) {
 		Balance:             c.OurBalance(),
 		PartnerBalance:      c.PartnerBalance(),
 		State:               c.State,
+		PrepareState:        v1.ChannelPrepareState(c.State),
 		SettleTimeout:       c.SettleTimeout,
 		TokenAddress:        c.TokenAddress().String(),
 		LockedAmount:        c.OurAmountLocked(),
@@ -234,6 +258,7 @@ func (a *API) DepositChannel(channelAddres string, balanceStr string) (channel s
 		Balance:             c.OurBalance(),
 		PartnerBalance:      c.PartnerBalance(),
 		State:               c.State,
+		PrepareState:        v1.ChannelPrepareState(c.State),
 		SettleTimeout:       c.SettleTimeout,
 		TokenAddress:        c.TokenAddress().String(),
 		LockedAmount:        c.OurAmountLocked(),
@@ -278,11 +303,37 @@ func (a *API) ChannelsEvent(fromBlock, toBlock int64, channelAddress string) (ev
 	return
 }
 
+//InternalEvent GET /api/1/events/internal?from_block=1337
+func (a *API) InternalEvent(fromBlock, toBlock int64) (eventsString string, err error) {
+	events, err := a.api.GetInternalEvents(fromBlock, toBlock)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	eventsString, err = marshal(events)
+	return
+}
+
+//Punishments GET /api/1/punishments
+func (a *API) Punishments() (punishments string, err error) {
+	ps, err := a.api.GetPunishments()
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	return marshal(ps)
+}
+
 //Address GET /api/1/address
 func (a *API) Address() (addr string) {
 	return a.api.Address().String()
 }
 
+//PublicKey returns the compressed public key of this node in hex, give it to whom keysends to us
+func (a *API) PublicKey() string {
+	return hexutil.Encode(a.api.PublicKey())
+}
+
 //Tokens GET /api/1/tokens
 func (a *API) Tokens() (tokens string) {
 	tokens, err := marshal(a.api.Tokens())
@@ -368,6 +419,245 @@ func (a *API) Transfers(tokenAddress, targetAddress string, amountstr string, fe
 	return marshal(req)
 }
 
+/*
+MultiPathTransfers POST /api/1/transfers/0x2a65aca4d5fc5b5c859090a6c34d164135398226/0x61c808d82a3ac53231750dadc13c777b59310bd9 with multi_path true
+amount is split to several routes sharing one lock secret hash, fee is the limit for all routes.
+*/
+func (a *API) MultiPathTransfers(tokenAddress, targetAddress string, amountstr string, feestr string, lockSecretHashstr string) (transfer string, err error) {
+	defer func() {
+		log.Trace(fmt.Sprintf("Api MultiPathTransfers tokenAddress=%s,targetAddress=%s,amountstr=%s,feestr=%s,id=%s,\nout transfer=\n%s,err=%v",
+			tokenAddress, targetAddress, amountstr, feestr, lockSecretHashstr, transfer, err,
+		))
+	}()
+	tokenAddr := common.HexToAddress(tokenAddress)
+	targetAddr := common.HexToAddress(targetAddress)
+	amount, _ := new(big.Int).SetString(amountstr, 0)
+	fee, _ := new(big.Int).SetString(feestr, 0)
+	lockSecretHash := common.HexToHash(lockSecretHashstr)
+	if amount == nil || amount.Cmp(utils.BigInt0) <= 0 {
+		err = errors.New("amount should be positive")
+		return
+	}
+	if fee == nil {
+		fee = utils.BigInt0
+	}
+	err = a.api.TransferAndWait(tokenAddr, amount, targetAddr, params.MaxRequestTimeout, &smartraiden.TransferOptions{
+		Fee:            fee,
+		LockSecretHash: lockSecretHash,
+		MultiPath:      true,
+	})
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	req := &v1.TransferData{}
+	req.Initiator = a.api.Raiden.NodeAddress.String()
+	req.Target = targetAddress
+	req.Token = tokenAddress
+	req.Amount = amount
+	req.LockSecretHash = lockSecretHashstr
+	req.Fee = fee
+	req.MultiPath = true
+	return marshal(req)
+}
+
+/*
+Keysend is a mediated transfer whose secret is encrypted to targetPublicKey,
+target reveals the secret as soon as the transfer arrives, no secret request round trip is needed.
+returns the transfer with lock secret hash.
+*/
+func (a *API) Keysend(tokenAddress, targetAddress string, amountstr string, feestr string, targetPublicKey string, paymentIdentifierstr string, memo string) (transfer string, err error) {
+	defer func() {
+		log.Trace(fmt.Sprintf("Api Keysend tokenAddress=%s,targetAddress=%s,amountstr=%s,feestr=%s,targetPublicKey=%s,paymentIdentifier=%s,memo=%s,\nout transfer=\n%s,err=%v",
+			tokenAddress, targetAddress, amountstr, feestr, targetPublicKey, paymentIdentifierstr, memo, transfer, err,
+		))
+	}()
+	tokenAddr := common.HexToAddress(tokenAddress)
+	targetAddr := common.HexToAddress(targetAddress)
+	amount, _ := new(big.Int).SetString(amountstr, 0)
+	fee, _ := new(big.Int).SetString(feestr, 0)
+	if amount == nil || amount.Cmp(utils.BigInt0) <= 0 {
+		err = errors.New("amount should be positive")
+		return
+	}
+	if fee == nil {
+		fee = utils.BigInt0
+	}
+	paymentIdentifier, err := parsePaymentIdentifier(paymentIdentifierstr)
+	if err != nil {
+		return
+	}
+	pub, err := encoding.ParsePublicKey(targetPublicKey)
+	if err != nil {
+		return
+	}
+	lockSecretHash, err := a.api.TransferAsync(tokenAddr, amount, targetAddr, &smartraiden.TransferOptions{
+		Fee:               fee,
+		PaymentIdentifier: paymentIdentifier,
+		Memo:              memo,
+		TargetPublicKey:   pub,
+	})
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	req := &v1.TransferData{}
+	req.Initiator = a.api.Raiden.NodeAddress.String()
+	req.Target = targetAddress
+	req.Token = tokenAddress
+	req.Amount = amount
+	req.LockSecretHash = lockSecretHash.String()
+	req.Fee = fee
+	req.IsAsync = true
+	req.PaymentIdentifier = paymentIdentifier
+	req.Memo = memo
+	req.TargetPublicKey = targetPublicKey
+	return marshal(req)
+}
+
+/*
+TransferWithPayment is a mediated transfer carrying paymentIdentifier and memo to target,
+target can find it by GetReceivedTransfersByPaymentIdentifier.
+*/
+func (a *API) TransferWithPayment(tokenAddress, targetAddress string, amountstr string, feestr string, lockSecretHashstr string, isMultiPath bool, paymentIdentifierstr string, memo string) (transfer string, err error) {
+	defer func() {
+		log.Trace(fmt.Sprintf("Api TransferWithPayment tokenAddress=%s,targetAddress=%s,amountstr=%s,feestr=%s,id=%s,isMultiPath=%v,paymentIdentifier=%s,memo=%s,\nout transfer=\n%s,err=%v",
+			tokenAddress, targetAddress, amountstr, feestr, lockSecretHashstr, isMultiPath, paymentIdentifierstr, memo, transfer, err,
+		))
+	}()
+	tokenAddr := common.HexToAddress(tokenAddress)
+	targetAddr := common.HexToAddress(targetAddress)
+	amount, _ := new(big.Int).SetString(amountstr, 0)
+	fee, _ := new(big.Int).SetString(feestr, 0)
+	lockSecretHash := common.HexToHash(lockSecretHashstr)
+	if amount == nil || amount.Cmp(utils.BigInt0) <= 0 {
+		err = errors.New("amount should be positive")
+		return
+	}
+	if fee == nil {
+		fee = utils.BigInt0
+	}
+	paymentIdentifier, err := parsePaymentIdentifier(paymentIdentifierstr)
+	if err != nil {
+		return
+	}
+	err = a.api.TransferAndWait(tokenAddr, amount, targetAddr, params.MaxRequestTimeout, &smartraiden.TransferOptions{
+		Fee:               fee,
+		LockSecretHash:    lockSecretHash,
+		MultiPath:         isMultiPath,
+		PaymentIdentifier: paymentIdentifier,
+		Memo:              memo,
+	})
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	req := &v1.TransferData{}
+	req.Initiator = a.api.Raiden.NodeAddress.String()
+	req.Target = targetAddress
+	req.Token = tokenAddress
+	req.Amount = amount
+	req.LockSecretHash = lockSecretHashstr
+	req.Fee = fee
+	req.MultiPath = isMultiPath
+	req.PaymentIdentifier = paymentIdentifier
+	req.Memo = memo
+	return marshal(req)
+}
+
+/*
+PrepareForCooperativeSettle mark a channel prepared for cooperative settle,
+no new transfer is accepted, after all the locks are finished, call CloseChannel with force=false.
+*/
+func (a *API) PrepareForCooperativeSettle(channelAddress string) (channel string, err error) {
+	return a.prepareForCooperativeSettle(channelAddress, false)
+}
+
+//CancelPrepareForCooperativeSettle channel can be used for transfer again
+func (a *API) CancelPrepareForCooperativeSettle(channelAddress string) (channel string, err error) {
+	return a.prepareForCooperativeSettle(channelAddress, true)
+}
+
+func (a *API) prepareForCooperativeSettle(channelAddress string, cancel bool) (channel string, err error) {
+	defer func() {
+		log.Trace(fmt.Sprintf("Api prepareForCooperativeSettle in channelAddress=%s,cancel=%v,out channel=\n%s,err=%v",
+			channelAddress, cancel, channel, err,
+		))
+	}()
+	c, err := a.api.GetChannel(common.HexToHash(channelAddress))
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	if cancel {
+		c, err = a.api.CancelPrepareForCooperativeSettle(c.TokenAddress(), c.PartnerAddress())
+	} else {
+		c, err = a.api.PrepareForCooperativeSettle(c.TokenAddress(), c.PartnerAddress())
+	}
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	d := &v1.ChannelData{
+		ChannelAddress:      common.BytesToHash(c.Key).String(),
+		OpenBlockNumber:     c.ChannelIdentifier.OpenBlockNumber,
+		PartnerAddrses:      c.PartnerAddress().String(),
+		Balance:             c.OurBalance(),
+		PartnerBalance:      c.PartnerBalance(),
+		State:               c.State,
+		StateString:         c.State.String(),
+		PrepareState:        v1.ChannelPrepareState(c.State),
+		SettleTimeout:       c.SettleTimeout,
+		RevealTimeout:       c.RevealTimeout,
+		TokenAddress:        c.TokenAddress().String(),
+		LockedAmount:        c.OurAmountLocked(),
+		PartnerLockedAmount: c.PartnerAmountLocked(),
+	}
+	channel, err = marshal(d)
+	return
+}
+
+/*
+TransferAsync start a mediated transfer and return immediately, returns lock secret hash of this transfer.
+*/
+func (a *API) TransferAsync(tokenAddress, targetAddress string, amountstr string, feestr string, lockSecretHashstr string, isMultiPath bool) (lockSecretHash string, err error) {
+	amount, _ := new(big.Int).SetString(amountstr, 0)
+	fee, _ := new(big.Int).SetString(feestr, 0)
+	if amount == nil || amount.Cmp(utils.BigInt0) <= 0 {
+		err = errors.New("amount should be positive")
+		return
+	}
+	if fee == nil {
+		fee = utils.BigInt0
+	}
+	h, err := a.api.TransferAsync(common.HexToAddress(tokenAddress), amount, common.HexToAddress(targetAddress), &smartraiden.TransferOptions{
+		Fee:            fee,
+		LockSecretHash: common.HexToHash(lockSecretHashstr),
+		MultiPath:      isMultiPath,
+	})
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	lockSecretHash = h.String()
+	return
+}
+
+//GetTransferStatus returns status of a transfer started by TransferAsync
+func (a *API) GetTransferStatus(lockSecretHash string) (status string, err error) {
+	st, err := a.api.GetTransferStatus(common.HexToHash(lockSecretHash))
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	return marshal(st)
+}
+
+//CancelTransfer cancel a transfer whose secret has not been revealed
+func (a *API) CancelTransfer(lockSecretHash string) (err error) {
+	return a.api.CancelTransfer(common.HexToHash(lockSecretHash))
+}
+
 /*
 TokenSwap token swap for maker
 role: "maker" or "taker"
@@ -497,6 +787,297 @@ func (a *API) GetReceivedTransfers(from, to int64) (r string, err error) {
 	return
 }
 
+//GetReceivedTransfersByPaymentIdentifier retuns received transfers carrying paymentIdentifier
+func (a *API) GetReceivedTransfersByPaymentIdentifier(paymentIdentifierstr string) (r string, err error) {
+	paymentIdentifier, err := parsePaymentIdentifier(paymentIdentifierstr)
+	if err != nil {
+		return
+	}
+	if paymentIdentifier == 0 {
+		err = errors.New("payment identifier should be positive")
+		return
+	}
+	trs, _, err := a.api.QueryReceivedTransfers(&models.TransferFilter{FromBlock: -1, ToBlock: -1, PaymentIdentifier: paymentIdentifier})
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	r, err = marshal(trs)
+	return
+}
+
+/*
+FindPath GET /api/1/path/0x745D52e50cd1b19563D3a3B7B6d2eB60b17E6bAE/0x69C5621db8093ee9a26cc2e253f929316E6E5b92?amount=10
+returns paths a transfer would use and their fee, nothing is sent.
+*/
+func (a *API) FindPath(tokenAddress, targetAddress string, amountstr string) (paths string, err error) {
+	amount, ok := new(big.Int).SetString(amountstr, 0)
+	if !ok {
+		err = errors.New("invalid amount")
+		return
+	}
+	ps, err := a.api.FindPath(common.HexToAddress(tokenAddress), common.HexToAddress(targetAddress), amount)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	paths, err = marshal(ps)
+	return
+}
+
+/*
+Rebalance POST /api/1/rebalance/0x745D52e50cd1b19563D3a3B7B6d2eB60b17E6bAE
+ratio 0 and empty maxFee means the configured ones.
+*/
+func (a *API) Rebalance(tokenAddress string, ratio float64, maxFeeStr string) (rebalance string, err error) {
+	var maxFee *big.Int
+	if maxFeeStr != "" {
+		var ok bool
+		maxFee, ok = new(big.Int).SetString(maxFeeStr, 0)
+		if !ok {
+			err = errors.New("invalid max fee")
+			return
+		}
+	}
+	rb, err := a.api.Rebalance(common.HexToAddress(tokenAddress), ratio, maxFee, params.MaxRequestTimeout)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	rebalance, err = marshal(rb)
+	return
+}
+
+//GetFeePolicy GET /api/1/fee_policy
+func (a *API) GetFeePolicy() (policy string, err error) {
+	fp, err := a.api.GetFeePolicy()
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	policy, err = marshal(fp)
+	return
+}
+
+/*
+SetFeePolicy PUT /api/1/fee_policy
+policy example:
+{
+    "default_fee": {"base_fee": 3, "fee_rate": 100},
+    "token_fees": {"0x745D52e50cd1b19563D3a3B7B6d2eB60b17E6bAE": {"base_fee": 1, "fee_rate": 0, "max_fee": 10}}
+}
+*/
+func (a *API) SetFeePolicy(policy string) (err error) {
+	defer func() {
+		log.Trace(fmt.Sprintf("Api SetFeePolicy in=%s,err=%v", policy, err))
+	}()
+	fp := &models.FeePolicy{}
+	err = json.Unmarshal([]byte(policy), fp)
+	if err != nil {
+		return
+	}
+	return a.api.SetFeePolicy(fp)
+}
+
+//GetDepositPolicy GET /api/1/deposit_policy
+func (a *API) GetDepositPolicy() (policy string, err error) {
+	dp, err := a.api.GetDepositPolicy()
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	policy, err = marshal(dp)
+	return
+}
+
+/*
+SetDepositPolicy PUT /api/1/deposit_policy
+policy example:
+{
+    "dry_run": false,
+    "token_deposits": {"0x745D52e50cd1b19563D3a3B7B6d2eB60b17E6bAE": {"min_balance": 10, "amount": 100, "max_daily": 300}}
+}
+*/
+func (a *API) SetDepositPolicy(policy string) (err error) {
+	defer func() {
+		log.Trace(fmt.Sprintf("Api SetDepositPolicy in=%s,err=%v", policy, err))
+	}()
+	dp := &models.DepositPolicy{}
+	err = json.Unmarshal([]byte(policy), dp)
+	if err != nil {
+		return
+	}
+	return a.api.SetDepositPolicy(dp)
+}
+
+//AutoDeposits GET /api/1/auto_deposits
+func (a *API) AutoDeposits() (deposits string, err error) {
+	ds, err := a.api.GetAutoDeposits()
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	return marshal(ds)
+}
+
+/*
+CreateInvoice POST /api/1/invoices
+expiry is seconds the invoice can be paid within, give uri of the result to the payer.
+*/
+func (a *API) CreateInvoice(tokenAddress string, amountstr string, expiry int64, memo string) (invoice string, err error) {
+	defer func() {
+		log.Trace(fmt.Sprintf("Api CreateInvoice tokenAddress=%s,amountstr=%s,expiry=%d,memo=%s,\nout invoice=\n%s,err=%v",
+			tokenAddress, amountstr, expiry, memo, invoice, err,
+		))
+	}()
+	amount, ok := new(big.Int).SetString(amountstr, 0)
+	if !ok {
+		err = errors.New("amount is not a integer")
+		return
+	}
+	inv, err := a.api.CreateInvoice(common.HexToAddress(tokenAddress), amount, time.Duration(expiry)*time.Second, memo)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	return marshal(inv)
+}
+
+/*
+CreateHoldInvoice POST /api/1/invoices with hold
+the payment is held until AcceptHeldInvoice or RejectHeldInvoice,
+lockSecretHash empty means the secret is kept by the node.
+*/
+func (a *API) CreateHoldInvoice(tokenAddress string, amountstr string, expiry int64, memo string, lockSecretHash string) (invoice string, err error) {
+	defer func() {
+		log.Trace(fmt.Sprintf("Api CreateHoldInvoice tokenAddress=%s,amountstr=%s,expiry=%d,memo=%s,lockSecretHash=%s,\nout invoice=\n%s,err=%v",
+			tokenAddress, amountstr, expiry, memo, lockSecretHash, invoice, err,
+		))
+	}()
+	amount, ok := new(big.Int).SetString(amountstr, 0)
+	if !ok {
+		err = errors.New("amount is not a integer")
+		return
+	}
+	var h common.Hash
+	if lockSecretHash != "" {
+		h = common.HexToHash(lockSecretHash)
+	}
+	inv, err := a.api.CreateHoldInvoice(common.HexToAddress(tokenAddress), amount, time.Duration(expiry)*time.Second, memo, h)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	return marshal(inv)
+}
+
+//AcceptHeldInvoice POST /api/1/invoices/0x2a8c...f57e/accept, secret empty means it's kept by the node
+func (a *API) AcceptHeldInvoice(lockSecretHash string, secret string) (err error) {
+	defer func() {
+		log.Trace(fmt.Sprintf("Api AcceptHeldInvoice lockSecretHash=%s,err=%v", lockSecretHash, err))
+	}()
+	var s common.Hash
+	if secret != "" {
+		s = common.HexToHash(secret)
+	}
+	return a.api.AcceptHeldInvoice(common.HexToHash(lockSecretHash), s)
+}
+
+//RejectHeldInvoice POST /api/1/invoices/0x2a8c...f57e/reject
+func (a *API) RejectHeldInvoice(lockSecretHash string) (err error) {
+	defer func() {
+		log.Trace(fmt.Sprintf("Api RejectHeldInvoice lockSecretHash=%s,err=%v", lockSecretHash, err))
+	}()
+	return a.api.RejectHeldInvoice(common.HexToHash(lockSecretHash))
+}
+
+//GetInvoices GET /api/1/invoices
+func (a *API) GetInvoices() (invoices string, err error) {
+	invs, err := a.api.GetInvoices()
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	return marshal(invs)
+}
+
+//DecodeInvoice GET /api/1/decodeinvoice?uri=smartraiden:...
+func (a *API) DecodeInvoice(uri string) (invoice string, err error) {
+	inv, err := a.api.DecodeInvoice(uri)
+	if err != nil {
+		return
+	}
+	return marshal(v1.NewDecodedInvoice(inv))
+}
+
+//PayInvoice POST /api/1/payinvoice
+func (a *API) PayInvoice(uri string, feestr string) (invoice string, err error) {
+	defer func() {
+		log.Trace(fmt.Sprintf("Api PayInvoice uri=%s,feestr=%s,\nout invoice=\n%s,err=%v", uri, feestr, invoice, err))
+	}()
+	fee, _ := new(big.Int).SetString(feestr, 0)
+	inv, err := a.api.PayInvoice(uri, fee, params.MaxRequestTimeout)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	return marshal(v1.NewDecodedInvoice(inv))
+}
+
+//Connections GET /api/1/connections
+func (a *API) Connections() (r string, err error) {
+	defer func() {
+		log.Trace(fmt.Sprintf("Api Connections out=\n%s,err=%v", r, err))
+	}()
+	infos, err := a.api.GetConnectionsInfo()
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	r, err = marshal(infos)
+	return
+}
+
+/*
+ConnectTokenNetwork PUT /api/1/connections/0x2a65aca4d5fc5b5c859090a6c34d164135398226
+automatically join a token network
+*/
+func (a *API) ConnectTokenNetwork(tokenAddress string, fundsStr string, initialChannelTarget int, joinableFundsTarget float64) (err error) {
+	defer func() {
+		log.Trace(fmt.Sprintf("Api ConnectTokenNetwork tokenAddress=%s,funds=%s,initialChannelTarget=%d,joinableFundsTarget=%f,err=%v",
+			tokenAddress, fundsStr, initialChannelTarget, joinableFundsTarget, err,
+		))
+	}()
+	tokenAddr := common.HexToAddress(tokenAddress)
+	funds, ok := new(big.Int).SetString(fundsStr, 0)
+	if !ok {
+		err = errors.New("funds is not a integer")
+		return
+	}
+	err = a.api.ConnectTokenNetwork(tokenAddr, funds, initialChannelTarget, joinableFundsTarget)
+	return
+}
+
+/*
+LeaveTokenNetwork DELETE /api/1/connections/0x2a65aca4d5fc5b5c859090a6c34d164135398226
+returns addresses of channels left
+*/
+func (a *API) LeaveTokenNetwork(tokenAddress string, onlyReceivingChannels bool) (channels string, err error) {
+	defer func() {
+		log.Trace(fmt.Sprintf("Api LeaveTokenNetwork tokenAddress=%s,onlyReceivingChannels=%v,\nout channels=%s,err=%v",
+			tokenAddress, onlyReceivingChannels, channels, err,
+		))
+	}()
+	tokenAddr := common.HexToAddress(tokenAddress)
+	chs, err := a.api.LeaveTokenNetwork(tokenAddr, onlyReceivingChannels)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	channels, err = marshal(chs)
+	return
+}
+
 // Subscription represents an event subscription where events are
 // delivered on a data channel.
 type Subscription struct {
@@ -77,3 +77,26 @@ func TestFormat(t *testing.T) {
 	a := utils.NewRandomAddress()
 	t.Logf("a=%q,a=%v,a=%s", a, a, a)
 }
+
+func TestParsePaymentIdentifier(t *testing.T) {
+	cases := []struct {
+		s   string
+		id  uint64
+		err bool
+	}{
+		{"", 0, false},
+		{"0", 0, false},
+		{"123", 123, false},
+		{"18446744073709551615", 18446744073709551615, false},
+		{"18446744073709551616", 0, true},
+		{"-1", 0, true},
+		{"0x10", 0, true},
+		{"abc", 0, true},
+	}
+	for _, c := range cases {
+		id, err := parsePaymentIdentifier(c.s)
+		if (err != nil) != c.err || id != c.id {
+			t.Errorf("parse %q got %d,%v", c.s, id, err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"encoding/gob"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//status of async transfer
+const (
+	AsyncTransferPending = "pending"
+	AsyncTransferSuccess = "success"
+	AsyncTransferFailed  = "failed"
+)
+
+/*
+AsyncTransfer is a transfer started asynchronously by user,
+it's kept for a while after finished, so user can query the result.
+*/
+type AsyncTransfer struct {
+	LockSecretHash common.Hash `storm:"id"`
+	Token          common.Address
+	Status         string
+	Error          string
+	FinishedBlock  int64 `storm:"index"` //0 if not finished
+}
+
+/*
+CanceledLock is a lock already sent when its transfer is canceled,
+the state manager is gone, so it has to be removed from channel after it expired.
+*/
+type CanceledLock struct {
+	ID                int `storm:"id,increment"`
+	ChannelIdentifier common.Hash
+	LockSecretHash    common.Hash
+	Expiration        int64 `storm:"index"`
+}
+
+func init() {
+	gob.Register(&AsyncTransfer{})
+	gob.Register(&CanceledLock{})
+}
+
+//NewAsyncTransfer save a pending async transfer
+func (model *ModelDB) NewAsyncTransfer(lockSecretHash common.Hash, token common.Address) error {
+	return model.db.Save(&AsyncTransfer{
+		LockSecretHash: lockSecretHash,
+		Token:          token,
+		Status:         AsyncTransferPending,
+	})
+}
+
+/*
+FinishAsyncTransfer mark an async transfer finished at blockNumber, errMsg empty means success.
+nothing happens if it's not an async transfer or it has finished.
+*/
+func (model *ModelDB) FinishAsyncTransfer(lockSecretHash common.Hash, token common.Address, errMsg string, blockNumber int64) error {
+	var t AsyncTransfer
+	err := model.db.One("LockSecretHash", lockSecretHash, &t)
+	if err == storm.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if t.Status != AsyncTransferPending {
+		return nil
+	}
+	t.Token = token
+	t.Status = AsyncTransferSuccess
+	if errMsg != "" {
+		t.Status = AsyncTransferFailed
+		t.Error = errMsg
+	}
+	t.FinishedBlock = blockNumber
+	return model.db.Save(&t)
+}
+
+//GetAsyncTransfer returns nil if not found
+func (model *ModelDB) GetAsyncTransfer(lockSecretHash common.Hash) (t *AsyncTransfer, err error) {
+	t = new(AsyncTransfer)
+	err = model.db.One("LockSecretHash", lockSecretHash, t)
+	if err == storm.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+//RemoveAsyncTransfersFinishedBefore forget async transfers finished before blockNumber
+func (model *ModelDB) RemoveAsyncTransfersFinishedBefore(blockNumber int64) error {
+	err := model.db.Select(q.Gt("FinishedBlock", int64(0)), q.Lt("FinishedBlock", blockNumber)).Delete(&AsyncTransfer{})
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	return err
+}
+
+//NewCanceledLock save a lock to be removed after expired
+func (model *ModelDB) NewCanceledLock(l *CanceledLock) error {
+	return model.db.Save(l)
+}
+
+//GetExpiredCanceledLocks returns canceled locks expired before blockNumber
+func (model *ModelDB) GetExpiredCanceledLocks(blockNumber int64) (ls []*CanceledLock, err error) {
+	err = model.db.Select(q.Lt("Expiration", blockNumber)).Find(&ls)
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	return
+}
+
+//RemoveCanceledLock remove a canceled lock which has been removed from channel
+func (model *ModelDB) RemoveCanceledLock(l *CanceledLock) error {
+	return model.db.DeleteStruct(l)
+}
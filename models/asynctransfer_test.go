@@ -0,0 +1,92 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelDB_AsyncTransfer(t *testing.T) {
+	m := setupDb(t)
+	lockSecretHash := utils.NewRandomHash()
+	token := utils.NewRandomAddress()
+	at, err := m.GetAsyncTransfer(lockSecretHash)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, at == nil, true)
+	err = m.NewAsyncTransfer(lockSecretHash, token)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	//not an async transfer
+	err = m.FinishAsyncTransfer(utils.NewRandomHash(), token, "", 10)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = m.FinishAsyncTransfer(lockSecretHash, token, "no route available", 10)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	//finished only once
+	err = m.FinishAsyncTransfer(lockSecretHash, token, "", 11)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	at, err = m.GetAsyncTransfer(lockSecretHash)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, at.Status, AsyncTransferFailed)
+	assert.EqualValues(t, at.Error, "no route available")
+	assert.EqualValues(t, at.FinishedBlock, 10)
+	pending := utils.NewRandomHash()
+	err = m.NewAsyncTransfer(pending, token)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = m.RemoveAsyncTransfersFinishedBefore(11)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	at, err = m.GetAsyncTransfer(lockSecretHash)
+	assert.EqualValues(t, at == nil, true)
+	at, err = m.GetAsyncTransfer(pending)
+	assert.EqualValues(t, at.Status, AsyncTransferPending)
+}
+
+func TestModelDB_CanceledLock(t *testing.T) {
+	m := setupDb(t)
+	l1 := &CanceledLock{ChannelIdentifier: utils.NewRandomHash(), LockSecretHash: utils.NewRandomHash(), Expiration: 10}
+	l2 := &CanceledLock{ChannelIdentifier: utils.NewRandomHash(), LockSecretHash: utils.NewRandomHash(), Expiration: 20}
+	for _, l := range []*CanceledLock{l1, l2} {
+		err := m.NewCanceledLock(l)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	ls, err := m.GetExpiredCanceledLocks(15)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, len(ls), 1)
+	assert.EqualValues(t, ls[0].LockSecretHash, l1.LockSecretHash)
+	err = m.RemoveCanceledLock(ls[0])
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ls, err = m.GetExpiredCanceledLocks(15)
+	assert.EqualValues(t, len(ls), 0)
+}
@@ -37,6 +37,57 @@ func (model *ModelDB) RegisterChannelSettleCallback(f cb.ChannelCb) {
 	model.mlock.Unlock()
 }
 
+//RegisterChannelUpdateCallback notify when a channel is saved, its balance proofs or locks may change
+func (model *ModelDB) RegisterChannelUpdateCallback(f cb.ChannelCb) {
+	model.mlock.Lock()
+	model.channelUpdateCallbacks[&f] = true
+	model.mlock.Unlock()
+}
+
+//SentTransferCb notify when a transfer sent success
+//return true to remove this callback, all the callback should never block.
+type SentTransferCb func(st *SentTransfer) (remove bool)
+
+//ReceivedTransferCb notify when a transfer received
+//return true to remove this callback, all the callback should never block.
+type ReceivedTransferCb func(rt *ReceivedTransfer) (remove bool)
+
+//InvoiceCb notify when status of an invoice changed
+//return true to remove this callback, all the callback should never block.
+type InvoiceCb func(inv *Invoice) (remove bool)
+
+//RegisterInvoiceCallback notify when status of an invoice changed
+func (model *ModelDB) RegisterInvoiceCallback(f InvoiceCb) {
+	model.mlock.Lock()
+	model.invoiceCallbacks[&f] = true
+	model.mlock.Unlock()
+}
+
+//PunishmentCb notify when status of a punishment changed
+//return true to remove this callback, all the callback should never block.
+type PunishmentCb func(p *Punishment) (remove bool)
+
+//RegisterPunishmentCallback notify when status of a punishment changed
+func (model *ModelDB) RegisterPunishmentCallback(f PunishmentCb) {
+	model.mlock.Lock()
+	model.punishmentCallbacks[&f] = true
+	model.mlock.Unlock()
+}
+
+//RegisterSentTransferCallback notify when a transfer sent success
+func (model *ModelDB) RegisterSentTransferCallback(f SentTransferCb) {
+	model.mlock.Lock()
+	model.sentTransferCallbacks[&f] = true
+	model.mlock.Unlock()
+}
+
+//RegisterReceivedTransferCallback notify when a transfer received
+func (model *ModelDB) RegisterReceivedTransferCallback(f ReceivedTransferCb) {
+	model.mlock.Lock()
+	model.receivedTransferCallbacks[&f] = true
+	model.mlock.Unlock()
+}
+
 /*
 do we need remove a callback?
 */
@@ -31,8 +31,10 @@ func (model *ModelDB) UpdateChannelNoTx(c *channeltype.Serialization) error {
 	err := model.db.Save(c)
 	if err != nil {
 		log.Error(fmt.Sprintf("UpdateChannelNoTx err:%s", err))
+		return err
 	}
-	return err
+	model.handleChannelCallback(model.channelUpdateCallbacks, c)
+	return nil
 }
 
 //UpdateChannelAndSaveAck update channel and save ack, must atomic
@@ -50,6 +52,9 @@ func (model *ModelDB) UpdateChannelAndSaveAck(c *channeltype.Serialization, echo
 	}
 	model.SaveAck(echohash, ack, tx)
 	err = tx.Commit()
+	if err == nil {
+		model.handleChannelCallback(model.channelUpdateCallbacks, c)
+	}
 	return
 }
 func (model *ModelDB) handleChannelCallback(m map[*cb.ChannelCb]bool, c *channeltype.Serialization) {
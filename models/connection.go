@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/gob"
+	"math/big"
+
+	"github.com/asdine/storm"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+ConnectionSetting is how we joined a token network by connection manager,
+it's restored at startup, so channels opened by others are still joined.
+*/
+type ConnectionSetting struct {
+	Token                common.Address `storm:"id"`
+	Funds                *big.Int
+	InitialChannelTarget int
+	JoinableFundsTarget  float64
+}
+
+func init() {
+	gob.Register(&ConnectionSetting{})
+}
+
+//SaveConnectionSetting save setting of a token network, the old one will be replaced
+func (model *ModelDB) SaveConnectionSetting(s *ConnectionSetting) error {
+	return model.db.Save(s)
+}
+
+//RemoveConnectionSetting remove setting of token network after we left it
+func (model *ModelDB) RemoveConnectionSetting(token common.Address) error {
+	err := model.db.DeleteStruct(&ConnectionSetting{Token: token})
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	return err
+}
+
+//GetConnectionSettings returns settings of all the token networks we have joined
+func (model *ModelDB) GetConnectionSettings() (ss []*ConnectionSetting, err error) {
+	err = model.db.All(&ss)
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	return
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelDB_ConnectionSetting(t *testing.T) {
+	m := setupDb(t)
+	ss, err := m.GetConnectionSettings()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, len(ss), 0)
+	token := utils.NewRandomAddress()
+	s := &ConnectionSetting{Token: token, Funds: big.NewInt(100), InitialChannelTarget: 3, JoinableFundsTarget: 0.4}
+	err = m.SaveConnectionSetting(s)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	s.Funds = big.NewInt(200)
+	err = m.SaveConnectionSetting(s)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ss, err = m.GetConnectionSettings()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, len(ss), 1)
+	assert.EqualValues(t, ss[0], s)
+	err = m.RemoveConnectionSetting(token)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = m.RemoveConnectionSetting(token)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ss, err = m.GetConnectionSettings()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, len(ss), 0)
+}
@@ -21,15 +21,20 @@ import (
 
 //ModelDB is thread safe
 type ModelDB struct {
-	db                      *storm.DB
-	lock                    sync.Mutex
-	newTokenCallbacks       map[*cb.NewTokenCb]bool
-	newChannelCallbacks     map[*cb.ChannelCb]bool
-	channelDepositCallbacks map[*cb.ChannelCb]bool
-	channelStateCallbacks   map[*cb.ChannelCb]bool
-	channelSettledCallbacks map[*cb.ChannelCb]bool
-	mlock                   sync.Mutex
-	Name                    string
+	db                        *storm.DB
+	lock                      sync.Mutex
+	newTokenCallbacks         map[*cb.NewTokenCb]bool
+	newChannelCallbacks       map[*cb.ChannelCb]bool
+	channelDepositCallbacks   map[*cb.ChannelCb]bool
+	channelStateCallbacks     map[*cb.ChannelCb]bool
+	channelSettledCallbacks   map[*cb.ChannelCb]bool
+	channelUpdateCallbacks    map[*cb.ChannelCb]bool
+	sentTransferCallbacks     map[*SentTransferCb]bool
+	receivedTransferCallbacks map[*ReceivedTransferCb]bool
+	invoiceCallbacks          map[*InvoiceCb]bool
+	punishmentCallbacks       map[*PunishmentCb]bool
+	mlock                     sync.Mutex
+	Name                      string
 	//SentTransferChan SentTransfer notify ,should never close
 	SentTransferChan chan *SentTransfer
 	//ReceivedTransferChan  ReceivedTransfer notify, should never close
@@ -42,13 +47,18 @@ const dbVersion = 1
 
 func newModelDB() (db *ModelDB) {
 	return &ModelDB{
-		newTokenCallbacks:       make(map[*cb.NewTokenCb]bool),
-		newChannelCallbacks:     make(map[*cb.ChannelCb]bool),
-		channelDepositCallbacks: make(map[*cb.ChannelCb]bool),
-		channelStateCallbacks:   make(map[*cb.ChannelCb]bool),
-		channelSettledCallbacks: make(map[*cb.ChannelCb]bool),
-		SentTransferChan:        make(chan *SentTransfer, 10),
-		ReceivedTransferChan:    make(chan *ReceivedTransfer, 10),
+		newTokenCallbacks:         make(map[*cb.NewTokenCb]bool),
+		newChannelCallbacks:       make(map[*cb.ChannelCb]bool),
+		channelDepositCallbacks:   make(map[*cb.ChannelCb]bool),
+		channelStateCallbacks:     make(map[*cb.ChannelCb]bool),
+		channelSettledCallbacks:   make(map[*cb.ChannelCb]bool),
+		channelUpdateCallbacks:    make(map[*cb.ChannelCb]bool),
+		sentTransferCallbacks:     make(map[*SentTransferCb]bool),
+		receivedTransferCallbacks: make(map[*ReceivedTransferCb]bool),
+		invoiceCallbacks:          make(map[*InvoiceCb]bool),
+		punishmentCallbacks:       make(map[*PunishmentCb]bool),
+		SentTransferChan:          make(chan *SentTransfer, 10),
+		ReceivedTransferChan:      make(chan *ReceivedTransfer, 10),
 	}
 
 }
@@ -97,7 +107,7 @@ func OpenDb(dbPath string) (model *ModelDB, err error) {
 			log.Error("database not closed  last..., try to restore?")
 		}
 	}
-
+	model.reindexTransfers()
 	return
 }
 
@@ -177,6 +187,7 @@ func init() {
 func (model *ModelDB) initDb() {
 	err := model.db.Init(&SentTransfer{})
 	err = model.db.Init(&ReceivedTransfer{})
+	err = model.db.Init(&InternalEvent{})
 	err = model.db.Set(bucketBlockNumber, keyBlockNumber, 0)
 	if err != nil {
 		log.Error(fmt.Sprintf("db err %s", err))
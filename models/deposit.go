@@ -0,0 +1,223 @@
+package models
+
+import (
+	"encoding/gob"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const bucketDepositPolicy = "bucketDepositPolicy"
+const keyDepositPolicy = "depositPolicy"
+
+/*
+DepositSetting tops up a channel with Amount when our balance in it is lower than MinBalance,
+no more than MaxDaily is deposited by this setting in 24 hours, nil means no limit.
+a token setting shares MaxDaily among all the channels of this token it applies to.
+*/
+type DepositSetting struct {
+	MinBalance *big.Int `json:"min_balance"`
+	Amount     *big.Int `json:"amount"`
+	MaxDaily   *big.Int `json:"max_daily,omitempty"`
+}
+
+/*
+DepositPolicy is the auto deposit settings of this node.
+ChannelDeposits has higher priority than TokenDeposits, channels match neither are never topped up.
+in dry run mode, deposits are only recorded and nothing is sent to the chain.
+*/
+type DepositPolicy struct {
+	DryRun          bool                               `json:"dry_run"`
+	TokenDeposits   map[common.Address]*DepositSetting `json:"token_deposits,omitempty"`
+	ChannelDeposits map[common.Hash]*DepositSetting    `json:"channel_deposits,omitempty"`
+}
+
+//status of auto deposit
+const (
+	AutoDepositDryRun  = "dry_run"
+	AutoDepositPending = "pending"
+	AutoDepositSuccess = "success"
+	AutoDepositFailed  = "failed"
+)
+
+//rules of auto deposit, which setting triggered it
+const (
+	AutoDepositRuleToken   = "token"
+	AutoDepositRuleChannel = "channel"
+)
+
+/*
+AutoDeposit is a deposit triggered by deposit policy, it's kept as an audit trail.
+*/
+type AutoDeposit struct {
+	ID                int            `storm:"id,increment" json:"id"`
+	ChannelIdentifier common.Hash    `storm:"index" json:"channel_identifier"`
+	Token             common.Address `storm:"index" json:"token_address"`
+	Partner           common.Address `json:"partner_address"`
+	Rule              string         `json:"rule"`
+	BlockNumber       int64          `json:"block_number"`
+	Balance           *big.Int       `json:"balance"` //our balance when triggered
+	Amount            *big.Int       `json:"amount"`
+	Status            string         `json:"status"`
+	Error             string         `json:"error,omitempty"`
+	Time              time.Time      `storm:"index" json:"time"`
+}
+
+var errInvalidDepositSetting = errors.New("invalid deposit setting")
+
+func init() {
+	gob.Register(&DepositPolicy{})
+	gob.Register(&AutoDeposit{})
+}
+
+//IsValid returns true when amount is positive and others are not negative
+func (ds *DepositSetting) IsValid() bool {
+	if ds.MinBalance == nil || ds.MinBalance.Sign() < 0 {
+		return false
+	}
+	if ds.Amount == nil || ds.Amount.Sign() <= 0 {
+		return false
+	}
+	if ds.MaxDaily != nil && ds.MaxDaily.Sign() < 0 {
+		return false
+	}
+	return true
+}
+
+//Validate check every deposit setting of this policy
+func (dp *DepositPolicy) Validate() error {
+	for _, ds := range dp.TokenDeposits {
+		if ds == nil || !ds.IsValid() {
+			return errInvalidDepositSetting
+		}
+	}
+	for _, ds := range dp.ChannelDeposits {
+		if ds == nil || !ds.IsValid() {
+			return errInvalidDepositSetting
+		}
+	}
+	return nil
+}
+
+//GetSetting returns the setting for channel of token and which rule it is, nil if none
+func (dp *DepositPolicy) GetSetting(channelIdentifier common.Hash, token common.Address) (ds *DepositSetting, rule string) {
+	if ds = dp.ChannelDeposits[channelIdentifier]; ds != nil {
+		return ds, AutoDepositRuleChannel
+	}
+	if ds = dp.TokenDeposits[token]; ds != nil {
+		return ds, AutoDepositRuleToken
+	}
+	return nil, ""
+}
+
+//SaveDepositPolicy save deposit policy to db, the old one will be replaced
+func (model *ModelDB) SaveDepositPolicy(dp *DepositPolicy) error {
+	return model.db.Set(bucketDepositPolicy, keyDepositPolicy, dp)
+}
+
+//GetDepositPolicy returns deposit policy saved in db, nil if never saved
+func (model *ModelDB) GetDepositPolicy() (dp *DepositPolicy, err error) {
+	dp = new(DepositPolicy)
+	err = model.db.Get(bucketDepositPolicy, keyDepositPolicy, dp)
+	if err == storm.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+//NewAutoDeposit save a new auto deposit, d.ID is set
+func (model *ModelDB) NewAutoDeposit(d *AutoDeposit) error {
+	d.Time = time.Now()
+	return model.db.Save(d)
+}
+
+//UpdateAutoDeposit save status of d
+func (model *ModelDB) UpdateAutoDeposit(d *AutoDeposit) error {
+	return model.db.Update(d)
+}
+
+//GetAutoDepositList returns all auto deposits, the latest first
+func (model *ModelDB) GetAutoDepositList() (ds []*AutoDeposit, err error) {
+	err = model.db.All(&ds, storm.Reverse())
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	return
+}
+
+//GetLastAutoDeposit returns the latest auto deposit of channel, nil if none
+func (model *ModelDB) GetLastAutoDeposit(channelIdentifier common.Hash) (d *AutoDeposit, err error) {
+	var ds []*AutoDeposit
+	err = model.db.Find("ChannelIdentifier", channelIdentifier, &ds, storm.Reverse(), storm.Limit(1))
+	if err == storm.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ds[0], nil
+}
+
+//GetAutoDepositFailures returns how many times in a row the latest auto deposits of channel failed
+func (model *ModelDB) GetAutoDepositFailures(channelIdentifier common.Hash) (n int, err error) {
+	var ds []*AutoDeposit
+	err = model.db.Find("ChannelIdentifier", channelIdentifier, &ds, storm.Reverse())
+	if err == storm.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return
+	}
+	for _, d := range ds {
+		if d.Status != AutoDepositFailed {
+			break
+		}
+		n++
+	}
+	return
+}
+
+//GetPendingAutoDeposits returns auto deposits waiting for the result
+func (model *ModelDB) GetPendingAutoDeposits() (ds []*AutoDeposit, err error) {
+	err = model.db.Select(q.Eq("Status", AutoDepositPending)).Find(&ds)
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	return
+}
+
+/*
+GetAutoDepositAmountSince returns how much deposited by the rule since t,
+for token rule, key is the token address, for channel rule, it's the channel identifier.
+dry run deposits are counted only if dryRun is true, failed ones are counted too,
+for they cost gas and will be tried again, otherwise a failing deposit is retried forever.
+*/
+func (model *ModelDB) GetAutoDepositAmountSince(rule string, key common.Hash, t time.Time, dryRun bool) (amount *big.Int, err error) {
+	amount = big.NewInt(0)
+	var ds []*AutoDeposit
+	if rule == AutoDepositRuleToken {
+		err = model.db.Find("Token", common.BytesToAddress(key[:]), &ds)
+	} else {
+		err = model.db.Find("ChannelIdentifier", key, &ds)
+	}
+	if err == storm.ErrNotFound {
+		return amount, nil
+	}
+	if err != nil {
+		return
+	}
+	for _, d := range ds {
+		if d.Rule != rule || d.Time.Before(t) || (d.Status == AutoDepositDryRun && !dryRun) {
+			continue
+		}
+		amount.Add(amount, d.Amount)
+	}
+	return
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelDB_DepositPolicy(t *testing.T) {
+	m := setupDb(t)
+	dp, err := m.GetDepositPolicy()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, dp == nil, true)
+	token := utils.NewRandomAddress()
+	ch := utils.NewRandomHash()
+	dp = &DepositPolicy{
+		DryRun: true,
+		TokenDeposits: map[common.Address]*DepositSetting{
+			token: {MinBalance: big.NewInt(10), Amount: big.NewInt(100), MaxDaily: big.NewInt(150)},
+		},
+		ChannelDeposits: map[common.Hash]*DepositSetting{
+			ch: {MinBalance: big.NewInt(20), Amount: big.NewInt(50)},
+		},
+	}
+	assert.EqualValues(t, dp.Validate(), nil)
+	err = m.SaveDepositPolicy(dp)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	dp2, err := m.GetDepositPolicy()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, dp2, dp)
+	ds, rule := dp2.GetSetting(ch, token)
+	assert.EqualValues(t, rule, AutoDepositRuleChannel)
+	assert.EqualValues(t, ds.Amount, big.NewInt(50))
+	ds, rule = dp2.GetSetting(utils.NewRandomHash(), token)
+	assert.EqualValues(t, rule, AutoDepositRuleToken)
+	assert.EqualValues(t, ds.Amount, big.NewInt(100))
+	ds, _ = dp2.GetSetting(utils.NewRandomHash(), utils.NewRandomAddress())
+	assert.EqualValues(t, ds == nil, true)
+	dp.TokenDeposits[token].Amount = big.NewInt(0)
+	assert.NotEqual(t, dp.Validate(), nil)
+}
+
+func TestModelDB_AutoDeposit(t *testing.T) {
+	m := setupDb(t)
+	token := utils.NewRandomAddress()
+	ch := utils.NewRandomHash()
+	since := time.Now().Add(-time.Hour)
+	d1 := &AutoDeposit{ChannelIdentifier: ch, Token: token, Rule: AutoDepositRuleToken, Balance: big.NewInt(1), Amount: big.NewInt(100), Status: AutoDepositPending}
+	d2 := &AutoDeposit{ChannelIdentifier: ch, Token: token, Rule: AutoDepositRuleToken, Balance: big.NewInt(1), Amount: big.NewInt(30), Status: AutoDepositDryRun}
+	d3 := &AutoDeposit{ChannelIdentifier: utils.NewRandomHash(), Token: token, Rule: AutoDepositRuleToken, Balance: big.NewInt(1), Amount: big.NewInt(7), Status: AutoDepositFailed}
+	for _, d := range []*AutoDeposit{d1, d2, d3} {
+		err := m.NewAutoDeposit(d)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	amount, err := m.GetAutoDepositAmountSince(AutoDepositRuleToken, common.BytesToHash(token[:]), since, false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	//failed ones are counted too
+	assert.EqualValues(t, amount, big.NewInt(107))
+	amount, err = m.GetAutoDepositAmountSince(AutoDepositRuleToken, common.BytesToHash(token[:]), since, true)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, amount, big.NewInt(137))
+	amount, err = m.GetAutoDepositAmountSince(AutoDepositRuleChannel, ch, since, true)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, amount.Sign(), 0)
+	amount, err = m.GetAutoDepositAmountSince(AutoDepositRuleToken, common.BytesToHash(token[:]), time.Now().Add(time.Minute), true)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, amount.Sign(), 0)
+	pending, err := m.GetPendingAutoDeposits()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, len(pending), 1)
+	assert.EqualValues(t, pending[0].ID, d1.ID)
+	d1.Status = AutoDepositSuccess
+	err = m.UpdateAutoDeposit(d1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	last, err := m.GetLastAutoDeposit(ch)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, last.ID, d2.ID)
+	last, err = m.GetLastAutoDeposit(utils.NewRandomHash())
+	assert.EqualValues(t, last == nil, true)
+	n, err := m.GetAutoDepositFailures(d3.ChannelIdentifier)
+	assert.EqualValues(t, n, 1)
+	n, err = m.GetAutoDepositFailures(ch)
+	assert.EqualValues(t, n, 0)
+	ds, err := m.GetAutoDepositList()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, len(ds), 3)
+	assert.EqualValues(t, ds[0].ID, d3.ID)
+	assert.EqualValues(t, ds[2].Status, AutoDepositSuccess)
+}
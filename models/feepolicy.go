@@ -0,0 +1,93 @@
+package models
+
+import (
+	"encoding/gob"
+	"errors"
+	"math/big"
+
+	"github.com/asdine/storm"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const bucketFeePolicy = "bucketFeePolicy"
+const keyFeePolicy = "feePolicy"
+
+/*
+FeeSetting is how much fee to charge for one mediated transfer,
+fee=BaseFee+amount*FeeRate/1000000, limited by MinFee and MaxFee if they are not nil.
+*/
+type FeeSetting struct {
+	BaseFee *big.Int `json:"base_fee"`
+	FeeRate int64    `json:"fee_rate"` //parts per million
+	MinFee  *big.Int `json:"min_fee,omitempty"`
+	MaxFee  *big.Int `json:"max_fee,omitempty"`
+}
+
+/*
+FeePolicy is the fee settings of this node as a mediator.
+PartnerFees has the highest priority, then TokenFees, DefaultFee is used when neither matches.
+*/
+type FeePolicy struct {
+	DefaultFee  *FeeSetting                    `json:"default_fee"`
+	TokenFees   map[common.Address]*FeeSetting `json:"token_fees,omitempty"`
+	PartnerFees map[common.Address]*FeeSetting `json:"partner_fees,omitempty"`
+}
+
+var errInvalidFeeSetting = errors.New("invalid fee setting")
+
+func init() {
+	gob.Register(&FeePolicy{})
+}
+
+//IsValid returns true when all fields are non negative and MinFee is not greater than MaxFee
+func (fs *FeeSetting) IsValid() bool {
+	if fs.FeeRate < 0 || (fs.BaseFee != nil && fs.BaseFee.Sign() < 0) {
+		return false
+	}
+	if fs.MinFee != nil && fs.MinFee.Sign() < 0 {
+		return false
+	}
+	if fs.MaxFee != nil && fs.MaxFee.Sign() < 0 {
+		return false
+	}
+	if fs.MinFee != nil && fs.MaxFee != nil && fs.MinFee.Cmp(fs.MaxFee) > 0 {
+		return false
+	}
+	return true
+}
+
+//Validate check every fee setting of this policy
+func (fp *FeePolicy) Validate() error {
+	if fp.DefaultFee == nil || !fp.DefaultFee.IsValid() {
+		return errInvalidFeeSetting
+	}
+	for _, fs := range fp.TokenFees {
+		if fs == nil || !fs.IsValid() {
+			return errInvalidFeeSetting
+		}
+	}
+	for _, fs := range fp.PartnerFees {
+		if fs == nil || !fs.IsValid() {
+			return errInvalidFeeSetting
+		}
+	}
+	return nil
+}
+
+//SaveFeePolicy save fee policy to db, the old one will be replaced
+func (model *ModelDB) SaveFeePolicy(fp *FeePolicy) error {
+	return model.db.Set(bucketFeePolicy, keyFeePolicy, fp)
+}
+
+//GetFeePolicy returns fee policy saved in db, nil if never saved
+func (model *ModelDB) GetFeePolicy() (fp *FeePolicy, err error) {
+	fp = new(FeePolicy)
+	err = model.db.Get(bucketFeePolicy, keyFeePolicy, fp)
+	if err == storm.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return
+}
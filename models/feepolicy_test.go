@@ -0,0 +1,45 @@
+package models
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelDB_SaveFeePolicy(t *testing.T) {
+	m := setupDb(t)
+	defer m.CloseDB()
+	fp, err := m.GetFeePolicy()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.Nil(t, fp)
+	token := utils.NewRandomAddress()
+	fp = &FeePolicy{
+		DefaultFee: &FeeSetting{BaseFee: big.NewInt(3)},
+		TokenFees: map[common.Address]*FeeSetting{
+			token: {BaseFee: big.NewInt(1), FeeRate: 100, MaxFee: big.NewInt(10)},
+		},
+	}
+	assert.Nil(t, fp.Validate())
+	err = m.SaveFeePolicy(fp)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	fp2, err := m.GetFeePolicy()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, fp, fp2)
+
+	fp.TokenFees[token].MinFee = big.NewInt(20)
+	assert.NotNil(t, fp.Validate())
+	fp.DefaultFee = nil
+	assert.NotNil(t, fp.Validate())
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/asdine/storm"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+InternalEvent is an event happened in this node, a transfer event or a channel state transition,
+it is kept for auditing after the fact.
+*/
+type InternalEvent struct {
+	ID                int         `storm:"id,increment" json:"-"`
+	BlockNumber       int64       `storm:"index" json:"block_number"`
+	Name              string      `json:"name"`
+	LockSecretHash    common.Hash `json:"lock_secret_hash"`
+	ChannelIdentifier common.Hash `json:"channel_identifier"`
+	Reason            string      `json:"reason"`
+	Time              time.Time   `json:"time"`
+	EventObject       interface{} `json:"-"` //transfer.Event or transfer.StateChange
+}
+
+func init() {
+	gob.Register(&InternalEvent{})
+}
+
+//NewInternalEvent save a event to db, eventObject must be registered to gob
+func (model *ModelDB) NewInternalEvent(blockNumber int64, name string, lockSecretHash, channelIdentifier common.Hash, reason string, eventObject interface{}) {
+	ev := &InternalEvent{
+		BlockNumber:       blockNumber,
+		Name:              name,
+		LockSecretHash:    lockSecretHash,
+		ChannelIdentifier: channelIdentifier,
+		Reason:            reason,
+		Time:              time.Now(),
+		EventObject:       eventObject,
+	}
+	err := model.db.Save(ev)
+	if err != nil {
+		log.Error(fmt.Sprintf("save InternalEvent %s err %s", name, err))
+	}
+}
+
+//RemoveInternalEventsBefore removes internal events happened before blockNumber
+func (model *ModelDB) RemoveInternalEventsBefore(blockNumber int64) error {
+	if blockNumber <= 0 {
+		return nil
+	}
+	var events []*InternalEvent
+	err := model.db.Range("BlockNumber", int64(0), blockNumber-1, &events)
+	if err == storm.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	tx, err := model.db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, ev := range events {
+		err = tx.DeleteStruct(ev)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+//GetEventsInBlockRange returns internal events between from and to blocks
+func (model *ModelDB) GetEventsInBlockRange(fromBlock, toBlock int64) (events []*InternalEvent, err error) {
+	if fromBlock < 0 {
+		fromBlock = 0
+	}
+	if toBlock < 0 {
+		toBlock = math.MaxInt64
+	}
+	err = model.db.Range("BlockNumber", fromBlock, toBlock, &events)
+	if err == storm.ErrNotFound { //ingore not found error
+		err = nil
+	}
+	return
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelDB_GetEventsInBlockRange(t *testing.T) {
+	m := setupDb(t)
+	lockSecretHash := utils.NewRandomHash()
+	ch := utils.NewRandomHash()
+	m.NewInternalEvent(2, "EventTransferSentFailed", lockSecretHash, utils.EmptyHash, "no route", nil)
+	m.NewInternalEvent(3, "EventUnlockFailed", lockSecretHash, ch, "lock expired", &SentAnnounceDisposed{ChannelIdentifier: ch})
+	m.NewInternalEvent(5, "EventTransferSentSuccess", utils.NewRandomHash(), ch, "", nil)
+	evs, err := m.GetEventsInBlockRange(0, 3)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, 2, len(evs))
+	assert.EqualValues(t, "EventTransferSentFailed", evs[0].Name)
+	assert.EqualValues(t, "no route", evs[0].Reason)
+	assert.EqualValues(t, lockSecretHash, evs[1].LockSecretHash)
+	assert.EqualValues(t, ch, evs[1].ChannelIdentifier)
+	st, ok := evs[1].EventObject.(*SentAnnounceDisposed)
+	if !ok || st.ChannelIdentifier != ch {
+		t.Errorf("event object error %v", evs[1].EventObject)
+	}
+	evs, err = m.GetEventsInBlockRange(4, -1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, 1, len(evs))
+	evs, err = m.GetEventsInBlockRange(6, 10)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, 0, len(evs))
+	err = m.RemoveInternalEventsBefore(3)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	evs, err = m.GetEventsInBlockRange(0, -1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, 2, len(evs))
+	assert.EqualValues(t, int64(3), evs[0].BlockNumber)
+	err = m.RemoveInternalEventsBefore(100)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	evs, err = m.GetEventsInBlockRange(0, -1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, 0, len(evs))
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//status of invoice
+const (
+	InvoiceOpen     = "open"
+	InvoiceHeld     = "held" //a transfer paying a hold invoice arrived, waiting to be accepted or rejected
+	InvoicePaid     = "paid"
+	InvoiceCanceled = "canceled"
+)
+
+/*
+Invoice is a payment request created by this node,
+the secret of LockSecretHash is kept here and revealed when a valid transfer arrives.
+a hold invoice is revealed only when the application accepts the transfer,
+its secret may be kept by the application, then Secret is empty.
+*/
+type Invoice struct {
+	LockSecretHash common.Hash    `storm:"id" json:"lock_secret_hash"`
+	Secret         common.Hash    `json:"-"`
+	Token          common.Address `json:"token_address"`
+	Amount         *big.Int       `json:"amount"`
+	Expiration     int64          `json:"expiration"` //unix time in seconds
+	Memo           string         `json:"memo,omitempty"`
+	URI            string         `json:"uri"`
+	Status         string         `json:"status"`
+	Hold           bool           `json:"hold,omitempty"`
+	Payer          common.Address `json:"payer,omitempty"` //initiator written in the transfer, not signed by anyone, it can be forged by any node on the path
+	PaidAmount     *big.Int       `json:"paid_amount,omitempty"`
+	CreateTime     time.Time      `json:"create_time"`
+	PaidTime       time.Time      `json:"paid_time,omitempty"`
+}
+
+func init() {
+	gob.Register(&Invoice{})
+}
+
+//IsOpen returns true if the invoice can still be paid at now
+func (inv *Invoice) IsOpen(now time.Time) bool {
+	return inv.Status == InvoiceOpen && now.Unix() < inv.Expiration
+}
+
+//NewInvoice save a new open invoice
+func (model *ModelDB) NewInvoice(inv *Invoice) error {
+	var old Invoice
+	if err := model.db.One("LockSecretHash", inv.LockSecretHash, &old); err == nil {
+		return fmt.Errorf("invoice %s already exists", inv.LockSecretHash.String())
+	}
+	inv.Status = InvoiceOpen
+	inv.CreateTime = time.Now()
+	return model.db.Save(inv)
+}
+
+//GetInvoice returns the invoice locked by lockSecretHash
+func (model *ModelDB) GetInvoice(lockSecretHash common.Hash) (*Invoice, error) {
+	var inv Invoice
+	err := model.db.One("LockSecretHash", lockSecretHash, &inv)
+	return &inv, err
+}
+
+//GetInvoiceList returns all invoices created by this node
+func (model *ModelDB) GetInvoiceList() (invs []*Invoice, err error) {
+	err = model.db.All(&invs)
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	return
+}
+
+//InvoicePaid marks invoice locked by lockSecretHash as paid by payer
+func (model *ModelDB) InvoicePaid(lockSecretHash common.Hash, payer common.Address, amount *big.Int) error {
+	inv, err := model.GetInvoice(lockSecretHash)
+	if err != nil {
+		return err
+	}
+	inv.Status = InvoicePaid
+	inv.Payer = payer
+	inv.PaidAmount = amount
+	inv.PaidTime = time.Now()
+	return model.saveInvoice(inv)
+}
+
+//InvoiceHeld marks hold invoice locked by lockSecretHash as held, a transfer of amount from payer is waiting
+func (model *ModelDB) InvoiceHeld(lockSecretHash common.Hash, payer common.Address, amount *big.Int) error {
+	inv, err := model.GetInvoice(lockSecretHash)
+	if err != nil {
+		return err
+	}
+	inv.Status = InvoiceHeld
+	inv.Payer = payer
+	inv.PaidAmount = amount
+	return model.saveInvoice(inv)
+}
+
+//CancelInvoice marks invoice locked by lockSecretHash as canceled, it cannot be paid any more
+func (model *ModelDB) CancelInvoice(lockSecretHash common.Hash) error {
+	inv, err := model.GetInvoice(lockSecretHash)
+	if err != nil {
+		return err
+	}
+	if inv.Status == InvoicePaid {
+		return fmt.Errorf("invoice %s has been paid", lockSecretHash.String())
+	}
+	inv.Status = InvoiceCanceled
+	return model.saveInvoice(inv)
+}
+
+func (model *ModelDB) saveInvoice(inv *Invoice) error {
+	err := model.db.Save(inv)
+	if err != nil {
+		return err
+	}
+	model.handleInvoiceCallback(inv)
+	return nil
+}
+
+func (model *ModelDB) handleInvoiceCallback(inv *Invoice) {
+	var cbs []*InvoiceCb
+	model.mlock.Lock()
+	for f := range model.invoiceCallbacks {
+		if (*f)(inv) {
+			cbs = append(cbs, f)
+		}
+	}
+	for _, f := range cbs {
+		delete(model.invoiceCallbacks, f)
+	}
+	model.mlock.Unlock()
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelDB_Invoice(t *testing.T) {
+	m := setupDb(t)
+	secret := utils.NewRandomHash()
+	inv := &Invoice{
+		LockSecretHash: utils.Sha3(secret[:]),
+		Secret:         secret,
+		Token:          utils.NewRandomAddress(),
+		Amount:         big.NewInt(20),
+		Expiration:     time.Now().Add(time.Hour).Unix(),
+		Memo:           "coffee",
+	}
+	err := m.NewInvoice(inv)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = m.NewInvoice(inv)
+	if err == nil {
+		t.Error("lock secret hash should be unique")
+	}
+	inv2, err := m.GetInvoice(inv.LockSecretHash)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, secret, inv2.Secret)
+	assert.EqualValues(t, true, inv2.IsOpen(time.Now()))
+	assert.EqualValues(t, false, inv2.IsOpen(time.Now().Add(2*time.Hour)))
+
+	payer := utils.NewRandomAddress()
+	err = m.InvoicePaid(inv.LockSecretHash, payer, big.NewInt(20))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	invs, err := m.GetInvoiceList()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, 1, len(invs))
+	assert.EqualValues(t, InvoicePaid, invs[0].Status)
+	assert.EqualValues(t, payer, invs[0].Payer)
+	assert.EqualValues(t, false, invs[0].IsOpen(time.Now()))
+	err = m.InvoicePaid(utils.NewRandomHash(), payer, big.NewInt(20))
+	assert.NotNil(t, err)
+}
+
+func TestModelDB_HoldInvoice(t *testing.T) {
+	m := setupDb(t)
+	var statuses []string
+	m.RegisterInvoiceCallback(func(inv *Invoice) bool {
+		statuses = append(statuses, inv.Status)
+		return false
+	})
+	inv := &Invoice{
+		LockSecretHash: utils.NewRandomHash(),
+		Token:          utils.NewRandomAddress(),
+		Amount:         big.NewInt(20),
+		Expiration:     time.Now().Add(time.Hour).Unix(),
+		Hold:           true,
+	}
+	err := m.NewInvoice(inv)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	payer := utils.NewRandomAddress()
+	err = m.InvoiceHeld(inv.LockSecretHash, payer, big.NewInt(25))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	inv2, err := m.GetInvoice(inv.LockSecretHash)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, InvoiceHeld, inv2.Status)
+	assert.EqualValues(t, true, inv2.Hold)
+	assert.EqualValues(t, payer, inv2.Payer)
+	assert.EqualValues(t, false, inv2.IsOpen(time.Now()))
+	err = m.CancelInvoice(inv.LockSecretHash)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, []string{InvoiceHeld, InvoiceCanceled}, statuses)
+	err = m.InvoicePaid(inv.LockSecretHash, payer, big.NewInt(25))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = m.CancelInvoice(inv.LockSecretHash)
+	assert.NotNil(t, err, "paid invoice cannot be canceled")
+}
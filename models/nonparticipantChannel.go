@@ -125,3 +125,26 @@ func (model *ModelDB) GetAllNonParticipantChannel(token common.Address) (edges [
 	}
 	return
 }
+
+//GetNonParticipantChannelToken returns token of channel, it's empty if the channel is unknown or settled
+func (model *ModelDB) GetNonParticipantChannelToken(channel common.Hash) (token common.Address, err error) {
+	tokens, err := model.GetAllTokens()
+	if err != nil {
+		return
+	}
+	for t := range tokens {
+		var m ChannelParticipantMap
+		err = model.db.Get(bucketChannel, t[:], &m)
+		if err == storm.ErrNotFound {
+			err = nil
+			continue
+		}
+		if err != nil {
+			return
+		}
+		if m[channel] != nil {
+			return t, nil
+		}
+	}
+	return
+}
@@ -62,3 +62,33 @@ func TestReadDbAgain(t *testing.T) {
 	}
 	log.Trace(fmt.Sprintf("edges=%s", utils.StringInterface(edges, 3)))
 }
+
+func TestModelDB_GetNonParticipantChannelToken(t *testing.T) {
+	model := setupDb(t)
+	defer model.CloseDB()
+	token := utils.NewRandomAddress()
+	err := model.AddToken(token, utils.NewRandomAddress())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	p1, p2 := utils.NewRandomAddress(), utils.NewRandomAddress()
+	channel := utils.Sha3(p1[:], p2[:], token[:])
+	err = model.NewNonParticipantChannel(token, channel, p1, p2)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	t2, err := model.GetNonParticipantChannelToken(channel)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, t2, token)
+	t2, err = model.GetNonParticipantChannelToken(utils.NewRandomHash())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, t2, utils.EmptyAddress)
+}
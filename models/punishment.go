@@ -0,0 +1,101 @@
+package models
+
+import (
+	"encoding/gob"
+	"time"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/asdine/storm"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//status of punishment
+const (
+	PunishmentPending = "pending"
+	PunishmentSuccess = "success"
+	PunishmentFailed  = "failed"
+)
+
+/*
+Punishment is created when partner unlocks a lock on chain which it has announced disposed,
+PunishObsoleteUnlock is submitted for it before the channel settles.
+*/
+type Punishment struct {
+	Key               common.Hash    `storm:"id" json:"-"`
+	LockHash          common.Hash    `json:"lock_hash"`
+	ChannelIdentifier common.Hash    `json:"channel_identifier"`
+	TokenNetwork      common.Address `json:"token_network_address"`
+	Partner           common.Address `json:"partner_address"` //the cheater
+	UnlockBlock       int64          `json:"unlock_block"`    //block partner unlocked the lock
+	Status            string         `json:"status" storm:"index"`
+	Attempts          int            `json:"attempts"`
+	Error             string         `json:"error,omitempty"`
+	CreateTime        time.Time      `json:"create_time"`
+	UpdateTime        time.Time      `json:"update_time"`
+}
+
+func init() {
+	gob.Register(&Punishment{})
+}
+
+/*
+NewPunishment save a pending punishment, nothing is changed if it already exists,
+for the unlock event may be received again after restart.
+*/
+func (model *ModelDB) NewPunishment(p *Punishment) (isNew bool, err error) {
+	p.Key = utils.Sha3(p.LockHash[:], p.ChannelIdentifier[:])
+	var old Punishment
+	if err = model.db.One("Key", p.Key, &old); err == nil {
+		return false, nil
+	}
+	p.Status = PunishmentPending
+	p.CreateTime = time.Now()
+	return true, model.savePunishment(p)
+}
+
+//UpdatePunishment save status of p
+func (model *ModelDB) UpdatePunishment(p *Punishment) error {
+	return model.savePunishment(p)
+}
+
+//GetPunishmentList returns all punishments
+func (model *ModelDB) GetPunishmentList() (ps []*Punishment, err error) {
+	err = model.db.All(&ps)
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	return
+}
+
+//GetPendingPunishments returns punishments not submitted successfully yet
+func (model *ModelDB) GetPendingPunishments() (ps []*Punishment, err error) {
+	err = model.db.Find("Status", PunishmentPending, &ps)
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	return
+}
+
+func (model *ModelDB) savePunishment(p *Punishment) error {
+	p.UpdateTime = time.Now()
+	err := model.db.Save(p)
+	if err != nil {
+		return err
+	}
+	model.handlePunishmentCallback(p)
+	return nil
+}
+
+func (model *ModelDB) handlePunishmentCallback(p *Punishment) {
+	var cbs []*PunishmentCb
+	model.mlock.Lock()
+	for f := range model.punishmentCallbacks {
+		if (*f)(p) {
+			cbs = append(cbs, f)
+		}
+	}
+	for _, f := range cbs {
+		delete(model.punishmentCallbacks, f)
+	}
+	model.mlock.Unlock()
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelDB_Punishment(t *testing.T) {
+	m := setupDb(t)
+	var notified []string
+	m.RegisterPunishmentCallback(func(p *Punishment) bool {
+		notified = append(notified, p.Status)
+		return false
+	})
+	p := &Punishment{
+		LockHash:          utils.NewRandomHash(),
+		ChannelIdentifier: utils.NewRandomHash(),
+		Partner:           utils.NewRandomAddress(),
+		UnlockBlock:       30,
+	}
+	isNew, err := m.NewPunishment(p)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, isNew, true)
+	isNew, err = m.NewPunishment(&Punishment{LockHash: p.LockHash, ChannelIdentifier: p.ChannelIdentifier})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, isNew, false)
+	ps, err := m.GetPendingPunishments()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, len(ps), 1)
+	assert.EqualValues(t, ps[0].Partner, p.Partner)
+
+	p.Status = PunishmentSuccess
+	err = m.UpdatePunishment(p)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ps, err = m.GetPendingPunishments()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, len(ps), 0)
+	ps, err = m.GetPunishmentList()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, len(ps), 1)
+	assert.EqualValues(t, notified, []string{PunishmentPending, PunishmentSuccess})
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/gob"
+	"math/big"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//status of rebalance record
+const (
+	RebalancePending = "pending"
+	RebalanceSuccess = "success"
+	RebalanceFailed  = "failed"
+)
+
+/*
+RebalanceRecord is a transfer to ourselves started by the rebalancer,
+fee of records not failed is counted in the daily fee budget.
+*/
+type RebalanceRecord struct {
+	ID             int            `storm:"id,increment" json:"id"`
+	Token          common.Address `storm:"index" json:"token_address"`
+	LockSecretHash common.Hash    `json:"lock_secret_hash"`
+	Amount         *big.Int       `json:"amount"`
+	Fee            *big.Int       `json:"fee"`
+	Status         string         `json:"status"`
+	Time           time.Time      `json:"time"`
+}
+
+func init() {
+	gob.Register(&RebalanceRecord{})
+}
+
+//NewRebalanceRecord save a new rebalance record, r.ID is set
+func (model *ModelDB) NewRebalanceRecord(r *RebalanceRecord) error {
+	r.Time = time.Now()
+	return model.db.Save(r)
+}
+
+//UpdateRebalanceRecord save status of r
+func (model *ModelDB) UpdateRebalanceRecord(r *RebalanceRecord) error {
+	return model.db.Update(r)
+}
+
+//GetRebalanceFeeSince returns fee of rebalance transfers of token since t, failed ones are not counted
+func (model *ModelDB) GetRebalanceFeeSince(token common.Address, t time.Time) (fee *big.Int, err error) {
+	fee = big.NewInt(0)
+	var rs []*RebalanceRecord
+	err = model.db.Select(q.Eq("Token", token), q.Gte("Time", t)).Find(&rs)
+	if err == storm.ErrNotFound {
+		return fee, nil
+	}
+	if err != nil {
+		return
+	}
+	for _, r := range rs {
+		if r.Status != RebalanceFailed {
+			fee.Add(fee, r.Fee)
+		}
+	}
+	return
+}
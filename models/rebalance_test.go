@@ -0,0 +1,56 @@
+package models
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelDB_RebalanceFee(t *testing.T) {
+	m := setupDb(t)
+	token := utils.NewRandomAddress()
+	since := time.Now().Add(-time.Hour)
+	r1 := &RebalanceRecord{Token: token, LockSecretHash: utils.NewRandomHash(), Amount: big.NewInt(100), Fee: big.NewInt(3), Status: RebalancePending}
+	r2 := &RebalanceRecord{Token: token, LockSecretHash: utils.NewRandomHash(), Amount: big.NewInt(100), Fee: big.NewInt(5), Status: RebalancePending}
+	r3 := &RebalanceRecord{Token: utils.NewRandomAddress(), LockSecretHash: utils.NewRandomHash(), Amount: big.NewInt(100), Fee: big.NewInt(7), Status: RebalanceSuccess}
+	for _, r := range []*RebalanceRecord{r1, r2, r3} {
+		err := m.NewRebalanceRecord(r)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	fee, err := m.GetRebalanceFeeSince(token, since)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, fee, big.NewInt(8))
+	r2.Status = RebalanceFailed
+	err = m.UpdateRebalanceRecord(r2)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	fee, err = m.GetRebalanceFeeSince(token, since)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, fee, big.NewInt(3))
+	fee, err = m.GetRebalanceFeeSince(token, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, fee.Sign(), 0)
+	fee, err = m.GetRebalanceFeeSince(utils.NewRandomAddress(), since)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, fee.Sign(), 0)
+}
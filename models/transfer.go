@@ -18,11 +18,13 @@ type SentTransfer struct {
 	Key               string `storm:"id"`
 	BlockNumber       int64  `json:"block_number" storm:"index"`
 	OpenBlockNumber   int64
-	ChannelIdentifier common.Hash    `json:"channel_address"`
-	ToAddress         common.Address `json:"to_address"`
-	TokenAddress      common.Address `json:"token_address"`
+	ChannelIdentifier common.Hash    `json:"channel_address" storm:"index"`
+	ToAddress         common.Address `json:"to_address" storm:"index"`
+	TokenAddress      common.Address `json:"token_address" storm:"index"`
 	Nonce             int64          `json:"nonce"`
 	Amount            *big.Int       `json:"amount"`
+	PaymentIdentifier uint64         `json:"payment_identifier,omitempty" storm:"index"`
+	Memo              string         `json:"memo,omitempty"`
 }
 
 //ReceivedTransfer tokens I have received and where it comes from
@@ -30,17 +32,19 @@ type ReceivedTransfer struct {
 	Key               string `storm:"id"`
 	BlockNumber       int64  `json:"block_number" storm:"index"`
 	OpenBlockNumber   int64
-	ChannelIdentifier common.Hash    `json:"channel_address"`
-	TokenAddress      common.Address `json:"token_address"`
-	FromAddress       common.Address `json:"from_address"`
+	ChannelIdentifier common.Hash    `json:"channel_address" storm:"index"`
+	TokenAddress      common.Address `json:"token_address" storm:"index"`
+	FromAddress       common.Address `json:"from_address" storm:"index"`
 	Nonce             int64          `json:"nonce"`
 	Amount            *big.Int       `json:"amount"`
+	PaymentIdentifier uint64         `json:"payment_identifier,omitempty" storm:"index"` //set by the initiator of a mediated transfer
+	Memo              string         `json:"memo,omitempty"`
 }
 
 /*
 NewSentTransfer save a new sent transfer to db,this trqnsfer must be success
 */
-func (model *ModelDB) NewSentTransfer(blockNumber int64, channelAddr common.Hash, tokenAddr, toAddr common.Address, nonce int64, amount *big.Int) {
+func (model *ModelDB) NewSentTransfer(blockNumber int64, channelAddr common.Hash, tokenAddr, toAddr common.Address, nonce int64, amount *big.Int, paymentIdentifier uint64, memo string) {
 	key := fmt.Sprintf("%s-%d", channelAddr.String(), nonce)
 	st := &SentTransfer{
 		Key:               key,
@@ -50,6 +54,8 @@ func (model *ModelDB) NewSentTransfer(blockNumber int64, channelAddr common.Hash
 		ToAddress:         toAddr,
 		Nonce:             nonce,
 		Amount:            amount,
+		PaymentIdentifier: paymentIdentifier,
+		Memo:              memo,
 	}
 	if ost, err := model.GetSentTransfer(key); err == nil {
 		log.Error(fmt.Sprintf("NewSentTransfer, but already exist, old=\n%s,new=\n%s",
@@ -65,10 +71,25 @@ func (model *ModelDB) NewSentTransfer(blockNumber int64, channelAddr common.Hash
 	default:
 		//nerver block
 	}
+	model.handleSentTransferCallback(st)
+}
+
+func (model *ModelDB) handleSentTransferCallback(st *SentTransfer) {
+	var cbs []*SentTransferCb
+	model.mlock.Lock()
+	for f := range model.sentTransferCallbacks {
+		if (*f)(st) {
+			cbs = append(cbs, f)
+		}
+	}
+	for _, f := range cbs {
+		delete(model.sentTransferCallbacks, f)
+	}
+	model.mlock.Unlock()
 }
 
 //NewReceivedTransfer save a new received transfer to db
-func (model *ModelDB) NewReceivedTransfer(blockNumber int64, channelAddr common.Hash, tokenAddr, fromAddr common.Address, nonce int64, amount *big.Int) {
+func (model *ModelDB) NewReceivedTransfer(blockNumber int64, channelAddr common.Hash, tokenAddr, fromAddr common.Address, nonce int64, amount *big.Int, paymentIdentifier uint64, memo string) {
 	key := fmt.Sprintf("%s-%d", channelAddr.String(), nonce)
 	st := &ReceivedTransfer{
 		Key:               key,
@@ -78,6 +99,8 @@ func (model *ModelDB) NewReceivedTransfer(blockNumber int64, channelAddr common.
 		FromAddress:       fromAddr,
 		Nonce:             nonce,
 		Amount:            amount,
+		PaymentIdentifier: paymentIdentifier,
+		Memo:              memo,
 	}
 	if ost, err := model.GetReceivedTransfer(key); err == nil {
 		log.Error(fmt.Sprintf("NewReceivedTransfer, but already exist, old=\n%s,new=\n%s",
@@ -93,6 +116,21 @@ func (model *ModelDB) NewReceivedTransfer(blockNumber int64, channelAddr common.
 	default:
 		//never block
 	}
+	model.handleReceivedTransferCallback(st)
+}
+
+func (model *ModelDB) handleReceivedTransferCallback(rt *ReceivedTransfer) {
+	var cbs []*ReceivedTransferCb
+	model.mlock.Lock()
+	for f := range model.receivedTransferCallbacks {
+		if (*f)(rt) {
+			cbs = append(cbs, f)
+		}
+	}
+	for _, f := range cbs {
+		delete(model.receivedTransferCallbacks, f)
+	}
+	model.mlock.Unlock()
 }
 
 //GetSentTransfer return the sent transfer by key
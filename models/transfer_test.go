@@ -13,7 +13,7 @@ func TestModelDB_NewReceivedTransfer(t *testing.T) {
 	m := setupDb(t)
 	taddr := utils.NewRandomAddress()
 	caddr := utils.NewRandomHash()
-	m.NewReceivedTransfer(2, caddr, taddr, taddr, 3, big.NewInt(10))
+	m.NewReceivedTransfer(2, caddr, taddr, taddr, 3, big.NewInt(10), 0, "")
 	key := fmt.Sprintf("%s-%d", caddr.String(), 3)
 	r, err := m.GetReceivedTransfer(key)
 	if err != nil {
@@ -25,8 +25,8 @@ func TestModelDB_NewReceivedTransfer(t *testing.T) {
 	assert.EqualValues(t, r.Nonce, 3)
 	assert.EqualValues(t, r.Amount, big.NewInt(10))
 
-	m.NewReceivedTransfer(3, caddr, taddr, taddr, 4, big.NewInt(10))
-	m.NewReceivedTransfer(5, caddr, taddr, taddr, 6, big.NewInt(10))
+	m.NewReceivedTransfer(3, caddr, taddr, taddr, 4, big.NewInt(10), 0, "")
+	m.NewReceivedTransfer(5, caddr, taddr, taddr, 6, big.NewInt(10), 0, "")
 
 	trs, err := m.GetReceivedTransferInBlockRange(0, 3)
 	if err != nil {
@@ -53,7 +53,7 @@ func TestModelDB_NewSentTransfer(t *testing.T) {
 	m := setupDb(t)
 	taddr := utils.NewRandomAddress()
 	caddr := utils.NewRandomHash()
-	m.NewSentTransfer(2, caddr, taddr, taddr, 3, big.NewInt(10))
+	m.NewSentTransfer(2, caddr, taddr, taddr, 3, big.NewInt(10), 0, "")
 	key := fmt.Sprintf("%s-%d", caddr.String(), 3)
 	r, err := m.GetSentTransfer(key)
 	if err != nil {
@@ -65,8 +65,8 @@ func TestModelDB_NewSentTransfer(t *testing.T) {
 	assert.EqualValues(t, r.Nonce, 3)
 	assert.EqualValues(t, r.Amount, big.NewInt(10))
 
-	m.NewSentTransfer(3, caddr, taddr, taddr, 4, big.NewInt(10))
-	m.NewSentTransfer(5, caddr, taddr, taddr, 6, big.NewInt(10))
+	m.NewSentTransfer(3, caddr, taddr, taddr, 4, big.NewInt(10), 0, "")
+	m.NewSentTransfer(5, caddr, taddr, taddr, 6, big.NewInt(10), 0, "")
 
 	trs, err := m.GetSentTransferInBlockRange(0, 3)
 	if err != nil {
@@ -88,3 +88,120 @@ func TestModelDB_NewSentTransfer(t *testing.T) {
 	}
 	assert.EqualValues(t, len(trs), 0)
 }
+
+func TestModelDB_TransferCallback(t *testing.T) {
+	m := setupDb(t)
+	taddr := utils.NewRandomAddress()
+	caddr := utils.NewRandomHash()
+	var sents []*SentTransfer
+	var receiveds []*ReceivedTransfer
+	m.RegisterSentTransferCallback(func(st *SentTransfer) bool {
+		sents = append(sents, st)
+		return len(sents) >= 2
+	})
+	m.RegisterReceivedTransferCallback(func(rt *ReceivedTransfer) bool {
+		receiveds = append(receiveds, rt)
+		return false
+	})
+	m.NewSentTransfer(2, caddr, taddr, taddr, 3, big.NewInt(10), 0, "")
+	m.NewSentTransfer(3, caddr, taddr, taddr, 4, big.NewInt(10), 0, "")
+	//callback removed
+	m.NewSentTransfer(4, caddr, taddr, taddr, 5, big.NewInt(10), 0, "")
+	//already exists, no notification
+	m.NewReceivedTransfer(2, caddr, taddr, taddr, 3, big.NewInt(10), 0, "")
+	m.NewReceivedTransfer(2, caddr, taddr, taddr, 3, big.NewInt(10), 0, "")
+	assert.EqualValues(t, len(sents), 2)
+	assert.EqualValues(t, sents[1].Nonce, 4)
+	assert.EqualValues(t, len(receiveds), 1)
+}
+
+func TestModelDB_GetSentTransfers(t *testing.T) {
+	m := setupDb(t)
+	token1 := utils.NewRandomAddress()
+	token2 := utils.NewRandomAddress()
+	partner1 := utils.NewRandomAddress()
+	partner2 := utils.NewRandomAddress()
+	ch1 := utils.NewRandomHash()
+	ch2 := utils.NewRandomHash()
+	for i := 1; i <= 5; i++ {
+		m.NewSentTransfer(int64(i), ch1, token1, partner1, int64(i), big.NewInt(int64(i*10)), 0, "")
+		m.NewSentTransfer(int64(i), ch2, token2, partner2, int64(i), big.NewInt(int64(i*100)), 0, "")
+	}
+	all := &TransferFilter{FromBlock: -1, ToBlock: -1}
+	trs, next, err := m.GetSentTransfers(all)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, 10, len(trs))
+	assert.EqualValues(t, "", next)
+	for i := 1; i < len(trs); i++ {
+		assert.True(t, trs[i-1].BlockNumber <= trs[i].BlockNumber)
+	}
+
+	trs, _, err = m.GetSentTransfers(&TransferFilter{FromBlock: 2, ToBlock: 4, Token: token1})
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 3, len(trs))
+	trs, _, err = m.GetSentTransfers(&TransferFilter{FromBlock: -1, ToBlock: -1, Partner: partner2, MinAmount: big.NewInt(200), MaxAmount: big.NewInt(400)})
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 3, len(trs))
+	trs, _, err = m.GetSentTransfers(&TransferFilter{FromBlock: -1, ToBlock: -1, Channel: ch1, Descending: true})
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 5, len(trs))
+	assert.EqualValues(t, 5, trs[0].BlockNumber)
+	trs, _, err = m.GetSentTransfers(&TransferFilter{FromBlock: -1, ToBlock: -1, Token: utils.NewRandomAddress()})
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 0, len(trs))
+
+	//pages must cover all transfers without repeat
+	for _, desc := range []bool{false, true} {
+		f := &TransferFilter{FromBlock: -1, ToBlock: -1, Limit: 3, Descending: desc}
+		keys := make(map[string]bool)
+		pages := 0
+		for {
+			trs, next, err = m.GetSentTransfers(f)
+			assert.Equal(t, nil, err)
+			for _, tr := range trs {
+				assert.False(t, keys[tr.Key])
+				keys[tr.Key] = true
+			}
+			pages++
+			if next == "" {
+				break
+			}
+			f.Cursor = next
+		}
+		assert.EqualValues(t, 10, len(keys))
+		assert.EqualValues(t, 4, pages)
+	}
+	_, _, err = m.GetSentTransfers(&TransferFilter{FromBlock: -1, ToBlock: -1, Cursor: "abc"})
+	assert.NotEqual(t, nil, err)
+}
+
+func TestModelDB_GetReceivedTransfers(t *testing.T) {
+	m := setupDb(t)
+	token := utils.NewRandomAddress()
+	partner := utils.NewRandomAddress()
+	ch := utils.NewRandomHash()
+	for i := 1; i <= 4; i++ {
+		m.NewReceivedTransfer(int64(i), ch, token, partner, int64(i), big.NewInt(int64(i)), uint64(100+i), fmt.Sprintf("order %d", i))
+		m.NewReceivedTransfer(int64(i), utils.NewRandomHash(), token, utils.NewRandomAddress(), int64(i), big.NewInt(int64(i)), 0, "")
+	}
+	trs, _, err := m.GetReceivedTransfers(&TransferFilter{FromBlock: -1, ToBlock: -1, Partner: partner})
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 4, len(trs))
+	trs, next, err := m.GetReceivedTransfers(&TransferFilter{FromBlock: -1, ToBlock: -1, Token: token, Limit: 5})
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 5, len(trs))
+	trs, next, err = m.GetReceivedTransfers(&TransferFilter{FromBlock: -1, ToBlock: -1, Token: token, Limit: 5, Cursor: next})
+	assert.Equal(t, nil, err)
+	assert.EqualValues(t, 3, len(trs))
+	assert.EqualValues(t, "", next)
+
+	trs, _, err = m.GetReceivedTransfers(&TransferFilter{FromBlock: -1, ToBlock: -1, PaymentIdentifier: 102})
+	assert.Equal(t, nil, err)
+	if assert.EqualValues(t, 1, len(trs)) {
+		assert.EqualValues(t, "order 2", trs[0].Memo)
+		assert.EqualValues(t, partner, trs[0].FromAddress)
+	}
+}
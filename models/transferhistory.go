@@ -0,0 +1,237 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/asdine/storm"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//transferIndexVersion is increased when indexes of transfers are changed, old db will be reindexed
+const transferIndexVersion = 2
+
+//ErrInvalidCursor is returned when the cursor of transfer history is malformed
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+/*
+TransferFilter selects transfers from history, zero values mean no limit.
+transfers are ordered by block number, Cursor is the next cursor returned by the last page.
+*/
+type TransferFilter struct {
+	FromBlock         int64 //-1 means no limit
+	ToBlock           int64 //-1 means no limit
+	Token             common.Address
+	Partner           common.Address //receiver of sent transfers, sender of received transfers
+	Channel           common.Hash
+	PaymentIdentifier uint64
+	MinAmount         *big.Int
+	MaxAmount         *big.Int
+	Descending        bool
+	Limit             int
+	Cursor            string
+}
+
+//transferRecord is the part of SentTransfer and ReceivedTransfer used by filter
+type transferRecord struct {
+	blockNumber       int64
+	key               string
+	token             common.Address
+	partner           common.Address
+	channel           common.Hash
+	amount            *big.Int
+	paymentIdentifier uint64
+}
+
+func (r *transferRecord) cursor() string {
+	return fmt.Sprintf("%d:%s", r.blockNumber, r.key)
+}
+
+//less is the ascending order of transfers
+func (r *transferRecord) less(blockNumber int64, key string) bool {
+	if r.blockNumber != blockNumber {
+		return r.blockNumber < blockNumber
+	}
+	return r.key < key
+}
+
+func parseCursor(cursor string) (blockNumber int64, key string, err error) {
+	ss := strings.SplitN(cursor, ":", 2)
+	if len(ss) != 2 {
+		err = ErrInvalidCursor
+		return
+	}
+	blockNumber, err = strconv.ParseInt(ss[0], 10, 64)
+	if err != nil {
+		err = ErrInvalidCursor
+	}
+	key = ss[1]
+	return
+}
+
+func (f *TransferFilter) match(r *transferRecord) bool {
+	if f.FromBlock >= 0 && r.blockNumber < f.FromBlock {
+		return false
+	}
+	if f.ToBlock >= 0 && r.blockNumber > f.ToBlock {
+		return false
+	}
+	if f.Token != utils.EmptyAddress && r.token != f.Token {
+		return false
+	}
+	if f.Partner != utils.EmptyAddress && r.partner != f.Partner {
+		return false
+	}
+	if f.Channel != utils.EmptyHash && r.channel != f.Channel {
+		return false
+	}
+	if f.PaymentIdentifier != 0 && r.paymentIdentifier != f.PaymentIdentifier {
+		return false
+	}
+	if f.MinAmount != nil && (r.amount == nil || r.amount.Cmp(f.MinAmount) < 0) {
+		return false
+	}
+	if f.MaxAmount != nil && (r.amount == nil || r.amount.Cmp(f.MaxAmount) > 0) {
+		return false
+	}
+	return true
+}
+
+/*
+apply returns indexes of records selected by f in order, and cursor of the next page, empty if no more.
+*/
+func (f *TransferFilter) apply(records []*transferRecord) (selected []int, next string, err error) {
+	var last *transferRecord //the last item of previous page
+	if f.Cursor != "" {
+		last = new(transferRecord)
+		last.blockNumber, last.key, err = parseCursor(f.Cursor)
+		if err != nil {
+			return
+		}
+	}
+	for i, r := range records {
+		if !f.match(r) {
+			continue
+		}
+		if last != nil {
+			if !f.Descending && !last.less(r.blockNumber, r.key) {
+				continue
+			}
+			if f.Descending && !r.less(last.blockNumber, last.key) {
+				continue
+			}
+		}
+		selected = append(selected, i)
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		ri, rj := records[selected[i]], records[selected[j]]
+		if f.Descending {
+			return rj.less(ri.blockNumber, ri.key)
+		}
+		return ri.less(rj.blockNumber, rj.key)
+	})
+	if f.Limit > 0 && len(selected) > f.Limit {
+		selected = selected[:f.Limit]
+		next = records[selected[len(selected)-1]].cursor()
+	}
+	return
+}
+
+//findTransfers use the most selective index of filter, partnerField is ToAddress or FromAddress
+func (model *ModelDB) findTransfers(f *TransferFilter, partnerField string, to interface{}) (err error) {
+	switch {
+	case f.PaymentIdentifier != 0:
+		err = model.db.Find("PaymentIdentifier", f.PaymentIdentifier, to)
+	case f.Channel != utils.EmptyHash:
+		err = model.db.Find("ChannelIdentifier", f.Channel, to)
+	case f.Partner != utils.EmptyAddress:
+		err = model.db.Find(partnerField, f.Partner, to)
+	case f.Token != utils.EmptyAddress:
+		err = model.db.Find("TokenAddress", f.Token, to)
+	default:
+		fromBlock, toBlock := f.FromBlock, f.ToBlock
+		if fromBlock < 0 {
+			fromBlock = 0
+		}
+		if toBlock < 0 {
+			toBlock = math.MaxInt64
+		}
+		err = model.db.Range("BlockNumber", fromBlock, toBlock, to)
+	}
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	return
+}
+
+//GetSentTransfers returns sent transfers selected by filter and cursor of the next page
+func (model *ModelDB) GetSentTransfers(f *TransferFilter) (transfers []*SentTransfer, next string, err error) {
+	var all []*SentTransfer
+	err = model.findTransfers(f, "ToAddress", &all)
+	if err != nil {
+		return
+	}
+	records := make([]*transferRecord, len(all))
+	for i, t := range all {
+		records[i] = &transferRecord{t.BlockNumber, t.Key, t.TokenAddress, t.ToAddress, t.ChannelIdentifier, t.Amount, t.PaymentIdentifier}
+	}
+	selected, next, err := f.apply(records)
+	if err != nil {
+		return
+	}
+	transfers = []*SentTransfer{}
+	for _, i := range selected {
+		transfers = append(transfers, all[i])
+	}
+	return
+}
+
+//GetReceivedTransfers returns received transfers selected by filter and cursor of the next page
+func (model *ModelDB) GetReceivedTransfers(f *TransferFilter) (transfers []*ReceivedTransfer, next string, err error) {
+	var all []*ReceivedTransfer
+	err = model.findTransfers(f, "FromAddress", &all)
+	if err != nil {
+		return
+	}
+	records := make([]*transferRecord, len(all))
+	for i, t := range all {
+		records[i] = &transferRecord{t.BlockNumber, t.Key, t.TokenAddress, t.FromAddress, t.ChannelIdentifier, t.Amount, t.PaymentIdentifier}
+	}
+	selected, next, err := f.apply(records)
+	if err != nil {
+		return
+	}
+	transfers = []*ReceivedTransfer{}
+	for _, i := range selected {
+		transfers = append(transfers, all[i])
+	}
+	return
+}
+
+//reindexTransfers build indexes added to transfers after the db was created
+func (model *ModelDB) reindexTransfers() {
+	var ver int
+	err := model.db.Get(bucketMeta, "transferindex", &ver)
+	if err == nil && ver >= transferIndexVersion {
+		return
+	}
+	log.Info("reindex transfers, it may take a while...")
+	for _, t := range []interface{}{&SentTransfer{}, &ReceivedTransfer{}} {
+		err = model.db.ReIndex(t)
+		if err != nil && err != storm.ErrNotFound {
+			log.Error(fmt.Sprintf("reindex %T err %s", t, err))
+			return
+		}
+	}
+	err = model.db.Set(bucketMeta, "transferindex", transferIndexVersion)
+	if err != nil {
+		log.Error(fmt.Sprintf("db err %s", err))
+	}
+}
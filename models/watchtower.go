@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/gob"
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+WatchtowerAck is the latest package of a channel a watchtower has acknowledged,
+a new package is pushed when partner's nonce or the number of locks can be unlocked changes.
+*/
+type WatchtowerAck struct {
+	Key     string         `storm:"id"`
+	Tower   common.Address `json:"tower"`
+	Channel common.Hash    `json:"channel" storm:"index"`
+	Nonce   int64          `json:"nonce"`   //nonce of partner's balance proof
+	Unlocks int            `json:"unlocks"` //number of partner's locks whose secret is known
+	AckTime time.Time      `json:"ack_time"`
+}
+
+func init() {
+	gob.Register(&WatchtowerAck{})
+}
+
+func watchtowerAckKey(tower common.Address, channel common.Hash) string {
+	return fmt.Sprintf("%s-%s", tower.String(), channel.String())
+}
+
+//GetWatchtowerAck returns what tower has acknowledged for channel, nonce is 0 if nothing
+func (model *ModelDB) GetWatchtowerAck(tower common.Address, channel common.Hash) (a *WatchtowerAck, err error) {
+	a = new(WatchtowerAck)
+	err = model.db.One("Key", watchtowerAckKey(tower, channel), a)
+	if err == storm.ErrNotFound {
+		a = &WatchtowerAck{
+			Key:     watchtowerAckKey(tower, channel),
+			Tower:   tower,
+			Channel: channel,
+		}
+		err = nil
+	}
+	return
+}
+
+//SaveWatchtowerAck save a package acknowledged by tower
+func (model *ModelDB) SaveWatchtowerAck(a *WatchtowerAck) error {
+	a.Key = watchtowerAckKey(a.Tower, a.Channel)
+	a.AckTime = time.Now()
+	return model.db.Save(a)
+}
+
+//RemoveWatchtowerAcks remove acks of a settled channel
+func (model *ModelDB) RemoveWatchtowerAcks(channel common.Hash) error {
+	err := model.db.Select(q.Eq("Channel", channel)).Delete(&WatchtowerAck{})
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	return err
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelDB_WatchtowerAck(t *testing.T) {
+	m := setupDb(t)
+	tower := utils.NewRandomAddress()
+	channel := utils.NewRandomHash()
+	a, err := m.GetWatchtowerAck(tower, channel)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, a.Nonce, 0)
+	a.Nonce = 3
+	a.Unlocks = 1
+	err = m.SaveWatchtowerAck(a)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	a, err = m.GetWatchtowerAck(tower, channel)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, a.Nonce, 3)
+	assert.EqualValues(t, a.Unlocks, 1)
+	a, err = m.GetWatchtowerAck(utils.NewRandomAddress(), channel)
+	assert.EqualValues(t, a.Nonce, 0)
+
+	err = m.RemoveWatchtowerAcks(channel)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	a, err = m.GetWatchtowerAck(tower, channel)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, a.Nonce, 0)
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"encoding/gob"
+	"fmt"
+	"time"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+)
+
+//Webhook is a http url notified when something happens to this node
+type Webhook struct {
+	ID         int       `storm:"id,increment" json:"id"`
+	URL        string    `storm:"unique" json:"url"`
+	Events     []string  `json:"events,omitempty"` //empty means all events
+	CreateTime time.Time `json:"create_time"`
+}
+
+/*
+WebhookDelivery is a notification waiting to be posted to a webhook,
+it's removed after success or too many attempts.
+*/
+type WebhookDelivery struct {
+	ID        int    `storm:"id,increment"`
+	WebhookID int    `storm:"index"`
+	Event     string //type of the notification
+	Payload   []byte //body to post
+	Attempts  int    //how many times failed
+	NextTry   time.Time
+	LastError string
+}
+
+func init() {
+	gob.Register(&Webhook{})
+	gob.Register(&WebhookDelivery{})
+}
+
+//Accept returns true if this webhook wants event
+func (w *Webhook) Accept(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+//NewWebhook save a new webhook, url must be unique
+func (model *ModelDB) NewWebhook(w *Webhook) error {
+	w.CreateTime = time.Now()
+	return model.db.Save(w)
+}
+
+//GetWebhookList returns all webhooks
+func (model *ModelDB) GetWebhookList() (ws []*Webhook, err error) {
+	err = model.db.All(&ws)
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	return
+}
+
+//RemoveWebhook remove webhook and deliveries waiting for it
+func (model *ModelDB) RemoveWebhook(id int) error {
+	err := model.db.DeleteStruct(&Webhook{ID: id})
+	if err != nil {
+		return err
+	}
+	err = model.db.Select(q.Eq("WebhookID", id)).Delete(&WebhookDelivery{})
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	return err
+}
+
+//NewWebhookDelivery save a delivery to be posted now
+func (model *ModelDB) NewWebhookDelivery(d *WebhookDelivery) {
+	d.NextTry = time.Now()
+	err := model.db.Save(d)
+	if err != nil {
+		log.Error(fmt.Sprintf("save WebhookDelivery err %s", err))
+	}
+}
+
+//GetDueWebhookDeliveries returns deliveries which should be tried before `now`
+func (model *ModelDB) GetDueWebhookDeliveries(now time.Time) (ds []*WebhookDelivery, err error) {
+	var all []*WebhookDelivery
+	err = model.db.All(&all)
+	if err == storm.ErrNotFound {
+		err = nil
+	}
+	for _, d := range all {
+		if !d.NextTry.After(now) {
+			ds = append(ds, d)
+		}
+	}
+	return
+}
+
+//UpdateWebhookDelivery save a failed delivery
+func (model *ModelDB) UpdateWebhookDelivery(d *WebhookDelivery) error {
+	return model.db.Save(d)
+}
+
+//RemoveWebhookDelivery remove a finished delivery
+func (model *ModelDB) RemoveWebhookDelivery(d *WebhookDelivery) error {
+	return model.db.DeleteStruct(d)
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelDB_Webhook(t *testing.T) {
+	m := setupDb(t)
+	w := &Webhook{URL: "http://127.0.0.1:8000/notify", Events: []string{"received_transfer"}}
+	err := m.NewWebhook(w)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = m.NewWebhook(&Webhook{URL: w.URL})
+	if err == nil {
+		t.Error("url should be unique")
+	}
+	ws, err := m.GetWebhookList()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, len(ws), 1)
+	assert.EqualValues(t, ws[0].Accept("received_transfer"), true)
+	assert.EqualValues(t, ws[0].Accept("token_added"), false)
+
+	m.NewWebhookDelivery(&WebhookDelivery{WebhookID: w.ID, Event: "received_transfer", Payload: []byte("{}")})
+	d2 := &WebhookDelivery{WebhookID: w.ID, Event: "received_transfer"}
+	m.NewWebhookDelivery(d2)
+	d2.Attempts++
+	d2.NextTry = time.Now().Add(time.Hour)
+	err = m.UpdateWebhookDelivery(d2)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ds, err := m.GetDueWebhookDeliveries(time.Now())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assert.EqualValues(t, len(ds), 1)
+	assert.EqualValues(t, ds[0].Payload, []byte("{}"))
+	err = m.RemoveWebhookDelivery(ds[0])
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ds, _ = m.GetDueWebhookDeliveries(time.Now().Add(2 * time.Hour))
+	assert.EqualValues(t, len(ds), 1)
+
+	err = m.RemoveWebhook(w.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ws, _ = m.GetWebhookList()
+	assert.EqualValues(t, len(ws), 0)
+	ds, _ = m.GetDueWebhookDeliveries(time.Now().Add(2 * time.Hour))
+	assert.EqualValues(t, len(ds), 0)
+}
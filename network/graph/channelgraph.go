@@ -177,11 +177,13 @@ func (cg *ChannelGraph) HasChannel(source, target common.Address) bool {
 }
 
 var errAddressNotFoundInGraph = errors.New("address not found in channelgraph")
+var errNoPath = errors.New("no path")
 
 /*
-ShortestPath returns the shortestpath weight from source to target.  make sure only be called in one thread.
+ShortestPath returns the path with least fee from source to target,
+edges known not enough for amount are ignored. make sure only be called in one thread.
 */
-func (cg *ChannelGraph) ShortestPath(source, target common.Address, amount *big.Int, feeCharger fee.Charger) (totalWeight int64, err error) {
+func (cg *ChannelGraph) ShortestPath(source, target common.Address, amount *big.Int, feeCharger fee.Charger) (path *Path, err error) {
 	sourceIndex, ok := cg.address2index[source]
 	if !ok {
 		err = errAddressNotFoundInGraph
@@ -192,26 +194,13 @@ func (cg *ChannelGraph) ShortestPath(source, target common.Address, amount *big.
 		err = errAddressNotFoundInGraph
 		return
 	}
-	if sourceIndex == targetIndex {
-		return 0, nil
-	}
-	var g2 *dijkstra.Graph
-	if false { //make sure only be called in one thread.
-		g2 = cg.g.CloneGraph()
-	} else {
-		g2 = cg.g
-	}
-	for _, v := range g2.Verticies {
-		w := feeCharger.GetNodeChargeFee(cg.index2address[v.ID], cg.TokenAddress, amount).Int64()
-		if w > 0 { //for no fee policy, all nodes charge 0 ,so use the shortest path first.
-			v.SetWeight(w) // from v's fee is w.
-		}
-	}
-	path, err := g2.Shortest(sourceIndex, targetIndex)
-	if err != nil {
+	pf := cg.newPathFinder(amount, feeCharger)
+	nodes, cost, ok := pf.shortest(sourceIndex, targetIndex, nil, nil)
+	if !ok {
+		err = errNoPath
 		return
 	}
-	return path.Distance, nil
+	return pf.toPath(nodes, cost), nil
 }
 
 //RemoveChannel remove a channel from graph,and i'm a participant of this channel
@@ -264,7 +253,9 @@ func (cg *ChannelGraph) getNeighbours() []common.Address {
 
 type neighborWeight struct {
 	neighbor common.Address
-	weight   int64 //nerghbor to target's hops
+	fee      *big.Int //fee charged by nodes from neighbor to target,including neighbor
+	hops     int      //nerghbor to target's hops
+	path     []int    //nodes from neighbor to target
 }
 type neighborWeightList []*neighborWeight
 
@@ -272,7 +263,7 @@ func (nw neighborWeightList) Len() int {
 	return len(nw)
 }
 func (nw neighborWeightList) Less(i, j int) bool {
-	return nw[i].weight < nw[j].weight
+	return pathCost{nw[i].fee, nw[i].hops}.less(pathCost{nw[j].fee, nw[j].hops})
 }
 func (nw neighborWeightList) Swap(i, j int) {
 	var temp *neighborWeight
@@ -281,32 +272,116 @@ func (nw neighborWeightList) Swap(i, j int) {
 	nw[j] = temp
 }
 
+//excludeNodes returns index of us and excludeAddresses except target
+func (cg *ChannelGraph) excludeNodes(ourAddress, targetAddress common.Address, excludeAddresses map[common.Address]bool) map[int]bool {
+	excludeNodes := make(map[int]bool)
+	for addr := range excludeAddresses {
+		if i, ok := cg.address2index[addr]; ok && addr != targetAddress {
+			excludeNodes[i] = true
+		}
+	}
+	excludeNodes[cg.address2index[ourAddress]] = true
+	return excludeNodes
+}
+
 /*
-all the neighbors that can reach target
-they are ordered by hops to the target
+disjointNeighbor returns nw if its path doesn't pass through used nodes,
+otherwise another path from the same neighbor to target avoiding them, nil if there is none.
 */
-func (cg *ChannelGraph) orderedNeighbours(ourAddress, targetAddress common.Address, amount *big.Int, charger fee.Charger) neighborWeightList {
+func (pf *pathFinder) disjointNeighbor(nw *neighborWeight, excludeNodes, used map[int]bool) *neighborWeight {
+	last := len(nw.path) - 1
+	crossed := false
+	for _, n := range nw.path[:last] {
+		if used[n] {
+			crossed = true
+			break
+		}
+	}
+	if !crossed {
+		return nw
+	}
+	if used[nw.path[0]] {
+		return nil
+	}
+	exclude := make(map[int]bool)
+	for n := range excludeNodes {
+		exclude[n] = true
+	}
+	for n := range used {
+		exclude[n] = true
+	}
+	nodes, cost, ok := pf.shortest(nw.path[0], nw.path[last], exclude, nil)
+	if !ok {
+		return nil
+	}
+	fee := new(big.Int).Add(cost.fee, pf.nodeFee(nodes[0]))
+	return &neighborWeight{nw.neighbor, fee, cost.hops, nodes}
+}
 
+/*
+all the neighbors that can reach target without passing through us and excludeAddresses,
+they are ordered by fee and then hops to the target
+*/
+func (cg *ChannelGraph) orderedNeighbours(ourAddress, targetAddress common.Address, amount *big.Int, excludeAddresses map[common.Address]bool, charger fee.Charger) neighborWeightList {
+	targetIndex, ok := cg.address2index[targetAddress]
+	if !ok {
+		return nil
+	}
+	excludeNodes := cg.excludeNodes(ourAddress, targetAddress, excludeAddresses)
+	pf := cg.newPathFinder(amount, charger)
 	neighbors := cg.getNeighbours()
 	var nws neighborWeightList
 	for _, n := range neighbors {
-		w, err := cg.ShortestPath(n, targetAddress, amount, charger)
-		if err != nil {
+		if n == targetAddress {
+			nws = append(nws, &neighborWeight{n, big.NewInt(0), 0, []int{targetIndex}})
 			continue
 		}
-		nws = append(nws, &neighborWeight{n, w})
+		nodes, cost, ok := pf.shortest(cg.address2index[n], targetIndex, excludeNodes, nil)
+		if !ok {
+			continue
+		}
+		fee := new(big.Int).Add(cost.fee, pf.nodeFee(nodes[0]))
+		nws = append(nws, &neighborWeight{n, fee, cost.hops, nodes})
 	}
-	sort.Sort(nws)
+	sort.Stable(nws)
 	return nws
 }
 
 /*
-GetBestRoutes returns all neighbor nodes order by weight from it to target.
+GetBestRoutes returns all neighbor nodes order by fee from it to target.
+routes whose total fee is greater than maxFee are ignored, maxFee nil means no limit.
 我们现在的路由算法应该是有历史记忆的最短路径/最小费用算法.
 跳过所有已经走过的路径.
 */
 func (cg *ChannelGraph) GetBestRoutes(nodesStatus NodesStatusGetter, ourAddress common.Address,
-	targetAdress common.Address, amount *big.Int, excludeAddresses map[common.Address]bool, feeCharger fee.Charger) (onlineNodes []*route.State) {
+	targetAdress common.Address, amount *big.Int, maxFee *big.Int, excludeAddresses map[common.Address]bool, feeCharger fee.Charger) (onlineNodes []*route.State) {
+	onlineNodes, _ = cg.getRoutes(nodesStatus, ourAddress, targetAdress, amount, maxFee, excludeAddresses, feeCharger, false)
+	return
+}
+
+/*
+GetBestPaths returns the whole path of every route GetBestRoutes returns, in the same order.
+*/
+func (cg *ChannelGraph) GetBestPaths(nodesStatus NodesStatusGetter, ourAddress common.Address,
+	targetAdress common.Address, amount *big.Int, maxFee *big.Int, excludeAddresses map[common.Address]bool, feeCharger fee.Charger) (paths []*Path) {
+	_, paths = cg.getRoutes(nodesStatus, ourAddress, targetAdress, amount, maxFee, excludeAddresses, feeCharger, false)
+	return
+}
+
+/*
+GetRoutesForSplit is the same as GetBestRoutes, except that a channel which cannot afford the whole amount is still returned,
+so a multi path transfer can use as many channels as required to finish the transfer.
+paths of the routes returned share no node except target, a mediator cannot hold two locks with the same lock secret hash.
+mediators choose their own next hop, so this is what we expect, not what we can guarantee.
+*/
+func (cg *ChannelGraph) GetRoutesForSplit(nodesStatus NodesStatusGetter, ourAddress common.Address,
+	targetAdress common.Address, amount *big.Int, maxFee *big.Int, excludeAddresses map[common.Address]bool, feeCharger fee.Charger) (onlineNodes []*route.State) {
+	onlineNodes, _ = cg.getRoutes(nodesStatus, ourAddress, targetAdress, amount, maxFee, excludeAddresses, feeCharger, true)
+	return
+}
+
+func (cg *ChannelGraph) getRoutes(nodesStatus NodesStatusGetter, ourAddress common.Address,
+	targetAdress common.Address, amount *big.Int, maxFee *big.Int, excludeAddresses map[common.Address]bool, feeCharger fee.Charger, split bool) (onlineNodes []*route.State, paths []*Path) {
 	/*
 
 	   XXX: consider using multiple channels for a single transfer. Useful
@@ -318,11 +393,18 @@ func (cg *ChannelGraph) GetBestRoutes(nodesStatus NodesStatusGetter, ourAddress
 	   let the task use as many as required to finish the transfer.
 
 	*/
-	nws := cg.orderedNeighbours(ourAddress, targetAdress, amount, feeCharger)
+	nws := cg.orderedNeighbours(ourAddress, targetAdress, amount, excludeAddresses, feeCharger)
 	if len(nws) == 0 {
 		log.Warn(fmt.Sprintf("no routes avaiable from %s to %s", utils.APex(ourAddress), utils.APex(targetAdress)))
 		return
 	}
+	var pf *pathFinder
+	var excludeNodes, usedNodes map[int]bool
+	if split {
+		pf = cg.newPathFinder(amount, feeCharger)
+		excludeNodes = cg.excludeNodes(ourAddress, targetAdress, excludeAddresses)
+		usedNodes = make(map[int]bool) //nodes on the paths chosen,except target
+	}
 	for _, nw := range nws {
 		c := cg.GetPartenerAddress2Channel(nw.neighbor)
 		//don't send the message backwards
@@ -333,23 +415,41 @@ func (cg *ChannelGraph) GetBestRoutes(nodesStatus NodesStatusGetter, ourAddress
 			log.Debug(fmt.Sprintf("channel %s-%s cannot transfer ,ignoring ..", utils.APex(ourAddress), utils.APex(nw.neighbor)))
 			continue
 		}
-		if amount.Cmp(c.Distributable()) > 0 {
+		if split && c.Distributable().Sign() <= 0 {
+			continue
+		}
+		if !split && amount.Cmp(c.Distributable()) > 0 {
 			log.Debug(fmt.Sprintf("channel %s-%s doesn't have enough funds[%d],ignoring...", utils.APex(ourAddress), utils.APex(nw.neighbor), amount))
 			continue
 		}
+		if split {
+			nw = pf.disjointNeighbor(nw, excludeNodes, usedNodes)
+			if nw == nil {
+				continue
+			}
+		}
+		if maxFee != nil && nw.fee.Cmp(maxFee) > 0 {
+			log.Debug(fmt.Sprintf("route by %s need fee %s,more than %s,ignoring...", utils.APex(nw.neighbor), nw.fee, maxFee))
+			continue
+		}
 		deviceType, isOnline := nodesStatus.GetNetworkStatus(nw.neighbor)
 		if !isOnline || (deviceType == xmpptransport.TypeMobile && nw.neighbor != targetAdress) {
 			log.Debug(fmt.Sprintf("partener %s network ignored.. isOnline:%v,deviceType:%s", utils.APex(nw.neighbor), isOnline, deviceType))
 			continue
 		}
 		routeState := Channel2RouteState(c, nw.neighbor, amount, feeCharger)
-		if routeState.Fee.Cmp(utils.BigInt0) > 0 {
-			routeState.TotalFee = big.NewInt(int64(nw.weight))
-		} else { //no fee policy,
-			routeState.TotalFee = utils.BigInt0
-		}
-
+		routeState.TotalFee = nw.fee
 		onlineNodes = append(onlineNodes, routeState)
+		if split {
+			for _, n := range nw.path[:len(nw.path)-1] {
+				usedNodes[n] = true
+			}
+		}
+		p := &Path{Nodes: []common.Address{ourAddress}, TotalFee: nw.fee}
+		for _, n := range nw.path {
+			p.Nodes = append(p.Nodes, cg.index2address[n])
+		}
+		paths = append(paths, p)
 	}
 	return
 }
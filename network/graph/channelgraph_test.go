@@ -0,0 +1,127 @@
+package graph
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/channel"
+	"github.com/SmartMeshFoundation/SmartRaiden/channel/channeltype"
+	"github.com/SmartMeshFoundation/SmartRaiden/network/rpc/contracts"
+	"github.com/SmartMeshFoundation/SmartRaiden/network/xmpptransport"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mtree"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+type allOnline struct{}
+
+func (allOnline) GetNetworkStatus(addr common.Address) (deviceType string, isOnline bool) {
+	return xmpptransport.TypeOtherDevice, true
+}
+
+//addTestChannel add an open channel which needs no blockchain
+func addTestChannel(t *testing.T, cg *ChannelGraph, partner common.Address, balance int64) {
+	ch := &channel.Channel{
+		OurState:          channel.NewChannelEndState(cg.OurAddress, big.NewInt(balance), nil, mtree.EmptyTree),
+		PartnerState:      channel.NewChannelEndState(partner, big.NewInt(0), nil, mtree.EmptyTree),
+		ExternState:       &channel.ExternalState{},
+		ChannelIdentifier: contracts.ChannelUniqueID{ChannelIdentifier: utils.NewRandomHash()},
+		TokenAddress:      cg.TokenAddress,
+		State:             channeltype.StateOpened,
+	}
+	err := cg.AddChannel(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+/*
+A is us, B(1),C(2) and E(5) are our partners,
+paths from B and C merge at D(1) before they reach target F.
+
+	  B
+	 / \
+	A-C-D-F
+	 \   /
+	  E--
+*/
+func TestGetRoutesForSplit(t *testing.T) {
+	a, b, c, d, e, f := utils.NewRandomAddress(), utils.NewRandomAddress(), utils.NewRandomAddress(),
+		utils.NewRandomAddress(), utils.NewRandomAddress(), utils.NewRandomAddress()
+	cg := NewChannelGraph(a, utils.NewRandomAddress(), []common.Address{b, d, c, d, d, f, e, f})
+	addTestChannel(t, cg, b, 10)
+	addTestChannel(t, cg, c, 10)
+	addTestChannel(t, cg, e, 10)
+	charger := mapFeeCharger{b: 1, c: 2, d: 1, e: 5}
+	routes := cg.GetBestRoutes(allOnline{}, a, f, big.NewInt(5), nil, EmptyExlude, charger)
+	if assert.Len(t, routes, 3) {
+		assert.EqualValues(t, e, routes[2].HopNode())
+	}
+	//c is skipped, its path would meet b's at d
+	routes = cg.GetRoutesForSplit(allOnline{}, a, f, big.NewInt(15), nil, EmptyExlude, charger)
+	if assert.Len(t, routes, 2) {
+		assert.EqualValues(t, b, routes[0].HopNode())
+		assert.EqualValues(t, big.NewInt(2), routes[0].TotalFee)
+		assert.EqualValues(t, e, routes[1].HopNode())
+		assert.EqualValues(t, big.NewInt(5), routes[1].TotalFee)
+	}
+	//c can avoid d by g(3), the fee changes
+	g := utils.NewRandomAddress()
+	cg.AddPath(c, g)
+	cg.AddPath(g, f)
+	charger[g] = 3
+	routes = cg.GetRoutesForSplit(allOnline{}, a, f, big.NewInt(15), nil, EmptyExlude, charger)
+	if assert.Len(t, routes, 3) {
+		assert.EqualValues(t, c, routes[1].HopNode())
+		assert.EqualValues(t, big.NewInt(5), routes[1].TotalFee)
+	}
+}
+
+type offlineNodes map[common.Address]bool
+
+func (o offlineNodes) GetNetworkStatus(addr common.Address) (deviceType string, isOnline bool) {
+	return xmpptransport.TypeOtherDevice, !o[addr]
+}
+
+/*
+A is us, B(3) and C(0) are our partners, both reach target F,
+C's path is C-D(2)-F, B's is B-F.
+*/
+func TestGetBestPaths(t *testing.T) {
+	a, b, c, d, f := utils.NewRandomAddress(), utils.NewRandomAddress(), utils.NewRandomAddress(),
+		utils.NewRandomAddress(), utils.NewRandomAddress()
+	cg := NewChannelGraph(a, utils.NewRandomAddress(), []common.Address{b, f, c, d, d, f})
+	addTestChannel(t, cg, b, 10)
+	addTestChannel(t, cg, c, 20)
+	charger := mapFeeCharger{b: 3, c: 0, d: 2}
+	paths := cg.GetBestPaths(allOnline{}, a, f, big.NewInt(5), nil, EmptyExlude, charger)
+	if assert.Len(t, paths, 2) {
+		assert.EqualValues(t, []common.Address{a, c, d, f}, paths[0].Nodes)
+		assert.EqualValues(t, big.NewInt(2), paths[0].TotalFee)
+		assert.EqualValues(t, []common.Address{a, b, f}, paths[1].Nodes)
+		assert.EqualValues(t, big.NewInt(3), paths[1].TotalFee)
+	}
+	//b cannot afford 15 tokens
+	paths = cg.GetBestPaths(allOnline{}, a, f, big.NewInt(15), nil, EmptyExlude, charger)
+	if assert.Len(t, paths, 1) {
+		assert.EqualValues(t, c, paths[0].Nodes[1])
+	}
+	//c is more expensive now, b is the best
+	charger[c] = 5
+	paths = cg.GetBestPaths(allOnline{}, a, f, big.NewInt(5), nil, EmptyExlude, charger)
+	if assert.Len(t, paths, 2) {
+		assert.EqualValues(t, b, paths[0].Nodes[1])
+		assert.EqualValues(t, big.NewInt(7), paths[1].TotalFee)
+	}
+	paths = cg.GetBestPaths(allOnline{}, a, f, big.NewInt(5), big.NewInt(4), EmptyExlude, charger)
+	if assert.Len(t, paths, 1) {
+		assert.EqualValues(t, b, paths[0].Nodes[1])
+	}
+	paths = cg.GetBestPaths(offlineNodes{b: true}, a, f, big.NewInt(5), nil, EmptyExlude, charger)
+	if assert.Len(t, paths, 1) {
+		assert.EqualValues(t, c, paths[0].Nodes[1])
+	}
+	paths = cg.GetBestPaths(allOnline{}, a, utils.NewRandomAddress(), big.NewInt(5), nil, EmptyExlude, charger)
+	assert.Len(t, paths, 0)
+}
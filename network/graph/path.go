@@ -0,0 +1,343 @@
+package graph
+
+import (
+	"container/heap"
+	"math/big"
+	"sort"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/network/rpc/fee"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//Path is a route from source to target, both source and target are included in Nodes.
+type Path struct {
+	Nodes    []common.Address
+	TotalFee *big.Int //fee charged by all the mediated nodes on this path
+}
+
+//Hops of this path
+func (p *Path) Hops() int {
+	return len(p.Nodes) - 1
+}
+
+/*
+pathCost compares fee first, and then hops.
+if no node charges fee, the path with the least hops is the best.
+*/
+type pathCost struct {
+	fee  *big.Int
+	hops int
+}
+
+func (c pathCost) less(o pathCost) bool {
+	r := c.fee.Cmp(o.fee)
+	if r != 0 {
+		return r < 0
+	}
+	return c.hops < o.hops
+}
+
+type edge struct {
+	from, to int
+}
+
+/*
+pathFinder find paths for a specified amount,
+fee of each node is calculated only once for each query.
+*/
+type pathFinder struct {
+	cg      *ChannelGraph
+	amount  *big.Int
+	charger fee.Charger
+	fees    map[int]*big.Int
+}
+
+func (cg *ChannelGraph) newPathFinder(amount *big.Int, charger fee.Charger) *pathFinder {
+	return &pathFinder{
+		cg:      cg,
+		amount:  amount,
+		charger: charger,
+		fees:    make(map[int]*big.Int),
+	}
+}
+
+func (pf *pathFinder) nodeFee(index int) *big.Int {
+	f, ok := pf.fees[index]
+	if !ok {
+		f = pf.charger.GetNodeChargeFee(pf.cg.index2address[index], pf.cg.TokenAddress, pf.amount)
+		if f == nil || f.Sign() < 0 {
+			f = big.NewInt(0)
+		}
+		pf.fees[index] = f
+	}
+	return f
+}
+
+/*
+edgeUsable returns false only if we know this edge cannot transfer amount tokens,
+we only know the capacity of channels we participate in.
+*/
+func (pf *pathFinder) edgeUsable(from, to int) bool {
+	cg := pf.cg
+	fromAddr, toAddr := cg.index2address[from], cg.index2address[to]
+	if fromAddr == cg.OurAddress {
+		c := cg.PartenerAddress2Channel[toAddr]
+		if c == nil {
+			return true
+		}
+		return c.CanTransfer() && c.Distributable().Cmp(pf.amount) >= 0
+	}
+	if toAddr == cg.OurAddress {
+		c := cg.PartenerAddress2Channel[fromAddr]
+		if c == nil {
+			return true
+		}
+		return c.CanTransfer() && c.PartnerState.Distributable(c.OurState).Cmp(pf.amount) >= 0
+	}
+	return true
+}
+
+func (pf *pathFinder) neighbors(index int) []int {
+	ns, err := pf.cg.g.GetAllNeighbors(index)
+	if err != nil {
+		return nil
+	}
+	sort.Ints(ns) //make result stable
+	return ns
+}
+
+//costOf fee of all nodes between the first and the last one
+func (pf *pathFinder) costOf(nodes []int) pathCost {
+	c := pathCost{fee: big.NewInt(0), hops: len(nodes) - 1}
+	for i := 1; i < len(nodes)-1; i++ {
+		c.fee.Add(c.fee, pf.nodeFee(nodes[i]))
+	}
+	return c
+}
+
+type queueItem struct {
+	index int
+	cost  pathCost
+}
+type priorityQueue []*queueItem
+
+func (pq priorityQueue) Len() int            { return len(pq) }
+func (pq priorityQueue) Less(i, j int) bool  { return pq[i].cost.less(pq[j].cost) }
+func (pq priorityQueue) Swap(i, j int)       { pq[i], pq[j] = pq[j], pq[i] }
+func (pq *priorityQueue) Push(x interface{}) { *pq = append(*pq, x.(*queueItem)) }
+func (pq *priorityQueue) Pop() interface{} {
+	old := *pq
+	n := len(old)
+	item := old[n-1]
+	*pq = old[0 : n-1]
+	return item
+}
+
+/*
+shortest is dijkstra algorithm with big.Int fee,
+nodes in excludeNodes and edges in excludeEdges will be skipped.
+*/
+func (pf *pathFinder) shortest(src, dst int, excludeNodes map[int]bool, excludeEdges map[edge]bool) (nodes []int, cost pathCost, ok bool) {
+	best := map[int]pathCost{src: {fee: big.NewInt(0)}}
+	prev := make(map[int]int)
+	done := make(map[int]bool)
+	pq := &priorityQueue{{index: src, cost: best[src]}}
+	for pq.Len() > 0 {
+		item := heap.Pop(pq).(*queueItem)
+		u := item.index
+		if done[u] {
+			continue
+		}
+		done[u] = true
+		if u == dst {
+			break
+		}
+		var feeOfU *big.Int
+		if u == src {
+			feeOfU = big.NewInt(0)
+		} else {
+			feeOfU = pf.nodeFee(u)
+		}
+		for _, v := range pf.neighbors(u) {
+			if done[v] || excludeNodes[v] || excludeEdges[edge{u, v}] || !pf.edgeUsable(u, v) {
+				continue
+			}
+			c := pathCost{fee: new(big.Int).Add(item.cost.fee, feeOfU), hops: item.cost.hops + 1}
+			if old, exist := best[v]; exist && !c.less(old) {
+				continue
+			}
+			best[v] = c
+			prev[v] = u
+			heap.Push(pq, &queueItem{index: v, cost: c})
+		}
+	}
+	if !done[dst] {
+		return
+	}
+	for n := dst; n != src; n = prev[n] {
+		nodes = append([]int{n}, nodes...)
+	}
+	nodes = append([]int{src}, nodes...)
+	return nodes, best[dst], true
+}
+
+func (pf *pathFinder) toPath(nodes []int, cost pathCost) *Path {
+	p := &Path{TotalFee: cost.fee}
+	for _, n := range nodes {
+		p.Nodes = append(p.Nodes, pf.cg.index2address[n])
+	}
+	return p
+}
+
+func isPrefix(prefix, nodes []int) bool {
+	if len(nodes) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != nodes[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func samePath(a, b []int) bool {
+	return len(a) == len(b) && isPrefix(a, b)
+}
+
+/*
+kShortest is Yen's k shortest paths algorithm
+*/
+func (pf *pathFinder) kShortest(src, dst int, k int, excludeNodes map[int]bool) (paths [][]int, costs []pathCost) {
+	nodes, cost, ok := pf.shortest(src, dst, excludeNodes, nil)
+	if !ok {
+		return
+	}
+	paths = append(paths, nodes)
+	costs = append(costs, cost)
+	var candidates [][]int
+	var candidateCosts []pathCost
+	for len(paths) < k {
+		last := paths[len(paths)-1]
+		for i := 0; i < len(last)-1; i++ {
+			root := last[:i+1]
+			excludeEdges := make(map[edge]bool)
+			for _, p := range paths {
+				if len(p) > i+1 && isPrefix(root, p) {
+					excludeEdges[edge{p[i], p[i+1]}] = true
+				}
+			}
+			excludeNodes2 := make(map[int]bool)
+			for n := range excludeNodes {
+				excludeNodes2[n] = true
+			}
+			for _, n := range root[:i] {
+				excludeNodes2[n] = true
+			}
+			spur, _, ok := pf.shortest(last[i], dst, excludeNodes2, excludeEdges)
+			if !ok {
+				continue
+			}
+			total := append(append([]int{}, root[:i]...), spur...)
+			duplicated := false
+			for _, c := range candidates {
+				if samePath(c, total) {
+					duplicated = true
+					break
+				}
+			}
+			if !duplicated {
+				candidates = append(candidates, total)
+				candidateCosts = append(candidateCosts, pf.costOf(total))
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		bestIndex := 0
+		for i := 1; i < len(candidates); i++ {
+			if candidateCosts[i].less(candidateCosts[bestIndex]) {
+				bestIndex = i
+			}
+		}
+		paths = append(paths, candidates[bestIndex])
+		costs = append(costs, candidateCosts[bestIndex])
+		candidates = append(candidates[:bestIndex], candidates[bestIndex+1:]...)
+		candidateCosts = append(candidateCosts[:bestIndex], candidateCosts[bestIndex+1:]...)
+	}
+	return
+}
+
+/*
+KShortestPaths returns at most k paths from source to target which can transfer amount tokens,
+ordered by total fee and then hops. paths whose fee is greater than maxFee are ignored,
+maxFee nil means no limit.
+make sure only be called in one thread.
+*/
+func (cg *ChannelGraph) KShortestPaths(source, target common.Address, k int, amount *big.Int, maxFee *big.Int, feeCharger fee.Charger) (paths []*Path, err error) {
+	sourceIndex, ok := cg.address2index[source]
+	if !ok {
+		err = errAddressNotFoundInGraph
+		return
+	}
+	targetIndex, ok := cg.address2index[target]
+	if !ok {
+		err = errAddressNotFoundInGraph
+		return
+	}
+	if k <= 0 || sourceIndex == targetIndex {
+		return
+	}
+	pf := cg.newPathFinder(amount, feeCharger)
+	nodesList, costs := pf.kShortest(sourceIndex, targetIndex, k, nil)
+	for i, nodes := range nodesList {
+		if maxFee != nil && costs[i].fee.Cmp(maxFee) > 0 {
+			continue
+		}
+		paths = append(paths, pf.toPath(nodes, costs[i]))
+	}
+	if len(paths) == 0 {
+		err = errNoPath
+	}
+	return
+}
+
+/*
+CircularPath returns the path with the least fee which leaves us by the channel with out and comes back by the channel with in,
+without passing through us in the middle, it's used to move balance between our own channels.
+make sure only be called in one thread.
+*/
+func (cg *ChannelGraph) CircularPath(out, in common.Address, amount *big.Int, feeCharger fee.Charger) (path *Path, err error) {
+	ourIndex, ok := cg.address2index[cg.OurAddress]
+	if !ok {
+		err = errAddressNotFoundInGraph
+		return
+	}
+	outIndex, ok := cg.address2index[out]
+	if !ok {
+		err = errAddressNotFoundInGraph
+		return
+	}
+	inIndex, ok := cg.address2index[in]
+	if !ok {
+		err = errAddressNotFoundInGraph
+		return
+	}
+	pf := cg.newPathFinder(amount, feeCharger)
+	neighbors := make(map[int]bool)
+	for _, n := range pf.neighbors(ourIndex) {
+		neighbors[n] = true
+	}
+	if outIndex == inIndex || !neighbors[outIndex] || !neighbors[inIndex] ||
+		!pf.edgeUsable(ourIndex, outIndex) || !pf.edgeUsable(inIndex, ourIndex) {
+		err = errNoPath
+		return
+	}
+	nodes, _, ok := pf.shortest(outIndex, inIndex, map[int]bool{ourIndex: true}, nil)
+	if !ok {
+		err = errNoPath
+		return
+	}
+	nodes = append(append([]int{ourIndex}, nodes...), ourIndex)
+	return pf.toPath(nodes, pf.costOf(nodes)), nil
+}
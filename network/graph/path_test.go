@@ -0,0 +1,114 @@
+package graph
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type mapFeeCharger map[common.Address]int64
+
+func (m mapFeeCharger) GetNodeChargeFee(nodeAddress, tokenAddress common.Address, amount *big.Int) *big.Int {
+	return big.NewInt(m[nodeAddress])
+}
+
+/*
+	  B(10)
+	 /     \
+	A-C(1)-D(1)-F
+	 \         /
+	  E(1e30)--
+*/
+func TestKShortestPaths(t *testing.T) {
+	a, b, c, d, e, f := utils.NewRandomAddress(), utils.NewRandomAddress(), utils.NewRandomAddress(),
+		utils.NewRandomAddress(), utils.NewRandomAddress(), utils.NewRandomAddress()
+	edges := []common.Address{a, b, b, f, a, c, c, d, d, f, a, e, e, f}
+	cg := NewChannelGraph(a, utils.NewRandomAddress(), edges)
+	charger := mapFeeCharger{b: 10, c: 1, d: 1}
+	hugeFee, _ := new(big.Int).SetString("1000000000000000000000000000000", 10)
+	p, err := cg.ShortestPath(a, f, big.NewInt(1), charger)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if p.Hops() != 2 || p.Nodes[1] != e || p.TotalFee.Sign() != 0 {
+		t.Errorf("path with no fee should be the best,path=%s", utils.StringInterface(p, 3))
+	}
+	charger2 := &bigFeeCharger{mapFeeCharger: charger, node: e, fee: hugeFee}
+	paths, err := cg.KShortestPaths(a, f, 5, big.NewInt(1), nil, charger2)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(paths) != 3 {
+		t.Errorf("expect 3 paths,got %d", len(paths))
+		return
+	}
+	if paths[0].Hops() != 3 || paths[0].TotalFee.Int64() != 2 {
+		t.Errorf("first path error %s", utils.StringInterface(paths[0], 3))
+	}
+	if paths[1].Nodes[1] != b || paths[1].TotalFee.Int64() != 10 {
+		t.Errorf("second path error %s", utils.StringInterface(paths[1], 3))
+	}
+	if paths[2].Nodes[1] != e || paths[2].TotalFee.Cmp(hugeFee) != 0 {
+		t.Errorf("third path error %s", utils.StringInterface(paths[2], 3))
+	}
+	paths, err = cg.KShortestPaths(a, f, 5, big.NewInt(1), big.NewInt(5), charger2)
+	if err != nil || len(paths) != 1 {
+		t.Errorf("max fee should exclude expensive paths, err=%v", err)
+	}
+	_, err = cg.KShortestPaths(a, f, 5, big.NewInt(1), big.NewInt(1), charger2)
+	if err == nil {
+		t.Error("should no path")
+	}
+}
+
+type bigFeeCharger struct {
+	mapFeeCharger
+	node common.Address
+	fee  *big.Int
+}
+
+func (c *bigFeeCharger) GetNodeChargeFee(nodeAddress, tokenAddress common.Address, amount *big.Int) *big.Int {
+	if nodeAddress == c.node {
+		return c.fee
+	}
+	return c.mapFeeCharger.GetNodeChargeFee(nodeAddress, tokenAddress, amount)
+}
+
+/*
+A is us, B(2),C(3) and F are our partners,
+B can reach C by D(5) or E(1), F can reach nothing but us.
+*/
+func TestCircularPath(t *testing.T) {
+	a, b, c, d, e, f := utils.NewRandomAddress(), utils.NewRandomAddress(), utils.NewRandomAddress(),
+		utils.NewRandomAddress(), utils.NewRandomAddress(), utils.NewRandomAddress()
+	edges := []common.Address{a, b, a, c, b, d, d, c, b, e, e, c, a, f}
+	cg := NewChannelGraph(a, utils.NewRandomAddress(), edges)
+	charger := mapFeeCharger{b: 2, c: 3, d: 5, e: 1}
+	p, err := cg.CircularPath(b, c, big.NewInt(1), charger)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if p.Hops() != 4 || p.Nodes[0] != a || p.Nodes[1] != b || p.Nodes[2] != e || p.Nodes[3] != c || p.Nodes[4] != a {
+		t.Errorf("path error %s", utils.StringInterface(p, 3))
+	}
+	if p.TotalFee.Int64() != 6 {
+		t.Errorf("fee should be charged by b,e and c, got %s", p.TotalFee)
+	}
+	_, err = cg.CircularPath(b, b, big.NewInt(1), charger)
+	if err == nil {
+		t.Error("out and in should be different")
+	}
+	_, err = cg.CircularPath(b, f, big.NewInt(1), charger)
+	if err == nil {
+		t.Error("f can only reach b through us")
+	}
+	_, err = cg.CircularPath(b, d, big.NewInt(1), charger)
+	if err == nil {
+		t.Error("d is not our partner")
+	}
+}
@@ -45,6 +45,23 @@ func ensureContext(ctx context.Context) context.Context {
 	return ctx
 }
 
+/*
+EventGetChannel get events of history about channel `channelIdentifier`,
+channel identifier must be the first indexed argument of the event, empty means all channels.
+*/
+func EventGetChannel(ctx context.Context, contractAddress common.Address, channelIdentifier common.Hash, fromBlock rpc.BlockNumber,
+	toBlock rpc.BlockNumber, eventName string, abistr string, client *helper.SafeEthClient) ([]types.Log, error) {
+	q, err := buildQuery(contractAddress, fromBlock, toBlock, eventName, abistr)
+	if err != nil {
+		return nil, err
+	}
+	if channelIdentifier != utils.EmptyHash {
+		q.Topics = append(q.Topics, []common.Hash{channelIdentifier})
+	}
+	ctx = ensureContext(ctx)
+	return client.FilterLogs(ctx, *q)
+}
+
 //EventGetInternal get events of history
 //if contractAddress is empty,it will query all contract
 func EventGetInternal(ctx context.Context, contractAddress common.Address, fromBlock rpc.BlockNumber,
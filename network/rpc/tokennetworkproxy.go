@@ -242,6 +242,44 @@ func (t *TokenNetworkProxy) UnlockAsync(partnerAddr common.Address, transferAmou
 	return
 }
 
+//UpdateBalanceProofDelegate update partner's balance proof on behalf of participant, called by 3rd party
+func (t *TokenNetworkProxy) UpdateBalanceProofDelegate(partnerAddr, participantAddr common.Address, transferAmount *big.Int, locksRoot common.Hash, nonce int64, extraHash common.Hash, partnerSignature, participantSignature []byte) (err error) {
+	tx, err := t.GetContract().UpdateBalanceProofDelegate(t.bcs.Auth, partnerAddr, participantAddr, transferAmount, locksRoot, uint64(nonce), extraHash, partnerSignature, participantSignature)
+	if err != nil {
+		return
+	}
+	log.Info(fmt.Sprintf("UpdateBalanceProofDelegate  txhash=%s", tx.Hash().String()))
+	receipt, err := bind.WaitMined(GetCallContext(), t.bcs.Client, tx)
+	if err != nil {
+		return err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		log.Info(fmt.Sprintf("UpdateBalanceProofDelegate failed %s", receipt))
+		return errors.New("UpdateBalanceProofDelegate tx execution failed")
+	}
+	log.Info(fmt.Sprintf("UpdateBalanceProofDelegate success %s ,partner=%s,participant=%s", utils.APex(t.Address), utils.APex(partnerAddr), utils.APex(participantAddr)))
+	return nil
+}
+
+//UnlockDelegate unlock a partner's lock on behalf of participant, called by 3rd party
+func (t *TokenNetworkProxy) UnlockDelegate(partnerAddr, participantAddr common.Address, transferAmount *big.Int, lock *mtree.Lock, proof []byte, participantSignature []byte) (err error) {
+	tx, err := t.GetContract().UnlockDelegate(t.bcs.Auth, partnerAddr, participantAddr, transferAmount, big.NewInt(lock.Expiration), lock.Amount, lock.LockSecretHash, proof, participantSignature)
+	if err != nil {
+		return
+	}
+	log.Info(fmt.Sprintf("UnlockDelegate  txhash=%s", tx.Hash().String()))
+	receipt, err := bind.WaitMined(GetCallContext(), t.bcs.Client, tx)
+	if err != nil {
+		return err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		log.Info(fmt.Sprintf("UnlockDelegate failed %s", receipt))
+		return errors.New("UnlockDelegate tx execution failed")
+	}
+	log.Info(fmt.Sprintf("UnlockDelegate success %s ,partner=%s,participant=%s", utils.APex(t.Address), utils.APex(partnerAddr), utils.APex(participantAddr)))
+	return nil
+}
+
 //SettleChannel settle a channel
 func (t *TokenNetworkProxy) SettleChannel(p1Addr, p2Addr common.Address, p1Amount, p2Amount *big.Int, p1Locksroot, p2Locksroot common.Hash) (err error) {
 	tx, err := t.GetContract().SettleChannel(t.bcs.Auth, p1Addr, p1Amount, p2Locksroot, p2Addr, p2Amount, p2Locksroot)
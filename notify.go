@@ -0,0 +1,299 @@
+package smartraiden
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/channel/channeltype"
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/SmartMeshFoundation/SmartRaiden/params"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mediatedtransfer"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//types of notifications
+const (
+	NotifyTokenAdded       = "token_added"
+	NotifyChannelNew       = "channel_new"
+	NotifyChannelDeposit   = "channel_deposit"
+	NotifyChannelState     = "channel_state"
+	NotifyChannelSettled   = "channel_settled"
+	NotifySentTransfer     = "sent_transfer"
+	NotifyReceivedTransfer = "received_transfer"
+	NotifyInvoice          = "invoice"
+	NotifyPunishment       = "punishment"
+)
+
+//notifyBufferSize subscriber which falls behind this many notifications is dropped
+const notifyBufferSize = 100
+
+//ChannelNotifyData is the channel carried by a channel notification
+type ChannelNotifyData struct {
+	ChannelIdentifier   string            `json:"channel_identifier"`
+	OpenBlockNumber     int64             `json:"open_block_number"`
+	TokenAddress        string            `json:"token_address"`
+	PartnerAddress      string            `json:"partner_address"`
+	Balance             *big.Int          `json:"balance"`
+	PartnerBalance      *big.Int          `json:"partner_balance"`
+	LockedAmount        *big.Int          `json:"locked_amount"`
+	PartnerLockedAmount *big.Int          `json:"partner_locked_amount"`
+	State               channeltype.State `json:"state"`
+	StateString         string            `json:"state_string"`
+}
+
+func newChannelNotifyData(c *channeltype.Serialization) *ChannelNotifyData {
+	return &ChannelNotifyData{
+		ChannelIdentifier:   c.ChannelIdentifier.ChannelIdentifier.String(),
+		OpenBlockNumber:     c.ChannelIdentifier.OpenBlockNumber,
+		TokenAddress:        c.TokenAddress().String(),
+		PartnerAddress:      c.PartnerAddress().String(),
+		Balance:             c.OurBalance(),
+		PartnerBalance:      c.PartnerBalance(),
+		LockedAmount:        c.OurAmountLocked(),
+		PartnerLockedAmount: c.PartnerAmountLocked(),
+		State:               c.State,
+		StateString:         c.State.String(),
+	}
+}
+
+/*
+NotifyEvent is a notification about this node,
+only the field related to Type is set.
+replayed channel notifications carry the contract event instead of the channel.
+*/
+type NotifyEvent struct {
+	Type             string                   `json:"type"`
+	BlockNumber      int64                    `json:"block_number"`
+	Replayed         bool                     `json:"replayed,omitempty"`
+	TokenAddress     string                   `json:"token_address,omitempty"`
+	Channel          *ChannelNotifyData       `json:"channel,omitempty"`
+	Event            *EventData               `json:"event,omitempty"`
+	SentTransfer     *models.SentTransfer     `json:"sent_transfer,omitempty"`
+	ReceivedTransfer *models.ReceivedTransfer `json:"received_transfer,omitempty"`
+	Invoice          *models.Invoice          `json:"invoice,omitempty"`
+	Punishment       *models.Punishment       `json:"punishment,omitempty"`
+}
+
+/*
+NotifySubscription receives notifications of this node until Unsubscribe.
+if the subscriber is too slow, the subscription is closed, Done will be closed,
+subscribe again from the last block received.
+*/
+type NotifySubscription struct {
+	C        chan *NotifyEvent
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+//Done is closed when this subscription is closed
+func (s *NotifySubscription) Done() <-chan struct{} {
+	return s.done
+}
+
+//Unsubscribe stop receiving notifications, callbacks are removed at their next call
+func (s *NotifySubscription) Unsubscribe() {
+	s.doneOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+//notify is called by db callbacks, it must never block
+func (s *NotifySubscription) notify(e *NotifyEvent) (remove bool) {
+	select {
+	case <-s.done:
+		return true
+	default:
+	}
+	select {
+	case s.C <- e:
+	default:
+		log.Warn(fmt.Sprintf("notify subscriber is too slow, drop it"))
+		s.Unsubscribe()
+		return true
+	}
+	return false
+}
+
+func (rs *RaidenService) channelNotifyCb(notify func(e *NotifyEvent) bool, typ string) func(c *channeltype.Serialization) bool {
+	return func(c *channeltype.Serialization) bool {
+		return notify(&NotifyEvent{
+			Type:        typ,
+			BlockNumber: rs.GetBlockNumber(),
+			Channel:     newChannelNotifyData(c),
+		})
+	}
+}
+
+//newNotifySubscription register callbacks to db for a new subscription
+func (rs *RaidenService) newNotifySubscription() *NotifySubscription {
+	sub := &NotifySubscription{
+		C:    make(chan *NotifyEvent, notifyBufferSize),
+		done: make(chan struct{}),
+	}
+	rs.registerNotifyCallbacks(sub.notify)
+	return sub
+}
+
+//registerNotifyCallbacks register callbacks to db, every notification is passed to notify, it must never block
+func (rs *RaidenService) registerNotifyCallbacks(notify func(e *NotifyEvent) (remove bool)) {
+	db := rs.db
+	db.RegisterNewTokenCallback(func(token common.Address) bool {
+		return notify(&NotifyEvent{
+			Type:         NotifyTokenAdded,
+			BlockNumber:  rs.GetBlockNumber(),
+			TokenAddress: token.String(),
+		})
+	})
+	db.RegisterNewChannellCallback(rs.channelNotifyCb(notify, NotifyChannelNew))
+	db.RegisterChannelDepositCallback(rs.channelNotifyCb(notify, NotifyChannelDeposit))
+	db.RegisterChannelStateCallback(rs.channelNotifyCb(notify, NotifyChannelState))
+	db.RegisterChannelSettleCallback(rs.channelNotifyCb(notify, NotifyChannelSettled))
+	db.RegisterSentTransferCallback(func(st *models.SentTransfer) bool {
+		return notify(&NotifyEvent{
+			Type:         NotifySentTransfer,
+			BlockNumber:  st.BlockNumber,
+			SentTransfer: st,
+		})
+	})
+	db.RegisterReceivedTransferCallback(func(rt *models.ReceivedTransfer) bool {
+		return notify(&NotifyEvent{
+			Type:             NotifyReceivedTransfer,
+			BlockNumber:      rt.BlockNumber,
+			ReceivedTransfer: rt,
+		})
+	})
+	db.RegisterInvoiceCallback(func(inv *models.Invoice) bool {
+		return notify(&NotifyEvent{
+			Type:        NotifyInvoice,
+			BlockNumber: rs.GetBlockNumber(),
+			Invoice:     inv,
+		})
+	})
+	db.RegisterPunishmentCallback(func(p *models.Punishment) bool {
+		return notify(&NotifyEvent{
+			Type:        NotifyPunishment,
+			BlockNumber: rs.GetBlockNumber(),
+			Punishment:  p,
+		})
+	})
+}
+
+/*
+Subscribe notifications of new tokens, channel changes and transfers of this node.
+when fromBlock>=0, history since fromBlock is returned, notifications near fromBlock may be repeated.
+*/
+func (r *RaidenAPI) Subscribe(fromBlock int64) (sub *NotifySubscription, history []*NotifyEvent, err error) {
+	//register first, so nothing is lost between history and new notifications
+	sub = r.Raiden.newNotifySubscription()
+	if fromBlock < 0 {
+		return
+	}
+	history, err = r.notifyHistory(fromBlock)
+	if err != nil {
+		sub.Unsubscribe()
+		sub = nil
+	}
+	return
+}
+
+//notifyType returns type of notification for contract event, empty if it's not notified.
+func notifyType(eventType string) string {
+	switch eventType {
+	case params.NameTokenNetworkCreated:
+		return NotifyTokenAdded
+	case params.NameChannelOpened:
+		return NotifyChannelNew
+	case params.NameChannelNewDeposit, params.NameChannelWithdraw:
+		return NotifyChannelDeposit
+	case params.NameChannelClosed:
+		return NotifyChannelState
+	case params.NameChannelSettled, params.NameChannelCooperativeSettled:
+		return NotifyChannelSettled
+	}
+	return ""
+}
+
+/*
+notifyHistory rebuilds notifications since fromBlock from what has been saved,
+channel changes come from contract events of our channels recorded as internal events.
+*/
+func (r *RaidenAPI) notifyHistory(fromBlock int64) (history []*NotifyEvent, err error) {
+	db := r.Raiden.db
+	tokens, err := r.Raiden.BlockChainEvents.GetTokenNetworkCreated(fromBlock)
+	if err != nil {
+		return
+	}
+	for _, ev := range tokens {
+		history = append(history, &NotifyEvent{
+			Type:         NotifyTokenAdded,
+			BlockNumber:  int64(ev.Raw.BlockNumber),
+			TokenAddress: ev.TokenAddress.String(),
+		})
+	}
+	//channel opened event isn't saved, use channels in db instead
+	chs, err := db.GetChannelList(utils.EmptyAddress, utils.EmptyAddress)
+	if err != nil {
+		return
+	}
+	for _, c := range chs {
+		if c.ChannelIdentifier.OpenBlockNumber >= fromBlock {
+			history = append(history, &NotifyEvent{
+				Type:        NotifyChannelNew,
+				BlockNumber: c.ChannelIdentifier.OpenBlockNumber,
+				Channel:     newChannelNotifyData(c),
+			})
+		}
+	}
+	events, err := db.GetEventsInBlockRange(fromBlock, -1)
+	if err != nil {
+		return
+	}
+	for _, ev := range events {
+		st, ok := ev.EventObject.(mediatedtransfer.ContractStateChange)
+		if !ok {
+			continue
+		}
+		e := contractStateChange2EventData(st)
+		if e == nil || notifyType(e.EventType) == "" || e.EventType == params.NameChannelOpened {
+			continue
+		}
+		history = append(history, &NotifyEvent{
+			Type:        notifyType(e.EventType),
+			BlockNumber: e.BlockNumber,
+			Event:       e,
+		})
+	}
+	sents, err := db.GetSentTransferInBlockRange(fromBlock, -1)
+	if err != nil {
+		return
+	}
+	for _, st := range sents {
+		history = append(history, &NotifyEvent{
+			Type:         NotifySentTransfer,
+			BlockNumber:  st.BlockNumber,
+			SentTransfer: st,
+		})
+	}
+	receiveds, err := db.GetReceivedTransferInBlockRange(fromBlock, -1)
+	if err != nil {
+		return
+	}
+	for _, rt := range receiveds {
+		history = append(history, &NotifyEvent{
+			Type:             NotifyReceivedTransfer,
+			BlockNumber:      rt.BlockNumber,
+			ReceivedTransfer: rt,
+		})
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].BlockNumber < history[j].BlockNumber
+	})
+	for _, e := range history {
+		e.Replayed = true
+	}
+	return
+}
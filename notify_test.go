@@ -0,0 +1,41 @@
+package smartraiden
+
+import (
+	"testing"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/params"
+)
+
+func TestNotifySubscription(t *testing.T) {
+	sub := &NotifySubscription{
+		C:    make(chan *NotifyEvent, 2),
+		done: make(chan struct{}),
+	}
+	for i := 0; i < 2; i++ {
+		if sub.notify(&NotifyEvent{Type: NotifySentTransfer, BlockNumber: int64(i)}) {
+			t.Error("callback should not be removed")
+		}
+	}
+	//subscriber is too slow
+	if !sub.notify(&NotifyEvent{Type: NotifySentTransfer}) {
+		t.Error("slow subscriber should be dropped")
+	}
+	select {
+	case <-sub.Done():
+	default:
+		t.Error("subscription should be closed")
+	}
+	if len(sub.C) != 2 {
+		t.Error("notifications already received should be kept")
+	}
+	sub.Unsubscribe() //unsubscribe again is ok
+}
+
+func TestNotifyType(t *testing.T) {
+	if notifyType(params.NameChannelClosed) != NotifyChannelState {
+		t.Error("closed should be a channel state notification")
+	}
+	if notifyType(params.NameBalanceProofUpdated) != "" {
+		t.Error("balance proof updated is not notified")
+	}
+}
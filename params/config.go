@@ -2,6 +2,7 @@ package params
 
 import (
 	"crypto/ecdsa"
+	"math/big"
 	"os"
 	"os/user"
 	"path/filepath"
@@ -49,17 +50,37 @@ type Config struct {
 	UseConsole                bool
 	APIHost                   string
 	APIPort                   int
+	APIToken                  string //bearer token of operator, no authentication if both tokens are empty
+	APIReadOnlyToken          string //bearer token which can only query
+	APITLSCertFile            string //serve api by https when both cert and key are set
+	APITLSKeyFile             string
+	EnableDebugAPI            bool //mount debug and test apis, they can move tokens or stop the node
+	RPCHost                   string
+	RPCPort                   int    //json-rpc over http, disabled if 0
+	IPCPath                   string //json-rpc over unix socket, disabled if empty
 	RegistryAddress           common.Address
 	DataDir                   string
 	MyAddress                 common.Address
 	DebugCrash                bool          //for test only,work with conditionQuit
 	ConditionQuit             ConditionQuit //for test only
 	NetworkMode               NetworkMode
-	EnableMediationFee        bool //default false. which means no fee at all.
-	IgnoreMediatedNodeRequest bool // true: this node will ignore any mediated transfer who's target is not me.
-	EnableHealthCheck         bool //send ping periodically?
+	EnableMediationFee        bool   //default false. which means no fee at all.
+	FeePolicyFile             string //json file of fee policy, only used when EnableMediationFee is true
+	IgnoreMediatedNodeRequest bool   // true: this node will ignore any mediated transfer who's target is not me.
+	EnableHealthCheck         bool   //send ping periodically?
 	XMPPServer                string
-	IsMeshNetwork             bool //is mesh now?
+	IsMeshNetwork             bool          //is mesh now?
+	Watchtowers               []*Watchtower //partner's balance proofs are pushed to them
+	RebalanceRatio            float64       //share of ours in each channel the rebalancer moves toward, 0.5 means even
+	RebalanceMaxFee           *big.Int      //most fee paid for one rebalance transfer
+	RebalanceMaxDailyFee      *big.Int      //most fee the rebalancer pays for each token in 24 hours, nil means no limit
+	RebalanceInterval         time.Duration //rebalance channels periodically, disabled if 0
+}
+
+//Watchtower is a third party which updates balance proof and unlocks for this node when it's offline
+type Watchtower struct {
+	Address common.Address //sender of delegate transactions, packages are signed for it
+	URL     string         //where to post packages
 }
 
 //DefaultConfig default config
@@ -80,6 +101,7 @@ var DefaultConfig = Config{
 	MsgTimeout:        100 * time.Second,
 	EnableHealthCheck: false,
 	XMPPServer:        DefaultXMPPServer,
+	RebalanceRatio:    0.5,
 }
 
 //ConditionQuit is for test
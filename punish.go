@@ -0,0 +1,156 @@
+package smartraiden
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/channel/channeltype"
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mediatedtransfer"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/asdine/storm"
+)
+
+const punishMaxAttempts = 3
+
+var punishCheckInterval = 10 * time.Second
+
+/*
+obsoleteUnlockPunisher submits PunishObsoleteUnlock when partner unlocks a lock on chain which it has announced disposed.
+punishments are saved to db first, so the ones not finished are tried again after restart,
+it must be done before the channel is settled.
+*/
+type obsoleteUnlockPunisher struct {
+	rs       *RaidenService
+	wakeupCh chan struct{}
+}
+
+func newObsoleteUnlockPunisher(rs *RaidenService) *obsoleteUnlockPunisher {
+	return &obsoleteUnlockPunisher{
+		rs:       rs,
+		wakeupCh: make(chan struct{}, 1),
+	}
+}
+
+func (up *obsoleteUnlockPunisher) start() {
+	go up.loop()
+}
+
+func (up *obsoleteUnlockPunisher) wakeup() {
+	select {
+	case up.wakeupCh <- struct{}{}:
+	default:
+	}
+}
+
+/*
+onUnlock is called by the event handler when partner unlocks our lock,
+a punishment is created if partner has announced to dispose this lock.
+*/
+func (up *obsoleteUnlockPunisher) onUnlock(st *mediatedtransfer.ContractUnlockStateChange, partner *channeltype.Serialization) {
+	if !up.rs.db.IsLockHashCanPunish(st.LockHash, st.ChannelIdentifier) {
+		return
+	}
+	p := &models.Punishment{
+		LockHash:          st.LockHash,
+		ChannelIdentifier: st.ChannelIdentifier,
+		TokenNetwork:      st.TokenNetworkAddress,
+		Partner:           partner.PartnerAddress(),
+		UnlockBlock:       st.BlockNumber,
+	}
+	isNew, err := up.rs.db.NewPunishment(p)
+	if err != nil {
+		log.Error(fmt.Sprintf("NewPunishment err %s", err))
+		return
+	}
+	if isNew {
+		log.Warn(fmt.Sprintf("%s unlocked lock %s which it has announced disposed, punish it",
+			utils.APex2(p.Partner), utils.HPex(p.LockHash)))
+		up.wakeup()
+	}
+}
+
+func (up *obsoleteUnlockPunisher) loop() {
+	ticker := time.NewTicker(punishCheckInterval)
+	defer ticker.Stop()
+	for {
+		up.punishPending()
+		select {
+		case <-ticker.C:
+		case <-up.wakeupCh:
+		case <-up.rs.quitChan:
+			return
+		}
+	}
+}
+
+func (up *obsoleteUnlockPunisher) punishPending() {
+	ps, err := up.rs.db.GetPendingPunishments()
+	if err != nil {
+		log.Error(fmt.Sprintf("GetPendingPunishments err %s", err))
+		return
+	}
+	for _, p := range ps {
+		select {
+		case <-up.rs.quitChan:
+			return
+		default:
+		}
+		err = up.punish(p)
+		if err == nil {
+			p.Status = models.PunishmentSuccess
+			p.Error = ""
+		} else {
+			p.Attempts++
+			p.Error = err.Error()
+			if p.Attempts >= punishMaxAttempts || err == errPunishTooLate {
+				p.Status = models.PunishmentFailed
+			}
+		}
+		err = up.rs.db.UpdatePunishment(p)
+		if err != nil {
+			log.Error(fmt.Sprintf("UpdatePunishment err %s", err))
+		}
+		switch p.Status {
+		case models.PunishmentSuccess:
+			up.rs.db.NewInternalEvent(up.rs.GetBlockNumber(), "PunishObsoleteUnlockSuccess", utils.EmptyHash, p.ChannelIdentifier, "", p)
+		case models.PunishmentFailed:
+			log.Error(fmt.Sprintf("punish %s on channel %s failed %s", utils.HPex(p.LockHash), utils.HPex(p.ChannelIdentifier), p.Error))
+			up.rs.db.NewInternalEvent(up.rs.GetBlockNumber(), "PunishObsoleteUnlockFailed", utils.EmptyHash, p.ChannelIdentifier, p.Error, p)
+		}
+	}
+}
+
+var errPunishTooLate = fmt.Errorf("channel can be settled, too late to punish")
+
+func (up *obsoleteUnlockPunisher) punish(p *models.Punishment) error {
+	ad := up.rs.db.GetReceiviedAnnounceDisposed(p.LockHash, p.ChannelIdentifier)
+	if ad == nil {
+		return fmt.Errorf("announce disposed of lock %s not found", utils.HPex(p.LockHash))
+	}
+	c, err := up.rs.db.GetChannelByAddress(p.ChannelIdentifier)
+	if err == storm.ErrNotFound {
+		//settled channel is removed
+		return errPunishTooLate
+	}
+	if err != nil {
+		return err
+	}
+	if c.State != channeltype.StateClosed {
+		return errPunishTooLate
+	}
+	if up.rs.GetBlockNumber() >= c.ClosedBlock+int64(c.SettleTimeout) {
+		return errPunishTooLate
+	}
+	tokenNetwork, err := up.rs.Chain.TokenNetwork(p.TokenNetwork)
+	if err != nil {
+		return err
+	}
+	return tokenNetwork.PunishObsoleteUnlock(up.rs.NodeAddress, p.Partner, p.LockHash, ad.AdditionalHash, ad.Signature)
+}
+
+//GetPunishments returns punishments of obsolete unlocks of this node
+func (r *RaidenAPI) GetPunishments() ([]*models.Punishment, error) {
+	return r.Raiden.db.GetPunishmentList()
+}
@@ -0,0 +1,143 @@
+package smartraiden
+
+import (
+	"testing"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/channel/channeltype"
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/SmartMeshFoundation/SmartRaiden/network/rpc/contracts"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mediatedtransfer"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+func newTestPunishChannel(state channeltype.State, closedBlock int64) *channeltype.Serialization {
+	channel := utils.NewRandomHash()
+	partner := utils.NewRandomAddress()
+	return &channeltype.Serialization{
+		ChannelIdentifier: &contracts.ChannelUniqueID{
+			ChannelIdentifier: channel,
+			OpenBlockNumber:   3,
+		},
+		Key:                 channel[:],
+		TokenAddressBytes:   utils.NewRandomAddress().Bytes(),
+		PartnerAddressBytes: partner[:],
+		State:               state,
+		ClosedBlock:         closedBlock,
+		SettleTimeout:       100,
+	}
+}
+
+func getTestPunishment(t *testing.T, rs *RaidenService, lockHash common.Hash) *models.Punishment {
+	ps, err := rs.db.GetPunishmentList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range ps {
+		if p.LockHash == lockHash {
+			return p
+		}
+	}
+	return nil
+}
+
+func TestPunishOnUnlock(t *testing.T) {
+	rs := newTestRaidenWithDb(t, 100)
+	defer rs.db.CloseDB()
+	up := newObsoleteUnlockPunisher(rs)
+	c := newTestPunishChannel(channeltype.StateClosed, 90)
+	st := &mediatedtransfer.ContractUnlockStateChange{
+		ChannelIdentifier:   c.ChannelIdentifier.ChannelIdentifier,
+		BlockNumber:         95,
+		TokenNetworkAddress: utils.NewRandomAddress(),
+		LockHash:            utils.NewRandomHash(),
+		Participant:         c.PartnerAddress(),
+	}
+	//partner didn't dispose this lock
+	up.onUnlock(st, c)
+	assert2.Nil(t, getTestPunishment(t, rs, st.LockHash))
+	assert2.Equal(t, 0, len(up.wakeupCh))
+
+	err := rs.db.MarkLockHashCanPunish(models.NewReceivedAnnounceDisposed(st.LockHash, st.ChannelIdentifier, utils.NewRandomHash(), 3, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	up.onUnlock(st, c)
+	p := getTestPunishment(t, rs, st.LockHash)
+	if assert2.NotNil(t, p) {
+		assert2.Equal(t, models.PunishmentPending, p.Status)
+		assert2.Equal(t, c.PartnerAddress(), p.Partner)
+		assert2.Equal(t, st.TokenNetworkAddress, p.TokenNetwork)
+		assert2.EqualValues(t, 95, p.UnlockBlock)
+	}
+	assert2.Equal(t, 1, len(up.wakeupCh))
+	<-up.wakeupCh
+	//the same unlock event after restart
+	up.onUnlock(st, c)
+	assert2.Equal(t, 0, len(up.wakeupCh))
+	ps, err := rs.db.GetPunishmentList()
+	assert2.Nil(t, err)
+	assert2.Equal(t, 1, len(ps))
+}
+
+func TestPunishPending(t *testing.T) {
+	var blockNumber int64 = 300
+	rs := newTestRaidenWithDb(t, blockNumber)
+	defer rs.db.CloseDB()
+	up := newObsoleteUnlockPunisher(rs)
+	newPunishment := func(c *channeltype.Serialization, disposed bool) common.Hash {
+		p := &models.Punishment{
+			LockHash:     utils.NewRandomHash(),
+			TokenNetwork: utils.NewRandomAddress(),
+			Partner:      utils.NewRandomAddress(),
+			UnlockBlock:  blockNumber - 10,
+		}
+		p.ChannelIdentifier = utils.NewRandomHash()
+		if c != nil {
+			p.ChannelIdentifier = c.ChannelIdentifier.ChannelIdentifier
+			err := rs.db.NewChannel(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if disposed {
+			err := rs.db.MarkLockHashCanPunish(models.NewReceivedAnnounceDisposed(p.LockHash, p.ChannelIdentifier, utils.NewRandomHash(), 3, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, err := rs.db.NewPunishment(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p.LockHash
+	}
+	//announce disposed is lost, it's tried again until punishMaxAttempts
+	retry := newPunishment(newTestPunishChannel(channeltype.StateClosed, blockNumber-10), false)
+	//too late, no need to try again
+	settled := newPunishment(nil, true)
+	opened := newPunishment(newTestPunishChannel(channeltype.StateOpened, 0), true)
+	settleable := newPunishment(newTestPunishChannel(channeltype.StateClosed, blockNumber-100), true)
+
+	up.punishPending()
+	p := getTestPunishment(t, rs, retry)
+	assert2.Equal(t, models.PunishmentPending, p.Status)
+	assert2.Equal(t, 1, p.Attempts)
+	assert2.NotEmpty(t, p.Error)
+	for _, h := range []common.Hash{settled, opened, settleable} {
+		p = getTestPunishment(t, rs, h)
+		assert2.Equal(t, models.PunishmentFailed, p.Status)
+		assert2.Equal(t, 1, p.Attempts)
+		assert2.Equal(t, errPunishTooLate.Error(), p.Error)
+	}
+	for i := 1; i < punishMaxAttempts; i++ {
+		up.punishPending()
+	}
+	p = getTestPunishment(t, rs, retry)
+	assert2.Equal(t, models.PunishmentFailed, p.Status)
+	assert2.Equal(t, punishMaxAttempts, p.Attempts)
+	ps, err := rs.db.GetPendingPunishments()
+	assert2.Nil(t, err)
+	assert2.Equal(t, 0, len(ps))
+}
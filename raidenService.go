@@ -10,6 +10,7 @@ import (
 
 	"time"
 
+	"sync"
 	"sync/atomic"
 
 	"math/big"
@@ -34,6 +35,7 @@ import (
 	"github.com/SmartMeshFoundation/SmartRaiden/network/rpc/contracts"
 	"github.com/SmartMeshFoundation/SmartRaiden/network/rpc/fee"
 	"github.com/SmartMeshFoundation/SmartRaiden/params"
+	"github.com/SmartMeshFoundation/SmartRaiden/rerr"
 	"github.com/SmartMeshFoundation/SmartRaiden/transfer"
 	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mediatedtransfer"
 	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mediatedtransfer/initiator"
@@ -132,6 +134,9 @@ type RaidenService struct {
 	ethInited                           bool
 	EthConnectionStatus                 chan netshare.Status
 	ChanStartupComplete                 chan struct{}
+	Token2ConnectionManager             map[common.Address]*ConnectionManager //accessed by api,protected by connectionManagerLock
+	connectionManagerLock               sync.Mutex
+	punisher                            *obsoleteUnlockPunisher
 }
 
 //NewRaidenService create raiden service
@@ -170,10 +175,12 @@ func NewRaidenService(chain *rpc.BlockChainService, privateKey *ecdsa.PrivateKey
 		quitChan:                            make(chan struct{}),
 		EthConnectionStatus:                 make(chan netshare.Status, 10),
 		ChanStartupComplete:                 make(chan struct{}),
+		Token2ConnectionManager:             make(map[common.Address]*ConnectionManager),
 	}
 	rs.BlockNumber.Store(int64(0))
 	rs.MessageHandler = newRaidenMessageHandler(rs)
 	rs.StateMachineEventHandler = newStateMachineEventHandler(rs)
+	rs.punisher = newObsoleteUnlockPunisher(rs)
 	rs.Protocol = network.NewRaidenProtocol(transport, privateKey, rs)
 	rs.db, err = models.OpenDb(config.DataBasePath)
 	if err != nil {
@@ -181,6 +188,13 @@ func NewRaidenService(chain *rpc.BlockChainService, privateKey *ecdsa.PrivateKey
 		return
 	}
 	rs.Protocol.SetReceivedMessageSaver(NewAckHelper(rs.db))
+	if config.EnableMediationFee {
+		rs.FeePolicy, err = newConfigurableFeePolicyFromConfig(rs.db, config.FeePolicyFile)
+		if err != nil {
+			err = fmt.Errorf("load fee policy error %s", err)
+			return
+		}
+	}
 	/*
 		only one instance for one data directory
 	*/
@@ -220,7 +234,11 @@ func NewRaidenService(chain *rpc.BlockChainService, privateKey *ecdsa.PrivateKey
 
 // Start the node.
 func (rs *RaidenService) Start() (err error) {
-
+	//webhook deliveries are saved by db callbacks, so they must be registered before events replay
+	wn := newWebhookNotifier(rs)
+	wn.register()
+	rs.failInterruptedAutoDeposits()
+	rs.restoreConnectionManagers()
 	rs.AlarmTask.RegisterCallback(func(number int64) error {
 		rs.db.SaveLatestBlockNumber(number)
 		return rs.setBlockNumber(number)
@@ -265,6 +283,15 @@ func (rs *RaidenService) Start() (err error) {
 	<-rs.ChanStartupComplete
 	log.Info(fmt.Sprintf("raide"))
 	rs.startNeighboursHealthCheck()
+	wn.start()
+	rs.startConnectionManagers()
+	rs.punisher.start()
+	if rs.Config.RebalanceInterval > 0 {
+		newRebalancer(rs).start()
+	}
+	if len(rs.Config.Watchtowers) > 0 {
+		newWatchtowerClient(rs, rs.Config.Watchtowers).start()
+	}
 	err = rs.startSubscribeNeighborStatus()
 	if err != nil {
 		err = fmt.Errorf("startSubscribeNeighborStatus err %s", err)
@@ -437,6 +464,8 @@ func (rs *RaidenService) handleBlockNumber(blocknumber int64) {
 			when currentState==nil && StateManager.ManagerState!=StateManagerStateInit ,should delete rs statemanager.
 	*/
 	rs.StateMachineEventHandler.dispatchToAllTasks(statechange)
+	rs.removeCanceledLocks(blocknumber)
+	rs.removeOldInternalEvents(blocknumber)
 	for _, cg := range rs.Token2ChannelGraph {
 		for _, c := range cg.ChannelAddress2Channel {
 			err := rs.StateMachineEventHandler.ChannelStateTransition(c, statechange)
@@ -445,6 +474,7 @@ func (rs *RaidenService) handleBlockNumber(blocknumber int64) {
 			}
 		}
 	}
+	rs.autoDeposit(blocknumber)
 
 	return
 }
@@ -723,7 +753,7 @@ we must make sure that taker use the maker's secret.
 and taker's lock expiration should be short than maker's todo(fix this)
 */
 func (rs *RaidenService) startTakerMediatedTransfer(tokenAddress, target common.Address, amount *big.Int, lockSecretHash common.Hash, hashlock common.Hash, expiration int64) (result *utils.AsyncResult, stateManager *transfer.StateManager) {
-	return rs.startMediatedTransferInternal(tokenAddress, target, amount, utils.BigInt0, lockSecretHash, hashlock, expiration)
+	return rs.startMediatedTransferInternal(tokenAddress, target, amount, &TransferOptions{LockSecretHash: lockSecretHash}, hashlock, expiration, nil)
 }
 
 /*
@@ -731,10 +761,26 @@ lauch a new mediated trasfer
 Args:
  hashlock: caller can specify a hashlock or use empty ,when empty, will generate a random secret.
  expiration: caller can specify a valid blocknumber or 0, when 0 ,will calculate based on settle timeout of channel.
+ isMultiPath: split amount to several routes if no single route can afford it.
+ paymentIdentifier, memo: carried to target unchanged, 0 and empty if not used.
+ availableRoutes: caller can specify routes or use nil, when nil, will find the best routes to target.
 */
-func (rs *RaidenService) startMediatedTransferInternal(tokenAddress, target common.Address, amount *big.Int, fee *big.Int, lockSecretHash common.Hash, hashlock common.Hash, expiration int64) (result *utils.AsyncResult, stateManager *transfer.StateManager) {
+func (rs *RaidenService) startMediatedTransferInternal(tokenAddress, target common.Address, amount *big.Int, opts *TransferOptions, hashlock common.Hash, expiration int64, availableRoutes []*route.State) (result *utils.AsyncResult, stateManager *transfer.StateManager) {
 	g := rs.getToken2ChannelGraph(tokenAddress)
-	availableRoutes := g.GetBestRoutes(rs.Protocol, rs.NodeAddress, target, amount, graph.EmptyExlude, rs)
+	fee := opts.fee()
+	isMultiPath := opts.MultiPath
+	lockSecretHash := opts.LockSecretHash
+	var maxFee *big.Int
+	if fee.Cmp(utils.BigInt0) > 0 {
+		maxFee = fee //user will not pay more than this
+	}
+	if availableRoutes == nil {
+		if isMultiPath {
+			availableRoutes = g.GetRoutesForSplit(rs.Protocol, rs.NodeAddress, target, amount, maxFee, graph.EmptyExlude, rs)
+		} else {
+			availableRoutes = g.GetBestRoutes(rs.Protocol, rs.NodeAddress, target, amount, maxFee, graph.EmptyExlude, rs)
+		}
+	}
 	result = utils.NewAsyncResult()
 	if len(availableRoutes) <= 0 {
 		result.Result <- errors.New("no available route")
@@ -749,25 +795,44 @@ func (rs *RaidenService) startMediatedTransferInternal(tokenAddress, target comm
 		secret = utils.NewRandomHash()
 		lockSecretHash = utils.Sha3(secret[:])
 	}
+	if rs.Transfer2StateManager[utils.Sha3(lockSecretHash[:], tokenAddress[:])] != nil {
+		//for example, pay an invoice twice
+		result.Result <- fmt.Errorf("transfer with lock secret hash %s is in progress", lockSecretHash.String())
+		return
+	}
 	/*
 		when user specified fee, for test or other purpose.
 	*/
-	if fee.Cmp(utils.BigInt0) > 0 {
+	if fee.Cmp(utils.BigInt0) > 0 && !isMultiPath {
 		for _, r := range availableRoutes {
 			r.TotalFee = fee //use the user's fee to replace algorithm's
 		}
 	}
 	routesState := route.NewRoutesState(availableRoutes)
 	transferState := &mediatedtransfer.LockedTransferState{
-		TargetAmount:   new(big.Int).Set(amount),
-		Amount:         new(big.Int).Set(amount),
-		Token:          tokenAddress,
-		Initiator:      rs.NodeAddress,
-		Target:         target,
-		Expiration:     expiration,
-		LockSecretHash: lockSecretHash,
-		Secret:         secret,
-		Fee:            utils.BigInt0,
+		TargetAmount:      new(big.Int).Set(amount),
+		Amount:            new(big.Int).Set(amount),
+		Token:             tokenAddress,
+		Initiator:         rs.NodeAddress,
+		Target:            target,
+		Expiration:        expiration,
+		LockSecretHash:    lockSecretHash,
+		Secret:            secret,
+		Fee:               utils.BigInt0,
+		PaymentIdentifier: opts.PaymentIdentifier,
+		Memo:              opts.Memo,
+	}
+	if opts.TargetPublicKey != nil {
+		if secret == utils.EmptyHash {
+			result.Result <- errors.New("keysend transfer cannot use the lock secret hash given")
+			return
+		}
+		var err error
+		transferState.EncryptedSecret, err = encoding.EncryptSecret(secret, amount, opts.TargetPublicKey)
+		if err != nil {
+			result.Result <- err
+			return
+		}
 	}
 	/*
 		发起方每次切换路径不再切换密码,不切换依然可以保证安全
@@ -781,7 +846,12 @@ func (rs *RaidenService) startMediatedTransferInternal(tokenAddress, target comm
 		LockSecretHash: lockSecretHash,
 		Db:             rs.db,
 	}
-	stateManager = transfer.NewStateManager(initiator.StateTransition, nil, initiator.NameInitiatorTransition, lockSecretHash, transferState.Token)
+	if isMultiPath {
+		transferState.Fee = fee //fee limit of all parts
+		stateManager = transfer.NewStateManager(initiator.MultiPathStateTransition, nil, initiator.NameMultiPathInitiatorTransition, lockSecretHash, transferState.Token)
+	} else {
+		stateManager = transfer.NewStateManager(initiator.StateTransition, nil, initiator.NameInitiatorTransition, lockSecretHash, transferState.Token)
+	}
 	smkey := utils.Sha3(lockSecretHash[:], tokenAddress[:])
 	manager := rs.Transfer2StateManager[smkey]
 	if manager != nil {
@@ -789,17 +859,38 @@ func (rs *RaidenService) startMediatedTransferInternal(tokenAddress, target comm
 	}
 	rs.Transfer2StateManager[smkey] = stateManager
 	rs.Transfer2Result[smkey] = result
+	result.Tag = lockSecretHash
 	//rs.db.AddStateManager(stateManager)
 	rs.StateMachineEventHandler.dispatch(stateManager, initInitiator)
 	return
 }
 
+/*
+findPath returns paths a transfer to target would use,result.Tag is []*graph.Path, ordered as the routes to be tried.
+*/
+func (rs *RaidenService) findPath(tokenAddress, target common.Address, amount *big.Int) (result *utils.AsyncResult) {
+	result = utils.NewAsyncResult()
+	g := rs.getToken2ChannelGraph(tokenAddress)
+	if g == nil {
+		result.Result <- rerr.ErrNoTokenManager
+		return
+	}
+	paths := g.GetBestPaths(rs.Protocol, rs.NodeAddress, target, amount, nil, graph.EmptyExlude, rs)
+	if len(paths) == 0 {
+		result.Result <- rerr.ErrNoPathError
+		return
+	}
+	result.Tag = paths
+	result.Result <- nil
+	return
+}
+
 /*
 1. user start a mediated transfer
 2. user start a maker mediated transfer
 */
-func (rs *RaidenService) startMediatedTransfer(tokenAddress, target common.Address, amount *big.Int, fee *big.Int, lockSecretHash common.Hash) (result *utils.AsyncResult) {
-	result, _ = rs.startMediatedTransferInternal(tokenAddress, target, amount, fee, lockSecretHash, utils.EmptyHash, 0)
+func (rs *RaidenService) startMediatedTransfer(tokenAddress, target common.Address, amount *big.Int, opts *TransferOptions) (result *utils.AsyncResult) {
+	result, _ = rs.startMediatedTransferInternal(tokenAddress, target, amount, opts, utils.EmptyHash, 0, nil)
 	return
 }
 
@@ -839,7 +930,11 @@ func (rs *RaidenService) mediateMediatedTransfer(msg *encoding.MediatedTransfer,
 	} else {
 		ourAddress := rs.NodeAddress
 		exclude := graph.MakeExclude(msg.Sender, msg.Initiator)
-		avaiableRoutes := g.GetBestRoutes(rs.Protocol, rs.NodeAddress, targetAddr, amount, exclude, rs)
+		if msg.Initiator == targetAddr {
+			//circular transfer such as rebalance, initiator is where it goes back
+			exclude = graph.MakeExclude(msg.Sender)
+		}
+		avaiableRoutes := g.GetBestRoutes(rs.Protocol, rs.NodeAddress, targetAddr, amount, nil, exclude, rs)
 		routesState := route.NewRoutesState(avaiableRoutes)
 		blockNumber := rs.GetBlockNumber()
 		initMediator := &mediatedtransfer.ActionInitMediatorStateChange{
@@ -860,7 +955,8 @@ func (rs *RaidenService) mediateMediatedTransfer(msg *encoding.MediatedTransfer,
 
 //receive a MediatedTransfer, i'm the target
 func (rs *RaidenService) targetMediatedTransfer(msg *encoding.MediatedTransfer, ch *channel.Channel) {
-	smkey := utils.Sha3(msg.LockSecretHash[:], ch.TokenAddress[:])
+	fromTransfer := mediatedtransfer.LockedTransferFromMessage(msg, ch.TokenAddress)
+	smkey := mediatedtransfer.TargetStateManagerKey(fromTransfer, ch.ChannelIdentifier.ChannelIdentifier)
 	stateManager := rs.Transfer2StateManager[smkey]
 	/*
 		第一次收到这个密码,
@@ -884,7 +980,6 @@ func (rs *RaidenService) targetMediatedTransfer(msg *encoding.MediatedTransfer,
 	g := rs.getToken2ChannelGraph(ch.TokenAddress)
 	fromChannel := g.GetPartenerAddress2Channel(msg.Sender)
 	fromRoute := graph.Channel2RouteState(fromChannel, msg.Sender, msg.PaymentAmount, rs)
-	fromTransfer := mediatedtransfer.LockedTransferFromMessage(msg, ch.TokenAddress)
 	initTarget := &mediatedtransfer.ActionInitTargetStateChange{
 		OurAddress:  rs.NodeAddress,
 		FromRoute:   fromRoute,
@@ -893,10 +988,76 @@ func (rs *RaidenService) targetMediatedTransfer(msg *encoding.MediatedTransfer,
 		Message:     msg,
 		Db:          rs.db,
 	}
+	if inv, err := rs.db.GetInvoice(msg.LockSecretHash); err == nil && (inv.IsOpen(time.Now()) || inv.Hold && inv.Status == models.InvoiceCanceled) {
+		initTarget.Invoice = &mediatedtransfer.InvoiceState{
+			Token:    inv.Token,
+			Amount:   inv.Amount,
+			Secret:   inv.Secret,
+			Hold:     inv.Hold,
+			Canceled: inv.Status == models.InvoiceCanceled,
+		}
+	}
+	if len(msg.EncryptedSecret) > 0 {
+		secret, amount, err := encoding.DecryptSecret(msg.EncryptedSecret, msg.LockSecretHash, rs.PrivateKey)
+		if err != nil {
+			log.Warn(fmt.Sprintf("keysend transfer %s, but cannot decrypt secret %s", utils.HPex(msg.LockSecretHash), err))
+		} else {
+			initTarget.Secret = secret
+			initTarget.SecretAmount = amount
+		}
+	}
+	if msg.Initiator == rs.NodeAddress {
+		//circular transfer to ourselves, we know the secret as the initiator
+		mgr := rs.Transfer2StateManager[utils.Sha3(msg.LockSecretHash[:], ch.TokenAddress[:])]
+		if mgr != nil {
+			if state, ok := mgr.CurrentState.(*mediatedtransfer.InitiatorState); ok {
+				initTarget.Secret = state.Transfer.Secret
+				initTarget.SecretAmount = state.Transfer.TargetAmount
+			}
+		}
+	}
 	stateManager = transfer.NewStateManager(target.StateTransiton, nil, target.NameTargetTransition, fromTransfer.LockSecretHash, fromTransfer.Token)
 	//rs.db.AddStateManager(stateManager)
 	rs.Transfer2StateManager[smkey] = stateManager
 	rs.StateMachineEventHandler.dispatch(stateManager, initTarget)
+	if fromTransfer.IsMultiPath() {
+		rs.checkMultiPathTransferArrived(fromTransfer, stateManager)
+	}
+}
+
+/*
+checkMultiPathTransferArrived request the secret from initiator when locks of all parts have arrived.
+parts whose lock is not safe to wait are not counted, just like a normal transfer.
+*/
+func (rs *RaidenService) checkMultiPathTransferArrived(tr *mediatedtransfer.LockedTransferState, stateManager *transfer.StateManager) {
+	received := big.NewInt(0)
+	blockNumber := rs.GetBlockNumber()
+	for _, mgr := range rs.Transfer2StateManager {
+		if mgr.Name != target.NameTargetTransition || mgr.Identifier != tr.LockSecretHash {
+			continue
+		}
+		state, ok := mgr.CurrentState.(*mediatedtransfer.TargetState)
+		if !ok || state.FromTransfer.Token != tr.Token || !state.FromTransfer.IsMultiPath() {
+			continue
+		}
+		if !mediator.IsSafeToWait(state.FromTransfer, state.FromRoute.RevealTimeout(), blockNumber) {
+			continue
+		}
+		received.Add(received, state.FromTransfer.TargetAmount)
+	}
+	log.Trace(fmt.Sprintf("multi path transfer %s received %s of %s", utils.HPex(tr.LockSecretHash), received, tr.TotalAmount))
+	if received.Cmp(tr.TotalAmount) < 0 {
+		return
+	}
+	secretRequest := &mediatedtransfer.EventSendSecretRequest{
+		LockSecretHash: tr.LockSecretHash,
+		Amount:         new(big.Int).Set(tr.TotalAmount),
+		Receiver:       tr.Initiator,
+	}
+	err := rs.StateMachineEventHandler.OnEvent(secretRequest, stateManager)
+	if err != nil {
+		log.Error(fmt.Sprintf("send secret request for multi path transfer %s err %s", utils.HPex(tr.LockSecretHash), err))
+	}
 }
 
 func (rs *RaidenService) startHealthCheckFor(address common.Address) {
@@ -1018,6 +1179,7 @@ func (rs *RaidenService) depositChannel(channelAddress common.Hash, amount *big.
 	}
 	if c.State != channeltype.StateOpened {
 		result.Result <- errors.New("channel can deposit only when at open state")
+		return
 	}
 	result = c.ExternState.Deposit(c.TokenAddress, amount)
 	return
@@ -1195,7 +1357,7 @@ func (rs *RaidenService) tokenSwapMaker(tokenswap *TokenSwap) (result *utils.Asy
 	}
 	rs.SentMediatedTransferListenerMap[&sentMtrHook] = true
 	rs.ReceivedMediatedTrasnferListenerMap[&receiveMtrHook] = true
-	result = rs.startMediatedTransfer(tokenswap.FromToken, tokenswap.ToNodeAddress, tokenswap.FromAmount, utils.BigInt0, tokenswap.LockSecretHash)
+	result = rs.startMediatedTransfer(tokenswap.FromToken, tokenswap.ToNodeAddress, tokenswap.FromAmount, &TransferOptions{LockSecretHash: tokenswap.LockSecretHash})
 	return
 }
 
@@ -1292,6 +1454,13 @@ func (rs *RaidenService) SetFeePolicy(feePolicy fee.Charger) {
 	rs.FeePolicy = feePolicy
 }
 
+//getConnectionManager returns connection manager of token,nil if we never connect to this token network
+func (rs *RaidenService) getConnectionManager(tokenAddress common.Address) *ConnectionManager {
+	rs.connectionManagerLock.Lock()
+	defer rs.connectionManagerLock.Unlock()
+	return rs.Token2ConnectionManager[tokenAddress]
+}
+
 /*
 for debug only,quit if eventName exactly match
 */
@@ -1340,10 +1509,23 @@ func (rs *RaidenService) handleReq(req *apiReq) {
 	switch req.Name {
 	case transferReqName: //mediated transfer only
 		r := req.Req.(*transferReq)
-		if r.IsDirectTransfer {
+		if r.Options.IsDirect {
 			result = rs.directTransferAsync(r.TokenAddress, r.Target, r.Amount)
 		} else {
-			result = rs.startMediatedTransfer(r.TokenAddress, r.Target, r.Amount, r.Fee, r.LockSecretHash)
+			result = rs.startMediatedTransfer(r.TokenAddress, r.Target, r.Amount, r.Options)
+			if r.IsAsync && result.Tag != nil {
+				lockSecretHash := result.Tag.(common.Hash)
+				err := rs.db.NewAsyncTransfer(lockSecretHash, r.TokenAddress)
+				if err != nil {
+					log.Error(fmt.Sprintf("NewAsyncTransfer err %s", err))
+				}
+				select {
+				case err = <-result.Result: //failed immediately
+					rs.finishAsyncTransfer(lockSecretHash, r.TokenAddress, err)
+					result.Result <- err
+				default:
+				}
+			}
 		}
 	case newChannelReqName:
 		r := req.Req.(*newChannelReq)
@@ -1385,6 +1567,23 @@ func (rs *RaidenService) handleReq(req *apiReq) {
 	case cancelPrepareWithdrawReqName:
 		r := req.Req.(*closeSettleChannelReq)
 		result = rs.cancelPrepareForCooperativeSettleChannelOrWithdraw(r.addr)
+	case transferStatusReqName:
+		r := req.Req.(*transferStatusReq)
+		result = rs.transferStatus(r.lockSecretHash)
+	case cancelTransferReqName:
+		r := req.Req.(*transferStatusReq)
+		result = rs.cancelTransfer(r.lockSecretHash)
+	case settleHeldTransferReqName:
+		r := req.Req.(*settleHeldTransferReq)
+		result = rs.settleHeldTransfer(r.lockSecretHash, r.secret, r.accept)
+	case findPathReqName:
+		r := req.Req.(*findPathReq)
+		result = rs.findPath(r.tokenAddress, r.target, r.amount)
+	case stateManagersReqName:
+		result = rs.stateManagers()
+	case rebalanceReqName:
+		r := req.Req.(*rebalanceReq)
+		result = rs.rebalance(r.tokenAddress, r.ratio, r.maxFee)
 	default:
 		panic("unkown req")
 	}
@@ -16,13 +16,20 @@ import (
 
 	"crypto/ecdsa"
 
+	"github.com/SmartMeshFoundation/SmartRaiden/blockchain"
+	"github.com/SmartMeshFoundation/SmartRaiden/channel"
 	"github.com/SmartMeshFoundation/SmartRaiden/channel/channeltype"
+	"github.com/SmartMeshFoundation/SmartRaiden/encoding"
 	"github.com/SmartMeshFoundation/SmartRaiden/log"
 	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/SmartMeshFoundation/SmartRaiden/network/graph"
+	"github.com/SmartMeshFoundation/SmartRaiden/params"
 	"github.com/SmartMeshFoundation/SmartRaiden/rerr"
 	"github.com/SmartMeshFoundation/SmartRaiden/transfer"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mtree"
 	"github.com/SmartMeshFoundation/SmartRaiden/utils"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
 //RaidenAPI raiden for user
@@ -315,12 +322,41 @@ func (r *RaidenAPI) GetTokenTokenNetorks() (tokens models.AddressMap) {
 	return
 }
 
-//TransferAndWait Do a transfer with `target` with the given `amount` of `token_address`.
-func (r *RaidenAPI) TransferAndWait(token common.Address, amount *big.Int, fee *big.Int, target common.Address, lockSecretHash common.Hash, timeout time.Duration, isDirectTransfer bool) (err error) {
-	result, err := r.transferAsync(token, amount, fee, target, lockSecretHash, isDirectTransfer)
+/*
+TransferOptions are the optional parts of a transfer, nil options means a single path mediated transfer without fee limit.
+*/
+type TransferOptions struct {
+	Fee               *big.Int         //nil or zero means no fee limit, for multi path transfer it's the limit of all parts
+	LockSecretHash    common.Hash      //empty means the node chooses a new secret
+	IsDirect          bool             //transfer on the channel with target instead of a mediated transfer
+	MultiPath         bool             //split amount to several routes which share one lock secret hash
+	PaymentIdentifier uint64           //target can find the received transfer by it, mediated transfer only
+	Memo              string           //at most encoding.MaxMemoLength bytes, mediated transfer only
+	TargetPublicKey   *ecdsa.PublicKey //keysend transfer, the secret is encrypted to it and target reveals it without requesting
+}
+
+func (o *TransferOptions) fee() *big.Int {
+	if o.Fee == nil {
+		return utils.BigInt0
+	}
+	return o.Fee
+}
+
+//TransferAndWait Do a transfer with `target` with the given `amount` of `token_address`, and wait until it finished or timeout.
+func (r *RaidenAPI) TransferAndWait(token common.Address, amount *big.Int, target common.Address, timeout time.Duration, opts *TransferOptions) (err error) {
+	result, err := r.transferAsync(token, amount, target, opts, false)
 	if err != nil {
 		return err
 	}
+	return r.waitTransfer(result, timeout)
+}
+
+//PublicKey returns the compressed public key of this node, give it to the payers of keysend transfers
+func (r *RaidenAPI) PublicKey() []byte {
+	return crypto.CompressPubkey(&r.Raiden.PrivateKey.PublicKey)
+}
+
+func (r *RaidenAPI) waitTransfer(result *utils.AsyncResult, timeout time.Duration) (err error) {
 	if timeout > 0 {
 		timeoutCh := time.After(timeout)
 		select {
@@ -336,11 +372,51 @@ func (r *RaidenAPI) TransferAndWait(token common.Address, amount *big.Int, fee *
 
 //Transfer transfer and wait
 func (r *RaidenAPI) Transfer(token common.Address, amount *big.Int, fee *big.Int, target common.Address, lockSecretHash common.Hash, timeout time.Duration, isDirectTransfer bool) error {
-	return r.TransferAndWait(token, amount, fee, target, lockSecretHash, timeout, isDirectTransfer)
+	return r.TransferAndWait(token, amount, target, timeout, &TransferOptions{
+		Fee:            fee,
+		LockSecretHash: lockSecretHash,
+		IsDirect:       isDirectTransfer,
+	})
+}
+
+/*
+TransferAsync start a mediated transfer and return its lock secret hash immediately,
+use GetTransferStatus to query its status and CancelTransfer to cancel it.
+*/
+func (r *RaidenAPI) TransferAsync(token common.Address, amount *big.Int, target common.Address, opts *TransferOptions) (common.Hash, error) {
+	result, err := r.transferAsync(token, amount, target, opts, true)
+	if err != nil {
+		return utils.EmptyHash, err
+	}
+	if result.Tag == nil {
+		//failed before state manager was created
+		return utils.EmptyHash, <-result.Result
+	}
+	return result.Tag.(common.Hash), nil
+}
+
+//GetTransferStatus returns status of a transfer started by TransferAsync
+func (r *RaidenAPI) GetTransferStatus(lockSecretHash common.Hash) (status *TransferStatus, err error) {
+	result := r.Raiden.transferStatusClient(lockSecretHash)
+	err = <-result.Result
+	if err != nil {
+		return
+	}
+	status = result.Tag.(*TransferStatus)
+	return
+}
+
+//CancelTransfer cancel a transfer started by us, only possible before the secret is revealed
+func (r *RaidenAPI) CancelTransfer(lockSecretHash common.Hash) error {
+	result := r.Raiden.cancelTransferClient(lockSecretHash)
+	return <-result.Result
 }
 
 //transferAsync
-func (r *RaidenAPI) transferAsync(tokenAddress common.Address, amount *big.Int, fee *big.Int, target common.Address, lockSecretHash common.Hash, isDirectTransfer bool) (result *utils.AsyncResult, err error) {
+func (r *RaidenAPI) transferAsync(tokenAddress common.Address, amount *big.Int, target common.Address, opts *TransferOptions, isAsync bool) (result *utils.AsyncResult, err error) {
+	if opts == nil {
+		opts = &TransferOptions{}
+	}
 	tokens := r.Tokens()
 	found := false
 	for _, t := range tokens {
@@ -353,7 +429,7 @@ func (r *RaidenAPI) transferAsync(tokenAddress common.Address, amount *big.Int,
 		err = errors.New("token not exist")
 		return
 	}
-	if isDirectTransfer {
+	if opts.IsDirect {
 		var c *channeltype.Serialization
 		c, err = r.Raiden.db.GetChannel(tokenAddress, target)
 		if err != nil {
@@ -369,9 +445,84 @@ func (r *RaidenAPI) transferAsync(tokenAddress common.Address, amount *big.Int,
 		err = rerr.ErrInvalidAmount
 		return
 	}
+	if len(opts.Memo) > encoding.MaxMemoLength {
+		err = rerr.ErrMemoTooLong
+		return
+	}
+	if opts.IsDirect && (opts.PaymentIdentifier != 0 || opts.Memo != "") {
+		err = errors.New("direct transfer cannot carry payment identifier or memo")
+		return
+	}
+	if opts.IsDirect && (opts.MultiPath || isAsync) {
+		err = errors.New("direct transfer cannot be multi path or async")
+		return
+	}
+	if opts.TargetPublicKey != nil {
+		if crypto.PubkeyToAddress(*opts.TargetPublicKey) != target {
+			err = errors.New("public key doesn't match target")
+			return
+		}
+		if opts.IsDirect || opts.MultiPath || opts.LockSecretHash != utils.EmptyHash {
+			err = errors.New("keysend transfer must be a single path mediated transfer with a new secret")
+			return
+		}
+	}
 	log.Debug(fmt.Sprintf("initiating transfer initiator=%s target=%s token=%s amount=%d lockSecretHash=%s",
-		r.Raiden.NodeAddress.String(), target.String(), tokenAddress.String(), amount, lockSecretHash.String()))
-	result = r.Raiden.transferAsyncClient(tokenAddress, amount, fee, target, lockSecretHash, isDirectTransfer)
+		r.Raiden.NodeAddress.String(), target.String(), tokenAddress.String(), amount, opts.LockSecretHash.String()))
+	result = r.Raiden.transferAsyncClient(tokenAddress, amount, target, opts, isAsync)
+	return
+}
+
+//PathHop is a node on the path of a transfer
+type PathHop struct {
+	Address  common.Address `json:"address"`
+	Fee      *big.Int       `json:"fee"` //fee charged by this node, always 0 for us and target
+	IsOnline bool           `json:"is_online"`
+}
+
+//FoundPath is a path a transfer may use
+type FoundPath struct {
+	Hops      []*PathHop `json:"hops"`
+	TotalFee  *big.Int   `json:"total_fee"`
+	TotalCost *big.Int   `json:"total_cost"` //amount and total fee
+}
+
+/*
+FindPath returns paths a transfer of `amount` tokens to `target` would use, without sending anything.
+paths are ordered as they will be tried, the first one is the best.
+*/
+func (r *RaidenAPI) FindPath(tokenAddress, target common.Address, amount *big.Int) (paths []*FoundPath, err error) {
+	if amount == nil || amount.Cmp(utils.BigInt0) <= 0 {
+		err = rerr.ErrInvalidAmount
+		return
+	}
+	result := r.Raiden.findPathClient(tokenAddress, target, amount)
+	err = <-result.Result
+	if err != nil {
+		return
+	}
+	for _, p := range result.Tag.([]*graph.Path) {
+		fp := &FoundPath{
+			TotalFee:  p.TotalFee,
+			TotalCost: new(big.Int).Add(amount, p.TotalFee),
+		}
+		for i, n := range p.Nodes {
+			hop := &PathHop{
+				Address: n,
+				Fee:     big.NewInt(0),
+			}
+			if i > 0 && i < len(p.Nodes)-1 {
+				hop.Fee = r.Raiden.GetNodeChargeFee(n, tokenAddress, amount)
+			}
+			if i == 0 {
+				hop.IsOnline = true
+			} else {
+				_, hop.IsOnline = r.GetNodeNetworkState(n)
+			}
+			fp.Hops = append(fp.Hops, hop)
+		}
+		paths = append(paths, fp)
+	}
 	return
 }
 
@@ -454,6 +605,9 @@ func (r *RaidenAPI) CooperativeSettle(tokenAddress, partnerAddress common.Addres
 //PrepareForCooperativeSettle  mark a channel prepared for settle,  return when state has been updated to database
 func (r *RaidenAPI) PrepareForCooperativeSettle(tokenAddress, partnerAddress common.Address) (c *channeltype.Serialization, err error) {
 	c, err = r.Raiden.db.GetChannel(tokenAddress, partnerAddress)
+	if err != nil {
+		return
+	}
 	if c.State != channeltype.StateOpened {
 		err = rerr.InvalidState("channel must be  open")
 		return
@@ -472,8 +626,11 @@ func (r *RaidenAPI) PrepareForCooperativeSettle(tokenAddress, partnerAddress com
 //CancelPrepareForCooperativeSettle  cancel a mark. return when state has been updated to database
 func (r *RaidenAPI) CancelPrepareForCooperativeSettle(tokenAddress, partnerAddress common.Address) (c *channeltype.Serialization, err error) {
 	c, err = r.Raiden.db.GetChannel(tokenAddress, partnerAddress)
+	if err != nil {
+		return
+	}
 	if c.State != channeltype.StatePrepareForCooperativeSettle {
-		err = rerr.InvalidState("channel must be  open")
+		err = rerr.InvalidState("channel is not prepared for cooperative settle")
 		return
 	}
 	//send settle request
@@ -545,155 +702,251 @@ func (r *RaidenAPI) CancelPrepareForWithdraw(tokenAddress, partnerAddress common
 	return r.Raiden.db.GetChannelByAddress(c.ChannelIdentifier.ChannelIdentifier)
 }
 
-//GetTokenNetworkEvents return events about this token
-func (r *RaidenAPI) GetTokenNetworkEvents(tokenAddress common.Address, fromBlock, toBlock int64) (data []interface{}, err error) {
-	//type eventData struct {
-	//	/*
-	//				 {
-	//		        "event_type": "ChannelNew",
-	//		        "settle_timeout": 10,
-	//		        "netting_channel": "0xc0ea08a2d404d3172d2add29a45be56da40e2949",
-	//		        "participant1": "0x4894a542053248e0c504e3def2048c08f73e1ca6",
-	//		        "participant2": "0x356857Cd22CBEFccDa4e96AF13b408623473237A"
-	//		    }
-	//	*/
-	//	EventType      string `json:"event_type"`
-	//	SettleTimeout  int    `json:"settle_timeout"`
-	//	NettingChannel string `json:"netting_channel"`
-	//	Participant1   string `json:"participant1"`
-	//	Participant2   string `json:"participant2"`
-	//	TokenAddress   string `json:"token_address"`
-	//}
-	//tokens, err := r.Raiden.db.GetAllTokens()
-	//if err != nil {
-	//	return
-	//}
-	//for t, manager := range tokens {
-	//	if tokenAddress == utils.EmptyAddress || t == tokenAddress {
-	//		events, err := r.Raiden.BlockChainEvents.GetAllChannelManagerEvents(manager, fromBlock, toBlock)
-	//		if err != nil {
-	//			return nil, err
-	//		}
-	//		for _, e := range events {
-	//			e2 := e.(*blockchain.EventChannelOpen)
-	//			ed := &eventData{
-	//				EventType:      e2.EventName,
-	//				SettleTimeout:  e2.SettleTimeout,
-	//				NettingChannel: e2.NettingChannelAddress.String(),
-	//				Participant1:   e2.Participant1.String(),
-	//				Participant2:   e2.Participant2.String(),
-	//				TokenAddress:   t.String(),
-	//			}
-	//			data = append(data, ed)
-	//		}
-	//	}
-	//}
+/*
+ConnectTokenNetwork automatically join a token network,
+open channels with `initialChannelTarget` best connected nodes and keep `joinableFundsTarget` of funds
+for nodes who open channel with us.
+return when all the channels have been opened and deposited.
+*/
+func (r *RaidenAPI) ConnectTokenNetwork(tokenAddress common.Address, funds *big.Int, initialChannelTarget int, joinableFundsTarget float64) (err error) {
+	tokens, err := r.Raiden.db.GetAllTokens()
+	if err != nil {
+		return
+	}
+	if _, ok := tokens[tokenAddress]; !ok {
+		err = rerr.ErrNoTokenManager
+		return
+	}
+	r.Raiden.connectionManagerLock.Lock()
+	cm := r.Raiden.Token2ConnectionManager[tokenAddress]
+	if cm == nil {
+		cm = NewConnectionManager(r, tokenAddress)
+		r.Raiden.Token2ConnectionManager[tokenAddress] = cm
+	}
+	r.Raiden.connectionManagerLock.Unlock()
+	return cm.Connect(funds, initialChannelTarget, joinableFundsTarget)
+}
+
+/*
+LeaveTokenNetwork cooperative settle or close channels of this token network,
+return identifiers of channels we have left.
+if onlyReceiving is true, only channels which have received tokens are left.
+channels failed to be closed are listed in err, which is *LeaveError, and others are still left.
+*/
+func (r *RaidenAPI) LeaveTokenNetwork(tokenAddress common.Address, onlyReceiving bool) (channels []common.Hash, err error) {
+	cm := r.Raiden.getConnectionManager(tokenAddress)
+	if cm == nil {
+		cm = NewConnectionManager(r, tokenAddress)
+	}
+	channels, err = cm.Leave(onlyReceiving)
+	//even if some channels failed to be left, we don't join this token network any more
+	err2 := r.Raiden.db.RemoveConnectionSetting(tokenAddress)
+	if err2 != nil {
+		log.Error(fmt.Sprintf("RemoveConnectionSetting err %s", err2))
+	}
+	if err != nil {
+		return
+	}
+	r.Raiden.connectionManagerLock.Lock()
+	delete(r.Raiden.Token2ConnectionManager, tokenAddress)
+	r.Raiden.connectionManagerLock.Unlock()
 	return
 }
 
-//GetNetworkEvents all raiden events
-func (r *RaidenAPI) GetNetworkEvents(fromBlock, toBlock int64) ([]interface{}, error) {
-	//type eventData struct {
-	//	/*
-	//				 "event_type": "TokenAdded",
-	//		        "token_address": "0xea674fdde714fd979de3edf0f56aa9716b898ec8",
-	//		        "channel_manager_address": "0xc0ea08a2d404d3172d2add29a45be56da40e2949"
-	//	*/
-	//	EventType             string `json:"event_type"`
-	//	TokenAddress          string `json:"token_address"`
-	//	ChannelManagerAddress string `json:"channel_manager_address"`
-	//}
-	//events, err := r.Raiden.BlockChainEvents.GetAllRegistryEvents(r.Raiden.RegistryAddress, fromBlock, toBlock)
-	//if err != nil {
-	//	return nil, err
-	//}
-	//var data []interface{}
-	//for _, e := range events {
-	//	e2 := e.(*blockchain.EventTokenNetworkCreated)
-	//	ed := &eventData{
-	//		EventType:             e2.EventName,
-	//		TokenAddress:          e2.TokenAddress.String(),
-	//		ChannelManagerAddress: e2.TokenNetworkAddress.String(),
-	//	}
-	//	data = append(data, ed)
-	//}
-	return nil, nil
-}
-
-//GetChannelEvents events of this channel
-func (r *RaidenAPI) GetChannelEvents(channelAddress common.Hash, fromBlock, toBlock int64) (data []transfer.Event, err error) {
-
-	//var events []transfer.Event
-	//events, err = r.Raiden.BlockChainEvents.GetAllNettingChannelEvents(channelAddress, fromBlock, toBlock)
-	//if err != nil {
-	//	return
-	//}
-	//for _, e := range events {
-	//	m := make(map[string]interface{})
-	//	switch e2 := e.(type) {
-	//	case *blockchain.EventChannelNewBalance:
-	//		m["event_type"] = e2.EventName
-	//		m["participant"] = e2.ParticipantAddress.String()
-	//		m["balance"] = e2.Balance
-	//		m["block_number"] = e2.BlockNumber
-	//		data = append(data, m)
-	//	case *blockchain.EventChannelClosed:
-	//		m["event_type"] = e2.EventName
-	//		m["netting_channel_address"] = e2.ContractAddress.String()
-	//		m["closing_address"] = e2.ClosingAddress.String()
-	//		data = append(data, m)
-	//	case *blockchain.EventChannelSettled:
-	//		m["event_type"] = e2.EventName
-	//		m["netting_channel_address"] = e2.ContractAddress.String()
-	//		m["block_number"] = e2.BlockNumber
-	//		data = append(data, m)
-	//	case *blockchain.EventSecretRevealed:
-	//		m["event_type"] = e2.EventName
-	//		m["netting_channel_address"] = e2.ContractAddress.String()
-	//		m["secret"] = e2.Secret.String()
-	//		data = append(data, m)
-	//		//case *blockchain.EventNonClosingBalanceProofUpdated:
-	//		//	m["event_type"] = e2.EventName
-	//		//	m["token_address"] = t.String()
-	//		//	m["channel_manager_address"] = graph.TokenAddress.String()
-	//	}
-	//
-	//}
-	//
-	//var raidenEvents []*models.InternalEvent
-	//raidenEvents, err = r.Raiden.db.GetEventsInBlockRange(fromBlock, toBlock)
-	//if err != nil {
-	//	return
-	//}
-	////Here choose which raiden internal events we want to expose to the end user
-	//for _, ev := range raidenEvents {
-	//	m := make(map[string]interface{})
-	//	switch e2 := ev.EventObject.(type) {
-	//	case *transfer.EventTransferSentSuccess:
-	//		m["event_type"] = "EventTransferSentSuccess"
-	//		m["identifier"] = e2.LockSecretHash
-	//		m["block_number"] = ev.BlockNumber
-	//		m["amount"] = e2.Amount
-	//		m["target"] = e2.Target
-	//		data = append(data, m)
-	//	case *transfer.EventTransferSentFailed:
-	//		m["event_type"] = "EventTransferSentFailed"
-	//		m["identifier"] = e2.LockSecretHash
-	//		m["block_number"] = ev.BlockNumber
-	//		m["reason"] = e2.Reason
-	//		data = append(data, m)
-	//	case *transfer.EventTransferReceivedSuccess:
-	//		m["event_type"] = "EventTransferReceivedSuccess"
-	//		m["identifier"] = e2.LockSecretHash
-	//		m["block_number"] = ev.BlockNumber
-	//		m["amount"] = e2.Amount
-	//		m["initiator"] = e2.Initiator.String()
-	//		data = append(data, m)
-	//	}
-	//}
+//GetConnectionsInfo returns details of all the token networks we have connected to
+func (r *RaidenAPI) GetConnectionsInfo() (infos map[common.Address]*ConnectionDetail, err error) {
+	r.Raiden.connectionManagerLock.Lock()
+	cms := make(map[common.Address]*ConnectionManager)
+	for token, cm := range r.Raiden.Token2ConnectionManager {
+		cms[token] = cm
+	}
+	r.Raiden.connectionManagerLock.Unlock()
+	infos = make(map[common.Address]*ConnectionDetail)
+	for token, cm := range cms {
+		infos[token], err = cm.Detail()
+		if err != nil {
+			return
+		}
+	}
 	return
 }
 
+/*
+GetTokenNetworkEvents return events on token network of `tokenAddress` between `fromBlock` and `toBlock`,
+if tokenAddress is empty,returns events of all token networks.
+*/
+func (r *RaidenAPI) GetTokenNetworkEvents(tokenAddress common.Address, fromBlock, toBlock int64) (data []*EventData, err error) {
+	fromBlock, toBlock = normalizeBlockRange(fromBlock, toBlock)
+	tokens, err := r.Raiden.db.GetAllTokens()
+	if err != nil {
+		return
+	}
+	var events []*EventData
+	for t, tokenNetwork := range tokens {
+		if tokenAddress != utils.EmptyAddress && t != tokenAddress {
+			continue
+		}
+		var evs []*EventData
+		evs, err = r.tokenNetworkEvents(t, tokenNetwork, fromBlock)
+		if err != nil {
+			return
+		}
+		events = append(events, evs...)
+	}
+	return filterEvents(events, fromBlock, toBlock, nil), nil
+}
+
+func (r *RaidenAPI) tokenNetworkEvents(tokenAddress, tokenNetwork common.Address, fromBlock int64) (events []*EventData, err error) {
+	return r.channelEvents(tokenAddress, tokenNetwork, utils.EmptyHash, fromBlock)
+}
+
+//channelEvents returns events of channel on tokenNetwork, empty channelIdentifier means all channels
+func (r *RaidenAPI) channelEvents(tokenAddress, tokenNetwork common.Address, channelIdentifier common.Hash, fromBlock int64) (events []*EventData, err error) {
+	sts, err := r.Raiden.BlockChainEvents.GetChannelStateChanges(fromBlock, tokenNetwork, channelIdentifier)
+	if err != nil {
+		return
+	}
+	for _, st := range sts {
+		e := contractStateChange2EventData(st)
+		if e == nil {
+			continue
+		}
+		e.TokenAddress = tokenAddress.String()
+		events = append(events, e)
+	}
+	return
+}
+
+//GetNetworkEvents returns token network created and secret registered events between `fromBlock` and `toBlock`
+func (r *RaidenAPI) GetNetworkEvents(fromBlock, toBlock int64) (data []*EventData, err error) {
+	fromBlock, toBlock = normalizeBlockRange(fromBlock, toBlock)
+	tokenNetworks, err := r.Raiden.BlockChainEvents.GetTokenNetworkCreated(fromBlock)
+	if err != nil {
+		return
+	}
+	var events []*EventData
+	for _, ev := range tokenNetworks {
+		events = append(events, contractStateChange2EventData(blockchain.EventTokenNetworkCreated2StateChange(ev)))
+	}
+	secrets, err := r.Raiden.BlockChainEvents.GetAllSecretRevealed(fromBlock)
+	if err != nil {
+		return
+	}
+	for _, ev := range secrets {
+		events = append(events, contractStateChange2EventData(blockchain.EventSecretRevealed2StateChange(ev)))
+	}
+	return filterEvents(events, fromBlock, toBlock, nil), nil
+}
+
+/*
+GetChannelEvents returns events on blockchain and transfers of this node about this channel
+between `fromBlock` and `toBlock`
+*/
+func (r *RaidenAPI) GetChannelEvents(channelIdentifier common.Hash, fromBlock, toBlock int64) (data []*EventData, err error) {
+	fromBlock, toBlock = normalizeBlockRange(fromBlock, toBlock)
+	tokens, err := r.Raiden.db.GetAllTokens()
+	if err != nil {
+		return
+	}
+	//query only the token network of this channel if we know it, settled channels of others are forgotten.
+	tokenAddress := utils.EmptyAddress
+	c, err := r.Raiden.db.GetChannelByAddress(channelIdentifier)
+	if err == nil {
+		tokenAddress = c.TokenAddress()
+	} else {
+		tokenAddress, err = r.Raiden.db.GetNonParticipantChannelToken(channelIdentifier)
+		if err != nil {
+			return
+		}
+	}
+	if tokenAddress != utils.EmptyAddress {
+		tokens = models.AddressMap{tokenAddress: tokens[tokenAddress]}
+	}
+	var events []*EventData
+	for t, tokenNetwork := range tokens {
+		var evs []*EventData
+		evs, err = r.channelEvents(t, tokenNetwork, channelIdentifier, fromBlock)
+		if err != nil {
+			return
+		}
+		events = append(events, evs...)
+	}
+	sents, err := r.Raiden.db.GetSentTransferInBlockRange(fromBlock, toBlock)
+	if err != nil {
+		return
+	}
+	for _, st := range sents {
+		events = append(events, sentTransfer2EventData(st))
+	}
+	receiveds, err := r.Raiden.db.GetReceivedTransferInBlockRange(fromBlock, toBlock)
+	if err != nil {
+		return
+	}
+	for _, rt := range receiveds {
+		events = append(events, receivedTransfer2EventData(rt))
+	}
+	internals, err := r.Raiden.db.GetEventsInBlockRange(fromBlock, toBlock)
+	if err != nil {
+		return
+	}
+	for _, ie := range internals {
+		events = append(events, failedTransfer2EventData(ie))
+	}
+	return filterEvents(events, fromBlock, toBlock, func(e *EventData) bool {
+		return e.channel == channelIdentifier
+	}), nil
+}
+
+//GetFeePolicy returns the fee policy this node uses as a mediator
+func (r *RaidenAPI) GetFeePolicy() (*models.FeePolicy, error) {
+	c, ok := r.Raiden.FeePolicy.(*ConfigurableFeePolicy)
+	if !ok {
+		return nil, errors.New("mediation fee is not enabled")
+	}
+	return c.GetPolicy(), nil
+}
+
+//SetFeePolicy replace the fee policy, it takes effect immediately and is saved to db
+func (r *RaidenAPI) SetFeePolicy(fp *models.FeePolicy) error {
+	c, ok := r.Raiden.FeePolicy.(*ConfigurableFeePolicy)
+	if !ok {
+		return errors.New("mediation fee is not enabled")
+	}
+	return c.SetPolicy(fp)
+}
+
+/*
+GetInternalEvents query events and channel state transitions happened in this node,
+such as failed transfers.
+*/
+func (r *RaidenAPI) GetInternalEvents(fromBlock, toBlock int64) ([]*models.InternalEvent, error) {
+	return r.Raiden.db.GetEventsInBlockRange(fromBlock, toBlock)
+}
+
+//AddWebhook register a http url to be notified, events empty means all of WebhookEvents
+func (r *RaidenAPI) AddWebhook(u string, events []string) (w *models.Webhook, err error) {
+	w = &models.Webhook{
+		URL:    u,
+		Events: events,
+	}
+	err = validateWebhook(w)
+	if err != nil {
+		return
+	}
+	err = r.Raiden.db.NewWebhook(w)
+	return
+}
+
+//GetWebhooks returns all registered webhooks
+func (r *RaidenAPI) GetWebhooks() ([]*models.Webhook, error) {
+	return r.Raiden.db.GetWebhookList()
+}
+
+//RemoveWebhook remove a webhook, notifications not delivered are dropped
+func (r *RaidenAPI) RemoveWebhook(id int) error {
+	return r.Raiden.db.RemoveWebhook(id)
+}
+
 /*
 GetSentTransfers query sent transfers from db
 */
@@ -708,6 +961,20 @@ func (r *RaidenAPI) GetReceivedTransfers(from, to int64) ([]*models.ReceivedTran
 	return r.Raiden.db.GetReceivedTransferInBlockRange(from, to)
 }
 
+/*
+QuerySentTransfers query sent transfers by filter, next is the cursor of next page, empty if no more.
+*/
+func (r *RaidenAPI) QuerySentTransfers(filter *models.TransferFilter) (trs []*models.SentTransfer, next string, err error) {
+	return r.Raiden.db.GetSentTransfers(filter)
+}
+
+/*
+QueryReceivedTransfers query received transfers by filter, next is the cursor of next page, empty if no more.
+*/
+func (r *RaidenAPI) QueryReceivedTransfers(filter *models.TransferFilter) (trs []*models.ReceivedTransfer, next string, err error) {
+	return r.Raiden.db.GetReceivedTransfers(filter)
+}
+
 //Stop stop for mobile app
 func (r *RaidenAPI) Stop() {
 	log.Info("calling api stop..")
@@ -718,6 +985,11 @@ func (r *RaidenAPI) Stop() {
 /*
 {
     "channel_address": "0x5B3F0E96E45e1e4351F6460feBfB6007af25FBB0",
+    "open_block_number": 3000,
+    "token_network_address": "0x6B3F0E96E45e1e4351F6460feBfB6007af25FBB0",
+    "partner_address": "0x7B3F0E96E45e1e4351F6460feBfB6007af25FBB0",
+    "participant_address": "0x8B3F0E96E45e1e4351F6460feBfB6007af25FBB0",
+    "third_party_address": "0x9B3F0E96E45e1e4351F6460feBfB6007af25FBB0",
  "update_transfer":{
         "nonce": 32,
         "transferred_amount": 1800000000000000,
@@ -731,11 +1003,13 @@ func (r *RaidenAPI) Stop() {
         "locked_encoded": "0x00000033333333333333333333333333333333333333333",
         "merkle_proof": "0x3333333333333333333333333333",
         "secret": "0x333333333333333333333333333333333333333",
+        "signature": "0x557b478a024ade59c5c18e348c357aae6a4ec6e30131213f8cf6444214c57e89557b478a024ade59c5c18e348c357aae6a4ec6e30131213f8cf6444214c57e8927",
      },
       {
         "locked_encoded": "0x00000033333333333333333333333333333333333333333",
         "merkle_proof": "0x3333333333333333333333333333",
         "secret": "0x333333333333333333333333333333333333333",
+        "signature": "0x557b478a024ade59c5c18e348c357aae6a4ec6e30131213f8cf6444214c57e89557b478a024ade59c5c18e348c357aae6a4ec6e30131213f8cf6444214c57e8927",
      },
  ],
 }
@@ -752,26 +1026,46 @@ type unlock struct {
 	LockedEncoded string `json:"locked_encoded"`
 	MerkleProof   string `json:"merkle_proof"`
 	Secret        string `json:"secret"`
+	Signature     string `json:"signature"` //participant's signature for unlockDelegate
 }
 
-//ChannelFor3rd is for 3rd party to call update transfer
+//ChannelFor3rd is for 3rd party to call update transfer and unlock for participant
 type ChannelFor3rd struct {
-	ChannelAddress string         `json:"channel_address"`
-	UpdateTransfer updateTransfer `json:"update_transfer"`
-	Withdraws      []*unlock      `json:"withdraws"`
+	ChannelAddress      string         `json:"channel_address"`
+	OpenBlockNumber     int64          `json:"open_block_number"`
+	TokenNetworkAddress string         `json:"token_network_address"`
+	PartnerAddress      string         `json:"partner_address"`
+	ParticipantAddress  string         `json:"participant_address"`
+	ThirdPartyAddress   string         `json:"third_party_address"`
+	UpdateTransfer      updateTransfer `json:"update_transfer"`
+	Withdraws           []*unlock      `json:"withdraws"`
 }
 
 /*
 ChannelInformationFor3rdParty generate all information need by 3rd party
 */
 func (r *RaidenAPI) ChannelInformationFor3rdParty(channelAddr common.Hash, thirdAddr common.Address) (result *ChannelFor3rd, err error) {
-	var sig []byte
 	c, err := r.GetChannel(channelAddr)
 	if err != nil {
 		return
 	}
+	tokens, err := r.Raiden.db.GetAllTokens()
+	if err != nil {
+		return
+	}
+	return channelFor3rd(c, tokens[c.TokenAddress()], thirdAddr, r.Raiden.PrivateKey)
+}
+
+//channelFor3rd sign the package of channel c for thirdAddr
+func channelFor3rd(c *channeltype.Serialization, tokenNetwork, thirdAddr common.Address, privkey *ecdsa.PrivateKey) (result *ChannelFor3rd, err error) {
+	var sig []byte
 	c3 := new(ChannelFor3rd)
-	c3.ChannelAddress = channelAddr.String()
+	c3.ChannelAddress = c.ChannelIdentifier.ChannelIdentifier.String()
+	c3.OpenBlockNumber = c.ChannelIdentifier.OpenBlockNumber
+	c3.TokenNetworkAddress = tokenNetwork.String()
+	c3.PartnerAddress = c.PartnerAddress().String()
+	c3.ParticipantAddress = c.OurAddress.String()
+	c3.ThirdPartyAddress = thirdAddr.String()
 	if c.PartnerBalanceProof == nil {
 		result = c3
 		return
@@ -781,44 +1075,48 @@ func (r *RaidenAPI) ChannelInformationFor3rdParty(channelAddr common.Hash, third
 	c3.UpdateTransfer.Locksroot = c.PartnerBalanceProof.LocksRoot.String()
 	c3.UpdateTransfer.ExtraHash = c.PartnerBalanceProof.MessageHash.String()
 	c3.UpdateTransfer.ClosingSignature = common.Bytes2Hex(c.PartnerBalanceProof.Signature)
-	sig, err = signFor3rd(c, thirdAddr, r.Raiden.PrivateKey)
+	sig, err = signFor3rd(c, privkey)
 	if err != nil {
 		return
 	}
 	c3.UpdateTransfer.NonClosingSignature = common.Bytes2Hex(sig)
-	//todo 以后添加
-	//tree := mtree.NewMerkleTree(c.PartnerLeaves)
-	//var ws []*unlock
-	//for _, l := range c.PartnerLock2UnclaimedLocks() {
-	//	//proof := channel.ComputeProofForLock( l.Lock, tree)
-	//	//w := &unlock{
-	//	//	LockedEncoded: common.Bytes2Hex(mtree.Proof2Bytes(proof)),
-	//	//	Secret:        l.Secret.String(),
-	//	//	MerkleProof:   common.Bytes2Hex(transfer.Proof2Bytes(proof.MerkleProof)),
-	//	//}
-	//	//log.Trace(fmt.Sprintf("prootf=%s", utils.StringInterface(proof, 3)))
-	//	//ws = append(ws, w)
-	//}
-	//c3.Withdraws = ws
-
+	tree := mtree.NewMerkleTree(c.PartnerLeaves)
+	for _, l := range c.PartnerLock2UnclaimedLocks() {
+		proof := channel.ComputeProofForLock(l.Lock, tree)
+		sig, err = signUnlockFor3rd(c, l.Lock, thirdAddr, privkey)
+		if err != nil {
+			return
+		}
+		w := &unlock{
+			LockedEncoded: common.Bytes2Hex(l.Lock.AsBytes()),
+			MerkleProof:   common.Bytes2Hex(mtree.Proof2Bytes(proof.MerkleProof)),
+			Secret:        l.Secret.String(),
+			Signature:     common.Bytes2Hex(sig),
+		}
+		c3.Withdraws = append(c3.Withdraws, w)
+	}
 	result = c3
 	return
 }
 
-//make sure PartnerBalanceProof is not nil
-func signFor3rd(c *channeltype.Serialization, thirdAddr common.Address, privkey *ecdsa.PrivateKey) (sig []byte, err error) {
+/*
+signFor3rd sign partner's balance proof, so 3rd party can call updateBalanceProofDelegate for us.
+make sure PartnerBalanceProof is not nil
+*/
+func signFor3rd(c *channeltype.Serialization, privkey *ecdsa.PrivateKey) (sig []byte, err error) {
 	if c.PartnerBalanceProof == nil {
 		log.Error(fmt.Sprintf("PartnerBalanceProof is nil,must ber a error"))
 		return nil, errors.New("empty PartnerBalanceProof")
 	}
 	buf := new(bytes.Buffer)
-	err = binary.Write(buf, binary.BigEndian, c.PartnerBalanceProof.Nonce)
 	_, err = buf.Write(utils.BigIntTo32Bytes(c.PartnerBalanceProof.TransferAmount))
 	_, err = buf.Write(c.PartnerBalanceProof.LocksRoot[:])
-	_, err = buf.Write(c.ChannelIdentifier.ChannelIdentifier[:])
+	err = binary.Write(buf, binary.BigEndian, uint64(c.PartnerBalanceProof.Nonce))
 	_, err = buf.Write(c.PartnerBalanceProof.MessageHash[:])
+	_, err = buf.Write(c.ChannelIdentifier.ChannelIdentifier[:])
+	err = binary.Write(buf, binary.BigEndian, uint64(c.ChannelIdentifier.OpenBlockNumber))
+	_, err = buf.Write(utils.BigIntTo32Bytes(params.ChainID))
 	_, err = buf.Write(c.PartnerBalanceProof.Signature)
-	_, err = buf.Write(thirdAddr[:])
 	if err != nil {
 		log.Error(fmt.Sprintf("buf write error %s", err))
 	}
@@ -826,6 +1124,22 @@ func signFor3rd(c *channeltype.Serialization, thirdAddr common.Address, privkey
 	return utils.SignData(privkey, dataToSign)
 }
 
+//signUnlockFor3rd sign partner's lock, so 3rd party can call unlockDelegate for us.
+func signUnlockFor3rd(c *channeltype.Serialization, lock *mtree.Lock, thirdAddr common.Address, privkey *ecdsa.PrivateKey) (sig []byte, err error) {
+	buf := new(bytes.Buffer)
+	_, err = buf.Write(thirdAddr[:])
+	_, err = buf.Write(utils.BigIntTo32Bytes(big.NewInt(lock.Expiration)))
+	_, err = buf.Write(utils.BigIntTo32Bytes(lock.Amount))
+	_, err = buf.Write(lock.LockSecretHash[:])
+	_, err = buf.Write(c.ChannelIdentifier.ChannelIdentifier[:])
+	err = binary.Write(buf, binary.BigEndian, uint64(c.ChannelIdentifier.OpenBlockNumber))
+	_, err = buf.Write(utils.BigIntTo32Bytes(params.ChainID))
+	if err != nil {
+		log.Error(fmt.Sprintf("buf write error %s", err))
+	}
+	return utils.SignData(privkey, buf.Bytes())
+}
+
 //EventTransferSentSuccessWrapper wrapper
 type EventTransferSentSuccessWrapper struct {
 	transfer.EventTransferSentSuccess
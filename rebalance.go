@@ -0,0 +1,322 @@
+package smartraiden
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/SmartMeshFoundation/SmartRaiden/network/graph"
+	"github.com/SmartMeshFoundation/SmartRaiden/rerr"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/route"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//rebalanceTolerance channels whose share of ours is this close to the ratio are balanced enough
+const rebalanceTolerance = 0.1
+
+//rebalanceWaitTimeout is the longest time the rebalancer waits for a transfer, it goes on with other tokens after that
+const rebalanceWaitTimeout = 10 * time.Minute
+
+//rebalanceMemo is the memo of rebalance transfers, so they can be told apart in transfer history
+const rebalanceMemo = "rebalance"
+
+var errNothingToRebalance = errors.New("nothing to rebalance")
+
+/*
+Rebalance is a circular transfer to ourselves,
+which moves our balance from the channel with OutPartner to the channel with InPartner.
+the path is found in our channel graph, but mediators choose their own routes, so it may come back by another channel.
+*/
+type Rebalance struct {
+	Token          common.Address   `json:"token_address"`
+	OutPartner     common.Address   `json:"out_partner_address"`
+	InPartner      common.Address   `json:"in_partner_address"`
+	Amount         *big.Int         `json:"amount"`
+	Fee            *big.Int         `json:"fee"`
+	Path           []common.Address `json:"path"`
+	LockSecretHash common.Hash      `json:"lock_secret_hash"`
+}
+
+type channelBalance struct {
+	partner        common.Address
+	balance        *big.Int //ours
+	partnerBalance *big.Int
+	canSend        *big.Int //what we can send now, locks excluded
+	canReceive     *big.Int //what partner can send now, locks excluded
+}
+
+type rebalancePlan struct {
+	out    common.Address
+	in     common.Address
+	amount *big.Int
+}
+
+func shareOf(x *big.Int, ratio float64) *big.Int {
+	i, _ := new(big.Float).Mul(new(big.Float).SetInt(x), big.NewFloat(ratio)).Int(nil)
+	return i
+}
+
+func minBigInt(xs ...*big.Int) *big.Int {
+	m := xs[0]
+	for _, x := range xs[1:] {
+		if x.Cmp(m) < 0 {
+			m = x
+		}
+	}
+	return m
+}
+
+/*
+planRebalance pairs channels having more of ours than ratio with channels having less,
+amount of a pair moves both channels toward ratio as far as possible, leaving maxFee for the fee.
+pairs are ordered by amount, the largest first.
+*/
+func planRebalance(cbs []*channelBalance, ratio float64, maxFee *big.Int) (plans []*rebalancePlan) {
+	excess := make(map[common.Address]*big.Int)
+	var outs, ins []*channelBalance
+	for _, cb := range cbs {
+		capacity := new(big.Int).Add(cb.balance, cb.partnerBalance)
+		if capacity.Sign() <= 0 {
+			continue
+		}
+		e := new(big.Int).Sub(cb.balance, shareOf(capacity, ratio))
+		tolerance := shareOf(capacity, rebalanceTolerance)
+		if e.Cmp(tolerance) > 0 {
+			outs = append(outs, cb)
+		} else if new(big.Int).Neg(e).Cmp(tolerance) > 0 {
+			ins = append(ins, cb)
+		} else {
+			continue
+		}
+		excess[cb.partner] = e
+	}
+	for _, out := range outs {
+		canSend := new(big.Int).Sub(out.canSend, maxFee)
+		for _, in := range ins {
+			amount := minBigInt(excess[out.partner], new(big.Int).Neg(excess[in.partner]), canSend, in.canReceive)
+			if amount.Sign() <= 0 {
+				continue
+			}
+			plans = append(plans, &rebalancePlan{out.partner, in.partner, amount})
+		}
+	}
+	sort.SliceStable(plans, func(i, j int) bool {
+		r := plans[i].amount.Cmp(plans[j].amount)
+		if r != 0 {
+			return r > 0
+		}
+		return plans[i].out.Hex()+plans[i].in.Hex() < plans[j].out.Hex()+plans[j].in.Hex()
+	})
+	return
+}
+
+/*
+rebalance starts a transfer to ourselves for the first plan which has a path with fee no more than maxFee,
+result.Tag is *Rebalance if it's started.
+*/
+func (rs *RaidenService) rebalance(tokenAddress common.Address, ratio float64, maxFee *big.Int) (result *utils.AsyncResult) {
+	result = utils.NewAsyncResult()
+	g := rs.getToken2ChannelGraph(tokenAddress)
+	if g == nil {
+		result.Result <- rerr.ErrNoTokenManager
+		return
+	}
+	var cbs []*channelBalance
+	for partner, c := range g.PartenerAddress2Channel {
+		if !c.CanTransfer() {
+			continue
+		}
+		if _, isOnline := rs.Protocol.GetNetworkStatus(partner); !isOnline {
+			continue
+		}
+		cbs = append(cbs, &channelBalance{
+			partner:        partner,
+			balance:        c.Balance(),
+			partnerBalance: c.PartnerBalance(),
+			canSend:        c.Distributable(),
+			canReceive:     c.PartnerState.Distributable(c.OurState),
+		})
+	}
+	plans := planRebalance(cbs, ratio, maxFee)
+	if len(plans) == 0 {
+		result.Result <- errNothingToRebalance
+		return
+	}
+	for _, p := range plans {
+		path, err := g.CircularPath(p.out, p.in, p.amount, rs)
+		if err != nil {
+			log.Debug(fmt.Sprintf("rebalance %s from %s to %s, no path", p.amount, utils.APex2(p.out), utils.APex2(p.in)))
+			continue
+		}
+		if path.TotalFee.Cmp(maxFee) > 0 {
+			log.Debug(fmt.Sprintf("rebalance %s from %s to %s need fee %s,more than %s", p.amount, utils.APex2(p.out), utils.APex2(p.in), path.TotalFee, maxFee))
+			continue
+		}
+		routeState := graph.Channel2RouteState(g.GetPartenerAddress2Channel(p.out), p.out, p.amount, rs)
+		routeState.TotalFee = path.TotalFee
+		result, _ = rs.startMediatedTransferInternal(tokenAddress, rs.NodeAddress, p.amount, &TransferOptions{Memo: rebalanceMemo}, utils.EmptyHash, 0, []*route.State{routeState})
+		lockSecretHash, ok := result.Tag.(common.Hash)
+		if !ok {
+			//failed before state manager was created
+			return
+		}
+		log.Info(fmt.Sprintf("rebalance %s from %s to %s, fee=%s,lockSecretHash=%s", p.amount, utils.APex2(p.out), utils.APex2(p.in), path.TotalFee, utils.HPex(lockSecretHash)))
+		result.Tag = &Rebalance{
+			Token:          tokenAddress,
+			OutPartner:     p.out,
+			InPartner:      p.in,
+			Amount:         p.amount,
+			Fee:            path.TotalFee,
+			Path:           path.Nodes,
+			LockSecretHash: lockSecretHash,
+		}
+		return
+	}
+	result.Result <- rerr.ErrNoPathError
+	return
+}
+
+/*
+Rebalance moves our balance between channels of token by a transfer to ourselves,
+from the channel having the largest share of ours to one having too little, toward ratio of each channel.
+fee paid is no more than maxFee, zero ratio and nil maxFee means the configured ones.
+it waits until the transfer finished or timeout, rb is not nil once the transfer started.
+*/
+func (r *RaidenAPI) Rebalance(token common.Address, ratio float64, maxFee *big.Int, timeout time.Duration) (rb *Rebalance, err error) {
+	if ratio == 0 {
+		ratio = r.Raiden.Config.RebalanceRatio
+	}
+	if maxFee == nil {
+		maxFee = r.Raiden.Config.RebalanceMaxFee
+	}
+	if maxFee == nil {
+		maxFee = utils.BigInt0
+	}
+	if ratio <= 0 || ratio >= 1 {
+		err = fmt.Errorf("ratio should be between 0 and 1")
+		return
+	}
+	if maxFee.Sign() < 0 {
+		err = rerr.ErrInvalidAmount
+		return
+	}
+	result := r.Raiden.rebalanceClient(token, ratio, maxFee)
+	rb, _ = result.Tag.(*Rebalance)
+	err = r.waitTransfer(result, timeout)
+	return
+}
+
+/*
+rebalancer rebalances channels of every token periodically with the configured ratio and max fee,
+one transfer for each token at a time.
+fee paid for each token in 24 hours is no more than the configured daily fee, transfers are recorded in db for that.
+*/
+type rebalancer struct {
+	rs *RaidenService
+}
+
+func newRebalancer(rs *RaidenService) *rebalancer {
+	return &rebalancer{rs: rs}
+}
+
+func (rb *rebalancer) start() {
+	go rb.loop()
+}
+
+func (rb *rebalancer) loop() {
+	ticker := time.NewTicker(rb.rs.Config.RebalanceInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-rb.rs.quitChan:
+			return
+		}
+		tokens, err := rb.rs.db.GetAllTokens()
+		if err != nil {
+			log.Error(fmt.Sprintf("GetAllTokens err %s", err))
+			continue
+		}
+		for token := range tokens {
+			if !rb.rebalanceToken(token) {
+				return
+			}
+		}
+	}
+}
+
+//rebalanceToken returns false when the node quits
+func (rb *rebalancer) rebalanceToken(token common.Address) bool {
+	maxFee := rb.rs.Config.RebalanceMaxFee
+	if maxFee == nil {
+		maxFee = utils.BigInt0
+	}
+	if rb.rs.Config.RebalanceMaxDailyFee != nil {
+		spent, err := rb.rs.db.GetRebalanceFeeSince(token, time.Now().Add(-24*time.Hour))
+		if err != nil {
+			log.Error(fmt.Sprintf("GetRebalanceFeeSince err %s", err))
+			return true
+		}
+		left := new(big.Int).Sub(rb.rs.Config.RebalanceMaxDailyFee, spent)
+		if left.Sign() < 0 {
+			left = utils.BigInt0
+		}
+		if left.Cmp(maxFee) < 0 {
+			log.Debug(fmt.Sprintf("rebalance token %s spent %s fee in 24 hours, only %s left", utils.APex2(token), spent, left))
+			maxFee = left
+		}
+	}
+	result := rb.rs.rebalanceClient(token, rb.rs.Config.RebalanceRatio, maxFee)
+	var record *models.RebalanceRecord
+	if r, ok := result.Tag.(*Rebalance); ok {
+		record = &models.RebalanceRecord{
+			Token:          token,
+			LockSecretHash: r.LockSecretHash,
+			Amount:         r.Amount,
+			Fee:            r.Fee,
+			Status:         models.RebalancePending,
+		}
+		err := rb.rs.db.NewRebalanceRecord(record)
+		if err != nil {
+			log.Error(fmt.Sprintf("NewRebalanceRecord err %s", err))
+		}
+	}
+	var err error
+	select {
+	case err = <-result.Result:
+	case <-time.After(rebalanceWaitTimeout):
+		//the transfer goes on, its fee is still counted
+		log.Warn(fmt.Sprintf("rebalance token %s not finished in %s", utils.APex2(token), rebalanceWaitTimeout))
+		return true
+	case <-rb.rs.quitChan:
+		return false
+	}
+	if err == errNothingToRebalance {
+		return true
+	}
+	if record != nil && record.ID != 0 {
+		record.Status = models.RebalanceSuccess
+		if err != nil {
+			record.Status = models.RebalanceFailed
+		}
+		err2 := rb.rs.db.UpdateRebalanceRecord(record)
+		if err2 != nil {
+			log.Error(fmt.Sprintf("UpdateRebalanceRecord err %s", err2))
+		}
+	}
+	if r, ok := result.Tag.(*Rebalance); ok {
+		if err != nil {
+			log.Warn(fmt.Sprintf("rebalance token %s from %s to %s failed %s", utils.APex2(token), utils.APex2(r.OutPartner), utils.APex2(r.InPartner), err))
+		} else {
+			log.Info(fmt.Sprintf("rebalance token %s from %s to %s success, amount=%s,fee=%s", utils.APex2(token), utils.APex2(r.OutPartner), utils.APex2(r.InPartner), r.Amount, r.Fee))
+		}
+	} else if err != nil {
+		log.Info(fmt.Sprintf("rebalance token %s err %s", utils.APex2(token), err))
+	}
+	return true
+}
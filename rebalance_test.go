@@ -0,0 +1,163 @@
+package smartraiden
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mediatedtransfer"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mediatedtransfer/target"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+func newChannelBalance(balance, partnerBalance int64) *channelBalance {
+	return &channelBalance{
+		partner:        utils.NewRandomAddress(),
+		balance:        big.NewInt(balance),
+		partnerBalance: big.NewInt(partnerBalance),
+		canSend:        big.NewInt(balance),
+		canReceive:     big.NewInt(partnerBalance),
+	}
+}
+
+func TestPlanRebalance(t *testing.T) {
+	a, b, c, d := newChannelBalance(90, 10), newChannelBalance(10, 90), newChannelBalance(45, 55), newChannelBalance(20, 80)
+	plans := planRebalance([]*channelBalance{a, b, c, d}, 0.5, big.NewInt(5))
+	if len(plans) != 2 {
+		t.Errorf("expect 2 plans, got %s", utils.StringInterface(plans, 3))
+		return
+	}
+	if plans[0].out != a.partner || plans[0].in != b.partner || plans[0].amount.Int64() != 40 {
+		t.Errorf("first plan error %s", utils.StringInterface(plans[0], 3))
+	}
+	if plans[1].out != a.partner || plans[1].in != d.partner || plans[1].amount.Int64() != 30 {
+		t.Errorf("second plan error %s", utils.StringInterface(plans[1], 3))
+	}
+	//locked tokens and fee cannot be moved
+	a.canSend = big.NewInt(30)
+	plans = planRebalance([]*channelBalance{a, b}, 0.5, big.NewInt(5))
+	if len(plans) != 1 || plans[0].amount.Int64() != 25 {
+		t.Errorf("amount should be limited by what can be sent, plans=%s", utils.StringInterface(plans, 3))
+	}
+	//a channel with 20% of ours is balanced if the ratio is 0.2
+	plans = planRebalance([]*channelBalance{d, c}, 0.2, big.NewInt(0))
+	if len(plans) != 0 {
+		t.Errorf("nothing to rebalance, plans=%s", utils.StringInterface(plans, 3))
+	}
+}
+
+//recordTransition runs the real transition of mgr, but keeps state changes and events for checking instead of handling the events
+func recordTransition(mgr *transfer.StateManager, stateChanges *[]transfer.StateChange, events *[]transfer.Event) {
+	f := mgr.FuncStateTransition
+	mgr.FuncStateTransition = func(state transfer.State, stateChange transfer.StateChange) *transfer.TransitionResult {
+		it := f(state, stateChange)
+		*stateChanges = append(*stateChanges, stateChange)
+		*events = append(*events, it.Events...)
+		return &transfer.TransitionResult{NewState: it.NewState}
+	}
+}
+
+/*
+rebalance A-B-C-A, A is both the initiator and the target of one lock secret hash,
+the secret revealed by B and the unlock from C must reach both state managers of A.
+*/
+func TestCircularTransferDispatch(t *testing.T) {
+	rs := newTestRaidenWithDb(t, 100)
+	defer rs.db.CloseDB()
+	token := utils.NewRandomAddress()
+	b, c := utils.NewRandomAddress(), utils.NewRandomAddress()
+	initState := addTestInitiator(rs, token, newTestRoute(rs.NodeAddress, b, 100))
+	initState.Transfer.Target = rs.NodeAddress
+	lockSecretHash := initState.LockSecretHash
+	initMgr := rs.Transfer2StateManager[utils.Sha3(lockSecretHash[:], token[:])]
+	var initStateChanges []transfer.StateChange
+	var initEvents []transfer.Event
+	recordTransition(initMgr, &initStateChanges, &initEvents)
+
+	fromRoute := newTestRoute(rs.NodeAddress, c, 0)
+	fromTransfer := &mediatedtransfer.LockedTransferState{
+		TargetAmount:   big.NewInt(10),
+		Amount:         big.NewInt(10),
+		Token:          token,
+		Initiator:      rs.NodeAddress,
+		Target:         rs.NodeAddress,
+		Expiration:     190,
+		LockSecretHash: lockSecretHash,
+		Fee:            utils.BigInt0,
+	}
+	targetKey := mediatedtransfer.TargetStateManagerKey(fromTransfer, fromRoute.ChannelIdentifier)
+	if rs.Transfer2StateManager[targetKey] != nil {
+		t.Fatal("target state manager of a circular transfer must not replace the initiator's")
+	}
+	targetMgr := transfer.NewStateManager(target.StateTransiton, nil, target.NameTargetTransition, lockSecretHash, token)
+	rs.Transfer2StateManager[targetKey] = targetMgr
+	var targetStateChanges []transfer.StateChange
+	var targetEvents []transfer.Event
+	recordTransition(targetMgr, &targetStateChanges, &targetEvents)
+	//we know the secret as the initiator, so reveal it to C at once
+	rs.StateMachineEventHandler.dispatch(targetMgr, &mediatedtransfer.ActionInitTargetStateChange{
+		OurAddress:   rs.NodeAddress,
+		FromRoute:    fromRoute,
+		FromTranfer:  fromTransfer,
+		BlockNumber:  rs.GetBlockNumber(),
+		Secret:       initState.Secret,
+		SecretAmount: initState.Transfer.TargetAmount,
+	})
+	if assert2.Len(t, targetEvents, 1) {
+		ev, ok := targetEvents[0].(*mediatedtransfer.EventSendRevealSecret)
+		if assert2.True(t, ok) {
+			assert2.Equal(t, c, ev.Receiver)
+		}
+	}
+	//another transfer must not be disturbed
+	other := addTestInitiator(rs, token, newTestRoute(rs.NodeAddress, b, 100))
+	otherMgr := rs.Transfer2StateManager[utils.Sha3(other.LockSecretHash[:], token[:])]
+	var otherStateChanges []transfer.StateChange
+	var otherEvents []transfer.Event
+	recordTransition(otherMgr, &otherStateChanges, &otherEvents)
+
+	//B learned the secret from C and reveals it to us, the initiator unlocks to B
+	reveal := &mediatedtransfer.ReceiveSecretRevealStateChange{
+		Secret: initState.Secret,
+		Sender: b,
+	}
+	rs.StateMachineEventHandler.dispatchBySecretHash(lockSecretHash, reveal)
+	assert2.Equal(t, []transfer.StateChange{reveal}, initStateChanges)
+	assert2.Nil(t, initMgr.CurrentState)
+	var unlock *mediatedtransfer.EventSendBalanceProof
+	for _, e := range initEvents {
+		if e2, ok := e.(*mediatedtransfer.EventSendBalanceProof); ok {
+			unlock = e2
+		}
+	}
+	if assert2.NotNil(t, unlock) {
+		assert2.Equal(t, b, unlock.Receiver)
+	}
+	assert2.Equal(t, 2, len(targetStateChanges))
+	assert2.Equal(t, reveal, targetStateChanges[1])
+	assert2.Equal(t, mediatedtransfer.StateRevealSecret, targetMgr.CurrentState.(*mediatedtransfer.TargetState).State)
+
+	//C unlocks the lock to us, the target finishes
+	balanceProof := &mediatedtransfer.ReceiveBalanceProofStateChange{
+		LockSecretHash: lockSecretHash,
+		NodeAddress:    c,
+	}
+	rs.StateMachineEventHandler.dispatchBySecretHash(lockSecretHash, balanceProof)
+	assert2.Equal(t, 2, len(initStateChanges))
+	assert2.Equal(t, balanceProof, initStateChanges[1])
+	assert2.Equal(t, 3, len(targetStateChanges))
+	assert2.Equal(t, balanceProof, targetStateChanges[2])
+	assert2.Nil(t, targetMgr.CurrentState)
+	var received *transfer.EventTransferReceivedSuccess
+	for _, e := range targetEvents {
+		if e2, ok := e.(*transfer.EventTransferReceivedSuccess); ok {
+			received = e2
+		}
+	}
+	if assert2.NotNil(t, received) {
+		assert2.Equal(t, fromRoute.ChannelIdentifier, received.ChannelIdentifier)
+		assert2.EqualValues(t, big.NewInt(10), received.Amount)
+	}
+	assert2.Empty(t, otherStateChanges)
+}
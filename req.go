@@ -42,17 +42,22 @@ const cancelPrepareWithdrawReqName = "cancel mark withdraw"
 const depositChannelReqName = "deposit"
 const tokenSwapMakerReqName = "tokenswapmaker"
 const tokenSwapTakerReqName = "tokenswaptaker"
+const findPathReqName = "findpath"
+const transferStatusReqName = "transferstatus"
+const cancelTransferReqName = "canceltransfer"
+const settleHeldTransferReqName = "settleheldtransfer"
+const stateManagersReqName = "statemanagers"
+const rebalanceReqName = "rebalance"
 
 /*
 transfer api
 */
 type transferReq struct {
-	TokenAddress     common.Address
-	Amount           *big.Int
-	Target           common.Address
-	Fee              *big.Int
-	LockSecretHash   common.Hash
-	IsDirectTransfer bool
+	TokenAddress common.Address
+	Amount       *big.Int
+	Target       common.Address
+	Options      *TransferOptions
+	IsAsync      bool
 }
 
 /*
@@ -100,6 +105,40 @@ type tokenSwapTakerReq struct {
 	tokenSwap *TokenSwap
 }
 
+/*
+query or cancel a transfer api
+*/
+type transferStatusReq struct {
+	lockSecretHash common.Hash
+}
+
+/*
+accept or reject a transfer held by hold invoice api
+*/
+type settleHeldTransferReq struct {
+	lockSecretHash common.Hash
+	secret         common.Hash
+	accept         bool
+}
+
+/*
+query paths to target api
+*/
+type findPathReq struct {
+	tokenAddress common.Address
+	target       common.Address
+	amount       *big.Int
+}
+
+/*
+rebalance channels of a token api
+*/
+type rebalanceReq struct {
+	tokenAddress common.Address
+	ratio        float64
+	maxFee       *big.Int
+}
+
 /*
 general req's wraper
 */
@@ -121,17 +160,16 @@ Transfer `amount` between this node and `target`.
            - Network speed, making the transfer sufficiently fast so it doesn't
              expire.
 */
-func (rs *RaidenService) transferAsyncClient(tokenAddress common.Address, amount *big.Int, fee *big.Int, target common.Address, lockSecretHash common.Hash, isDirectTransfer bool) *utils.AsyncResult {
+func (rs *RaidenService) transferAsyncClient(tokenAddress common.Address, amount *big.Int, target common.Address, opts *TransferOptions, isAsync bool) *utils.AsyncResult {
 	req := &apiReq{
 		ReqID: utils.RandomString(10),
 		Name:  transferReqName,
 		Req: &transferReq{
-			TokenAddress:     tokenAddress,
-			Amount:           amount,
-			Target:           target,
-			LockSecretHash:   lockSecretHash,
-			Fee:              fee,
-			IsDirectTransfer: isDirectTransfer,
+			TokenAddress: tokenAddress,
+			Amount:       amount,
+			Target:       target,
+			Options:      opts,
+			IsAsync:      isAsync,
 		},
 	}
 	return rs.sendReqClient(req)
@@ -264,3 +302,50 @@ func (rs *RaidenService) tokenSwapTakerClient(tokenswap *TokenSwap) *utils.Async
 	}
 	return rs.sendReqClient(req)
 }
+func (rs *RaidenService) findPathClient(tokenAddress, target common.Address, amount *big.Int) *utils.AsyncResult {
+	req := &apiReq{
+		ReqID: utils.RandomString(10),
+		Name:  findPathReqName,
+		Req:   &findPathReq{tokenAddress, target, amount},
+	}
+	return rs.sendReqClient(req)
+}
+func (rs *RaidenService) transferStatusClient(lockSecretHash common.Hash) *utils.AsyncResult {
+	req := &apiReq{
+		ReqID: utils.RandomString(10),
+		Name:  transferStatusReqName,
+		Req:   &transferStatusReq{lockSecretHash},
+	}
+	return rs.sendReqClient(req)
+}
+func (rs *RaidenService) stateManagersClient() *utils.AsyncResult {
+	req := &apiReq{
+		ReqID: utils.RandomString(10),
+		Name:  stateManagersReqName,
+	}
+	return rs.sendReqClient(req)
+}
+func (rs *RaidenService) cancelTransferClient(lockSecretHash common.Hash) *utils.AsyncResult {
+	req := &apiReq{
+		ReqID: utils.RandomString(10),
+		Name:  cancelTransferReqName,
+		Req:   &transferStatusReq{lockSecretHash},
+	}
+	return rs.sendReqClient(req)
+}
+func (rs *RaidenService) settleHeldTransferClient(lockSecretHash, secret common.Hash, accept bool) *utils.AsyncResult {
+	req := &apiReq{
+		ReqID: utils.RandomString(10),
+		Name:  settleHeldTransferReqName,
+		Req:   &settleHeldTransferReq{lockSecretHash, secret, accept},
+	}
+	return rs.sendReqClient(req)
+}
+func (rs *RaidenService) rebalanceClient(tokenAddress common.Address, ratio float64, maxFee *big.Int) *utils.AsyncResult {
+	req := &apiReq{
+		ReqID: utils.RandomString(10),
+		Name:  rebalanceReqName,
+		Req:   &rebalanceReq{tokenAddress, ratio, maxFee},
+	}
+	return rs.sendReqClient(req)
+}
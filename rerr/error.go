@@ -159,3 +159,9 @@ func TransactionThrew(txName string, receipt *types.Receipt) error {
 
 //ErrTransferTimeout  timeout error
 var ErrTransferTimeout = errors.New("TransferTimeout")
+
+//ErrMemoTooLong memo of a transfer is longer than encoding.MaxMemoLength
+var ErrMemoTooLong = errors.New("MemoTooLong")
+
+//ErrInvoiceExpired the invoice cannot be paid any more
+var ErrInvoiceExpired = errors.New("InvoiceExpired")
@@ -0,0 +1,526 @@
+/*
+Package client is a Go client of the SmartRaiden REST API /api/1,
+requests and responses use the same types as restful/v1.
+*/
+package client
+
+//go:generate go run ../../cmd/tools/openapi/main.go -o ../../docs/openapi.json
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SmartMeshFoundation/SmartRaiden"
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/SmartMeshFoundation/SmartRaiden/restful/v1"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//DefaultTimeout of a request, transfers and channel operations may wait for blocks
+var DefaultTimeout = 5 * time.Minute
+
+//Error is returned when the node answers with a non 2xx status
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("smartraiden api status %d: %s", e.StatusCode, e.Message)
+}
+
+/*
+Client talks to one SmartRaiden node.
+Token is sent as a bearer token when the node is started with --api-token-file.
+*/
+type Client struct {
+	Host       string //such as http://127.0.0.1:5001
+	Token      string
+	HTTPClient *http.Client
+}
+
+//New create a client of the node listening at host
+func New(host string) *Client {
+	return &Client{
+		Host:       strings.TrimSuffix(host, "/"),
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+//do send `in` as json body if not nil, and decode the response into `out` if not nil
+func (c *Client) do(method, path string, query url.Values, in, out interface{}) error {
+	_, err := c.doWithHeader(method, path, query, in, out)
+	return err
+}
+
+//doWithHeader is the same as do, and returns the response header
+func (c *Client) doWithHeader(method, path string, query url.Values, in, out interface{}) (http.Header, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	u := c.Host + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.Header, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		e := &Error{StatusCode: resp.StatusCode}
+		//rest.Error writes {"Error":"..."}
+		var m struct {
+			Error string
+		}
+		if json.Unmarshal(data, &m) == nil && m.Error != "" {
+			e.Message = m.Error
+		} else {
+			e.Message = strings.TrimSpace(string(data))
+		}
+		return resp.Header, e
+	}
+	if out == nil || len(data) == 0 {
+		return resp.Header, nil
+	}
+	return resp.Header, json.Unmarshal(data, out)
+}
+
+func blockRange(fromBlock, toBlock int64) url.Values {
+	q := url.Values{}
+	if fromBlock >= 0 {
+		q.Set("from_block", strconv.FormatInt(fromBlock, 10))
+	}
+	if toBlock >= 0 {
+		q.Set("to_block", strconv.FormatInt(toBlock, 10))
+	}
+	return q
+}
+
+//Address returns address of the node
+func (c *Client) Address() (addr common.Address, err error) {
+	var m map[string]string
+	err = c.do(http.MethodGet, "/api/1/address", nil, nil, &m)
+	if err != nil {
+		return
+	}
+	addr = common.HexToAddress(m["our_address"])
+	return
+}
+
+//PublicKey returns the compressed public key of the node in hex, which is needed to keysend to it
+func (c *Client) PublicKey() (pub string, err error) {
+	var m map[string]string
+	err = c.do(http.MethodGet, "/api/1/address", nil, nil, &m)
+	pub = m["our_public_key"]
+	return
+}
+
+//Tokens returns registered tokens and their token networks
+func (c *Client) Tokens() (tokens models.AddressMap, err error) {
+	err = c.do(http.MethodGet, "/api/1/tokens", nil, nil, &tokens)
+	return
+}
+
+//TokenPartners returns partners of our channels of token
+func (c *Client) TokenPartners(token common.Address) (partners []*v1.PartnersData, err error) {
+	err = c.do(http.MethodGet, "/api/1/tokens/"+token.String()+"/partners", nil, nil, &partners)
+	return
+}
+
+//RegisterToken register a new token to the raiden network
+func (c *Client) RegisterToken(token common.Address) (ret *v1.RegisterTokenData, err error) {
+	ret = new(v1.RegisterTokenData)
+	err = c.do(http.MethodPut, "/api/1/tokens/"+token.String(), nil, nil, ret)
+	return
+}
+
+//Connections returns token networks joined
+func (c *Client) Connections() (infos map[common.Address]*smartraiden.ConnectionDetail, err error) {
+	err = c.do(http.MethodGet, "/api/1/connections", nil, nil, &infos)
+	return
+}
+
+//ConnectTokenNetwork join the token network of token, returns when channels are opened and deposited
+func (c *Client) ConnectTokenNetwork(token common.Address, req *v1.ConnectionData) error {
+	return c.do(http.MethodPut, "/api/1/connections/"+token.String(), nil, req, nil)
+}
+
+//LeaveTokenNetwork settle or close channels of token, returns channels left
+func (c *Client) LeaveTokenNetwork(token common.Address, onlyReceivingChannels bool) (channels []common.Hash, err error) {
+	err = c.do(http.MethodDelete, "/api/1/connections/"+token.String(), nil, &v1.LeaveData{OnlyReceivingChannels: onlyReceivingChannels}, &channels)
+	return
+}
+
+//Channels returns all channels of the node
+func (c *Client) Channels() (chs []*v1.ChannelData, err error) {
+	err = c.do(http.MethodGet, "/api/1/channels", nil, nil, &chs)
+	return
+}
+
+//Channel returns details of a channel
+func (c *Client) Channel(channel common.Hash) (ch *v1.ChannelDataDetail, err error) {
+	ch = new(v1.ChannelDataDetail)
+	err = c.do(http.MethodGet, "/api/1/channels/"+channel.String(), nil, nil, ch)
+	return
+}
+
+//OpenChannel open a channel with partner and deposit balance to it
+func (c *Client) OpenChannel(token, partner common.Address, settleTimeout int, balance *big.Int) (ch *v1.ChannelData, err error) {
+	req := &v1.ChannelData{
+		PartnerAddrses: partner.String(),
+		TokenAddress:   token.String(),
+		Balance:        balance,
+		SettleTimeout:  settleTimeout,
+	}
+	ch = new(v1.ChannelData)
+	err = c.do(http.MethodPut, "/api/1/channels", nil, req, ch)
+	return
+}
+
+func (c *Client) patchChannel(channel common.Hash, req *v1.ChannelStateData) (ch *v1.ChannelData, err error) {
+	ch = new(v1.ChannelData)
+	err = c.do(http.MethodPatch, "/api/1/channels/"+channel.String(), nil, req, ch)
+	return
+}
+
+//Deposit add amount to our balance of the channel
+func (c *Client) Deposit(channel common.Hash, amount *big.Int) (*v1.ChannelData, error) {
+	return c.patchChannel(channel, &v1.ChannelStateData{Balance: amount})
+}
+
+//CloseChannel cooperative settle the channel, or close it if force
+func (c *Client) CloseChannel(channel common.Hash, force bool) (*v1.ChannelData, error) {
+	return c.patchChannel(channel, &v1.ChannelStateData{State: "closed", Force: force})
+}
+
+//SettleChannel settle a closed channel after settle timeout
+func (c *Client) SettleChannel(channel common.Hash) (*v1.ChannelData, error) {
+	return c.patchChannel(channel, &v1.ChannelStateData{State: "settled"})
+}
+
+//ChannelFor3rdParty returns what the third party needs to update transfer and unlock for us
+func (c *Client) ChannelFor3rdParty(channel common.Hash, thirdParty common.Address) (ret *smartraiden.ChannelFor3rd, err error) {
+	ret = new(smartraiden.ChannelFor3rd)
+	err = c.do(http.MethodGet, "/api/1/thirdparty/"+channel.String()+"/"+thirdParty.String(), nil, nil, ret)
+	return
+}
+
+func (c *Client) withdraw(channel common.Hash, req *v1.WithdrawData) (ch *v1.ChannelData, err error) {
+	ch = new(v1.ChannelData)
+	err = c.do(http.MethodPut, "/api/1/withdraw/"+channel.String(), nil, req, ch)
+	return
+}
+
+//Withdraw amount from the channel
+func (c *Client) Withdraw(channel common.Hash, amount *big.Int) (*v1.ChannelData, error) {
+	return c.withdraw(channel, &v1.WithdrawData{Amount: amount})
+}
+
+//PrepareForWithdraw stop accepting new transfers on the channel before withdraw
+func (c *Client) PrepareForWithdraw(channel common.Hash) (*v1.ChannelData, error) {
+	return c.withdraw(channel, &v1.WithdrawData{Op: v1.OpPrepareWithdraw})
+}
+
+//CancelPrepareForWithdraw accept transfers on the channel again
+func (c *Client) CancelPrepareForWithdraw(channel common.Hash) (*v1.ChannelData, error) {
+	return c.withdraw(channel, &v1.WithdrawData{Op: v1.OpCancelPrepare})
+}
+
+//PrepareForCooperativeSettle stop accepting new transfers on the channel before cooperative settle
+func (c *Client) PrepareForCooperativeSettle(channel common.Hash) (ch *v1.ChannelData, err error) {
+	ch = new(v1.ChannelData)
+	err = c.do(http.MethodPut, "/api/1/settle/"+channel.String(), nil, &v1.SettleData{Op: v1.OpPrepareSettle}, ch)
+	return
+}
+
+//CancelPrepareForCooperativeSettle accept transfers on the channel again
+func (c *Client) CancelPrepareForCooperativeSettle(channel common.Hash) (ch *v1.ChannelData, err error) {
+	ch = new(v1.ChannelData)
+	err = c.do(http.MethodPut, "/api/1/settle/"+channel.String(), nil, &v1.SettleData{Op: v1.OpCancelPrepare}, ch)
+	return
+}
+
+/*
+Transfer send req.Amount of token to target.
+it returns after the transfer finished unless req.IsAsync,
+then query it with TransferStatus by the returned LockSecretHash.
+*/
+func (c *Client) Transfer(token, target common.Address, req *v1.TransferData) (ret *v1.TransferData, err error) {
+	ret = new(v1.TransferData)
+	err = c.do(http.MethodPost, "/api/1/transfers/"+token.String()+"/"+target.String(), nil, req, ret)
+	return
+}
+
+//TransferStatus returns status of an async transfer
+func (c *Client) TransferStatus(lockSecretHash common.Hash) (status *smartraiden.TransferStatus, err error) {
+	status = new(smartraiden.TransferStatus)
+	err = c.do(http.MethodGet, "/api/1/transfer_status/"+lockSecretHash.String(), nil, nil, status)
+	return
+}
+
+//CancelTransfer cancel an async transfer whose secret is not revealed
+func (c *Client) CancelTransfer(lockSecretHash common.Hash) error {
+	return c.do(http.MethodDelete, "/api/1/transfer_status/"+lockSecretHash.String(), nil, nil, nil)
+}
+
+//FindPath returns paths a transfer of amount would use
+func (c *Client) FindPath(token, target common.Address, amount *big.Int) (paths []*smartraiden.FoundPath, err error) {
+	q := url.Values{}
+	q.Set("amount", amount.String())
+	err = c.do(http.MethodGet, "/api/1/path/"+token.String()+"/"+target.String(), q, nil, &paths)
+	return
+}
+
+//Rebalance moves the node's balance between channels of token by a transfer to itself and waits
+func (c *Client) Rebalance(token common.Address, req *v1.RebalanceData) (rb *smartraiden.Rebalance, err error) {
+	rb = new(smartraiden.Rebalance)
+	err = c.do(http.MethodPost, "/api/1/rebalance/"+token.String(), nil, req, rb)
+	return
+}
+
+//SentTransfers returns transfers sent between fromBlock and toBlock, -1 means no limit
+func (c *Client) SentTransfers(fromBlock, toBlock int64) (trs []*models.SentTransfer, err error) {
+	err = c.do(http.MethodGet, "/api/1/querysenttransfer", blockRange(fromBlock, toBlock), nil, &trs)
+	return
+}
+
+//ReceivedTransfers returns transfers received between fromBlock and toBlock, -1 means no limit
+func (c *Client) ReceivedTransfers(fromBlock, toBlock int64) (trs []*models.ReceivedTransfer, err error) {
+	err = c.do(http.MethodGet, "/api/1/queryreceivedtransfer", blockRange(fromBlock, toBlock), nil, &trs)
+	return
+}
+
+func transferQuery(f *models.TransferFilter) url.Values {
+	q := blockRange(f.FromBlock, f.ToBlock)
+	if f.Token != utils.EmptyAddress {
+		q.Set("token", f.Token.String())
+	}
+	if f.Partner != utils.EmptyAddress {
+		q.Set("partner", f.Partner.String())
+	}
+	if f.Channel != utils.EmptyHash {
+		q.Set("channel", f.Channel.String())
+	}
+	if f.PaymentIdentifier != 0 {
+		q.Set("payment_identifier", strconv.FormatUint(f.PaymentIdentifier, 10))
+	}
+	if f.MinAmount != nil {
+		q.Set("min_amount", f.MinAmount.String())
+	}
+	if f.MaxAmount != nil {
+		q.Set("max_amount", f.MaxAmount.String())
+	}
+	if f.Descending {
+		q.Set("order", "desc")
+	}
+	if f.Limit > 0 {
+		q.Set("limit", strconv.Itoa(f.Limit))
+	}
+	if f.Cursor != "" {
+		q.Set("cursor", f.Cursor)
+	}
+	return q
+}
+
+/*
+QuerySentTransfers returns sent transfers selected by filter,
+next is the cursor of next page, empty if no more.
+*/
+func (c *Client) QuerySentTransfers(f *models.TransferFilter) (trs []*models.SentTransfer, next string, err error) {
+	h, err := c.doWithHeader(http.MethodGet, "/api/1/querysenttransfer", transferQuery(f), nil, &trs)
+	if err == nil {
+		next = h.Get(v1.HeaderNextCursor)
+	}
+	return
+}
+
+/*
+QueryReceivedTransfers returns received transfers selected by filter,
+next is the cursor of next page, empty if no more.
+*/
+func (c *Client) QueryReceivedTransfers(f *models.TransferFilter) (trs []*models.ReceivedTransfer, next string, err error) {
+	h, err := c.doWithHeader(http.MethodGet, "/api/1/queryreceivedtransfer", transferQuery(f), nil, &trs)
+	if err == nil {
+		next = h.Get(v1.HeaderNextCursor)
+	}
+	return
+}
+
+//TokenSwap start a token swap as maker or wait for it as taker, id must be same for both side
+func (c *Client) TokenSwap(target common.Address, id int, req *v1.TokenSwapData) error {
+	return c.do(http.MethodPut, "/api/1/token_swaps/"+target.String()+"/"+strconv.Itoa(id), nil, req, nil)
+}
+
+//FeePolicy returns the mediation fee policy
+func (c *Client) FeePolicy() (fp *models.FeePolicy, err error) {
+	fp = new(models.FeePolicy)
+	err = c.do(http.MethodGet, "/api/1/fee_policy", nil, nil, fp)
+	return
+}
+
+//SetFeePolicy replace the mediation fee policy
+func (c *Client) SetFeePolicy(fp *models.FeePolicy) error {
+	return c.do(http.MethodPut, "/api/1/fee_policy", nil, fp, nil)
+}
+
+//DepositPolicy returns the auto deposit policy
+func (c *Client) DepositPolicy() (dp *models.DepositPolicy, err error) {
+	dp = new(models.DepositPolicy)
+	err = c.do(http.MethodGet, "/api/1/deposit_policy", nil, nil, dp)
+	return
+}
+
+//SetDepositPolicy replace the auto deposit policy
+func (c *Client) SetDepositPolicy(dp *models.DepositPolicy) error {
+	return c.do(http.MethodPut, "/api/1/deposit_policy", nil, dp, nil)
+}
+
+//AutoDeposits returns deposits triggered by the deposit policy
+func (c *Client) AutoDeposits() (ds []*models.AutoDeposit, err error) {
+	err = c.do(http.MethodGet, "/api/1/auto_deposits", nil, nil, &ds)
+	return
+}
+
+//CreateInvoice create an invoice of amount token which can be paid within expiry
+func (c *Client) CreateInvoice(token common.Address, amount *big.Int, expiry time.Duration, memo string) (inv *models.Invoice, err error) {
+	inv = new(models.Invoice)
+	req := &v1.InvoiceData{Token: token.String(), Amount: amount, Expiry: int64(expiry / time.Second), Memo: memo}
+	err = c.do(http.MethodPost, "/api/1/invoices", nil, req, inv)
+	return
+}
+
+//CreateHoldInvoice create an invoice whose payment is held until accepted or rejected, lockSecretHash empty means the node keeps the secret
+func (c *Client) CreateHoldInvoice(token common.Address, amount *big.Int, expiry time.Duration, memo string, lockSecretHash common.Hash) (inv *models.Invoice, err error) {
+	inv = new(models.Invoice)
+	req := &v1.InvoiceData{Token: token.String(), Amount: amount, Expiry: int64(expiry / time.Second), Memo: memo, Hold: true}
+	if lockSecretHash != utils.EmptyHash {
+		req.LockSecretHash = lockSecretHash.String()
+	}
+	err = c.do(http.MethodPost, "/api/1/invoices", nil, req, inv)
+	return
+}
+
+//AcceptInvoice receive the payment held by a hold invoice, secret empty means it's kept by the node
+func (c *Client) AcceptInvoice(lockSecretHash, secret common.Hash) (inv *models.Invoice, err error) {
+	inv = new(models.Invoice)
+	req := &v1.AcceptInvoiceData{}
+	if secret != utils.EmptyHash {
+		req.Secret = secret.String()
+	}
+	err = c.do(http.MethodPost, "/api/1/invoices/"+lockSecretHash.String()+"/accept", nil, req, inv)
+	return
+}
+
+//RejectInvoice give back the payment held by a hold invoice and cancel it
+func (c *Client) RejectInvoice(lockSecretHash common.Hash) (inv *models.Invoice, err error) {
+	inv = new(models.Invoice)
+	err = c.do(http.MethodPost, "/api/1/invoices/"+lockSecretHash.String()+"/reject", nil, nil, inv)
+	return
+}
+
+//Invoices returns invoices created by the node
+func (c *Client) Invoices() (invs []*models.Invoice, err error) {
+	err = c.do(http.MethodGet, "/api/1/invoices", nil, nil, &invs)
+	return
+}
+
+//Invoice returns an invoice created by the node
+func (c *Client) Invoice(lockSecretHash common.Hash) (inv *models.Invoice, err error) {
+	inv = new(models.Invoice)
+	err = c.do(http.MethodGet, "/api/1/invoices/"+lockSecretHash.String(), nil, nil, inv)
+	return
+}
+
+//DecodeInvoice decodes and verifies an invoice uri
+func (c *Client) DecodeInvoice(uri string) (inv *v1.DecodedInvoice, err error) {
+	inv = new(v1.DecodedInvoice)
+	q := url.Values{}
+	q.Set("uri", uri)
+	err = c.do(http.MethodGet, "/api/1/decodeinvoice", q, nil, inv)
+	return
+}
+
+//PayInvoice pays an invoice and wait, fee nil means no fee
+func (c *Client) PayInvoice(uri string, fee *big.Int) (inv *v1.DecodedInvoice, err error) {
+	inv = new(v1.DecodedInvoice)
+	err = c.do(http.MethodPost, "/api/1/payinvoice", nil, &v1.PayInvoiceData{URI: uri, Fee: fee}, inv)
+	return
+}
+
+//Webhooks returns all webhooks
+func (c *Client) Webhooks() (ws []*models.Webhook, err error) {
+	err = c.do(http.MethodGet, "/api/1/webhooks", nil, nil, &ws)
+	return
+}
+
+//AddWebhook register url to be notified of events, empty events means all
+func (c *Client) AddWebhook(u string, events []string) (w *models.Webhook, err error) {
+	w = new(models.Webhook)
+	err = c.do(http.MethodPost, "/api/1/webhooks", nil, &v1.WebhookData{URL: u, Events: events}, w)
+	return
+}
+
+//RemoveWebhook delete a webhook
+func (c *Client) RemoveWebhook(id int) error {
+	return c.do(http.MethodDelete, "/api/1/webhooks/"+strconv.Itoa(id), nil, nil, nil)
+}
+
+//NetworkEvents returns contract events of the raiden network
+func (c *Client) NetworkEvents(fromBlock, toBlock int64) (events []*smartraiden.EventData, err error) {
+	err = c.do(http.MethodGet, "/api/1/events/network", blockRange(fromBlock, toBlock), nil, &events)
+	return
+}
+
+//TokenEvents returns contract events of the token network of token
+func (c *Client) TokenEvents(token common.Address, fromBlock, toBlock int64) (events []*smartraiden.EventData, err error) {
+	err = c.do(http.MethodGet, "/api/1/events/tokens/"+token.String(), blockRange(fromBlock, toBlock), nil, &events)
+	return
+}
+
+//ChannelEvents returns contract events of the channel
+func (c *Client) ChannelEvents(channel common.Hash, fromBlock, toBlock int64) (events []*smartraiden.EventData, err error) {
+	err = c.do(http.MethodGet, "/api/1/events/channels/"+channel.String(), blockRange(fromBlock, toBlock), nil, &events)
+	return
+}
+
+//InternalEvents returns events and state changes recorded by the node
+func (c *Client) InternalEvents(fromBlock, toBlock int64) (events []*models.InternalEvent, err error) {
+	err = c.do(http.MethodGet, "/api/1/events/internal", blockRange(fromBlock, toBlock), nil, &events)
+	return
+}
+
+//Punishments returns punishments of obsolete unlocks submitted by the node
+func (c *Client) Punishments() (ps []*models.Punishment, err error) {
+	err = c.do(http.MethodGet, "/api/1/punishments", nil, nil, &ps)
+	return
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/SmartMeshFoundation/SmartRaiden/restful/v1"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient(t *testing.T) {
+	token := utils.NewRandomAddress()
+	target := utils.NewRandomAddress()
+	var gotMethod, gotPath, gotAuth, gotQuery string
+	var gotBody v1.TransferData
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath, gotAuth, gotQuery = r.Method, r.URL.Path, r.Header.Get("Authorization"), r.URL.RawQuery
+		switch r.URL.Path {
+		case "/api/1/querysenttransfer":
+			w.Header().Set(v1.HeaderNextCursor, "3:abc")
+			w.Write([]byte(`[{"Key":"abc","block_number":3}]`))
+		case "/api/1/transfers/" + token.String() + "/" + target.String():
+			json.NewDecoder(r.Body).Decode(&gotBody)
+			gotBody.Token = token.String()
+			json.NewEncoder(w).Encode(&gotBody)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"Error":"channel not found"}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL + "/")
+	c.Token = "secret"
+	ret, err := c.Transfer(token, target, &v1.TransferData{Amount: big.NewInt(10), IsAsync: true, PaymentIdentifier: 7, Memo: "order 7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualValues(t, http.MethodPost, gotMethod)
+	assert.EqualValues(t, "Bearer secret", gotAuth)
+	assert.EqualValues(t, big.NewInt(10), gotBody.Amount)
+	assert.EqualValues(t, true, gotBody.IsAsync)
+	assert.EqualValues(t, 7, gotBody.PaymentIdentifier)
+	assert.EqualValues(t, "order 7", ret.Memo)
+	assert.EqualValues(t, token.String(), ret.Token)
+
+	_, err = c.Channel(utils.NewRandomHash())
+	assert.EqualValues(t, http.MethodGet, gotMethod)
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expect *Error, got %v", err)
+	}
+	assert.EqualValues(t, http.StatusNotFound, e.StatusCode)
+	assert.EqualValues(t, "channel not found", e.Message)
+	assert.Contains(t, gotPath, "/api/1/channels/")
+
+	trs, next, err := c.QuerySentTransfers(&models.TransferFilter{FromBlock: -1, ToBlock: 5, Token: token, Descending: true, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualValues(t, 1, len(trs))
+	assert.EqualValues(t, "3:abc", next)
+	assert.EqualValues(t, "limit=1&order=desc&to_block=5&token="+token.String(), gotQuery)
+}
+
+func TestOpenAPI(t *testing.T) {
+	doc := OpenAPI()
+	paths := doc["paths"].(dataMap)
+	for _, e := range Endpoints {
+		p := pathParam.ReplaceAllString(e.Path, "{$1}")
+		item, ok := paths[p].(dataMap)
+		if !ok {
+			t.Errorf("path %s missing", p)
+			continue
+		}
+		assert.NotNil(t, item[strings.ToLower(e.Method)])
+	}
+	schemas := doc["components"].(dataMap)["schemas"].(dataMap)
+	props := schemas["TransferData"].(dataMap)["properties"].(dataMap)
+	assert.EqualValues(t, dataMap{"type": "integer"}, props["amount"])
+	assert.EqualValues(t, dataMap{"type": "boolean"}, props["is_async"])
+	_, err := json.Marshal(doc)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,245 @@
+package client
+
+import (
+	"encoding"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/SmartMeshFoundation/SmartRaiden"
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/SmartMeshFoundation/SmartRaiden/restful/v1"
+)
+
+//Endpoint is an api of /api/1, Request and Response are values of the json body types, nil if none
+type Endpoint struct {
+	Method   string
+	Path     string //path of go-json-rest, such as /api/1/channels/:channel
+	Summary  string
+	Query    []string
+	Request  interface{}
+	Response interface{}
+}
+
+//Endpoints are the apis covered by this client, debug and test apis are not included
+var Endpoints = []*Endpoint{
+	{"GET", "/api/1/address", "address of this node", nil, nil, map[string]string{}},
+	{"GET", "/api/1/tokens", "registered tokens and their token networks", nil, nil, models.AddressMap{}},
+	{"GET", "/api/1/tokens/:token/partners", "partners of our channels of token", nil, nil, []*v1.PartnersData{}},
+	{"PUT", "/api/1/tokens/:token", "register a token", nil, nil, &v1.RegisterTokenData{}},
+	{"GET", "/api/1/connections", "joined token networks", nil, nil, map[string]*smartraiden.ConnectionDetail{}},
+	{"PUT", "/api/1/connections/:token", "join a token network", nil, &v1.ConnectionData{}, nil},
+	{"DELETE", "/api/1/connections/:token", "leave a token network", nil, &v1.LeaveData{}, []string{}},
+	{"PUT", "/api/1/token_swaps/:target/:id", "token swap as maker or taker", nil, &v1.TokenSwapData{}, nil},
+	{"POST", "/api/1/transfers/:token/:target", "send a transfer", nil, &v1.TransferData{}, &v1.TransferData{}},
+	{"GET", "/api/1/transfer_status/:lockSecretHash", "status of an async transfer", nil, nil, &smartraiden.TransferStatus{}},
+	{"DELETE", "/api/1/transfer_status/:lockSecretHash", "cancel an async transfer", nil, nil, nil},
+	{"GET", "/api/1/path/:token/:target", "paths of a transfer", []string{"amount"}, nil, []*smartraiden.FoundPath{}},
+	{"POST", "/api/1/rebalance/:token", "move our balance between channels by a transfer to ourselves and wait", nil, &v1.RebalanceData{}, &smartraiden.Rebalance{}},
+	{"GET", "/api/1/querysenttransfer", "sent transfers, next page cursor is in header X-Next-Cursor", transferHistoryQuery, nil, []*models.SentTransfer{}},
+	{"GET", "/api/1/queryreceivedtransfer", "received transfers, next page cursor is in header X-Next-Cursor", transferHistoryQuery, nil, []*models.ReceivedTransfer{}},
+	{"POST", "/api/1/invoices", "create an invoice", nil, &v1.InvoiceData{}, &models.Invoice{}},
+	{"GET", "/api/1/invoices", "invoices created by this node", nil, nil, []*models.Invoice{}},
+	{"GET", "/api/1/invoices/:lockSecretHash", "an invoice created by this node", nil, nil, &models.Invoice{}},
+	{"POST", "/api/1/invoices/:lockSecretHash/accept", "reveal the secret of a hold invoice and receive the held payment", nil, &v1.AcceptInvoiceData{}, &models.Invoice{}},
+	{"POST", "/api/1/invoices/:lockSecretHash/reject", "give back the held payment and cancel a hold invoice", nil, nil, &models.Invoice{}},
+	{"GET", "/api/1/decodeinvoice", "decode and verify an invoice uri", []string{"uri"}, nil, &v1.DecodedInvoice{}},
+	{"POST", "/api/1/payinvoice", "pay an invoice and wait", nil, &v1.PayInvoiceData{}, &v1.DecodedInvoice{}},
+	{"GET", "/api/1/channels", "all channels", nil, nil, []*v1.ChannelData{}},
+	{"GET", "/api/1/channels/:channel", "details of a channel", nil, nil, &v1.ChannelDataDetail{}},
+	{"PUT", "/api/1/channels", "open a channel", nil, &v1.ChannelData{}, &v1.ChannelData{}},
+	{"PATCH", "/api/1/channels/:channel", "deposit, close or settle a channel", nil, &v1.ChannelStateData{}, &v1.ChannelData{}},
+	{"GET", "/api/1/thirdparty/:channel/:3rd", "channel information for a third party", nil, nil, &smartraiden.ChannelFor3rd{}},
+	{"PUT", "/api/1/withdraw/:channel", "withdraw or prepare for withdraw", nil, &v1.WithdrawData{}, &v1.ChannelData{}},
+	{"PUT", "/api/1/settle/:channel", "prepare for cooperative settle", nil, &v1.SettleData{}, &v1.ChannelData{}},
+	{"GET", "/api/1/fee_policy", "mediation fee policy", nil, nil, &models.FeePolicy{}},
+	{"PUT", "/api/1/fee_policy", "replace mediation fee policy", nil, &models.FeePolicy{}, &models.FeePolicy{}},
+	{"GET", "/api/1/deposit_policy", "auto deposit policy", nil, nil, &models.DepositPolicy{}},
+	{"PUT", "/api/1/deposit_policy", "replace auto deposit policy", nil, &models.DepositPolicy{}, &models.DepositPolicy{}},
+	{"GET", "/api/1/auto_deposits", "deposits triggered by the deposit policy, the latest first", nil, nil, []*models.AutoDeposit{}},
+	{"GET", "/api/1/webhooks", "all webhooks", nil, nil, []*models.Webhook{}},
+	{"POST", "/api/1/webhooks", "add a webhook", nil, &v1.WebhookData{}, &models.Webhook{}},
+	{"DELETE", "/api/1/webhooks/:id", "remove a webhook", nil, nil, nil},
+	{"GET", "/api/1/events/network", "contract events of the raiden network", []string{"from_block", "to_block"}, nil, []*smartraiden.EventData{}},
+	{"GET", "/api/1/events/tokens/:token", "contract events of a token network", []string{"from_block", "to_block"}, nil, []*smartraiden.EventData{}},
+	{"GET", "/api/1/events/channels/:channel", "contract events of a channel", []string{"from_block", "to_block"}, nil, []*smartraiden.EventData{}},
+	{"GET", "/api/1/events/internal", "events recorded by this node", []string{"from_block", "to_block"}, nil, []*models.InternalEvent{}},
+	{"GET", "/api/1/punishments", "punishments of obsolete unlocks submitted by this node", nil, nil, []*models.Punishment{}},
+}
+
+type dataMap map[string]interface{}
+
+//transferHistoryQuery is the query of transfer history
+var transferHistoryQuery = []string{"from_block", "to_block", "token", "partner", "channel", "payment_identifier", "min_amount", "max_amount", "order", "limit", "cursor", "format"}
+
+//stringQuery are query parameters which are not integers
+var stringQuery = map[string]bool{
+	"token": true, "partner": true, "channel": true, "order": true, "cursor": true, "format": true, "uri": true,
+}
+
+var pathParam = regexp.MustCompile(`:([^/]+)`)
+
+/*
+OpenAPI generates an OpenAPI 3 document of Endpoints,
+schemas come from the json encoding of the request and response types.
+*/
+func OpenAPI() dataMap {
+	g := &schemaGenerator{schemas: make(dataMap)}
+	paths := make(dataMap)
+	for _, e := range Endpoints {
+		p := pathParam.ReplaceAllString(e.Path, "{$1}")
+		var params []dataMap
+		for _, m := range pathParam.FindAllStringSubmatch(e.Path, -1) {
+			params = append(params, dataMap{"name": m[1], "in": "path", "required": true, "schema": dataMap{"type": "string"}})
+		}
+		for _, q := range e.Query {
+			typ := "integer"
+			if stringQuery[q] {
+				typ = "string"
+			}
+			params = append(params, dataMap{"name": q, "in": "query", "schema": dataMap{"type": typ}})
+		}
+		op := dataMap{
+			"summary":     e.Summary,
+			"operationId": strings.ToLower(e.Method) + pathParam.ReplaceAllString(strings.Replace(e.Path[len("/api/1"):], "/", "_", -1), "$1"),
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if e.Request != nil {
+			op["requestBody"] = dataMap{
+				"required": true,
+				"content":  dataMap{"application/json": dataMap{"schema": g.schema(reflect.TypeOf(e.Request))}},
+			}
+		}
+		ok := dataMap{"description": "success"}
+		if e.Response != nil {
+			ok["content"] = dataMap{"application/json": dataMap{"schema": g.schema(reflect.TypeOf(e.Response))}}
+		}
+		op["responses"] = dataMap{
+			"200":     ok,
+			"default": dataMap{"$ref": "#/components/responses/Error"},
+		}
+		item, _ := paths[p].(dataMap)
+		if item == nil {
+			item = make(dataMap)
+			paths[p] = item
+		}
+		item[strings.ToLower(e.Method)] = op
+	}
+	return dataMap{
+		"openapi": "3.0.0",
+		"info": dataMap{
+			"title":   "SmartRaiden REST API",
+			"version": "1",
+		},
+		"paths": paths,
+		"components": dataMap{
+			"securitySchemes": dataMap{"bearer": dataMap{"type": "http", "scheme": "bearer"}},
+			"responses": dataMap{
+				"Error": dataMap{
+					"description": "error",
+					"content": dataMap{"application/json": dataMap{"schema": dataMap{
+						"type":       "object",
+						"properties": dataMap{"Error": dataMap{"type": "string"}},
+					}}},
+				},
+			},
+			"schemas": g.schemas,
+		},
+		"security": []dataMap{{"bearer": []string{}}},
+	}
+}
+
+var (
+	bigIntType        = reflect.TypeOf(big.Int{})
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+type schemaGenerator struct {
+	schemas dataMap
+}
+
+//schema of t as encoding/json encodes it, named structs are put into components
+func (g *schemaGenerator) schema(t reflect.Type) dataMap {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == bigIntType:
+		return dataMap{"type": "integer"}
+	case t == timeType:
+		return dataMap{"type": "string", "format": "date-time"}
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		//common.Address and common.Hash are hex strings
+		return dataMap{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return dataMap{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return dataMap{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return dataMap{"type": "number"}
+	case reflect.String:
+		return dataMap{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			if t.Kind() == reflect.Slice {
+				return dataMap{"type": "string", "format": "byte"}
+			}
+			return dataMap{"type": "array", "items": dataMap{"type": "integer"}}
+		}
+		return dataMap{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return dataMap{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := t.Name()
+		if _, ok := g.schemas[name]; !ok {
+			g.schemas[name] = dataMap{} //recursive types
+			g.schemas[name] = g.structSchema(t)
+		}
+		return dataMap{"$ref": "#/components/schemas/" + name}
+	}
+	//interface{}, anything
+	return dataMap{}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) dataMap {
+	props := make(dataMap)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		name := f.Name
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if n := strings.Split(tag, ",")[0]; n != "" {
+			name = n
+		}
+		if f.Anonymous && tag == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for k, v := range g.structSchema(ft)["properties"].(dataMap) {
+					props[k] = v
+				}
+				continue
+			}
+		}
+		props[name] = g.schema(f.Type)
+	}
+	return dataMap{"type": "object", "properties": props}
+}
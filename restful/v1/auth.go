@@ -0,0 +1,83 @@
+package v1
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+//roles of api users
+const (
+	roleNone     = ""
+	roleReadOnly = "readonly"
+	roleOperator = "operator"
+)
+
+//operatorOnlyPrefixes are GET apis which change something or give away signed proofs
+var operatorOnlyPrefixes = []string{
+	"/api/1/stop",
+	"/api/1/switch/",
+	"/api/1/debug/",
+	"/api/1/thirdparty/",
+}
+
+/*
+AuthMiddleware checks bearer token in header Authorization,
+operator can call all the apis, readonly can only query.
+*/
+type AuthMiddleware struct {
+	OperatorToken string
+	ReadOnlyToken string
+}
+
+func tokenEqual(a, b string) bool {
+	return len(b) > 0 && subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func (mw *AuthMiddleware) role(r *rest.Request) string {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return roleNone
+	}
+	token := strings.TrimSpace(auth[len("Bearer "):])
+	if tokenEqual(token, mw.OperatorToken) {
+		return roleOperator
+	}
+	if tokenEqual(token, mw.ReadOnlyToken) {
+		return roleReadOnly
+	}
+	return roleNone
+}
+
+func isReadOnlyRequest(r *rest.Request) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+	for _, p := range operatorOnlyPrefixes {
+		if strings.HasPrefix(r.URL.Path, p) {
+			return false
+		}
+	}
+	return true
+}
+
+//MiddlewareFunc makes AuthMiddleware implement the rest.Middleware interface.
+func (mw *AuthMiddleware) MiddlewareFunc(handler rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		switch mw.role(r) {
+		case roleOperator:
+		case roleReadOnly:
+			if !isReadOnlyRequest(r) {
+				rest.Error(w, "permission denied", http.StatusForbidden)
+				return
+			}
+		default:
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			rest.Error(w, "not authorized", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
@@ -0,0 +1,192 @@
+package v1
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/params"
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAuthTestServer(t *testing.T) *httptest.Server {
+	api := rest.NewApi()
+	api.Use(&AuthMiddleware{
+		OperatorToken: "operator",
+		ReadOnlyToken: "readonly",
+	})
+	ok := func(w rest.ResponseWriter, r *rest.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	router, err := rest.MakeRouter(
+		rest.Get("/api/1/channels", ok),
+		rest.Put("/api/1/channels", ok),
+		rest.Get("/api/1/stop", ok),
+		rest.Get("/api/1/debug/ethstatus", ok),
+		rest.Get("/api/1/thirdparty/:channel/:3rd", ok),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.SetApp(router)
+	return httptest.NewServer(api.MakeHandler())
+}
+
+func doRequest(t *testing.T, client *http.Client, method, url, auth string) int {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	server := newAuthTestServer(t)
+	defer server.Close()
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		auth   string
+		status int
+	}{
+		{"missing token", http.MethodGet, "/api/1/channels", "", http.StatusUnauthorized},
+		{"not bearer", http.MethodGet, "/api/1/channels", "Basic operator", http.StatusUnauthorized},
+		{"wrong token", http.MethodGet, "/api/1/channels", "Bearer wrong", http.StatusUnauthorized},
+		{"empty token", http.MethodGet, "/api/1/channels", "Bearer ", http.StatusUnauthorized},
+		{"readonly query", http.MethodGet, "/api/1/channels", "Bearer readonly", http.StatusOK},
+		{"readonly write", http.MethodPut, "/api/1/channels", "Bearer readonly", http.StatusForbidden},
+		{"readonly stop", http.MethodGet, "/api/1/stop", "Bearer readonly", http.StatusForbidden},
+		{"readonly debug", http.MethodGet, "/api/1/debug/ethstatus", "Bearer readonly", http.StatusForbidden},
+		{"readonly thirdparty", http.MethodGet, "/api/1/thirdparty/0x1/0x2", "Bearer readonly", http.StatusForbidden},
+		{"operator thirdparty", http.MethodGet, "/api/1/thirdparty/0x1/0x2", "Bearer operator", http.StatusOK},
+		{"operator query", http.MethodGet, "/api/1/channels", "Bearer operator", http.StatusOK},
+		{"operator write", http.MethodPut, "/api/1/channels", "Bearer operator", http.StatusOK},
+		{"operator debug", http.MethodGet, "/api/1/debug/ethstatus", "Bearer operator", http.StatusOK},
+	}
+	for _, c := range cases {
+		status := doRequest(t, http.DefaultClient, c.method, server.URL+c.path, c.auth)
+		assert.Equal(t, c.status, status, c.name)
+	}
+}
+
+func TestDebugAPIDisabled(t *testing.T) {
+	Config = &params.Config{APIToken: "operator"}
+	defer func() { Config = nil }()
+	handler, err := makeHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	for _, p := range []string{"/api/1/stop", "/api/1/switch/true", "/api/1/debug/ethstatus", "/api/1/debug/balance/0x1/0x2"} {
+		status := doRequest(t, http.DefaultClient, http.MethodGet, server.URL+p, "Bearer operator")
+		assert.Equal(t, http.StatusNotFound, status, p)
+	}
+	status := doRequest(t, http.DefaultClient, http.MethodGet, server.URL+"/api/1/stop", "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+//writeTestCert writes a self signed certificate for 127.0.0.1
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = path.Join(dir, "cert.pem")
+	keyFile = path.Join(dir, "key.pem")
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestServeTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apitls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeTestCert(t, dir)
+	Config = &params.Config{
+		APIToken:       "operator",
+		APITLSCertFile: certFile,
+		APITLSKeyFile:  keyFile,
+	}
+	defer func() { Config = nil }()
+	handler, err := makeHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go serve(l, handler)
+	pool := x509.NewCertPool()
+	pem, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.AppendCertsFromPEM(pem)
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		Timeout:   5 * time.Second,
+	}
+	//authentication is still required over https
+	status := doRequest(t, client, http.MethodGet, "https://"+l.Addr().String()+"/api/1/stop", "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status = doRequest(t, client, http.MethodGet, "https://"+l.Addr().String()+"/api/1/stop", "Bearer operator")
+	assert.Equal(t, http.StatusNotFound, status)
+	//plain http is not served
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Get("http://" + l.Addr().String() + "/api/1/stop")
+	if err == nil {
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		resp.Body.Close()
+	}
+}
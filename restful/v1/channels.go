@@ -31,8 +31,34 @@ type ChannelData struct {
 	TokenAddress        string            `json:"token_address"`
 	State               channeltype.State `json:"state"`
 	StateString         string
-	SettleTimeout       int `json:"settle_timeout"`
-	RevealTimeout       int `json:"reveal_timeout"`
+	PrepareState        string `json:"prepare_state,omitempty"` //preparesettle or preparewithdraw
+	SettleTimeout       int    `json:"settle_timeout"`
+	RevealTimeout       int    `json:"reveal_timeout"`
+}
+
+//ChannelStateData is the request of PATCH /api/1/channels/:channel, deposit if Balance>0 else close or settle
+type ChannelStateData struct {
+	State   string //closed or settled
+	Balance *big.Int
+	Force   bool //close instead of cooperative settle
+}
+
+//operations of withdraw and settle
+const (
+	OpPrepareWithdraw = "preparewithdraw"
+	OpPrepareSettle   = "preparesettle"
+	OpCancelPrepare   = "cancelprepare"
+)
+
+//WithdrawData is the request of PUT /api/1/withdraw/:channel, withdraw if Amount>0 else do Op
+type WithdrawData struct {
+	Amount *big.Int
+	Op     string
+}
+
+//SettleData is the request of PUT /api/1/settle/:channel
+type SettleData struct {
+	Op string
 }
 
 //ChannelDataDetail more info
@@ -47,8 +73,9 @@ type ChannelDataDetail struct {
 	TokenAddress        string            `json:"token_address"`
 	State               channeltype.State `json:"state"`
 	StateString         string
-	SettleTimeout       int `json:"settle_timeout"`
-	RevealTimeout       int `json:"reveal_timeout"`
+	PrepareState        string `json:"prepare_state,omitempty"` //preparesettle or preparewithdraw
+	SettleTimeout       int    `json:"settle_timeout"`
+	RevealTimeout       int    `json:"reveal_timeout"`
 
 	/*
 		extended
@@ -84,6 +111,7 @@ func GetChannelList(w rest.ResponseWriter, r *rest.Request) {
 			PartnerBalance:      c.PartnerBalance(),
 			State:               c.State,
 			StateString:         c.State.String(),
+			PrepareState:        ChannelPrepareState(c.State),
 			TokenAddress:        c.TokenAddress().String(),
 			SettleTimeout:       c.SettleTimeout,
 			RevealTimeout:       c.RevealTimeout,
@@ -141,6 +169,7 @@ func SpecifiedChannel(w rest.ResponseWriter, r *rest.Request) {
 		PartnerBalance:           c.PartnerBalance(),
 		State:                    c.State,
 		StateString:              c.State.String(),
+		PrepareState:             ChannelPrepareState(c.State),
 		SettleTimeout:            c.SettleTimeout,
 		TokenAddress:             c.TokenAddress().String(),
 		LockedAmount:             c.OurAmountLocked(),
@@ -192,6 +221,7 @@ func OpenChannel(w rest.ResponseWriter, r *rest.Request) {
 			PartnerBalance:      c.PartnerBalance(),
 			State:               c.State,
 			StateString:         c.State.String(),
+			PrepareState:        ChannelPrepareState(c.State),
 			SettleTimeout:       c.SettleTimeout,
 			TokenAddress:        c.TokenAddress().String(),
 			LockedAmount:        c.OurAmountLocked(),
@@ -220,13 +250,7 @@ func CloseSettleDepositChannel(w rest.ResponseWriter, r *rest.Request) {
 		return
 	}
 	chAddr := common.HexToHash(chstr)
-	type Req struct {
-		State    string
-		StateInt channeltype.State
-		Balance  *big.Int
-		Force    bool
-	}
-	req := &Req{}
+	req := &ChannelStateData{}
 	err := r.DecodeJsonPayload(req)
 	if err != nil {
 		rest.Error(w, err.Error(), http.StatusBadRequest)
@@ -245,19 +269,20 @@ func CloseSettleDepositChannel(w rest.ResponseWriter, r *rest.Request) {
 			return
 		}
 	} else {
+		var stateInt channeltype.State
 		if req.State == "closed" {
-			req.StateInt = channeltype.StateClosed
+			stateInt = channeltype.StateClosed
 		} else if req.State == "settled" {
-			req.StateInt = channeltype.StateSettled
+			stateInt = channeltype.StateSettled
 		} else {
-			req.StateInt = channeltype.StateError
+			stateInt = channeltype.StateError
 		}
 		//close or settle
-		if req.StateInt != channeltype.StateClosed && req.StateInt != channeltype.StateSettled {
+		if stateInt != channeltype.StateClosed && stateInt != channeltype.StateSettled {
 			rest.Error(w, "argument error", http.StatusBadRequest)
 			return
 		}
-		if req.StateInt == channeltype.StateClosed {
+		if stateInt == channeltype.StateClosed {
 			if req.Force {
 				c, err = RaidenAPI.Close(c.TokenAddress(), c.PartnerAddress())
 				if err != nil {
@@ -275,7 +300,7 @@ func CloseSettleDepositChannel(w rest.ResponseWriter, r *rest.Request) {
 				}
 			}
 
-		} else if req.StateInt == channeltype.StateSettled {
+		} else if stateInt == channeltype.StateSettled {
 			c, err = RaidenAPI.Settle(c.TokenAddress(), c.PartnerAddress())
 			if err != nil {
 				log.Error(err.Error())
@@ -292,6 +317,7 @@ func CloseSettleDepositChannel(w rest.ResponseWriter, r *rest.Request) {
 		PartnerBalance:      c.PartnerBalance(),
 		State:               c.State,
 		StateString:         c.State.String(),
+		PrepareState:        ChannelPrepareState(c.State),
 		SettleTimeout:       c.SettleTimeout,
 		TokenAddress:        c.TokenAddress().String(),
 		LockedAmount:        c.OurAmountLocked(),
@@ -311,14 +337,7 @@ func withdraw(w rest.ResponseWriter, r *rest.Request) {
 		return
 	}
 	chAddr := common.HexToHash(chstr)
-	type Req struct {
-		Amount *big.Int
-		Op     string
-	}
-	const OpPrepareWithdraw = "preparewithdraw"
-	const OpCancelPrepare = "cancelprepare"
-
-	req := &Req{}
+	req := &WithdrawData{}
 	err := r.DecodeJsonPayload(req)
 	if err != nil {
 		rest.Error(w, err.Error(), http.StatusBadRequest)
@@ -357,6 +376,83 @@ func withdraw(w rest.ResponseWriter, r *rest.Request) {
 		PartnerBalance:      c.PartnerBalance(),
 		State:               c.State,
 		StateString:         c.State.String(),
+		PrepareState:        ChannelPrepareState(c.State),
+		SettleTimeout:       c.SettleTimeout,
+		TokenAddress:        c.TokenAddress().String(),
+		LockedAmount:        c.OurAmountLocked(),
+		PartnerLockedAmount: c.PartnerAmountLocked(),
+		RevealTimeout:       c.RevealTimeout,
+	}
+	err = w.WriteJson(d)
+	if err != nil {
+		log.Warn(fmt.Sprintf("writejson err %s", err))
+	}
+}
+
+//ChannelPrepareState returns which operation the channel is prepared for, empty if none
+func ChannelPrepareState(s channeltype.State) string {
+	switch s {
+	case channeltype.StatePrepareForCooperativeSettle:
+		return "preparesettle"
+	case channeltype.StatePrepareForWithdraw:
+		return "preparewithdraw"
+	}
+	return ""
+}
+
+/*
+settle prepare for cooperative settle or cancel it,
+no new transfer is accepted after prepared, so the pending locks can be finished before settle.
+*/
+func settle(w rest.ResponseWriter, r *rest.Request) {
+	chstr := r.PathParam("channel")
+	if len(chstr) != len(utils.EmptyHash.String()) {
+		rest.Error(w, "argument error", http.StatusBadRequest)
+		return
+	}
+	chAddr := common.HexToHash(chstr)
+	req := &SettleData{}
+	err := r.DecodeJsonPayload(req)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Op != OpPrepareSettle && req.Op != OpCancelPrepare {
+		rest.Error(w, fmt.Sprintf("unkown operation %s", req.Op), http.StatusBadRequest)
+		return
+	}
+	c, err := RaidenAPI.GetChannel(chAddr)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if req.Op == OpPrepareSettle && c.State != channeltype.StateOpened {
+		rest.Error(w, fmt.Sprintf("channel is %s, only opened channel can be prepared for settle", c.State), http.StatusBadRequest)
+		return
+	}
+	if req.Op == OpCancelPrepare && c.State != channeltype.StatePrepareForCooperativeSettle {
+		rest.Error(w, fmt.Sprintf("channel is %s, it's not prepared for settle", c.State), http.StatusBadRequest)
+		return
+	}
+	if req.Op == OpPrepareSettle {
+		c, err = RaidenAPI.PrepareForCooperativeSettle(c.TokenAddress(), c.PartnerAddress())
+	} else {
+		c, err = RaidenAPI.CancelPrepareForCooperativeSettle(c.TokenAddress(), c.PartnerAddress())
+	}
+	if err != nil {
+		log.Error(fmt.Sprintf("%s err %s", req.Op, err))
+		rest.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	d := &ChannelData{
+		ChannelAddress:      c.ChannelIdentifier.ChannelIdentifier.String(),
+		OpenBlockNumber:     c.ChannelIdentifier.OpenBlockNumber,
+		PartnerAddrses:      c.PartnerAddress().String(),
+		Balance:             c.OurBalance(),
+		PartnerBalance:      c.PartnerBalance(),
+		State:               c.State,
+		StateString:         c.State.String(),
+		PrepareState:        ChannelPrepareState(c.State),
 		SettleTimeout:       c.SettleTimeout,
 		TokenAddress:        c.TokenAddress().String(),
 		LockedAmount:        c.OurAmountLocked(),
@@ -0,0 +1,104 @@
+package v1
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/SmartMeshFoundation/SmartRaiden/params"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//ConnectionData is the request of connecting to a token network
+type ConnectionData struct {
+	Funds                *big.Int `json:"funds"`
+	InitialChannelTarget int      `json:"initial_channel_target"`
+	JoinableFundsTarget  float64  `json:"joinable_funds_target"`
+}
+
+//LeaveData is the request of leaving a token network
+type LeaveData struct {
+	OnlyReceivingChannels bool `json:"only_receiving_channels"`
+}
+
+/*
+GetConnections query details of previously joined token networks
+*/
+func GetConnections(w rest.ResponseWriter, r *rest.Request) {
+	infos, err := RaidenAPI.GetConnectionsInfo()
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	err = w.WriteJson(infos)
+	if err != nil {
+		log.Warn(fmt.Sprintf("writejson err %s", err))
+	}
+}
+
+/*
+ConnectTokenNetwork automatically join a token network,
+return when all channels have been opened and deposited.
+*/
+func ConnectTokenNetwork(w rest.ResponseWriter, r *rest.Request) {
+	token := r.PathParam("token")
+	tokenAddr := common.HexToAddress(token)
+	if tokenAddr == utils.EmptyAddress {
+		rest.Error(w, "argument error", http.StatusBadRequest)
+		return
+	}
+	req := &ConnectionData{
+		InitialChannelTarget: params.DefaultInitialChannelTarget,
+		JoinableFundsTarget:  params.DefaultJoinableFundsTarget,
+	}
+	err := r.DecodeJsonPayload(req)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = RaidenAPI.ConnectTokenNetwork(tokenAddr, req.Funds, req.InitialChannelTarget, req.JoinableFundsTarget)
+	if err != nil {
+		log.Error(fmt.Sprintf("ConnectTokenNetwork %s err %s", tokenAddr.String(), err))
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+/*
+LeaveTokenNetwork cooperative settle or close channels of this token network.
+returns addresses of the channels left, or channels failed to be left in the error.
+*/
+func LeaveTokenNetwork(w rest.ResponseWriter, r *rest.Request) {
+	token := r.PathParam("token")
+	tokenAddr := common.HexToAddress(token)
+	if tokenAddr == utils.EmptyAddress {
+		rest.Error(w, "argument error", http.StatusBadRequest)
+		return
+	}
+	req := &LeaveData{}
+	if r.ContentLength > 0 {
+		err := r.DecodeJsonPayload(req)
+		if err != nil {
+			rest.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	chs, err := RaidenAPI.LeaveTokenNetwork(tokenAddr, req.OnlyReceivingChannels)
+	if err != nil {
+		log.Error(fmt.Sprintf("LeaveTokenNetwork %s err %s", tokenAddr.String(), err))
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	addrs := []string{}
+	for _, c := range chs {
+		addrs = append(addrs, c.String())
+	}
+	err = w.WriteJson(addrs)
+	if err != nil {
+		log.Warn(fmt.Sprintf("writejson err %s", err))
+	}
+}
@@ -12,6 +12,11 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 )
 
+//RegisterTokenData is the response of registering a token
+type RegisterTokenData struct {
+	ChannelManagerAddress string `json:"channel_manager_address"`
+}
+
 /*
 RegisterToken register a new token to the raiden network.
 this address must be a valid ERC20 token
@@ -20,14 +25,11 @@ func RegisterToken(w rest.ResponseWriter, r *rest.Request) {
 	token := r.PathParam("token")
 	tokenAddr := common.HexToAddress(token)
 	mgr, err := RaidenAPI.RegisterToken(tokenAddr)
-	type Ret struct {
-		ChannelManagerAddress string `json:"channel_manager_address"`
-	}
 	if err != nil {
 		log.Error(fmt.Sprintf("RegisterToken %s err:%s", tokenAddr.String(), err))
 		rest.Error(w, err.Error(), http.StatusConflict)
 	} else {
-		ret := &Ret{ChannelManagerAddress: mgr.String()}
+		ret := &RegisterTokenData{ChannelManagerAddress: mgr.String()}
 		err = w.WriteJson(ret)
 		if err != nil {
 			log.Warn(fmt.Sprintf("writejson err %s", err))
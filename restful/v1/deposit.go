@@ -0,0 +1,62 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+/*
+GetDepositPolicy returns the auto deposit policy of this node
+*/
+func GetDepositPolicy(w rest.ResponseWriter, r *rest.Request) {
+	dp, err := RaidenAPI.GetDepositPolicy()
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	err = w.WriteJson(dp)
+	if err != nil {
+		log.Warn(fmt.Sprintf("writejson err %s", err))
+	}
+}
+
+/*
+SetDepositPolicy replace the auto deposit policy without restart
+*/
+func SetDepositPolicy(w rest.ResponseWriter, r *rest.Request) {
+	dp := &models.DepositPolicy{}
+	err := r.DecodeJsonPayload(dp)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = RaidenAPI.SetDepositPolicy(dp)
+	if err != nil {
+		log.Error(fmt.Sprintf("SetDepositPolicy err %s", err))
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = w.WriteJson(dp)
+	if err != nil {
+		log.Warn(fmt.Sprintf("writejson err %s", err))
+	}
+}
+
+/*
+GetAutoDeposits returns deposits triggered by the deposit policy
+*/
+func GetAutoDeposits(w rest.ResponseWriter, r *rest.Request) {
+	ds, err := RaidenAPI.GetAutoDeposits()
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	err = w.WriteJson(ds)
+	if err != nil {
+		log.Warn(fmt.Sprintf("writejson err %s", err))
+	}
+}
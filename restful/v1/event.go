@@ -110,3 +110,20 @@ func EventChannels(w rest.ResponseWriter, r *rest.Request) {
 		log.Warn(fmt.Sprintf("writejson err %s", err))
 	}
 }
+
+/*
+EventInternal returns all events and channel state transitions recorded by this node
+*/
+func EventInternal(w rest.ResponseWriter, r *rest.Request) {
+	fromBlock, toBlock := getFromTo(r)
+	events, err := RaidenAPI.GetInternalEvents(fromBlock, toBlock)
+	if err != nil {
+		log.Error(err.Error())
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	err = w.WriteJson(events)
+	if err != nil {
+		log.Warn(fmt.Sprintf("writejson err %s", err))
+	}
+}
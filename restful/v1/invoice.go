@@ -0,0 +1,244 @@
+package v1
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/encoding"
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/SmartMeshFoundation/SmartRaiden/params"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//InvoiceData is the request of creating an invoice
+type InvoiceData struct {
+	Token          string   `json:"token_address"`
+	Amount         *big.Int `json:"amount"`
+	Expiry         int64    `json:"expiry"` //seconds the invoice can be paid within
+	Memo           string   `json:"memo"`
+	Hold           bool     `json:"hold"`             //payment is held until accepted or rejected
+	LockSecretHash string   `json:"lock_secret_hash"` //hold invoice only, the secret is kept by the application
+}
+
+//AcceptInvoiceData is the request of accepting a held payment
+type AcceptInvoiceData struct {
+	Secret string `json:"secret"` //can be empty if the secret is kept by this node
+}
+
+//PayInvoiceData is the request of paying an invoice
+type PayInvoiceData struct {
+	URI string   `json:"uri"`
+	Fee *big.Int `json:"fee"`
+}
+
+//DecodedInvoice is an invoice created by other node
+type DecodedInvoice struct {
+	Token          common.Address `json:"token_address"`
+	Amount         *big.Int       `json:"amount"`
+	Expiration     int64          `json:"expiration"`
+	LockSecretHash common.Hash    `json:"lock_secret_hash"`
+	Payee          common.Address `json:"payee"`
+	Memo           string         `json:"memo,omitempty"`
+}
+
+//NewDecodedInvoice returns the json view of inv
+func NewDecodedInvoice(inv *encoding.Invoice) *DecodedInvoice {
+	return &DecodedInvoice{
+		Token:          inv.Token,
+		Amount:         inv.Amount,
+		Expiration:     inv.Expiration,
+		LockSecretHash: inv.LockSecretHash,
+		Payee:          inv.Payee,
+		Memo:           inv.Memo,
+	}
+}
+
+/*
+CreateInvoice is the api of POST /api/1/invoices
+*/
+func CreateInvoice(w rest.ResponseWriter, r *rest.Request) {
+	req := &InvoiceData{}
+	err := r.DecodeJsonPayload(req)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !common.IsHexAddress(req.Token) {
+		rest.Error(w, fmt.Sprintf("invalid token %s", req.Token), http.StatusBadRequest)
+		return
+	}
+	var lockSecretHash common.Hash
+	if req.LockSecretHash != "" {
+		if !req.Hold {
+			rest.Error(w, "lock_secret_hash can only be given for hold invoice", http.StatusBadRequest)
+			return
+		}
+		b := common.FromHex(req.LockSecretHash)
+		if len(b) != common.HashLength {
+			rest.Error(w, fmt.Sprintf("invalid lock_secret_hash %s", req.LockSecretHash), http.StatusBadRequest)
+			return
+		}
+		lockSecretHash = common.BytesToHash(b)
+	}
+	expiry := time.Duration(req.Expiry) * time.Second
+	var inv *models.Invoice
+	if req.Hold {
+		inv, err = RaidenAPI.CreateHoldInvoice(common.HexToAddress(req.Token), req.Amount, expiry, req.Memo, lockSecretHash)
+	} else {
+		inv, err = RaidenAPI.CreateInvoice(common.HexToAddress(req.Token), req.Amount, expiry, req.Memo)
+	}
+	if err != nil {
+		log.Error(fmt.Sprintf("CreateInvoice err %s", err))
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	err = w.WriteJson(inv)
+	if err != nil {
+		log.Warn(fmt.Sprintf("writejson err %s", err))
+	}
+}
+
+/*
+GetInvoices returns all invoices created by this node
+*/
+func GetInvoices(w rest.ResponseWriter, r *rest.Request) {
+	invs, err := RaidenAPI.GetInvoices()
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	err = w.WriteJson(invs)
+	if err != nil {
+		log.Warn(fmt.Sprintf("writejson err %s", err))
+	}
+}
+
+/*
+GetInvoice is the api of GET /api/1/invoices/:lockSecretHash
+*/
+func GetInvoice(w rest.ResponseWriter, r *rest.Request) {
+	inv, err := RaidenAPI.GetInvoice(common.HexToHash(r.PathParam("lockSecretHash")))
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	err = w.WriteJson(inv)
+	if err != nil {
+		log.Warn(fmt.Sprintf("writejson err %s", err))
+	}
+}
+
+/*
+AcceptInvoice is the api of POST /api/1/invoices/:lockSecretHash/accept,
+the secret is revealed and the held payment will be received.
+*/
+func AcceptInvoice(w rest.ResponseWriter, r *rest.Request) {
+	lockSecretHash := common.HexToHash(r.PathParam("lockSecretHash"))
+	if _, err := RaidenAPI.GetInvoice(lockSecretHash); err != nil {
+		rest.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	req := &AcceptInvoiceData{}
+	if r.ContentLength > 0 {
+		err := r.DecodeJsonPayload(req)
+		if err != nil {
+			rest.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	var secret common.Hash
+	if req.Secret != "" {
+		b := common.FromHex(req.Secret)
+		if len(b) != common.HashLength || utils.Sha3(b) != lockSecretHash {
+			rest.Error(w, "secret doesn't match lock secret hash", http.StatusBadRequest)
+			return
+		}
+		secret = common.BytesToHash(b)
+	}
+	err := RaidenAPI.AcceptHeldInvoice(lockSecretHash, secret)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	inv, err := RaidenAPI.GetInvoice(lockSecretHash)
+	if err == nil {
+		err = w.WriteJson(inv)
+	}
+	if err != nil {
+		log.Warn(fmt.Sprintf("writejson err %s", err))
+	}
+}
+
+/*
+RejectInvoice is the api of POST /api/1/invoices/:lockSecretHash/reject,
+the held payment is given back to the payer and the invoice is canceled.
+*/
+func RejectInvoice(w rest.ResponseWriter, r *rest.Request) {
+	lockSecretHash := common.HexToHash(r.PathParam("lockSecretHash"))
+	if _, err := RaidenAPI.GetInvoice(lockSecretHash); err != nil {
+		rest.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	err := RaidenAPI.RejectHeldInvoice(lockSecretHash)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	inv, err := RaidenAPI.GetInvoice(lockSecretHash)
+	if err == nil {
+		err = w.WriteJson(inv)
+	}
+	if err != nil {
+		log.Warn(fmt.Sprintf("writejson err %s", err))
+	}
+}
+
+/*
+DecodeInvoice is the api of GET /api/1/decodeinvoice?uri=smartraiden:...
+*/
+func DecodeInvoice(w rest.ResponseWriter, r *rest.Request) {
+	inv, err := RaidenAPI.DecodeInvoice(r.URL.Query().Get("uri"))
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = w.WriteJson(NewDecodedInvoice(inv))
+	if err != nil {
+		log.Warn(fmt.Sprintf("writejson err %s", err))
+	}
+}
+
+/*
+PayInvoice is the api of POST /api/1/payinvoice, it returns when the payment succeeded or failed.
+*/
+func PayInvoice(w rest.ResponseWriter, r *rest.Request) {
+	req := &PayInvoiceData{}
+	err := r.DecodeJsonPayload(req)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Fee != nil && req.Fee.Sign() < 0 {
+		rest.Error(w, "fee should not be negative", http.StatusBadRequest)
+		return
+	}
+	if _, err = RaidenAPI.DecodeInvoice(req.URI); err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	inv, err := RaidenAPI.PayInvoice(req.URI, req.Fee, params.MaxRequestTimeout)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	err = w.WriteJson(NewDecodedInvoice(inv))
+	if err != nil {
+		log.Warn(fmt.Sprintf("writejson err %s", err))
+	}
+}
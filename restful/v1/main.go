@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"net"
 	"net/http"
 
 	"fmt"
@@ -27,27 +28,67 @@ var Config *params.Config
 Start the restful server
 */
 func Start() {
+	handler, err := makeHandler()
+	if err != nil {
+		log.Crit(fmt.Sprintf("maker router :%s", err))
+	}
+	listen := fmt.Sprintf("%s:%d", Config.APIHost, Config.APIPort)
+	l, err := net.Listen("tcp", listen)
+	if err != nil {
+		log.Crit(fmt.Sprintf("listen %s err %s", listen, err))
+	}
+	log.Crit(fmt.Sprintf("listen and serve :%s", serve(l, handler)))
+}
 
+//serve api on l, https is used when cert file is configured
+func serve(l net.Listener, handler http.Handler) error {
+	if Config.APITLSCertFile != "" {
+		return http.ServeTLS(l, handler, Config.APITLSCertFile, Config.APITLSKeyFile)
+	}
+	return http.Serve(l, handler)
+}
+
+//makeHandler makes the api with authentication and routes enabled by Config
+func makeHandler() (http.Handler, error) {
 	api := rest.NewApi()
 	api.Use(rest.DefaultDevStack...)
-	router, err := rest.MakeRouter(
+	if Config.APIToken != "" {
+		api.Use(&AuthMiddleware{
+			OperatorToken: Config.APIToken,
+			ReadOnlyToken: Config.APIReadOnlyToken,
+		})
+	} else if Config.APIHost != "127.0.0.1" && Config.APIHost != "localhost" {
+		log.Warn(fmt.Sprintf("api listens on %s without authentication", Config.APIHost))
+	}
+	routes := []*rest.Route{
 		rest.Get("/api/1/address", Address),
 		rest.Get("/api/1/tokens", Tokens),
 		rest.Get("/api/1/tokens/:token/partners", TokenPartners),
 		rest.Put("/api/1/tokens/:token", RegisterToken),
+		/*
+			connections
+		*/
+		rest.Get("/api/1/connections", GetConnections),
+		rest.Put("/api/1/connections/:token", ConnectTokenNetwork),
+		rest.Delete("/api/1/connections/:token", LeaveTokenNetwork),
 		/*
 			transfer
 		*/
 		rest.Put("/api/1/token_swaps/:target/:id", TokenSwap),
 		rest.Post("/api/1/transfers/:token/:target", Transfers),
+		rest.Get("/api/1/transfer_status/:lockSecretHash", GetTransferStatus),
+		rest.Delete("/api/1/transfer_status/:lockSecretHash", CancelTransfer),
+		rest.Get("/api/1/path/:token/:target", FindPath),
+		rest.Post("/api/1/rebalance/:token", Rebalance),
 		rest.Get("/api/1/querysenttransfer", GetSentTransfers),
 		rest.Get("/api/1/queryreceivedtransfer", GetReceivedTransfers),
-		/*
-			test
-		*/
-		rest.Get("/api/1/stop", Stop),
-		rest.Get("/api/1/switch/:mesh", SwitchNetwork),
-		rest.Post("/api/1/updatenodes", UpdateMeshNetworkNodes),
+		rest.Post("/api/1/invoices", CreateInvoice),
+		rest.Get("/api/1/invoices", GetInvoices),
+		rest.Get("/api/1/invoices/:lockSecretHash", GetInvoice),
+		rest.Post("/api/1/invoices/:lockSecretHash/accept", AcceptInvoice),
+		rest.Post("/api/1/invoices/:lockSecretHash/reject", RejectInvoice),
+		rest.Get("/api/1/decodeinvoice", DecodeInvoice),
+		rest.Post("/api/1/payinvoice", PayInvoice),
 		/*
 			channels
 		*/
@@ -71,25 +112,53 @@ func Start() {
 			3. cancel prepare:
 			{"op": "cancelprepare"}
 		*/
-		rest.Put("/api/1/settle/:channel", nil),
+		rest.Put("/api/1/settle/:channel", settle),
+		/*
+			fee policy
+		*/
+		rest.Get("/api/1/fee_policy", GetFeePolicy),
+		rest.Put("/api/1/fee_policy", SetFeePolicy),
+		/*
+			auto deposit
+		*/
+		rest.Get("/api/1/deposit_policy", GetDepositPolicy),
+		rest.Put("/api/1/deposit_policy", SetDepositPolicy),
+		rest.Get("/api/1/auto_deposits", GetAutoDeposits),
 		/*
 			events
 		*/
+		rest.Get("/api/1/stream", Stream),
+		rest.Get("/api/1/webhooks", GetWebhooks),
+		rest.Post("/api/1/webhooks", AddWebhook),
+		rest.Delete("/api/1/webhooks/:id", RemoveWebhook),
 		rest.Get("/api/1/events/network", EventNetwork),
 		rest.Get("/api/1/events/tokens/:token", EventTokens),
 		rest.Get("/api/1/events/channels/:channel", EventChannels),
-		/*
-			for debug only
-		*/
-		rest.Get("/api/1/debug/balance/:token/:addr", Balance),
-		rest.Get("/api/1/debug/transfer/:token/:addr/:value", TransferToken),
-		rest.Get("/api/1/debug/ethbalance/:addr", EthBalance),
-		rest.Get("/api/1/debug/ethstatus", EthereumStatus),
-	)
+		rest.Get("/api/1/events/internal", EventInternal),
+		rest.Get("/api/1/punishments", GetPunishments),
+	}
+	if Config.EnableDebugAPI {
+		log.Warn("debug api is enabled, anyone who can call api is able to stop this node and move its tokens")
+		routes = append(routes,
+			/*
+				test
+			*/
+			rest.Get("/api/1/stop", Stop),
+			rest.Get("/api/1/switch/:mesh", SwitchNetwork),
+			rest.Post("/api/1/updatenodes", UpdateMeshNetworkNodes),
+			/*
+				for debug only
+			*/
+			rest.Get("/api/1/debug/balance/:token/:addr", Balance),
+			rest.Get("/api/1/debug/transfer/:token/:addr/:value", TransferToken),
+			rest.Get("/api/1/debug/ethbalance/:addr", EthBalance),
+			rest.Get("/api/1/debug/ethstatus", EthereumStatus),
+		)
+	}
+	router, err := rest.MakeRouter(routes...)
 	if err != nil {
-		log.Crit(fmt.Sprintf("maker router :%s", err))
+		return nil, err
 	}
 	api.SetApp(router)
-	listen := fmt.Sprintf("%s:%d", Config.APIHost, Config.APIPort)
-	log.Crit(fmt.Sprintf("http listen and serve :%s", http.ListenAndServe(listen, api.MakeHandler())))
+	return api.MakeHandler(), nil
 }
@@ -0,0 +1,34 @@
+package v1
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/*
+FindPath is the api of /api/1/path/:token/:target?amount=
+returns paths and fee of a transfer before sending it.
+*/
+func FindPath(w rest.ResponseWriter, r *rest.Request) {
+	tokenAddr := common.HexToAddress(r.PathParam("token"))
+	targetAddr := common.HexToAddress(r.PathParam("target"))
+	amount, ok := new(big.Int).SetString(r.URL.Query().Get("amount"), 0)
+	if !ok || amount.Sign() <= 0 {
+		rest.Error(w, "invalid amount", http.StatusBadRequest)
+		return
+	}
+	paths, err := RaidenAPI.FindPath(tokenAddr, targetAddr, amount)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	err = w.WriteJson(paths)
+	if err != nil {
+		log.Warn(fmt.Sprintf("writejson err %s", err))
+	}
+}
@@ -0,0 +1,30 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/stretchr/testify/assert"
+)
+
+//invalid amount is refused before RaidenAPI is used
+func TestFindPathInvalidAmount(t *testing.T) {
+	api := rest.NewApi()
+	router, err := rest.MakeRouter(
+		rest.Get("/api/1/path/:token/:target", FindPath),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.SetApp(router)
+	server := httptest.NewServer(api.MakeHandler())
+	defer server.Close()
+	url := server.URL + "/api/1/path/" + utils.NewRandomAddress().String() + "/" + utils.NewRandomAddress().String()
+	for _, q := range []string{"", "?amount=", "?amount=0", "?amount=-3", "?amount=abc", "?amount=1.5"} {
+		status := doRequest(t, http.DefaultClient, http.MethodGet, url+q, "")
+		assert.Equal(t, http.StatusBadRequest, status, q)
+	}
+}
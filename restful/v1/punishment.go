@@ -0,0 +1,24 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+/*
+GetPunishments returns punishments of obsolete unlocks submitted by this node
+*/
+func GetPunishments(w rest.ResponseWriter, r *rest.Request) {
+	ps, err := RaidenAPI.GetPunishments()
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	err = w.WriteJson(ps)
+	if err != nil {
+		log.Warn(fmt.Sprintf("writejson err %s", err))
+	}
+}
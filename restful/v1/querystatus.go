@@ -8,6 +8,7 @@ import (
 	"github.com/SmartMeshFoundation/SmartRaiden/utils"
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 )
 
 type dataMap map[string]interface{}
@@ -18,6 +19,7 @@ Address is api of /api/1/address
 func Address(w rest.ResponseWriter, r *rest.Request) {
 	data := make(dataMap)
 	data["our_address"] = RaidenAPI.Raiden.NodeAddress.String()
+	data["our_public_key"] = hexutil.Encode(RaidenAPI.PublicKey())
 	err := w.WriteJson(data)
 	if err != nil {
 		log.Warn(fmt.Sprintf("writejson err %s", err))
@@ -34,7 +36,8 @@ func Tokens(w rest.ResponseWriter, r *rest.Request) {
 	}
 }
 
-type partnersData struct {
+//PartnersData is a partner of a token
+type PartnersData struct {
 	PartnerAddress string `json:"partner_address"`
 	Channel        string `json:"channel"`
 }
@@ -62,9 +65,9 @@ func TokenPartners(w rest.ResponseWriter, r *rest.Request) {
 		rest.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	var datas []*partnersData
+	var datas []*PartnersData
 	for _, c := range chs {
-		d := &partnersData{
+		d := &PartnersData{
 			PartnerAddress: c.PartnerAddress().String(),
 			Channel:        "api/1/channles/" + c.ChannelIdentifier.ChannelIdentifier.String(),
 		}
@@ -0,0 +1,55 @@
+package v1
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/SmartMeshFoundation/SmartRaiden/params"
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//RebalanceData is the request of rebalancing channels of a token, both are optional
+type RebalanceData struct {
+	Ratio  float64  `json:"ratio"`   //share of ours in each channel to move toward
+	MaxFee *big.Int `json:"max_fee"` //most fee to pay
+}
+
+/*
+Rebalance is the api of POST /api/1/rebalance/:token,
+it moves our balance between channels of token by a transfer to ourselves and waits until it finished.
+*/
+func Rebalance(w rest.ResponseWriter, r *rest.Request) {
+	token := r.PathParam("token")
+	if !common.IsHexAddress(token) {
+		rest.Error(w, "invalid token address", http.StatusBadRequest)
+		return
+	}
+	req := &RebalanceData{}
+	if r.ContentLength > 0 {
+		err := r.DecodeJsonPayload(req)
+		if err != nil {
+			rest.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Ratio < 0 || req.Ratio >= 1 {
+		rest.Error(w, "ratio should be between 0 and 1", http.StatusBadRequest)
+		return
+	}
+	if req.MaxFee != nil && req.MaxFee.Sign() < 0 {
+		rest.Error(w, "max fee should not be negative", http.StatusBadRequest)
+		return
+	}
+	rb, err := RaidenAPI.Rebalance(common.HexToAddress(token), req.Ratio, req.MaxFee, params.MaxRequestTimeout)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	err = w.WriteJson(rb)
+	if err != nil {
+		log.Warn(fmt.Sprintf("writejson err %s", err))
+	}
+}
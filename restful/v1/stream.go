@@ -0,0 +1,80 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SmartMeshFoundation/SmartRaiden"
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+//streamPingInterval keep the connection alive when there is nothing to notify
+var streamPingInterval = 30 * time.Second
+
+func writeStreamEvent(w http.ResponseWriter, e *smartraiden.NotifyEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.BlockNumber, e.Type, data)
+	return err
+}
+
+/*
+Stream is the api of /api/1/stream, it pushes notifications of this node as server-sent events.
+notifications since `from_block` are sent first, a reconnecting client resumes from header Last-Event-ID.
+*/
+func Stream(w rest.ResponseWriter, r *rest.Request) {
+	fromBlock, _ := getFromTo(r)
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		if n, err := strconv.ParseInt(id, 10, 64); err == nil {
+			fromBlock = n
+		}
+	}
+	hw, ok := w.(http.ResponseWriter)
+	flusher, ok2 := w.(http.Flusher)
+	if !ok || !ok2 {
+		rest.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	sub, history, err := RaidenAPI.Subscribe(fromBlock)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer sub.Unsubscribe()
+	hw.Header().Set("Content-Type", "text/event-stream")
+	hw.Header().Set("Cache-Control", "no-cache")
+	hw.WriteHeader(http.StatusOK)
+	for _, e := range history {
+		if err = writeStreamEvent(hw, e); err != nil {
+			log.Info(fmt.Sprintf("stream write err %s", err))
+			return
+		}
+	}
+	flusher.Flush()
+	ping := time.NewTicker(streamPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case e := <-sub.C:
+			err = writeStreamEvent(hw, e)
+		case <-ping.C:
+			_, err = fmt.Fprint(hw, ": ping\n\n")
+		case <-sub.Done():
+			//subscriber too slow, client should reconnect
+			return
+		case <-r.Context().Done():
+			return
+		}
+		if err != nil {
+			log.Info(fmt.Sprintf("stream write err %s", err))
+			return
+		}
+		flusher.Flush()
+	}
+}
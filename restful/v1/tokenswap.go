@@ -11,6 +11,15 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 )
 
+//TokenSwapData is the request of a token swap, role is maker or taker
+type TokenSwapData struct {
+	Role            string   `json:"role"`
+	SendingAmount   *big.Int `json:"sending_amount"`
+	SendingToken    string   `json:"sending_token"`
+	ReceivingAmount *big.Int `json:"receiving_amount"`
+	ReceivingToken  string   `json:"receiving_token"`
+}
+
 /*
 TokenSwap is the api of /api/1/tokenswap/:id
 :id must be a unique identifier.
@@ -25,13 +34,6 @@ func TokenSwap(w rest.ResponseWriter, r *rest.Request) {
 	       "receiving_token": "0x2a65aca4d5fc5b5c859090a6c34d164135398226"
 	   }
 	*/
-	type Req struct {
-		Role            string   `json:"role"`
-		SendingAmount   *big.Int `json:"sending_amount"`
-		SendingToken    string   `json:"sending_token"`
-		ReceivingAmount *big.Int `json:"receiving_amount"`
-		ReceivingToken  string   `json:"receiving_token"`
-	}
 	targetstr := r.PathParam("target")
 	idstr := r.PathParam("id")
 	var target common.Address
@@ -46,7 +48,7 @@ func TokenSwap(w rest.ResponseWriter, r *rest.Request) {
 		rest.Error(w, "must provide a valid id ", http.StatusBadRequest)
 		return
 	}
-	req := &Req{}
+	req := &TokenSwapData{}
 	err = r.DecodeJsonPayload(req)
 	if err != nil {
 		log.Error(err.Error())
@@ -5,22 +5,30 @@ import (
 	"math/big"
 	"net/http"
 
+	"github.com/SmartMeshFoundation/SmartRaiden"
+	"github.com/SmartMeshFoundation/SmartRaiden/encoding"
 	"github.com/SmartMeshFoundation/SmartRaiden/log"
 	"github.com/SmartMeshFoundation/SmartRaiden/params"
 	"github.com/SmartMeshFoundation/SmartRaiden/utils"
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
 //TransferData post for transfers
 type TransferData struct {
-	Initiator      string   `json:"initiator_address"`
-	Target         string   `json:"target_address"`
-	Token          string   `json:"token_address"`
-	Amount         *big.Int `json:"amount"`
-	LockSecretHash string   `json:"lock_secret_hash"`
-	Fee            *big.Int `json:"fee"`
-	IsDirect       bool     `json:"is_direct"`
+	Initiator         string   `json:"initiator_address"`
+	Target            string   `json:"target_address"`
+	Token             string   `json:"token_address"`
+	Amount            *big.Int `json:"amount"`
+	LockSecretHash    string   `json:"lock_secret_hash"`
+	Fee               *big.Int `json:"fee"`
+	IsDirect          bool     `json:"is_direct"`
+	MultiPath         bool     `json:"multi_path"`                   //split amount to several routes sharing one lock secret hash
+	IsAsync           bool     `json:"is_async"`                     //return immediately,query status by GET /api/1/transfer_status/:lockSecretHash
+	PaymentIdentifier uint64   `json:"payment_identifier,omitempty"` //carried to target,which can query received transfers by it
+	Memo              string   `json:"memo,omitempty"`
+	TargetPublicKey   string   `json:"target_public_key,omitempty"` //keysend, the secret is encrypted to target, which reveals it at once
 }
 
 /*
@@ -49,7 +57,52 @@ func Transfers(w rest.ResponseWriter, r *rest.Request) {
 		rest.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	err = RaidenAPI.Transfer(tokenAddr, req.Amount, req.Fee, targetAddr, common.HexToHash(req.LockSecretHash), params.MaxRequestTimeout, req.IsDirect)
+	if req.MultiPath && req.IsDirect {
+		rest.Error(w, "multi path transfer cannot be a direct transfer", http.StatusBadRequest)
+		return
+	}
+	if req.IsAsync && req.IsDirect {
+		rest.Error(w, "direct transfer cannot be async", http.StatusBadRequest)
+		return
+	}
+	if req.IsDirect && (req.PaymentIdentifier != 0 || req.Memo != "") {
+		rest.Error(w, "direct transfer cannot carry payment identifier or memo", http.StatusBadRequest)
+		return
+	}
+	if len(req.Memo) > encoding.MaxMemoLength {
+		rest.Error(w, fmt.Sprintf("memo is longer than %d bytes", encoding.MaxMemoLength), http.StatusBadRequest)
+		return
+	}
+	opts := &smartraiden.TransferOptions{
+		Fee:               req.Fee,
+		LockSecretHash:    common.HexToHash(req.LockSecretHash),
+		IsDirect:          req.IsDirect,
+		MultiPath:         req.MultiPath,
+		PaymentIdentifier: req.PaymentIdentifier,
+		Memo:              req.Memo,
+	}
+	if req.TargetPublicKey != "" {
+		opts.TargetPublicKey, err = encoding.ParsePublicKey(req.TargetPublicKey)
+		if err != nil {
+			rest.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if crypto.PubkeyToAddress(*opts.TargetPublicKey) != targetAddr {
+			rest.Error(w, "target_public_key doesn't match target", http.StatusBadRequest)
+			return
+		}
+		if req.IsDirect || req.MultiPath || req.LockSecretHash != "" {
+			rest.Error(w, "keysend transfer cannot be direct, multi path or use lock_secret_hash", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.IsAsync {
+		var lockSecretHash common.Hash
+		lockSecretHash, err = RaidenAPI.TransferAsync(tokenAddr, req.Amount, targetAddr, opts)
+		req.LockSecretHash = lockSecretHash.String()
+	} else {
+		err = RaidenAPI.TransferAndWait(tokenAddr, req.Amount, targetAddr, params.MaxRequestTimeout, opts)
+	}
 	if err != nil {
 		rest.Error(w, err.Error(), http.StatusConflict)
 		return
@@ -64,35 +117,68 @@ func Transfers(w rest.ResponseWriter, r *rest.Request) {
 }
 
 /*
-GetSentTransfers retuns list of sent transfer between `from_block` and `to_block`
+GetTransferStatus is the api of GET /api/1/transfer_status/:lockSecretHash
 */
-func GetSentTransfers(w rest.ResponseWriter, r *rest.Request) {
-	from, to := getFromTo(r)
-	log.Trace(fmt.Sprintf("from=%d,to=%d\n", from, to))
-	trs, err := RaidenAPI.GetSentTransfers(from, to)
+func GetTransferStatus(w rest.ResponseWriter, r *rest.Request) {
+	lockSecretHash := common.HexToHash(r.PathParam("lockSecretHash"))
+	status, err := RaidenAPI.GetTransferStatus(lockSecretHash)
 	if err != nil {
-		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
-	err = w.WriteJson(trs)
+	err = w.WriteJson(status)
 	if err != nil {
 		log.Warn(fmt.Sprintf("writejson err %s", err))
 	}
 }
 
+/*
+CancelTransfer is the api of DELETE /api/1/transfer_status/:lockSecretHash
+a transfer can be canceled only before the secret is revealed.
+*/
+func CancelTransfer(w rest.ResponseWriter, r *rest.Request) {
+	lockSecretHash := common.HexToHash(r.PathParam("lockSecretHash"))
+	err := RaidenAPI.CancelTransfer(lockSecretHash)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+/*
+GetSentTransfers retuns list of sent transfer between `from_block` and `to_block`,
+filtered by token, partner, channel and amount, paged by limit and cursor, and exported in format json, csv or jsonl.
+*/
+func GetSentTransfers(w rest.ResponseWriter, r *rest.Request) {
+	f, format, err := getTransferFilter(r)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Trace(fmt.Sprintf("filter=%s", utils.StringInterface1(f)))
+	trs, next, err := RaidenAPI.QuerySentTransfers(f)
+	if err != nil {
+		transferQueryError(w, err)
+		return
+	}
+	writeTransfers(w, format, "sent_transfers", next, sentRows(trs))
+}
+
 /*
 GetReceivedTransfers retuns list of received transfer between `from_block` and `to_block`
-it contains token swap
+it contains token swap, query is the same as GetSentTransfers, partner is the sender.
 */
 func GetReceivedTransfers(w rest.ResponseWriter, r *rest.Request) {
-	from, to := getFromTo(r)
-	trs, err := RaidenAPI.GetReceivedTransfers(from, to)
+	f, format, err := getTransferFilter(r)
 	if err != nil {
-		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	err = w.WriteJson(trs)
+	trs, next, err := RaidenAPI.QueryReceivedTransfers(f)
 	if err != nil {
-		log.Warn(fmt.Sprintf("writejson err %s", err))
+		transferQueryError(w, err)
+		return
 	}
+	writeTransfers(w, format, "received_transfers", next, receivedRows(trs))
 }
@@ -0,0 +1,187 @@
+package v1
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//export formats of transfer history
+const (
+	FormatJSON  = "json"
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+//HeaderNextCursor is the response header with cursor of the next page of transfer history
+const HeaderNextCursor = "X-Next-Cursor"
+
+/*
+getTransferFilter parse query of transfer history:
+from_block,to_block,token,partner,channel,payment_identifier,min_amount,max_amount,order(asc|desc),limit,cursor
+*/
+func getTransferFilter(r *rest.Request) (f *models.TransferFilter, format string, err error) {
+	f = new(models.TransferFilter)
+	f.FromBlock, f.ToBlock = getFromTo(r)
+	q := r.URL.Query()
+	parseAddress := func(name string, addr *common.Address) {
+		if s := q.Get(name); s != "" && err == nil {
+			if !common.IsHexAddress(s) {
+				err = fmt.Errorf("invalid %s %s", name, s)
+				return
+			}
+			*addr = common.HexToAddress(s)
+		}
+	}
+	parseAmount := func(name string) (v *big.Int) {
+		if s := q.Get(name); s != "" && err == nil {
+			var ok bool
+			v, ok = new(big.Int).SetString(s, 10)
+			if !ok || v.Sign() < 0 {
+				err = fmt.Errorf("invalid %s %s", name, s)
+			}
+		}
+		return
+	}
+	parseAddress("token", &f.Token)
+	parseAddress("partner", &f.Partner)
+	if s := q.Get("channel"); s != "" {
+		b := common.FromHex(s)
+		if len(b) != common.HashLength {
+			err = fmt.Errorf("invalid channel %s", s)
+		}
+		f.Channel = common.BytesToHash(b)
+	}
+	if s := q.Get("payment_identifier"); s != "" && err == nil {
+		f.PaymentIdentifier, err = strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			err = fmt.Errorf("invalid payment_identifier %s", s)
+		}
+	}
+	f.MinAmount = parseAmount("min_amount")
+	f.MaxAmount = parseAmount("max_amount")
+	if err != nil {
+		return
+	}
+	switch q.Get("order") {
+	case "", "asc":
+	case "desc":
+		f.Descending = true
+	default:
+		err = fmt.Errorf("order must be asc or desc")
+		return
+	}
+	if s := q.Get("limit"); s != "" {
+		f.Limit, err = strconv.Atoi(s)
+		if err != nil || f.Limit < 0 {
+			err = fmt.Errorf("invalid limit %s", s)
+			return
+		}
+	}
+	f.Cursor = q.Get("cursor")
+	format = q.Get("format")
+	switch format {
+	case "":
+		format = FormatJSON
+	case FormatJSON, FormatCSV, FormatJSONL:
+	default:
+		err = fmt.Errorf("format must be json, csv or jsonl")
+	}
+	return
+}
+
+//transferRows is sent or received transfers to export
+type transferRows interface {
+	Len() int
+	Columns() []string
+	Record(i int) []string
+	Item(i int) interface{}
+}
+
+type sentRows []*models.SentTransfer
+
+func (s sentRows) Len() int               { return len(s) }
+func (s sentRows) Item(i int) interface{} { return s[i] }
+func (s sentRows) Columns() []string {
+	return []string{"key", "block_number", "channel_address", "to_address", "token_address", "nonce", "amount", "payment_identifier", "memo"}
+}
+func (s sentRows) Record(i int) []string {
+	t := s[i]
+	return []string{t.Key, strconv.FormatInt(t.BlockNumber, 10), t.ChannelIdentifier.String(), t.ToAddress.String(),
+		t.TokenAddress.String(), strconv.FormatInt(t.Nonce, 10), amountString(t.Amount),
+		strconv.FormatUint(t.PaymentIdentifier, 10), t.Memo}
+}
+
+type receivedRows []*models.ReceivedTransfer
+
+func (s receivedRows) Len() int               { return len(s) }
+func (s receivedRows) Item(i int) interface{} { return s[i] }
+func (s receivedRows) Columns() []string {
+	return []string{"key", "block_number", "channel_address", "from_address", "token_address", "nonce", "amount", "payment_identifier", "memo"}
+}
+func (s receivedRows) Record(i int) []string {
+	t := s[i]
+	return []string{t.Key, strconv.FormatInt(t.BlockNumber, 10), t.ChannelIdentifier.String(), t.FromAddress.String(),
+		t.TokenAddress.String(), strconv.FormatInt(t.Nonce, 10), amountString(t.Amount),
+		strconv.FormatUint(t.PaymentIdentifier, 10), t.Memo}
+}
+
+func amountString(a *big.Int) string {
+	if a == nil {
+		return "0"
+	}
+	return a.String()
+}
+
+/*
+writeTransfers write transfers as a json array, csv with header line or json lines,
+cursor of the next page is in header X-Next-Cursor.
+*/
+func writeTransfers(w rest.ResponseWriter, format, name, next string, rows transferRows) {
+	if next != "" {
+		w.Header().Set(HeaderNextCursor, next)
+	}
+	var err error
+	switch format {
+	case FormatCSV:
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.csv", name))
+		cw := csv.NewWriter(w.(http.ResponseWriter))
+		err = cw.Write(rows.Columns())
+		for i := 0; i < rows.Len() && err == nil; i++ {
+			err = cw.Write(rows.Record(i))
+		}
+		cw.Flush()
+		if err == nil {
+			err = cw.Error()
+		}
+	case FormatJSONL:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.jsonl", name))
+		enc := json.NewEncoder(w.(http.ResponseWriter))
+		for i := 0; i < rows.Len() && err == nil; i++ {
+			err = enc.Encode(rows.Item(i))
+		}
+	default:
+		err = w.WriteJson(rows)
+	}
+	if err != nil {
+		log.Warn(fmt.Sprintf("write transfers err %s", err))
+	}
+}
+
+func transferQueryError(w rest.ResponseWriter, err error) {
+	if err == models.ErrInvalidCursor {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rest.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,71 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+//WebhookData is the request of adding a webhook, empty Events means all events
+type WebhookData struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+/*
+GetWebhooks returns all webhooks of this node
+*/
+func GetWebhooks(w rest.ResponseWriter, r *rest.Request) {
+	ws, err := RaidenAPI.GetWebhooks()
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	err = w.WriteJson(ws)
+	if err != nil {
+		log.Warn(fmt.Sprintf("writejson err %s", err))
+	}
+}
+
+/*
+AddWebhook register a url to be notified
+*/
+func AddWebhook(w rest.ResponseWriter, r *rest.Request) {
+	req := &WebhookData{}
+	err := r.DecodeJsonPayload(req)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	wh, err := RaidenAPI.AddWebhook(req.URL, req.Events)
+	if err != nil {
+		log.Error(fmt.Sprintf("AddWebhook err %s", err))
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	err = w.WriteJson(wh)
+	if err != nil {
+		log.Warn(fmt.Sprintf("writejson err %s", err))
+	}
+}
+
+/*
+RemoveWebhook delete a webhook by id
+*/
+func RemoveWebhook(w rest.ResponseWriter, r *rest.Request) {
+	id, err := strconv.Atoi(r.PathParam("id"))
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = RaidenAPI.RemoveWebhook(id)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
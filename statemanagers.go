@@ -0,0 +1,62 @@
+package smartraiden
+
+import (
+	"sort"
+	"time"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//StateManagerInfo is a transfer this node is working on, as initiator, mediator or target
+type StateManagerInfo struct {
+	Name                   string         `json:"name"`
+	LockSecretHash         common.Hash    `json:"lock_secret_hash"`
+	TokenAddress           common.Address `json:"token_address"`
+	ManagerState           string         `json:"manager_state"`
+	Finished               bool           `json:"finished"` //current state is gone, waiting for balance proofs
+	LastActive             time.Time      `json:"last_active"`
+	ChannelAddress         common.Hash    `json:"channel_address"`
+	ChannelAddressTo       common.Hash    `json:"channel_address_to"`
+	IsBalanceProofSent     bool           `json:"is_balance_proof_sent"`
+	IsBalanceProofReceived bool           `json:"is_balance_proof_received"`
+}
+
+/*
+stateManagers returns all the state managers in memory, result.Tag is []*StateManagerInfo
+*/
+func (rs *RaidenService) stateManagers() (result *utils.AsyncResult) {
+	result = utils.NewAsyncResult()
+	infos := []*StateManagerInfo{}
+	for _, mgr := range rs.Transfer2StateManager {
+		infos = append(infos, &StateManagerInfo{
+			Name:                   mgr.Name,
+			LockSecretHash:         mgr.Identifier,
+			TokenAddress:           mgr.TokenAddress,
+			ManagerState:           mgr.ManagerState,
+			Finished:               mgr.CurrentState == nil,
+			LastActive:             mgr.LastActive,
+			ChannelAddress:         mgr.ChannelAddress,
+			ChannelAddressTo:       mgr.ChannelAddressTo,
+			IsBalanceProofSent:     mgr.IsBalanceProofSent,
+			IsBalanceProofReceived: mgr.IsBalanceProofReceived,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].LastActive.After(infos[j].LastActive)
+	})
+	result.Tag = infos
+	result.Result <- nil
+	return
+}
+
+//GetStateManagers returns transfers in progress on this node, the latest active first
+func (r *RaidenAPI) GetStateManagers() (infos []*StateManagerInfo, err error) {
+	result := r.Raiden.stateManagersClient()
+	err = <-result.Result
+	if err != nil {
+		return
+	}
+	infos = result.Tag.([]*StateManagerInfo)
+	return
+}
@@ -35,6 +35,8 @@ type EventTransferSentSuccess struct {
 	Target            common.Address
 	ChannelIdentifier common.Hash
 	Token             common.Address
+	PaymentIdentifier uint64
+	Memo              string
 }
 
 /*
@@ -45,10 +47,12 @@ EventTransferSentFailed emitted by the payer when a transfer has failed.
         has failed, they may infer about lock successes and failures.
 */
 type EventTransferSentFailed struct {
-	LockSecretHash common.Hash
-	Reason         string
-	Target         common.Address //transfer's target, may be not the same as receipient
-	Token          common.Address
+	LockSecretHash    common.Hash
+	Reason            string
+	Target            common.Address //transfer's target, may be not the same as receipient
+	Token             common.Address
+	Amount            *big.Int
+	ChannelIdentifier common.Hash //channel of the last route tried, empty if no route has been tried
 }
 
 /*
@@ -64,6 +68,8 @@ type EventTransferReceivedSuccess struct {
 	Amount            *big.Int
 	Initiator         common.Address
 	ChannelIdentifier common.Hash
+	PaymentIdentifier uint64 //0 for direct transfers
+	Memo              string
 }
 
 func init() {
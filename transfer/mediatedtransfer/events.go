@@ -10,27 +10,35 @@ import (
 
 // EventSendMediatedTransfer A mediated transfer that must be sent to `node_address`.
 type EventSendMediatedTransfer struct {
-	Token          common.Address
-	Amount         *big.Int
-	LockSecretHash common.Hash
-	Initiator      common.Address
-	Target         common.Address
-	Expiration     int64
-	Receiver       common.Address
-	Fee            *big.Int // target should get amount-fee.
+	Token             common.Address
+	Amount            *big.Int
+	LockSecretHash    common.Hash
+	Initiator         common.Address
+	Target            common.Address
+	Expiration        int64
+	Receiver          common.Address
+	Fee               *big.Int // target should get amount-fee.
+	TotalAmount       *big.Int //amount of the whole multi path transfer, 0 for a normal one
+	PaymentIdentifier uint64
+	Memo              string
+	EncryptedSecret   []byte
 }
 
 //NewEventSendMediatedTransfer create EventSendMediatedTransfer
 func NewEventSendMediatedTransfer(transfer *LockedTransferState, receiver common.Address) *EventSendMediatedTransfer {
 	return &EventSendMediatedTransfer{
-		Token:          transfer.Token,
-		Amount:         new(big.Int).Set(transfer.Amount),
-		LockSecretHash: transfer.LockSecretHash,
-		Initiator:      transfer.Initiator,
-		Target:         transfer.Target,
-		Expiration:     transfer.Expiration,
-		Receiver:       receiver,
-		Fee:            transfer.Fee,
+		Token:             transfer.Token,
+		Amount:            new(big.Int).Set(transfer.Amount),
+		LockSecretHash:    transfer.LockSecretHash,
+		Initiator:         transfer.Initiator,
+		Target:            transfer.Target,
+		Expiration:        transfer.Expiration,
+		Receiver:          receiver,
+		Fee:               transfer.Fee,
+		TotalAmount:       transfer.TotalAmount,
+		PaymentIdentifier: transfer.PaymentIdentifier,
+		Memo:              transfer.Memo,
+		EncryptedSecret:   transfer.EncryptedSecret,
 	}
 }
 
@@ -175,6 +183,26 @@ type EventWithdrawSuccess struct {
 	LockSecretHash common.Hash
 }
 
+//EventInvoicePaid emitted when the target received a transfer paying its invoice
+type EventInvoicePaid struct {
+	LockSecretHash common.Hash
+	Amount         *big.Int
+	Payer          common.Address //initiator claimed by the transfer, unauthenticated
+}
+
+//EventInvoiceHeld emitted when the target holds a transfer paying its hold invoice
+type EventInvoiceHeld struct {
+	LockSecretHash common.Hash
+	Amount         *big.Int
+	Payer          common.Address //initiator claimed by the transfer, unauthenticated, don't accept a held transfer because of it
+}
+
+//EventInvoiceRejected emitted when the target disposes a transfer paying its hold invoice
+type EventInvoiceRejected struct {
+	LockSecretHash common.Hash
+	Reason         string
+}
+
 /*
 上家没有在expiration之内给我balanceproof，我也没有在链上兑现（因为没有密码）。
 必须等待上家的 RemoveExpiredHashlockTransfer, 然后移除.
@@ -193,10 +221,14 @@ func init() {
 	gob.Register(&EventSendBalanceProof{})
 	gob.Register(&EventSendSecretRequest{})
 	gob.Register(&EventSendAnnounceDisposed{})
+	gob.Register(&EventSendAnnounceDisposedResponse{})
 	gob.Register(&EventContractSendRegisterSecret{})
 	gob.Register(&EventContractSendWithdraw{})
 	gob.Register(&EventUnlockSuccess{})
 	gob.Register(&EventUnlockFailed{})
 	gob.Register(&EventWithdrawSuccess{})
 	gob.Register(&EventWithdrawFailed{})
+	gob.Register(&EventInvoicePaid{})
+	gob.Register(&EventInvoiceHeld{})
+	gob.Register(&EventInvoiceRejected{})
 }
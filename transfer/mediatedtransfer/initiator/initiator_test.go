@@ -311,8 +311,9 @@ func TestRefundTransferNoMoreRoutes(t *testing.T) {
 
 	events := sm.Dispatch(stateChange)
 	assert(t, len(events), 2)
-	_, ok := events[0].(*transfer.EventTransferSentFailed)
+	failed, ok := events[0].(*transfer.EventTransferSentFailed)
 	assert(t, ok, true)
+	assert(t, failed.ChannelIdentifier, routes[0].ChannelIdentifier)
 	assert(t, sm.CurrentState == nil, true)
 }
 func TestRefundTransferInvalidSender(t *testing.T) {
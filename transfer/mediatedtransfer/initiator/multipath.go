@@ -0,0 +1,338 @@
+package initiator
+
+import (
+	"fmt"
+
+	"math/big"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer"
+	mt "github.com/SmartMeshFoundation/SmartRaiden/transfer/mediatedtransfer"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/route"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//NameMultiPathInitiatorTransition name for state manager of a multi path transfer
+const NameMultiPathInitiatorTransition = "MultiPathInitiatorTransition"
+
+func routeTotalFee(r *route.State) *big.Int {
+	if r.TotalFee == nil {
+		return utils.BigInt0
+	}
+	return r.TotalFee
+}
+
+/*
+splitAmount split amount to routes in order, every route takes as much as it can afford.
+returns nil if all the routes together cannot afford amount, or fee of all the routes is greater than maxFee.
+maxFee nil means no limit.
+a channel is used by one part at most, two locks with the same lock secret hash cannot be in one channel.
+routes are expected not to meet before the target, see ChannelGraph.GetRoutesForSplit.
+*/
+func splitAmount(routes []*route.State, amount *big.Int, maxFee *big.Int) (amounts []*big.Int, chosen []*route.State) {
+	left := new(big.Int).Set(amount)
+	totalFee := big.NewInt(0)
+	used := make(map[common.Hash]bool)
+	for _, r := range routes {
+		if left.Sign() <= 0 {
+			break
+		}
+		if !r.CanTransfer() || used[r.ChannelIdentifier] {
+			continue
+		}
+		fee := routeTotalFee(r)
+		if maxFee != nil && new(big.Int).Add(totalFee, fee).Cmp(maxFee) > 0 {
+			continue
+		}
+		capacity := new(big.Int).Sub(r.AvailableBalance(), fee)
+		if capacity.Sign() <= 0 {
+			continue
+		}
+		if capacity.Cmp(left) > 0 {
+			capacity.Set(left)
+		}
+		used[r.ChannelIdentifier] = true
+		totalFee.Add(totalFee, fee)
+		left.Sub(left, capacity)
+		amounts = append(amounts, capacity)
+		chosen = append(chosen, r)
+	}
+	if left.Sign() > 0 {
+		return nil, nil
+	}
+	return
+}
+
+func multiPathFailed(state *mt.MultiPathInitiatorState, reason string, channelIdentifier common.Hash) transfer.Event {
+	return &transfer.EventTransferSentFailed{
+		LockSecretHash:    state.LockSecretHash,
+		Reason:            reason,
+		Target:            state.Transfer.Target,
+		Token:             state.Transfer.Token,
+		Amount:            state.Transfer.TargetAmount,
+		ChannelIdentifier: channelIdentifier,
+	}
+}
+
+func handleMultiPathInit(st *mt.ActionInitInitiatorStateChange) *transfer.TransitionResult {
+	tr := st.Tranfer
+	state := &mt.MultiPathInitiatorState{
+		OurAddress:     st.OurAddress,
+		Transfer:       tr,
+		BlockNumber:    st.BlockNumber,
+		LockSecretHash: st.LockSecretHash,
+		Secret:         st.Secret,
+		Db:             st.Db,
+	}
+	tr.TotalAmount = new(big.Int).Set(tr.TargetAmount)
+	var maxFee *big.Int
+	if tr.Fee != nil && tr.Fee.Sign() > 0 {
+		maxFee = tr.Fee
+	}
+	amounts, routes := splitAmount(st.Routes.AvailableRoutes, tr.TargetAmount, maxFee)
+	if len(amounts) == 0 {
+		return &transfer.TransitionResult{
+			NewState: nil,
+			Events: []transfer.Event{
+				multiPathFailed(state, "no routes can afford this multi path transfer", utils.EmptyHash),
+				&mt.EventRemoveStateManager{
+					Key: utils.Sha3(state.LockSecretHash[:], tr.Token[:]),
+				},
+			},
+		}
+	}
+	var events []transfer.Event
+	for i, r := range routes {
+		part := &mt.InitiatorState{
+			OurAddress: st.OurAddress,
+			Transfer: &mt.LockedTransferState{
+				TargetAmount:      amounts[i],
+				Amount:            amounts[i],
+				Token:             tr.Token,
+				Initiator:         tr.Initiator,
+				Target:            tr.Target,
+				Expiration:        tr.Expiration,
+				LockSecretHash:    tr.LockSecretHash,
+				Secret:            tr.Secret,
+				Fee:               utils.BigInt0,
+				TotalAmount:       tr.TotalAmount,
+				PaymentIdentifier: tr.PaymentIdentifier,
+				Memo:              tr.Memo,
+			},
+			Routes:         route.NewRoutesState([]*route.State{r}),
+			BlockNumber:    st.BlockNumber,
+			LockSecretHash: st.LockSecretHash,
+			Secret:         st.Secret,
+			Db:             st.Db,
+		}
+		state.Parts = append(state.Parts, part)
+		events = append(events, mergePartResult(state, i, tryNewRoute(part))...)
+	}
+	return finishIfAllPartsDone(state, events)
+}
+
+/*
+mergePartResult update part i with it, and filter events of this part.
+RemoveStateManager of a part means this part has finished,
+and a failed part means the whole transfer failed, this is reported only once.
+success of a part is kept, the whole transfer succeeds when all parts have.
+*/
+func mergePartResult(state *mt.MultiPathInitiatorState, i int, it *transfer.TransitionResult) (events []transfer.Event) {
+	if it.NewState == nil {
+		state.Parts[i] = nil
+	} else {
+		state.Parts[i] = it.NewState.(*mt.InitiatorState)
+	}
+	for _, ev := range it.Events {
+		switch e2 := ev.(type) {
+		case *mt.EventRemoveStateManager:
+			state.Parts[i] = nil
+		case *transfer.EventTransferSentFailed:
+			if !state.Failed {
+				state.Failed = true
+				events = append(events, multiPathFailed(state, fmt.Sprintf("part %d failed: %s", i, e2.Reason), e2.ChannelIdentifier))
+			}
+		case *transfer.EventTransferSentSuccess:
+			state.Sent = append(state.Sent, e2)
+		default:
+			events = append(events, ev)
+		}
+	}
+	return
+}
+
+/*
+finishIfAllPartsDone remove this state manager when all parts have finished,
+the transfer succeeds only if every part has succeeded, then success of every part is reported,
+each with its own channel and amount.
+*/
+func finishIfAllPartsDone(state *mt.MultiPathInitiatorState, events []transfer.Event) *transfer.TransitionResult {
+	for _, p := range state.Parts {
+		if p != nil {
+			return &transfer.TransitionResult{
+				NewState: state,
+				Events:   events,
+			}
+		}
+	}
+	if !state.Failed {
+		if len(state.Sent) == len(state.Parts) {
+			for _, e := range state.Sent {
+				events = append(events, e)
+			}
+		} else {
+			state.Failed = true
+			events = append(events, multiPathFailed(state, "some parts finished without success", utils.EmptyHash))
+		}
+	}
+	events = append(events, &mt.EventRemoveStateManager{
+		Key: utils.Sha3(state.LockSecretHash[:], state.Transfer.Token[:]),
+	})
+	return &transfer.TransitionResult{
+		NewState: nil,
+		Events:   events,
+	}
+}
+
+/*
+forEachPart apply fn to all the unfinished parts
+*/
+func forEachPart(state *mt.MultiPathInitiatorState, fn func(part *mt.InitiatorState) *transfer.TransitionResult) *transfer.TransitionResult {
+	var events []transfer.Event
+	for i, p := range state.Parts {
+		if p == nil {
+			continue
+		}
+		events = append(events, mergePartResult(state, i, fn(p))...)
+	}
+	return finishIfAllPartsDone(state, events)
+}
+
+/*
+target requests the secret only when locks of all parts have arrived,
+so the amount must be the whole amount.
+*/
+func handleMultiPathSecretRequest(state *mt.MultiPathInitiatorState, st *mt.ReceiveSecretRequestStateChange) *transfer.TransitionResult {
+	tr := state.Transfer
+	if st.Sender != tr.Target || st.LockSecretHash != tr.LockSecretHash {
+		return &transfer.TransitionResult{
+			NewState: state,
+			Events:   nil,
+		}
+	}
+	allSent := true
+	for _, p := range state.Parts {
+		if p == nil || p.Route == nil {
+			allSent = false
+			break
+		}
+	}
+	if !allSent || state.Failed || st.Amount.Cmp(tr.TargetAmount) != 0 {
+		log.Warn(fmt.Sprintf("multi path transfer %s receive invalid secret request amount=%s, allSent=%v,failed=%v",
+			utils.HPex(tr.LockSecretHash), st.Amount, allSent, state.Failed))
+		return forEachPart(state, cancelCurrentRoute)
+	}
+	revealSecret := &mt.EventSendRevealSecret{
+		LockSecretHash: tr.LockSecretHash,
+		Secret:         tr.Secret,
+		Token:          tr.Token,
+		Receiver:       tr.Target,
+		Sender:         state.OurAddress,
+	}
+	state.RevealSecret = revealSecret
+	for _, p := range state.Parts {
+		p.RevealSecret = revealSecret
+	}
+	return &transfer.TransitionResult{
+		NewState: state,
+		Events:   []transfer.Event{revealSecret},
+	}
+}
+
+/*
+once failed, secret will never be revealed,
+parts still in flight can be forgotten after their locks expired.
+*/
+func handleMultiPathBlock(state *mt.MultiPathInitiatorState, st *transfer.BlockStateChange) *transfer.TransitionResult {
+	if state.BlockNumber < st.BlockNumber {
+		state.BlockNumber = st.BlockNumber
+	}
+	return forEachPart(state, func(part *mt.InitiatorState) *transfer.TransitionResult {
+		it := handleBlock(part, st)
+		if state.Failed && part.BlockNumber > part.Transfer.Expiration {
+			it.NewState = nil
+		}
+		return it
+	})
+}
+
+/*
+MultiPathStateTransition is State machine for a node starting a multi path transfer.
+every part works like a normal initiator, except that only the whole transfer can reveal the secret.
+*/
+func MultiPathStateTransition(originalState transfer.State, st transfer.StateChange) *transfer.TransitionResult {
+	it := &transfer.TransitionResult{
+		NewState: originalState,
+		Events:   nil,
+	}
+	state, ok := originalState.(*mt.MultiPathInitiatorState)
+	if !ok {
+		if originalState != nil {
+			panic("MultiPathInitiatorState StateTransition get type error")
+		}
+		state = nil //originalState is nil
+	}
+	if state == nil {
+		staii, ok := st.(*mt.ActionInitInitiatorStateChange)
+		if ok {
+			return handleMultiPathInit(staii)
+		}
+		log.Warn(fmt.Sprintf("originalState,statechange should not be here originalState=\n%s\n,statechange=\n%s",
+			utils.StringInterface1(originalState), utils.StringInterface1(st)))
+		return it
+	}
+	switch st2 := st.(type) {
+	case *transfer.BlockStateChange:
+		it = handleMultiPathBlock(state, st2)
+	case *mt.ReceiveSecretRevealStateChange:
+		it = forEachPart(state, func(part *mt.InitiatorState) *transfer.TransitionResult {
+			return handleSecretReveal(part, st2)
+		})
+	case *mt.ContractSecretRevealOnChainStateChange:
+		it = forEachPart(state, func(part *mt.InitiatorState) *transfer.TransitionResult {
+			return handleSecretRevealOnChain(part, st2)
+		})
+	case *mt.ReceiveSecretRequestStateChange:
+		if state.RevealSecret == nil {
+			it = handleMultiPathSecretRequest(state, st2)
+		} else {
+			log.Warn(fmt.Sprintf("recevie secret request but initiator have already sent reveal secret"))
+		}
+	case *mt.ReceiveAnnounceDisposedStateChange:
+		if state.RevealSecret == nil {
+			it = forEachPart(state, func(part *mt.InitiatorState) *transfer.TransitionResult {
+				return handleRefund(part, st2)
+			})
+		} else {
+			log.Warn(fmt.Sprintf("secret already revealed ,but initiator recevied announce disposed %s", utils.StringInterface(st, 3)))
+		}
+	case *mt.ActionCancelRouteStateChange:
+		if state.RevealSecret == nil {
+			it = forEachPart(state, func(part *mt.InitiatorState) *transfer.TransitionResult {
+				return handleCancelRoute(part, st2)
+			})
+		} else {
+			panic(fmt.Sprintf("secret already revealed,route cannot canceled"))
+		}
+	case *transfer.ActionCancelTransferStateChange:
+		if state.RevealSecret == nil {
+			it = forEachPart(state, handleCancelTransfer)
+		} else {
+			panic(fmt.Sprintf("secret already revealed,transfer cannot canceled"))
+		}
+	default:
+		log.Error(fmt.Sprintf("multi path initiator received unkown state change %s", utils.StringInterface(st, 3)))
+	}
+	return it
+}
@@ -0,0 +1,118 @@
+package initiator
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mediatedtransfer"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/route"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils/utest"
+)
+
+func TestSplitAmount(t *testing.T) {
+	routes := []*route.State{
+		utest.MakeRoute(utest.HOP2, big.NewInt(10), utest.UnitSettleTimeout, utest.UnitRevealTimeout, 0, utils.NewRandomHash()),
+		utest.MakeRoute(utest.HOP3, big.NewInt(7), utest.UnitSettleTimeout, utest.UnitRevealTimeout, 0, utils.NewRandomHash()),
+		utest.MakeRoute(utest.HOP4, big.NewInt(20), utest.UnitSettleTimeout, utest.UnitRevealTimeout, 0, utils.NewRandomHash()),
+	}
+	routes[1].TotalFee = big.NewInt(2)
+	amounts, chosen := splitAmount(routes, big.NewInt(14), nil)
+	assert(t, len(amounts), 2)
+	assert(t, amounts[0], big.NewInt(10))
+	assert(t, amounts[1], big.NewInt(4))
+	assert(t, chosen[1], routes[1])
+	//fee of HOP3 is too high, use HOP4 instead
+	amounts, chosen = splitAmount(routes, big.NewInt(14), big.NewInt(1))
+	assert(t, len(amounts), 2)
+	assert(t, chosen[1], routes[2])
+	amounts, _ = splitAmount(routes, big.NewInt(100), nil)
+	assert(t, amounts == nil, true)
+	//the same channel can not be used by two parts
+	routes[1] = utest.MakeRoute(utest.HOP2, big.NewInt(7), utest.UnitSettleTimeout, utest.UnitRevealTimeout, 0, routes[0].ChannelIdentifier)
+	amounts, chosen = splitAmount(routes, big.NewInt(14), nil)
+	assert(t, len(amounts), 2)
+	assert(t, chosen[1], routes[2])
+}
+
+func TestMultiPathTransfer(t *testing.T) {
+	routes := []*route.State{
+		utest.MakeRoute(utest.HOP2, utest.UnitTransferAmount, utest.UnitSettleTimeout, utest.UnitRevealTimeout, 0, utils.NewRandomHash()),
+		utest.MakeRoute(utest.HOP3, utest.UnitTransferAmount, utest.UnitSettleTimeout, utest.UnitRevealTimeout, 0, utils.NewRandomHash()),
+	}
+	amount := big.NewInt(15)
+	initStateChange := makeInitStateChange(routes, utest.HOP1, amount, 0, utest.ADDR, utest.UnitTokenAddress)
+	it := MultiPathStateTransition(nil, initStateChange)
+	state := it.NewState.(*mediatedtransfer.MultiPathInitiatorState)
+	assert(t, len(state.Parts), 2)
+	assert(t, len(it.Events), 2)
+	for i, ev := range it.Events {
+		mtr := ev.(*mediatedtransfer.EventSendMediatedTransfer)
+		assert(t, mtr.TotalAmount, amount)
+		assert(t, mtr.Receiver, routes[i].HopNode())
+		assert(t, mtr.LockSecretHash, initStateChange.LockSecretHash)
+	}
+
+	//only part of the amount has arrived, secret must not be revealed
+	sr := &mediatedtransfer.ReceiveSecretRequestStateChange{
+		Amount:         big.NewInt(5),
+		LockSecretHash: initStateChange.LockSecretHash,
+		Sender:         utest.HOP1,
+	}
+	it = MultiPathStateTransition(state, sr)
+	assert(t, it.NewState, nil)
+	failed := 0
+	for _, ev := range it.Events {
+		if _, ok := ev.(*mediatedtransfer.EventSendRevealSecret); ok {
+			t.Error("secret should not be revealed")
+		}
+		if _, ok := ev.(*transfer.EventTransferSentFailed); ok {
+			failed++
+		}
+	}
+	assert(t, failed, 1)
+
+	initStateChange = makeInitStateChange(routes, utest.HOP1, amount, 0, utest.ADDR, utest.UnitTokenAddress)
+	state = MultiPathStateTransition(nil, initStateChange).NewState.(*mediatedtransfer.MultiPathInitiatorState)
+	sr.Amount = amount
+	sr.LockSecretHash = initStateChange.LockSecretHash
+	it = MultiPathStateTransition(state, sr)
+	assert(t, len(it.Events), 1)
+	_, ok := it.Events[0].(*mediatedtransfer.EventSendRevealSecret)
+	assert(t, ok, true)
+
+	reveal := &mediatedtransfer.ReceiveSecretRevealStateChange{
+		Secret: initStateChange.Secret,
+		Sender: utest.HOP2,
+	}
+	it = MultiPathStateTransition(state, reveal)
+	assert(t, it.NewState, state)
+	assert(t, state.Parts[0] == nil, true)
+	for _, ev := range it.Events {
+		if _, ok := ev.(*mediatedtransfer.EventRemoveStateManager); ok {
+			t.Error("state manager should not be removed until all parts finished")
+		}
+		if _, ok := ev.(*transfer.EventTransferSentSuccess); ok {
+			t.Error("transfer should not succeed until all parts succeeded")
+		}
+	}
+	reveal.Sender = utest.HOP3
+	it = MultiPathStateTransition(state, reveal)
+	assert(t, it.NewState, nil)
+	var successes []*transfer.EventTransferSentSuccess
+	for _, ev := range it.Events {
+		if e, ok := ev.(*transfer.EventTransferSentSuccess); ok {
+			successes = append(successes, e)
+		}
+	}
+	//every part reports its own channel and amount
+	assert(t, len(successes), 2)
+	assert(t, successes[0].Amount, utest.UnitTransferAmount)
+	assert(t, successes[0].ChannelIdentifier, routes[0].ChannelIdentifier)
+	assert(t, successes[1].Amount, new(big.Int).Sub(amount, utest.UnitTransferAmount))
+	assert(t, successes[1].ChannelIdentifier, routes[1].ChannelIdentifier)
+	assert(t, successes[1].LockSecretHash, initStateChange.LockSecretHash)
+	removed := it.Events[len(it.Events)-1].(*mediatedtransfer.EventRemoveStateManager)
+	assert(t, removed.Key, utils.Sha3(initStateChange.LockSecretHash[:], utest.UnitTokenAddress[:]))
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mediatedtransfer/mediator"
 	"github.com/SmartMeshFoundation/SmartRaiden/transfer/route"
 	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
 )
 
 //NameInitiatorTransition name for state manager
@@ -40,6 +41,8 @@ func userCancelTransfer(state *mt.InitiatorState) *transfer.TransitionResult {
 	if state.RevealSecret != nil {
 		panic("cannot cancel a transfer with a RevealSecret in flight")
 	}
+	lockSecretHash := state.Transfer.LockSecretHash
+	channelIdentifier := lastTriedChannel(state)
 	state.Transfer.Secret = utils.EmptyHash
 	state.Transfer.LockSecretHash = utils.EmptyHash
 	state.Message = nil
@@ -47,10 +50,12 @@ func userCancelTransfer(state *mt.InitiatorState) *transfer.TransitionResult {
 	state.SecretRequest = nil
 	state.RevealSecret = nil
 	cancel := &transfer.EventTransferSentFailed{
-		LockSecretHash: state.Transfer.LockSecretHash,
-		Reason:         "user canceled transfer",
-		Target:         state.Transfer.Target,
-		Token:          state.Transfer.Token,
+		LockSecretHash:    lockSecretHash,
+		Reason:            "user canceled transfer",
+		Target:            state.Transfer.Target,
+		Token:             state.Transfer.Token,
+		Amount:            state.Transfer.TargetAmount,
+		ChannelIdentifier: channelIdentifier,
 	}
 	return &transfer.TransitionResult{
 		NewState: nil,
@@ -58,6 +63,17 @@ func userCancelTransfer(state *mt.InitiatorState) *transfer.TransitionResult {
 	}
 }
 
+//lastTriedChannel returns the channel of current route or the last canceled one
+func lastTriedChannel(state *mt.InitiatorState) common.Hash {
+	if state.Route != nil {
+		return state.Route.ChannelIdentifier
+	}
+	if state.Routes != nil && len(state.Routes.CanceledRoutes) > 0 {
+		return state.Routes.CanceledRoutes[len(state.Routes.CanceledRoutes)-1].ChannelIdentifier
+	}
+	return utils.EmptyHash
+}
+
 func tryNewRoute(state *mt.InitiatorState) *transfer.TransitionResult {
 	if state.Route != nil {
 		panic("cannot try a new route while one is being used")
@@ -83,10 +99,12 @@ func tryNewRoute(state *mt.InitiatorState) *transfer.TransitionResult {
 			         not released.
 		*/
 		transferFailed := &transfer.EventTransferSentFailed{
-			LockSecretHash: state.Transfer.LockSecretHash,
-			Reason:         "no route available",
-			Target:         state.Transfer.Target,
-			Token:          state.Transfer.Token,
+			LockSecretHash:    state.Transfer.LockSecretHash,
+			Reason:            "no route available",
+			Target:            state.Transfer.Target,
+			Token:             state.Transfer.Token,
+			Amount:            state.Transfer.TargetAmount,
+			ChannelIdentifier: lastTriedChannel(state),
 		}
 		events := []transfer.Event{transferFailed}
 		removeManager := &mt.EventRemoveStateManager{
@@ -115,15 +133,18 @@ func tryNewRoute(state *mt.InitiatorState) *transfer.TransitionResult {
 		lockExpiration = state.Transfer.Expiration
 	}
 	tr := &mt.LockedTransferState{
-		TargetAmount:   state.Transfer.TargetAmount,
-		Amount:         new(big.Int).Add(state.Transfer.TargetAmount, tryRoute.TotalFee),
-		Token:          state.Transfer.Token,
-		Initiator:      state.Transfer.Initiator,
-		Target:         state.Transfer.Target,
-		Expiration:     lockExpiration,
-		LockSecretHash: state.LockSecretHash,
-		Secret:         state.Secret,
-		Fee:            tryRoute.TotalFee,
+		TargetAmount:      state.Transfer.TargetAmount,
+		Amount:            new(big.Int).Add(state.Transfer.TargetAmount, tryRoute.TotalFee),
+		Token:             state.Transfer.Token,
+		Initiator:         state.Transfer.Initiator,
+		Target:            state.Transfer.Target,
+		Expiration:        lockExpiration,
+		LockSecretHash:    state.LockSecretHash,
+		Secret:            state.Secret,
+		Fee:               tryRoute.TotalFee,
+		TotalAmount:       state.Transfer.TotalAmount,
+		PaymentIdentifier: state.Transfer.PaymentIdentifier,
+		Memo:              state.Transfer.Memo,
 	}
 	msg := mt.NewEventSendMediatedTransfer(tr, tryRoute.HopNode())
 	state.Transfer = tr
@@ -199,6 +220,11 @@ func handleSecretRequest(state *mt.InitiatorState, stateChange *mt.ReceiveSecret
 		stateChange.Amount.Cmp(state.Transfer.TargetAmount) == 0
 	isInvalid := stateChange.Sender == state.Transfer.Target &&
 		stateChange.LockSecretHash == state.Transfer.LockSecretHash && !isValid
+	if isValid && state.Transfer.Secret == utils.EmptyHash {
+		//paying an invoice, the target should know the secret
+		log.Warn(fmt.Sprintf("receive secret request of %s, but secret is unknown", utils.HPex(state.Transfer.LockSecretHash)))
+		isValid = false
+	}
 	if isValid {
 		/*
 		   Reveal the secret to the target node and wait for its confirmation,
@@ -271,6 +297,8 @@ func transferSuccessEvents(state *mt.InitiatorState) (events []transfer.Event) {
 		Target:            tr.Target,
 		ChannelIdentifier: state.Route.ChannelIdentifier,
 		Token:             tr.Token,
+		PaymentIdentifier: tr.PaymentIdentifier,
+		Memo:              tr.Memo,
 	}
 	unlockSuccess := &mt.EventUnlockSuccess{
 		LockSecretHash: tr.LockSecretHash,
@@ -296,6 +324,14 @@ func handleSecretReveal(state *mt.InitiatorState, st *mt.ReceiveSecretRevealStat
 			Events:   nil,
 		}
 	}
+	/*
+		paying an invoice, only the target knows the secret, we learn it from the next hop.
+	*/
+	if st.Sender == state.Route.HopNode() && state.Transfer.Secret == utils.EmptyHash &&
+		utils.Sha3(st.Secret[:]) == state.Transfer.LockSecretHash {
+		state.Transfer.Secret = st.Secret
+		state.Secret = st.Secret
+	}
 	if st.Sender == state.Route.HopNode() && st.Secret == state.Transfer.Secret {
 		/*
 					   next hop learned the secret, unlock the token locally and send the
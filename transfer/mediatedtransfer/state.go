@@ -7,7 +7,9 @@ import (
 
 	"github.com/SmartMeshFoundation/SmartRaiden/channel/channeltype"
 	"github.com/SmartMeshFoundation/SmartRaiden/encoding"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer"
 	"github.com/SmartMeshFoundation/SmartRaiden/transfer/route"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
 	"github.com/ethereum/go-ethereum/common"
 )
 
@@ -15,15 +17,24 @@ import (
 LockedTransferState is State of a transfer that is time hash locked.
 */
 type LockedTransferState struct {
-	TargetAmount   *big.Int       //amount target should recevied
-	Amount         *big.Int       // Amount of `token` being transferred.
-	Token          common.Address //Token being transferred.
-	Initiator      common.Address //Transfer initiator
-	Target         common.Address //Transfer target address.
-	Expiration     int64          //The absolute block number that the lock expires.
-	LockSecretHash common.Hash    // The hashlock.
-	Secret         common.Hash    //The secret that unlocks the lock, may be None.
-	Fee            *big.Int       // how much fee left for other hop node.
+	TargetAmount      *big.Int       //amount target should recevied
+	Amount            *big.Int       // Amount of `token` being transferred.
+	Token             common.Address //Token being transferred.
+	Initiator         common.Address //Transfer initiator
+	Target            common.Address //Transfer target address.
+	Expiration        int64          //The absolute block number that the lock expires.
+	LockSecretHash    common.Hash    // The hashlock.
+	Secret            common.Hash    //The secret that unlocks the lock, may be None.
+	Fee               *big.Int       // how much fee left for other hop node.
+	TotalAmount       *big.Int       //amount target should receive from all parts of a multi path transfer, 0 for a normal one
+	PaymentIdentifier uint64         //set by initiator for target, forwarded unchanged
+	Memo              string
+	EncryptedSecret   []byte //keysend transfer only, secret encrypted to target, forwarded unchanged
+}
+
+//IsMultiPath is this transfer a part of a multi path transfer?
+func (l *LockedTransferState) IsMultiPath() bool {
+	return l.TotalAmount != nil && l.TotalAmount.Sign() > 0
 }
 
 //AlmostEqual if two state equals?
@@ -39,17 +50,33 @@ func (l *LockedTransferState) AlmostEqual(other *LockedTransferState) bool {
 //LockedTransferFromMessage Create LockedTransferState from a MediatedTransfer message.
 func LockedTransferFromMessage(msg *encoding.MediatedTransfer, tokenAddress common.Address) *LockedTransferState {
 	return &LockedTransferState{
-		TargetAmount:   new(big.Int).Sub(msg.PaymentAmount, msg.Fee),
-		Amount:         new(big.Int).Set(msg.PaymentAmount),
-		Initiator:      msg.Initiator,
-		Target:         msg.Target,
-		Expiration:     msg.Expiration,
-		LockSecretHash: msg.LockSecretHash,
-		Fee:            msg.Fee,
-		Token:          tokenAddress,
+		TargetAmount:      new(big.Int).Sub(msg.PaymentAmount, msg.Fee),
+		Amount:            new(big.Int).Set(msg.PaymentAmount),
+		Initiator:         msg.Initiator,
+		Target:            msg.Target,
+		Expiration:        msg.Expiration,
+		LockSecretHash:    msg.LockSecretHash,
+		Fee:               msg.Fee,
+		Token:             tokenAddress,
+		TotalAmount:       msg.TotalAmount,
+		PaymentIdentifier: msg.PaymentIdentifier,
+		Memo:              msg.Memo,
+		EncryptedSecret:   msg.EncryptedSecret,
 	}
 }
 
+/*
+TargetStateManagerKey is the key of target's state manager in Transfer2StateManager.
+target has one state manager for each part of a multi path transfer, so channel is needed to tell them apart.
+a circular transfer to ourselves needs it too, otherwise the key is the same as the initiator's.
+*/
+func TargetStateManagerKey(tr *LockedTransferState, channelIdentifier common.Hash) common.Hash {
+	if tr.IsMultiPath() || tr.Initiator == tr.Target {
+		return utils.Sha3(tr.LockSecretHash[:], tr.Token[:], channelIdentifier[:])
+	}
+	return utils.Sha3(tr.LockSecretHash[:], tr.Token[:])
+}
+
 /*
 InitiatorState is State of a node initiating a mediated transfer.
 */
@@ -68,6 +95,24 @@ type InitiatorState struct {
 	Db                channeltype.Db
 }
 
+/*
+MultiPathInitiatorState is State of a node initiating a multi path transfer.
+amount is split to several parts, each part is a InitiatorState using a different route,
+all parts share the same lock secret hash, and secret is revealed only once.
+*/
+type MultiPathInitiatorState struct {
+	OurAddress     common.Address       //This node address.
+	Transfer       *LockedTransferState //the whole transfer
+	Parts          []*InitiatorState    //nil if this part has finished
+	BlockNumber    int64                //Latest known block number.
+	LockSecretHash common.Hash
+	Secret         common.Hash
+	RevealSecret   *EventSendRevealSecret
+	Failed         bool                                 //some part failed, secret must never be revealed
+	Sent           []*transfer.EventTransferSentSuccess //success of parts, reported when all parts have succeeded
+	Db             channeltype.Db
+}
+
 /*
 MediatorState is State of a node mediating a transfer.
 */
@@ -111,6 +156,9 @@ const StateRevealSecret = "reveal_secret"
 //StateBalanceProof receive balance proof
 const StateBalanceProof = "balance_proof"
 
+//StateHeld transfer pays a hold invoice, waiting for the application to accept or reject it
+const StateHeld = "held"
+
 //StateWaitingRegisterSecret wait register secret on chain
 const StateWaitingRegisterSecret = "waiting_register_secret"
 
@@ -132,6 +180,20 @@ type TargetState struct {
 	Secret       common.Hash
 	State        string // default secret_request
 	Db           channeltype.Db
+	Invoice      *InvoiceState //not nil if the transfer pays an open invoice of ours
+}
+
+/*
+InvoiceState is an open invoice of the target locked by the same lock secret hash,
+the target knows the secret and reveals it at once when the transfer is enough to pay the invoice.
+a hold invoice is held until the application accepts it with the secret or rejects it.
+*/
+type InvoiceState struct {
+	Token    common.Address
+	Amount   *big.Int
+	Secret   common.Hash //empty if the application keeps the secret of a hold invoice
+	Hold     bool
+	Canceled bool //hold invoice rejected by the application, transfers paying it are disposed at once
 }
 
 /*
@@ -225,6 +287,7 @@ func NewMediationPairState(payerRoute, payeeRoute *route.State, payerTransfer, p
 func init() {
 	gob.Register(&LockedTransferState{})
 	gob.Register(&InitiatorState{})
+	gob.Register(&MultiPathInitiatorState{})
 	gob.Register(&MediatorState{})
 	gob.Register(&TargetState{})
 	gob.Register(&MediationPairState{})
@@ -52,12 +52,15 @@ type MediatorReReceiveStateChange struct {
 
 //ActionInitTargetStateChange Initial state for a new target.
 type ActionInitTargetStateChange struct {
-	OurAddress  common.Address       //This node address.
-	FromTranfer *LockedTransferState //The received MediatedTransfer.
-	FromRoute   *route.State         //The route from which the MediatedTransfer was received.
-	BlockNumber int64
-	Message     *encoding.MediatedTransfer //the message trigger this statechange
-	Db          channeltype.Db             //get the latest channel state
+	OurAddress   common.Address       //This node address.
+	FromTranfer  *LockedTransferState //The received MediatedTransfer.
+	FromRoute    *route.State         //The route from which the MediatedTransfer was received.
+	BlockNumber  int64
+	Message      *encoding.MediatedTransfer //the message trigger this statechange
+	Db           channeltype.Db             //get the latest channel state
+	Invoice      *InvoiceState              //open invoice locked by the same lock secret hash, nil if none
+	Secret       common.Hash                //decrypted from a keysend transfer, empty if it's not
+	SecretAmount *big.Int                   //amount the initiator intends us to receive, decrypted with Secret
 }
 
 /*
@@ -70,6 +73,17 @@ type ActionCancelRouteStateChange struct {
 	LockSecretHash common.Hash
 }
 
+//ActionAcceptHeldStateChange the application accepts a transfer held by the target and reveals Secret
+type ActionAcceptHeldStateChange struct {
+	LockSecretHash common.Hash
+	Secret         common.Hash
+}
+
+//ActionRejectHeldStateChange the application rejects a transfer held by the target
+type ActionRejectHeldStateChange struct {
+	LockSecretHash common.Hash
+}
+
 //ReceiveSecretRequestStateChange A SecretRequest message received.
 type ReceiveSecretRequestStateChange struct {
 	Amount         *big.Int
@@ -282,6 +296,8 @@ func init() {
 	gob.Register(&ActionInitMediatorStateChange{})
 	gob.Register(&ActionInitTargetStateChange{})
 	gob.Register(&ActionCancelRouteStateChange{})
+	gob.Register(&ActionAcceptHeldStateChange{})
+	gob.Register(&ActionRejectHeldStateChange{})
 	gob.Register(&ReceiveSecretRequestStateChange{})
 	gob.Register(&ReceiveSecretRevealStateChange{})
 	gob.Register(&ReceiveAnnounceDisposedStateChange{})
@@ -293,4 +309,8 @@ func init() {
 	gob.Register(&ContractNewChannelStateChange{})
 	gob.Register(&ContractTokenAddedStateChange{})
 	gob.Register(&ContractBalanceProofUpdatedStateChange{})
+	gob.Register(&ContractUnlockStateChange{})
+	gob.Register(&ContractChannelWithdrawStateChange{})
+	gob.Register(&ContractCooperativeSettledStateChange{})
+	gob.Register(&ContractPunishedStateChange{})
 }
@@ -7,9 +7,14 @@ import (
 
 	"os"
 
+	"github.com/SmartMeshFoundation/SmartRaiden/channel"
+	"github.com/SmartMeshFoundation/SmartRaiden/channel/channeltype"
 	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/SmartMeshFoundation/SmartRaiden/network/rpc/contracts"
 	"github.com/SmartMeshFoundation/SmartRaiden/transfer"
 	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mediatedtransfer"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mtree"
+	"github.com/SmartMeshFoundation/SmartRaiden/transfer/route"
 	"github.com/SmartMeshFoundation/SmartRaiden/utils"
 	"github.com/SmartMeshFoundation/SmartRaiden/utils/utest"
 	"github.com/ethereum/go-ethereum/common"
@@ -124,6 +129,40 @@ func TestHandleInitTarget(t *testing.T) {
 	assert(t, ev.Receiver, initiator)
 }
 
+//keysend transfer reveals the secret at once, unless it's less than the initiator sent.
+func TestHandleInitTargetKeysend(t *testing.T) {
+	var blockNumber int64 = 1
+	var amount int64 = 10
+	var expire = int64(utest.UnitRevealTimeout) + blockNumber + 1
+	initiator := utest.HOP1
+
+	st := makeInitStateChange(utest.ADDR, amount, blockNumber, initiator, expire)
+	st.Secret = utest.UnitSecret
+	st.SecretAmount = big.NewInt(amount)
+	it := handleInitTraget(st)
+	assert(t, len(it.Events), 1)
+	ev, ok := it.Events[0].(*mediatedtransfer.EventSendRevealSecret)
+	assert(t, ok, true)
+	assert(t, ev.Secret, utest.UnitSecret)
+	assert(t, ev.Receiver, st.FromRoute.HopNode())
+
+	//mediators skimmed part of the amount
+	st = makeInitStateChange(utest.ADDR, amount-1, blockNumber, initiator, expire)
+	st.Secret = utest.UnitSecret
+	st.SecretAmount = big.NewInt(amount)
+	it = handleInitTraget(st)
+	assert(t, len(it.Events), 0)
+	state := it.NewState.(*mediatedtransfer.TargetState)
+	assert(t, state.FromTransfer.Secret, utils.EmptyHash)
+	assert(t, state.State != mediatedtransfer.StateRevealSecret, true)
+
+	//amount unknown
+	st = makeInitStateChange(utest.ADDR, amount, blockNumber, initiator, expire)
+	st.Secret = utest.UnitSecret
+	it = handleInitTraget(st)
+	assert(t, len(it.Events), 0)
+}
+
 // Init transfer must do nothing if the expiration is bad.
 func TestHandleInitTargetBadExpiration(t *testing.T) {
 	var blockNumber int64 = 1
@@ -283,3 +322,157 @@ func TestStateTransition(t *testing.T) {
 	assert(t, newstate.BlockNumber, blockNumber+1)
 
 }
+
+/*
+makeInvoiceInitStateChange a transfer locked by the lock secret hash of invoice,
+its route is built directly so it needs no chain.
+*/
+func makeInvoiceInitStateChange(amount int64, blockNumber int64, invoice *mediatedtransfer.InvoiceState) *mediatedtransfer.ActionInitTargetStateChange {
+	ch := &channel.Channel{
+		OurState:          channel.NewChannelEndState(utest.ADDR, big.NewInt(0), nil, mtree.EmptyTree),
+		PartnerState:      channel.NewChannelEndState(utest.HOP1, big.NewInt(amount), nil, mtree.EmptyTree),
+		ExternState:       &channel.ExternalState{},
+		ChannelIdentifier: contracts.ChannelUniqueID{ChannelIdentifier: utils.NewRandomHash()},
+		State:             channeltype.StateOpened,
+		RevealTimeout:     utest.UnitRevealTimeout,
+	}
+	expire := blockNumber + int64(utest.UnitRevealTimeout) + 5
+	lockSecretHash := utils.Sha3(utest.UnitSecret[:])
+	return &mediatedtransfer.ActionInitTargetStateChange{
+		OurAddress:  utest.ADDR,
+		FromRoute:   route.NewState(ch),
+		FromTranfer: utest.MakeTransfer(big.NewInt(amount), utest.HOP1, utest.ADDR, expire, utils.EmptyHash, lockSecretHash, utest.UnitTokenAddress),
+		BlockNumber: blockNumber,
+		Invoice:     invoice,
+	}
+}
+
+//the secret of an invoice is revealed only when the transfer pays it
+func TestHandleInitTargetInvoice(t *testing.T) {
+	var blockNumber int64 = 1
+	newInvoice := func() *mediatedtransfer.InvoiceState {
+		return &mediatedtransfer.InvoiceState{
+			Token:  utest.UnitTokenAddress,
+			Amount: big.NewInt(10),
+			Secret: utest.UnitSecret,
+		}
+	}
+	it := StateTransiton(nil, makeInvoiceInitStateChange(10, blockNumber, newInvoice()))
+	if assert(t, len(it.Events), 1) {
+		ev, ok := it.Events[0].(*mediatedtransfer.EventSendRevealSecret)
+		assert(t, ok, true)
+		assert(t, ev.Secret, utest.UnitSecret)
+		assert(t, ev.Receiver, utest.HOP1)
+	}
+	state := it.NewState.(*mediatedtransfer.TargetState)
+	assert(t, state.State, mediatedtransfer.StateRevealSecret)
+	assert(t, state.Invoice != nil, true)
+
+	//payment too small
+	it = StateTransiton(nil, makeInvoiceInitStateChange(9, blockNumber, newInvoice()))
+	assert(t, len(it.Events), 0)
+	state = it.NewState.(*mediatedtransfer.TargetState)
+	assert(t, state.FromTransfer.Secret, utils.EmptyHash)
+	assert(t, state.Invoice == nil, true)
+
+	//wrong token
+	inv := newInvoice()
+	inv.Token = utils.NewRandomAddress()
+	it = StateTransiton(nil, makeInvoiceInitStateChange(10, blockNumber, inv))
+	assert(t, len(it.Events), 0)
+	state = it.NewState.(*mediatedtransfer.TargetState)
+	assert(t, state.FromTransfer.Secret, utils.EmptyHash)
+
+	//canceled invoice, the lock is given back at once
+	inv = newInvoice()
+	inv.Canceled = true
+	st := makeInvoiceInitStateChange(10, blockNumber, inv)
+	it = StateTransiton(nil, st)
+	assert(t, it.NewState == nil, true)
+	assertRejected(t, it, st, "invoice canceled")
+}
+
+func assertRejected(t *testing.T, it *transfer.TransitionResult, st *mediatedtransfer.ActionInitTargetStateChange, reason string) {
+	if !assert(t, len(it.Events), 3) {
+		return
+	}
+	disposed, ok := it.Events[0].(*mediatedtransfer.EventSendAnnounceDisposed)
+	assert(t, ok, true)
+	assert(t, disposed.LockSecretHash, st.FromTranfer.LockSecretHash)
+	assert(t, disposed.Receiver, utest.HOP1)
+	rejected, ok := it.Events[1].(*mediatedtransfer.EventInvoiceRejected)
+	assert(t, ok, true)
+	assert(t, rejected.Reason, reason)
+	remove, ok := it.Events[2].(*mediatedtransfer.EventRemoveStateManager)
+	assert(t, ok, true)
+	assert(t, remove.Key, mediatedtransfer.TargetStateManagerKey(st.FromTranfer, st.FromRoute.ChannelIdentifier))
+}
+
+//a transfer paying a hold invoice waits for the application
+func TestHoldInvoice(t *testing.T) {
+	var blockNumber int64 = 1
+	newHeld := func() (*mediatedtransfer.TargetState, *mediatedtransfer.ActionInitTargetStateChange) {
+		st := makeInvoiceInitStateChange(10, blockNumber, &mediatedtransfer.InvoiceState{
+			Token:  utest.UnitTokenAddress,
+			Amount: big.NewInt(10),
+			Hold:   true,
+		})
+		it := StateTransiton(nil, st)
+		if assert(t, len(it.Events), 1) {
+			ev, ok := it.Events[0].(*mediatedtransfer.EventInvoiceHeld)
+			assert(t, ok, true)
+			assert(t, ev.LockSecretHash, st.FromTranfer.LockSecretHash)
+			assert(t, ev.Amount, big.NewInt(10))
+		}
+		state := it.NewState.(*mediatedtransfer.TargetState)
+		assert(t, state.State, mediatedtransfer.StateHeld)
+		return state, st
+	}
+
+	//accept with a wrong secret
+	state, st := newHeld()
+	it := StateTransiton(state, &mediatedtransfer.ActionAcceptHeldStateChange{
+		LockSecretHash: st.FromTranfer.LockSecretHash,
+		Secret:         utils.NewRandomHash(),
+	})
+	assert(t, len(it.Events), 0)
+	assert(t, state.State, mediatedtransfer.StateHeld)
+	assert(t, state.FromTransfer.Secret, utils.EmptyHash)
+	//then with the right one
+	it = StateTransiton(state, &mediatedtransfer.ActionAcceptHeldStateChange{
+		LockSecretHash: st.FromTranfer.LockSecretHash,
+		Secret:         utest.UnitSecret,
+	})
+	if assert(t, len(it.Events), 1) {
+		ev, ok := it.Events[0].(*mediatedtransfer.EventSendRevealSecret)
+		assert(t, ok, true)
+		assert(t, ev.Secret, utest.UnitSecret)
+	}
+	assert(t, state.State, mediatedtransfer.StateRevealSecret)
+
+	//reject
+	state, st = newHeld()
+	it = StateTransiton(state, &mediatedtransfer.ActionRejectHeldStateChange{LockSecretHash: st.FromTranfer.LockSecretHash})
+	assert(t, it.NewState == nil, true)
+	assertRejected(t, it, st, "rejected")
+	//reject a transfer not held does nothing
+	it = StateTransiton(nil, makeInvoiceInitStateChange(9, blockNumber, &mediatedtransfer.InvoiceState{
+		Token:  utest.UnitTokenAddress,
+		Amount: big.NewInt(10),
+		Hold:   true,
+	}))
+	state = it.NewState.(*mediatedtransfer.TargetState)
+	it = StateTransiton(state, &mediatedtransfer.ActionRejectHeldStateChange{LockSecretHash: state.FromTransfer.LockSecretHash})
+	assert(t, it.NewState, state)
+	assert(t, len(it.Events), 0)
+
+	//held until it's no longer safe to reveal the secret
+	state, st = newHeld()
+	unsafeBlock := st.FromTranfer.Expiration - int64(utest.UnitRevealTimeout)
+	it = StateTransiton(state, &transfer.BlockStateChange{BlockNumber: unsafeBlock - 1})
+	assert(t, it.NewState, state)
+	assert(t, len(it.Events), 0)
+	it = StateTransiton(state, &transfer.BlockStateChange{BlockNumber: unsafeBlock})
+	assert(t, it.NewState == nil, true)
+	assertRejected(t, it, st, "reveal timeout")
+}
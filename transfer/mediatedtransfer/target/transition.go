@@ -8,6 +8,7 @@ import (
 	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mediatedtransfer"
 	"github.com/SmartMeshFoundation/SmartRaiden/transfer/mediatedtransfer/mediator"
 	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
 )
 
 //NameTargetTransition name for state manager
@@ -48,11 +49,34 @@ func handleInitTraget(st *mediatedtransfer.ActionInitTargetStateChange) *transfe
 		Db:           st.Db,
 	}
 	safeToWait := mediator.IsSafeToWait(tr, route.RevealTimeout(), blockNumber)
+	if st.Invoice != nil && !tr.IsMultiPath() {
+		return handleInvoice(state, st.Invoice, safeToWait)
+	}
+	/*
+		keysend transfer, the initiator gave us the secret, reveal it to the previous hop without asking.
+		if mediators took more than the initiator intended, let the lock expire.
+	*/
+	if st.Secret != utils.EmptyHash && !tr.IsMultiPath() {
+		var events []transfer.Event
+		if st.SecretAmount == nil || tr.Amount.Cmp(st.SecretAmount) < 0 {
+			log.Warn(fmt.Sprintf("keysend transfer %s amount %s is less than %s the initiator sent, refuse to reveal secret",
+				utils.HPex(tr.LockSecretHash), tr.Amount, st.SecretAmount))
+		} else if safeToWait {
+			events = eventsForReveal(state, st.Secret)
+		}
+		return &transfer.TransitionResult{
+			NewState: state,
+			Events:   events,
+		}
+	}
 	/*
 			  if there is not enough time to safely withdraw the token on-chain
 		     silently let the transfer expire.
 	*/
-	if safeToWait {
+	/*
+		part of a multi path transfer, secret request is sent when all parts have arrived.
+	*/
+	if safeToWait && !tr.IsMultiPath() {
 		secretRequest := &mediatedtransfer.EventSendSecretRequest{
 			LockSecretHash: tr.LockSecretHash,
 			Amount:         tr.Amount,
@@ -70,6 +94,110 @@ func handleInitTraget(st *mediatedtransfer.ActionInitTargetStateChange) *transfe
 	}
 }
 
+/*
+handleInvoice the transfer is locked by the lock secret hash of our invoice,
+the initiator doesn't know the secret, so we reveal it to the previous hop if the transfer pays the invoice,
+otherwise the lock just expires.
+a transfer paying a hold invoice is held until the application accepts or rejects it.
+*/
+func handleInvoice(state *mediatedtransfer.TargetState, invoice *mediatedtransfer.InvoiceState, safeToWait bool) *transfer.TransitionResult {
+	tr := state.FromTransfer
+	var events []transfer.Event
+	if invoice.Canceled {
+		state.Invoice = invoice
+		return rejectHeld(state, "invoice canceled")
+	}
+	if !safeToWait || tr.Token != invoice.Token || tr.Amount.Cmp(invoice.Amount) < 0 {
+		log.Warn(fmt.Sprintf("transfer %s doesn't pay invoice, token=%s,amount=%s,invoice amount=%s,safeToWait=%v",
+			utils.HPex(tr.LockSecretHash), utils.APex2(tr.Token), tr.Amount, invoice.Amount, safeToWait))
+	} else if invoice.Hold {
+		state.Invoice = invoice
+		state.State = mediatedtransfer.StateHeld
+		events = append(events, &mediatedtransfer.EventInvoiceHeld{
+			LockSecretHash: tr.LockSecretHash,
+			Amount:         tr.Amount,
+			Payer:          tr.Initiator,
+		})
+	} else {
+		state.Invoice = invoice
+		events = append(events, eventsForReveal(state, invoice.Secret)...)
+	}
+	return &transfer.TransitionResult{
+		NewState: state,
+		Events:   events,
+	}
+}
+
+//eventsForReveal reveal the secret of our invoice to the previous hop
+func eventsForReveal(state *mediatedtransfer.TargetState, secret common.Hash) []transfer.Event {
+	tr := state.FromTransfer
+	state.State = mediatedtransfer.StateRevealSecret
+	tr.Secret = secret
+	return []transfer.Event{&mediatedtransfer.EventSendRevealSecret{
+		LockSecretHash: tr.LockSecretHash,
+		Secret:         tr.Secret,
+		Token:          tr.Token,
+		Receiver:       state.FromRoute.HopNode(),
+		Sender:         state.OurAddress,
+	}}
+}
+
+/*
+rejectHeld gives the lock back to the previous hop by AnnounceDisposed,
+so the payer can try another route or fail at once instead of waiting for the lock to expire.
+*/
+func rejectHeld(state *mediatedtransfer.TargetState, reason string) *transfer.TransitionResult {
+	tr := state.FromTransfer
+	log.Info(fmt.Sprintf("reject held transfer %s, reason=%s", utils.HPex(tr.LockSecretHash), reason))
+	events := []transfer.Event{
+		&mediatedtransfer.EventSendAnnounceDisposed{
+			Amount:         tr.Amount,
+			LockSecretHash: tr.LockSecretHash,
+			Expiration:     tr.Expiration,
+			Token:          tr.Token,
+			Receiver:       state.FromRoute.HopNode(),
+		},
+		&mediatedtransfer.EventInvoiceRejected{
+			LockSecretHash: tr.LockSecretHash,
+			Reason:         reason,
+		},
+		&mediatedtransfer.EventRemoveStateManager{
+			Key: mediatedtransfer.TargetStateManagerKey(tr, state.FromRoute.ChannelIdentifier),
+		},
+	}
+	return &transfer.TransitionResult{
+		NewState: nil,
+		Events:   events,
+	}
+}
+
+//handleAcceptHeld the application accepts the held transfer, reveal the secret
+func handleAcceptHeld(state *mediatedtransfer.TargetState, st *mediatedtransfer.ActionAcceptHeldStateChange) *transfer.TransitionResult {
+	var events []transfer.Event
+	if state.State != mediatedtransfer.StateHeld || utils.Sha3(st.Secret[:]) != state.FromTransfer.LockSecretHash {
+		log.Error(fmt.Sprintf("accept held transfer %s, but state=%s or secret doesn't match",
+			utils.HPex(state.FromTransfer.LockSecretHash), state.State))
+	} else {
+		events = eventsForReveal(state, st.Secret)
+	}
+	return &transfer.TransitionResult{
+		NewState: state,
+		Events:   events,
+	}
+}
+
+//handleRejectHeld the application rejects the held transfer
+func handleRejectHeld(state *mediatedtransfer.TargetState, st *mediatedtransfer.ActionRejectHeldStateChange) *transfer.TransitionResult {
+	if state.State != mediatedtransfer.StateHeld {
+		log.Error(fmt.Sprintf("reject held transfer %s, but state=%s", utils.HPex(state.FromTransfer.LockSecretHash), state.State))
+		return &transfer.TransitionResult{
+			NewState: state,
+			Events:   nil,
+		}
+	}
+	return rejectHeld(state, "rejected")
+}
+
 //handleSecretRegisteredOnChain this state manager has finished
 func handleSecretRegisteredOnChain(state *mediatedtransfer.TargetState, st *mediatedtransfer.ContractSecretRevealOnChainStateChange) (it *transfer.TransitionResult) {
 	var events []transfer.Event
@@ -82,7 +210,7 @@ func handleSecretRegisteredOnChain(state *mediatedtransfer.TargetState, st *medi
 		*/
 		state.State = mediatedtransfer.StateSecretRegistered
 		ev := &mediatedtransfer.EventRemoveStateManager{
-			Key: utils.Sha3(st.LockSecretHash[:], state.FromTransfer.Token[:]),
+			Key: mediatedtransfer.TargetStateManagerKey(state.FromTransfer, state.FromRoute.ChannelIdentifier),
 		}
 		events = append(events, ev)
 	} else {
@@ -131,7 +259,7 @@ func handleBalanceProof(state *mediatedtransfer.TargetState, st *mediatedtransfe
 	if st.NodeAddress == state.FromRoute.HopNode() && state.FromTransfer.LockSecretHash == st.LockSecretHash {
 		state.State = mediatedtransfer.StateBalanceProof
 		ev := &mediatedtransfer.EventRemoveStateManager{
-			Key: utils.Sha3(state.FromTransfer.LockSecretHash[:], state.FromTransfer.Token[:]),
+			Key: mediatedtransfer.TargetStateManagerKey(state.FromTransfer, state.FromRoute.ChannelIdentifier),
 		}
 		events = append(events, ev)
 	}
@@ -155,7 +283,12 @@ func handleBlock(state *mediatedtransfer.TargetState, st *transfer.BlockStateCha
 
 	*/
 	var events []transfer.Event
-	if state.State != mediatedtransfer.StateWaitingRegisterSecret && state.State != mediatedtransfer.StateSecretRegistered {
+	if state.State == mediatedtransfer.StateHeld {
+		//no time left to reveal the secret safely, give the lock back
+		if !mediator.IsSafeToWait(state.FromTransfer, state.FromRoute.RevealTimeout(), state.BlockNumber) {
+			return rejectHeld(state, "reveal timeout")
+		}
+	} else if state.State != mediatedtransfer.StateWaitingRegisterSecret && state.State != mediatedtransfer.StateSecretRegistered {
 		events = eventsForRegisterSecret(state)
 	}
 	it = &transfer.TransitionResult{
@@ -192,6 +325,8 @@ func clearIfFinalized(previt *transfer.TransitionResult) (it *transfer.Transitio
 			Amount:            state.FromTransfer.Amount,
 			Initiator:         state.FromTransfer.Initiator,
 			ChannelIdentifier: state.FromRoute.ChannelIdentifier,
+			PaymentIdentifier: state.FromTransfer.PaymentIdentifier,
+			Memo:              state.FromTransfer.Memo,
 		}
 		unlockSuccess := &mediatedtransfer.EventWithdrawSuccess{
 			LockSecretHash: state.FromTransfer.LockSecretHash,
@@ -200,6 +335,13 @@ func clearIfFinalized(previt *transfer.TransitionResult) (it *transfer.Transitio
 			NewState: nil,
 			Events:   append(it.Events, transferSuccess, unlockSuccess),
 		}
+		if state.Invoice != nil {
+			it.Events = append(it.Events, &mediatedtransfer.EventInvoicePaid{
+				LockSecretHash: state.FromTransfer.LockSecretHash,
+				Amount:         state.FromTransfer.Amount,
+				Payer:          state.FromTransfer.Initiator,
+			})
+		}
 	}
 	return it
 }
@@ -230,6 +372,10 @@ func StateTransiton(originalState transfer.State, stateChange transfer.StateChan
 				//可能会反复收到 reveal secret, 比如 token swap的时候,再比如存在环路的时候
 				it = handleSecretReveal(state, st2)
 			}
+		case *mediatedtransfer.ActionAcceptHeldStateChange:
+			it = handleAcceptHeld(state, st2)
+		case *mediatedtransfer.ActionRejectHeldStateChange:
+			it = handleRejectHeld(state, st2)
 		case *mediatedtransfer.ReceiveBalanceProofStateChange:
 			//有可能在不知道密码的情况下直接收到 unlock 消息,比如
 			it = handleBalanceProof(state, st2)
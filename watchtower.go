@@ -0,0 +1,159 @@
+package smartraiden
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/channel/channeltype"
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/SmartMeshFoundation/SmartRaiden/params"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var watchtowerCheckInterval = time.Minute
+
+/*
+watchtowerClient pushes the package of a channel to configured watchtowers,
+whenever partner's balance proof or the locks can be unlocked change.
+what a watchtower has acknowledged is saved in db, so nothing is pushed twice after restart.
+*/
+type watchtowerClient struct {
+	rs      *RaidenService
+	towers  []*params.Watchtower
+	client  *http.Client
+	wakeup  chan struct{}
+	retries map[string]*watchtowerRetry //tower-channel -> failures, only used in push loop
+}
+
+type watchtowerRetry struct {
+	attempts int
+	nextTry  time.Time
+}
+
+func newWatchtowerClient(rs *RaidenService, towers []*params.Watchtower) *watchtowerClient {
+	return &watchtowerClient{
+		rs:      rs,
+		towers:  towers,
+		client:  &http.Client{Timeout: webhookTimeout},
+		wakeup:  make(chan struct{}, 1),
+		retries: make(map[string]*watchtowerRetry),
+	}
+}
+
+func (wc *watchtowerClient) start() {
+	wc.rs.db.RegisterChannelUpdateCallback(func(c *channeltype.Serialization) bool {
+		select {
+		case wc.wakeup <- struct{}{}:
+		default:
+		}
+		return false
+	})
+	wc.rs.db.RegisterChannelSettleCallback(func(c *channeltype.Serialization) bool {
+		err := wc.rs.db.RemoveWatchtowerAcks(c.ChannelIdentifier.ChannelIdentifier)
+		if err != nil {
+			log.Error(fmt.Sprintf("RemoveWatchtowerAcks err %s", err))
+		}
+		return false
+	})
+	go wc.loop()
+}
+
+func (wc *watchtowerClient) loop() {
+	ticker := time.NewTicker(watchtowerCheckInterval)
+	defer ticker.Stop()
+	for {
+		wc.pushAll()
+		select {
+		case <-ticker.C:
+		case <-wc.wakeup:
+		case <-wc.rs.quitChan:
+			return
+		}
+	}
+}
+
+//pushAll push packages which are newer than what towers have acknowledged
+func (wc *watchtowerClient) pushAll() {
+	cs, err := wc.rs.db.GetChannelList(utils.EmptyAddress, utils.EmptyAddress)
+	if err != nil {
+		log.Error(fmt.Sprintf("GetChannelList err %s", err))
+		return
+	}
+	tokens, err := wc.rs.db.GetAllTokens()
+	if err != nil {
+		log.Error(fmt.Sprintf("GetAllTokens err %s", err))
+		return
+	}
+	for _, c := range cs {
+		if c.State == channeltype.StateSettled || c.PartnerBalanceProof == nil || c.PartnerBalanceProof.Nonce == 0 {
+			continue
+		}
+		unlocks := len(c.PartnerLock2UnclaimedLocks())
+		for _, tower := range wc.towers {
+			select {
+			case <-wc.rs.quitChan:
+				return
+			default:
+			}
+			a, err := wc.rs.db.GetWatchtowerAck(tower.Address, c.ChannelIdentifier.ChannelIdentifier)
+			if err != nil {
+				log.Error(fmt.Sprintf("GetWatchtowerAck err %s", err))
+				continue
+			}
+			if a.Nonce > c.PartnerBalanceProof.Nonce || (a.Nonce == c.PartnerBalanceProof.Nonce && a.Unlocks >= unlocks) {
+				continue
+			}
+			r := wc.retries[a.Key]
+			if r != nil && time.Now().Before(r.nextTry) {
+				continue
+			}
+			err = wc.push(tower, c, tokens[c.TokenAddress()])
+			if err != nil {
+				if r == nil {
+					r = new(watchtowerRetry)
+					wc.retries[a.Key] = r
+				}
+				r.attempts++
+				r.nextTry = time.Now().Add(webhookBackoff(r.attempts))
+				log.Warn(fmt.Sprintf("push channel %s to watchtower %s err %s, will retry at %s",
+					utils.HPex(a.Channel), tower.URL, err, r.nextTry))
+				continue
+			}
+			delete(wc.retries, a.Key)
+			a.Nonce = c.PartnerBalanceProof.Nonce
+			a.Unlocks = unlocks
+			err = wc.rs.db.SaveWatchtowerAck(a)
+			if err != nil {
+				log.Error(fmt.Sprintf("SaveWatchtowerAck err %s", err))
+			}
+		}
+	}
+}
+
+func (wc *watchtowerClient) push(tower *params.Watchtower, c *channeltype.Serialization, tokenNetwork common.Address) error {
+	p, err := channelFor3rd(c, tokenNetwork, tower.Address, wc.rs.PrivateKey)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	resp, err := wc.client.Post(tower.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("status %s %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	log.Info(fmt.Sprintf("push channel %s to watchtower %s, nonce=%d,unlocks=%d",
+		utils.HPex(c.ChannelIdentifier.ChannelIdentifier), tower.URL, c.PartnerBalanceProof.Nonce, len(p.Withdraws)))
+	return nil
+}
@@ -0,0 +1,272 @@
+package smartraiden
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/channel/channeltype"
+	"github.com/SmartMeshFoundation/SmartRaiden/log"
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+//WebhookEvents are notifications can be posted to webhooks
+var WebhookEvents = []string{NotifyReceivedTransfer, NotifyChannelState, NotifyChannelSettled, NotifyTokenAdded, NotifyInvoice, NotifyPunishment}
+
+const webhookMaxAttempts = 20
+
+var webhookTimeout = 10 * time.Second
+var webhookCheckInterval = 5 * time.Second
+var webhookMaxBackoff = time.Hour
+
+//webhookBackoff is how long to wait after `attempts` failures
+func webhookBackoff(attempts int) time.Duration {
+	d := webhookCheckInterval
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= webhookMaxBackoff {
+			return webhookMaxBackoff
+		}
+	}
+	return d
+}
+
+func isWebhookEvent(event string) bool {
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+webhookNotifier posts notifications to webhooks.
+notifications are saved to db by the db callbacks before posting, so the ones not delivered are retried after restart.
+every webhook is posted by its own goroutine, a dead webhook never delays the others.
+*/
+type webhookNotifier struct {
+	rs     *RaidenService
+	client *http.Client
+	wakeup chan struct{}
+	lock   sync.Mutex
+	busy   map[int]bool //webhooks being posted
+}
+
+func newWebhookNotifier(rs *RaidenService) *webhookNotifier {
+	return &webhookNotifier{
+		rs:     rs,
+		client: &http.Client{Timeout: webhookTimeout},
+		wakeup: make(chan struct{}, 1),
+		busy:   make(map[int]bool),
+	}
+}
+
+//register must be called before events are replayed at startup, otherwise notifications of them are lost
+func (wn *webhookNotifier) register() {
+	wn.rs.registerNotifyCallbacks(func(e *NotifyEvent) bool {
+		wn.addDeliveries(e)
+		return false
+	})
+}
+
+func (wn *webhookNotifier) start() {
+	go wn.deliverLoop()
+}
+
+//addDeliveries save a delivery for every webhook which wants this notification
+func (wn *webhookNotifier) addDeliveries(e *NotifyEvent) {
+	if !isWebhookEvent(e.Type) {
+		return
+	}
+	//channel_state is notified for all state changes, only closed is interesting
+	if e.Type == NotifyChannelState && e.Channel.State != channeltype.StateClosed {
+		return
+	}
+	ws, err := wn.rs.db.GetWebhookList()
+	if err != nil {
+		log.Error(fmt.Sprintf("GetWebhookList err %s", err))
+		return
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		log.Error(fmt.Sprintf("marshal notification err %s", err))
+		return
+	}
+	added := false
+	for _, w := range ws {
+		if w.Accept(e.Type) {
+			wn.rs.db.NewWebhookDelivery(&models.WebhookDelivery{
+				WebhookID: w.ID,
+				Event:     e.Type,
+				Payload:   payload,
+			})
+			added = true
+		}
+	}
+	if added {
+		select {
+		case wn.wakeup <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (wn *webhookNotifier) deliverLoop() {
+	ticker := time.NewTicker(webhookCheckInterval)
+	defer ticker.Stop()
+	for {
+		wn.deliverDue()
+		select {
+		case <-ticker.C:
+		case <-wn.wakeup:
+		case <-wn.rs.quitChan:
+			return
+		}
+	}
+}
+
+/*
+deliverDue try all deliveries which are due, failed ones are tried again later.
+webhooks still busy with deliveries of last time are skipped,
+the returned WaitGroup is done when deliveries started this time finish.
+*/
+func (wn *webhookNotifier) deliverDue() *sync.WaitGroup {
+	wg := new(sync.WaitGroup)
+	ws, err := wn.rs.db.GetWebhookList()
+	if err != nil {
+		log.Error(fmt.Sprintf("GetWebhookList err %s", err))
+		return wg
+	}
+	urls := make(map[int]string)
+	idle := make(map[int]bool)
+	wn.lock.Lock()
+	for _, w := range ws {
+		urls[w.ID] = w.URL
+		if !wn.busy[w.ID] {
+			//mark it before reading deliveries, so no delivery is posted twice
+			wn.busy[w.ID] = true
+			idle[w.ID] = true
+		}
+	}
+	wn.lock.Unlock()
+	ds, err := wn.rs.db.GetDueWebhookDeliveries(time.Now())
+	if err != nil {
+		log.Error(fmt.Sprintf("GetDueWebhookDeliveries err %s", err))
+	}
+	hooks := make(map[int][]*models.WebhookDelivery)
+	for _, d := range ds {
+		if _, ok := urls[d.WebhookID]; !ok {
+			//webhook has been removed
+			err = wn.rs.db.RemoveWebhookDelivery(d)
+			if err != nil {
+				log.Error(fmt.Sprintf("RemoveWebhookDelivery err %s", err))
+			}
+			continue
+		}
+		if idle[d.WebhookID] {
+			hooks[d.WebhookID] = append(hooks[d.WebhookID], d)
+		}
+	}
+	wn.lock.Lock()
+	defer wn.lock.Unlock()
+	for id := range idle {
+		if len(hooks[id]) == 0 {
+			delete(wn.busy, id)
+			continue
+		}
+		wg.Add(1)
+		go func(id int, u string, ds []*models.WebhookDelivery) {
+			defer wg.Done()
+			wn.deliverHook(u, ds)
+			wn.lock.Lock()
+			delete(wn.busy, id)
+			wn.lock.Unlock()
+		}(id, urls[id], hooks[id])
+	}
+	return wg
+}
+
+//deliverHook post deliveries of one webhook in order
+func (wn *webhookNotifier) deliverHook(u string, ds []*models.WebhookDelivery) {
+	for _, d := range ds {
+		select {
+		case <-wn.rs.quitChan:
+			return
+		default:
+		}
+		err := wn.post(u, d)
+		if err != nil {
+			d.Attempts++
+			d.LastError = err.Error()
+			if d.Attempts < webhookMaxAttempts {
+				d.NextTry = time.Now().Add(webhookBackoff(d.Attempts))
+				log.Warn(fmt.Sprintf("post webhook %s err %s, will retry at %s", u, err, d.NextTry))
+				err = wn.rs.db.UpdateWebhookDelivery(d)
+				if err != nil {
+					log.Error(fmt.Sprintf("UpdateWebhookDelivery err %s", err))
+				}
+				continue
+			}
+			log.Error(fmt.Sprintf("post webhook %s failed %d times, give up %s", u, d.Attempts, d.Payload))
+		}
+		err = wn.rs.db.RemoveWebhookDelivery(d)
+		if err != nil {
+			log.Error(fmt.Sprintf("RemoveWebhookDelivery err %s", err))
+		}
+	}
+}
+
+/*
+post the payload, signature of the body by this node is in header X-SmartRaiden-Signature,
+receiver should recover the signer and compare it with X-SmartRaiden-Node.
+*/
+func (wn *webhookNotifier) post(u string, d *models.WebhookDelivery) error {
+	sig, err := utils.SignData(wn.rs.PrivateKey, d.Payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(d.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-SmartRaiden-Node", wn.rs.NodeAddress.String())
+	req.Header.Set("X-SmartRaiden-Signature", hexutil.Encode(sig))
+	req.Header.Set("X-SmartRaiden-Event", d.Event)
+	req.Header.Set("X-SmartRaiden-Delivery", strconv.Itoa(d.ID)) //same for retries of one notification
+	resp, err := wn.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
+
+//validateWebhook check url and events of a new webhook
+func validateWebhook(w *models.Webhook) error {
+	u, err := url.Parse(w.URL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("webhook url must be http or https")
+	}
+	for _, e := range w.Events {
+		if !isWebhookEvent(e) {
+			return fmt.Errorf("unknown webhook event %s", e)
+		}
+	}
+	return nil
+}
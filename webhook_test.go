@@ -0,0 +1,152 @@
+package smartraiden
+
+import (
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/SmartMeshFoundation/SmartRaiden/models"
+	"github.com/SmartMeshFoundation/SmartRaiden/utils"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestWebhookBackoff(t *testing.T) {
+	if webhookBackoff(1) != webhookCheckInterval || webhookBackoff(3) != 4*webhookCheckInterval {
+		t.Error("backoff should double")
+	}
+	if webhookBackoff(webhookMaxAttempts) != webhookMaxBackoff {
+		t.Error("backoff should be limited")
+	}
+}
+
+func TestWebhookDeliver(t *testing.T) {
+	dbPath := path.Join(os.TempDir(), "testwebhook.db")
+	os.Remove(dbPath)
+	db, err := models.OpenDb(dbPath)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer db.CloseDB()
+	key, _ := crypto.GenerateKey()
+	rs := &RaidenService{
+		db:          db,
+		PrivateKey:  key,
+		NodeAddress: crypto.PubkeyToAddress(key.PublicKey),
+		quitChan:    make(chan struct{}),
+	}
+	fail := true
+	var body []byte
+	var signer string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, _ = ioutil.ReadAll(r.Body)
+		sig, _ := hexutil.Decode(r.Header.Get("X-SmartRaiden-Signature"))
+		addr, _ := utils.Ecrecover(utils.Sha3(body), sig)
+		signer = addr.String()
+	}))
+	defer server.Close()
+	if err = validateWebhook(&models.Webhook{URL: "ftp://127.0.0.1"}); err == nil {
+		t.Error("only http url is allowed")
+	}
+	w := &models.Webhook{URL: server.URL, Events: []string{NotifyReceivedTransfer}}
+	if err = validateWebhook(w); err != nil {
+		t.Error(err)
+		return
+	}
+	if err = db.NewWebhook(w); err != nil {
+		t.Error(err)
+		return
+	}
+	wn := newWebhookNotifier(rs)
+	wn.addDeliveries(&NotifyEvent{Type: NotifyTokenAdded, TokenAddress: utils.NewRandomAddress().String()})
+	wn.addDeliveries(&NotifyEvent{Type: NotifyReceivedTransfer, ReceivedTransfer: &models.ReceivedTransfer{Key: "k"}})
+	wn.deliverDue().Wait()
+	ds, _ := db.GetDueWebhookDeliveries(time.Now().Add(time.Hour))
+	if len(ds) != 1 || ds[0].Attempts != 1 || ds[0].Event != NotifyReceivedTransfer {
+		t.Errorf("failed delivery should be kept for retry %s", utils.StringInterface(ds, 2))
+		return
+	}
+	fail = false
+	//not due yet
+	wn.deliverDue().Wait()
+	if body != nil {
+		t.Error("should wait before retry")
+	}
+	ds[0].NextTry = time.Now()
+	db.UpdateWebhookDelivery(ds[0])
+	wn.deliverDue().Wait()
+	if string(body) != string(ds[0].Payload) || signer != rs.NodeAddress.String() {
+		t.Errorf("deliver err body=%s,signer=%s", body, signer)
+	}
+	ds, _ = db.GetDueWebhookDeliveries(time.Now().Add(time.Hour))
+	if len(ds) != 0 {
+		t.Error("delivery should be removed after success")
+	}
+}
+
+func TestWebhookDeliverEachHook(t *testing.T) {
+	dbPath := path.Join(os.TempDir(), "testwebhookeach.db")
+	os.Remove(dbPath)
+	db, err := models.OpenDb(dbPath)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer db.CloseDB()
+	key, _ := crypto.GenerateKey()
+	rs := &RaidenService{
+		db:          db,
+		PrivateKey:  key,
+		NodeAddress: crypto.PubkeyToAddress(key.PublicKey),
+		quitChan:    make(chan struct{}),
+	}
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	delivered := make(chan struct{}, 1)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered <- struct{}{}
+	}))
+	defer fast.Close()
+	for _, u := range []string{slow.URL, fast.URL} {
+		if err = db.NewWebhook(&models.Webhook{URL: u, Events: []string{NotifyReceivedTransfer}}); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	wn := newWebhookNotifier(rs)
+	wn.register()
+	//delivery is saved by db callback
+	db.NewReceivedTransfer(1, utils.NewRandomHash(), utils.NewRandomAddress(), utils.NewRandomAddress(), 1, big.NewInt(10), 0, "")
+	ds, _ := db.GetDueWebhookDeliveries(time.Now())
+	if len(ds) != 2 {
+		t.Errorf("expect 2 deliveries,got %d", len(ds))
+		return
+	}
+	wg := wn.deliverDue()
+	select {
+	case <-delivered:
+	case <-time.After(5 * time.Second):
+		t.Error("fast webhook should not wait for the slow one")
+	}
+	//slow webhook is busy, it must not be posted again
+	wn.deliverDue().Wait()
+	close(release)
+	wg.Wait()
+	ds, _ = db.GetDueWebhookDeliveries(time.Now().Add(time.Hour))
+	if len(ds) != 0 {
+		t.Errorf("deliveries should be removed after success %s", utils.StringInterface(ds, 2))
+	}
+}